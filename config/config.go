@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -13,6 +14,10 @@ type Config struct {
 	Database DatabaseConfig
 	Server   ServerConfig
 	Security SecurityConfig
+	Tracing  TracingConfig
+	Bank     BankConfig
+	Notify   NotificationsConfig
+	Storage  StorageConfig
 }
 
 type DatabaseConfig struct {
@@ -22,6 +27,8 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// QueryTimeout bounds the database work done for a single request
+	QueryTimeout time.Duration
 }
 
 type ServerConfig struct {
@@ -32,6 +39,52 @@ type ServerConfig struct {
 type SecurityConfig struct {
 	SessionSecret   string
 	SessionDuration time.Duration
+	// AdminEmails lists the accounts allowed on /api/admin routes
+	AdminEmails []string
+	// VerifyEmailURL is the page verification emails link to; it reads the
+	// token query parameter and posts it to /api/verify-email
+	VerifyEmailURL string
+}
+
+type BankConfig struct {
+	// IBANCountryCode and IBANBankCode turn on IBANs for account numbers;
+	// leave both empty to show plain account numbers only
+	IBANCountryCode string
+	IBANBankCode    string
+	// ClosedAccountRetention is how long a closed account's personal data
+	// is kept before it is anonymized
+	ClosedAccountRetention time.Duration
+	// FreezeUnreconciled suspends accounts whose balance doesn't match
+	// their transaction history when the daily reconciliation runs
+	FreezeUnreconciled bool
+}
+
+// NotificationsConfig picks the delivery channels. A channel without a
+// provider configured goes to the development Sink instead: "file" writes
+// to SinkDir, "log" to the server log.
+type NotificationsConfig struct {
+	EmailFrom      string
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUser       string
+	SMTPPassword   string
+	SMSWebhookURL  string
+	PushWebhookURL string
+	Sink           string
+	SinkDir        string
+	// WorkerInterval is how often queued notifications are sent
+	WorkerInterval time.Duration
+}
+
+// StorageConfig says where uploaded files such as KYC documents are kept
+type StorageConfig struct {
+	Dir string
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "stdout" or "file"
+	Exporter string
+	FilePath string
 }
 
 func (c *Config) Validate() error {
@@ -44,6 +97,28 @@ func (c *Config) Validate() error {
 	if c.Database.DBName == "" {
 		return fmt.Errorf("DB_NAME is required")
 	}
+	if (c.Bank.IBANCountryCode == "") != (c.Bank.IBANBankCode == "") {
+		return fmt.Errorf("IBAN_COUNTRY_CODE and IBAN_BANK_CODE must be set together")
+	}
+	if c.Bank.IBANCountryCode != "" && !isLetters(c.Bank.IBANCountryCode, 2) {
+		return fmt.Errorf("IBAN_COUNTRY_CODE must be a two-letter country code")
+	}
+	if c.Notify.SMTPHost == "" && c.Server.Env == "production" {
+		return fmt.Errorf("SMTP_HOST is required in production")
+	}
+	if c.Bank.ClosedAccountRetention < 0 {
+		return fmt.Errorf("CLOSED_ACCOUNT_RETENTION_DAYS must not be negative")
+	}
+	switch c.Notify.Sink {
+	case "", "file", "log":
+	default:
+		return fmt.Errorf("NOTIFY_SINK must be one of file, log")
+	}
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "file":
+	default:
+		return fmt.Errorf("TRACING_EXPORTER must be one of none, stdout, file")
+	}
 	return nil
 }
 
@@ -65,10 +140,41 @@ func Load() (*Config, error) {
 			Password: getEnv("DB_PASSWORD", ""),
 			Port:     getEnv("DB_PORT", "5432"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			QueryTimeout: getDurationEnv("DB_QUERY_TIMEOUT", 10) * time.Second,
 		},
 		Security: SecurityConfig{
 			SessionSecret:   getEnv("SESSION_SECRET", "change-this-to-a-random-secret-in-production"),
 			SessionDuration: getDurationEnv("SESSION_DURATION", 24) * time.Hour,
+			AdminEmails:     getListEnv("ADMIN_EMAILS"),
+			VerifyEmailURL:  getEnv("VERIFY_EMAIL_URL", "http://localhost:8080/verify-email"),
+		},
+		Tracing: TracingConfig{
+			Exporter: getEnv("TRACING_EXPORTER", "none"),
+			FilePath: getEnv("TRACING_FILE", "traces.jsonl"),
+		},
+		Bank: BankConfig{
+			IBANCountryCode: strings.ToUpper(getEnv("IBAN_COUNTRY_CODE", "")),
+			IBANBankCode:    strings.ToUpper(getEnv("IBAN_BANK_CODE", "")),
+
+			ClosedAccountRetention: getDurationEnv("CLOSED_ACCOUNT_RETENTION_DAYS", 2555) * 24 * time.Hour,
+			FreezeUnreconciled:     getBoolEnv("RECONCILIATION_FREEZE", false),
+		},
+		Notify: NotificationsConfig{
+			EmailFrom:      getEnv("NOTIFY_EMAIL_FROM", "Go Bank <no-reply@gobank.local>"),
+			SMTPHost:       getEnv("SMTP_HOST", ""),
+			SMTPPort:       getEnv("SMTP_PORT", "587"),
+			SMTPUser:       getEnv("SMTP_USER", ""),
+			SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+			SMSWebhookURL:  getEnv("SMS_WEBHOOK_URL", ""),
+			PushWebhookURL: getEnv("PUSH_WEBHOOK_URL", ""),
+			Sink:           getEnv("NOTIFY_SINK", "file"),
+			SinkDir:        getEnv("NOTIFY_SINK_DIR", "outbox"),
+
+			WorkerInterval: getDurationEnv("NOTIFY_WORKER_INTERVAL", 15) * time.Second,
+		},
+		Storage: StorageConfig{
+			Dir: getEnv("KYC_STORAGE_DIR", "uploads"),
 		},
 	}
 
@@ -118,6 +224,38 @@ func getIntEnv(key string, defualtValue int) int {
 	return value
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getListEnv splits a comma-separated variable, dropping empty entries
+func getListEnv(key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// isLetters reports whether s is exactly n ASCII letters
+func isLetters(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
 func getDurationEnv(key string, defaultValue int) time.Duration {
 
 	return time.Duration(getIntEnv(key, defaultValue))
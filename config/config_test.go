@@ -111,6 +111,35 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "no smtp host in production",
+			shouldErr: true,
+			config: &Config{
+				Database: DatabaseConfig{
+					Password: "password",
+					DBName:   "testdb",
+				},
+				Server: ServerConfig{
+					Env: "production",
+				},
+				Security: SecurityConfig{
+					SessionSecret: "some-secret",
+				},
+			},
+		},
+		{
+			name:      "unknown tracing exporter",
+			shouldErr: true,
+			config: &Config{
+				Database: DatabaseConfig{
+					Password: "password",
+					DBName:   "testdb",
+				},
+				Tracing: TracingConfig{
+					Exporter: "jaeger",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -80,8 +80,8 @@ func (db *DB) Close() error {
 	return db.DB.Close()
 }
 
-func (db *DB) Health() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (db *DB) Health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
@@ -1,20 +1,80 @@
 -- Drop tables if they exist (for development)
+DROP TABLE IF EXISTS transaction_attachments CASCADE;
+DROP TABLE IF EXISTS transaction_annotations CASCADE;
+DROP TABLE IF EXISTS budgets CASCADE;
+DROP TABLE IF EXISTS transaction_categories CASCADE;
+DROP TABLE IF EXISTS category_rules CASCADE;
+DROP TABLE IF EXISTS balance_snapshots CASCADE;
+DROP TABLE IF EXISTS balance_discrepancies CASCADE;
+DROP TABLE IF EXISTS reconciliation_runs CASCADE;
+DROP TABLE IF EXISTS account_closures CASCADE;
+DROP TABLE IF EXISTS kyc_documents CASCADE;
+DROP TABLE IF EXISTS kyc_profiles CASCADE;
+DROP TABLE IF EXISTS email_verifications CASCADE;
+DROP TABLE IF EXISTS alert_rules CASCADE;
+DROP TABLE IF EXISTS notification_deliveries CASCADE;
+DROP TABLE IF EXISTS notification_preferences CASCADE;
+DROP TABLE IF EXISTS payment_requests CASCADE;
+DROP TABLE IF EXISTS maintenance_fee_charges CASCADE;
+DROP TABLE IF EXISTS fee_rules CASCADE;
+DROP TABLE IF EXISTS interest_accruals CASCADE;
+DROP TABLE IF EXISTS payees CASCADE;
+DROP TABLE IF EXISTS transfer_batch_items CASCADE;
+DROP TABLE IF EXISTS transfer_batches CASCADE;
 DROP TABLE IF EXISTS transactions CASCADE;
+DROP TABLE IF EXISTS pots CASCADE;
 DROP TABLE IF EXISTS sessions CASCADE;
 DROP TABLE IF EXISTS accounts CASCADE;
+DROP TABLE IF EXISTS account_products CASCADE;
+
+-- Account products: account types and the interest they pay
+CREATE TABLE account_products (
+    code VARCHAR(20) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    -- Annual percentage rate, e.g. 2.5 for 2.5%
+    annual_rate NUMERIC(9, 6) NOT NULL DEFAULT 0 CHECK (annual_rate >= 0 AND annual_rate <= 100),
+    -- Annual percentage rate charged on overdrawn balances
+    overdraft_rate NUMERIC(9, 6) NOT NULL DEFAULT 0 CHECK (overdraft_rate >= 0 AND overdraft_rate <= 100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO account_products (code, name, annual_rate, overdraft_rate) VALUES
+    ('checking', 'Checking', 0, 19.9),
+    ('savings', 'Savings', 2.5, 19.9);
 
 -- Accounts table
 CREATE TABLE accounts (
     id SERIAL PRIMARY KEY,
+    account_number VARCHAR(10) UNIQUE NOT NULL,
+    product VARCHAR(20) NOT NULL DEFAULT 'checking' REFERENCES account_products(code),
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
     first_name VARCHAR(100) NOT NULL,
     last_name VARCHAR(100) NOT NULL,
-    balance DECIMAL(15, 2) DEFAULT 0.00 CHECK (balance >= 0),
+    balance DECIMAL(15, 2) DEFAULT 0.00,
+    -- Approved overdraft; the balance may go this far below zero
+    overdraft_limit DECIMAL(15, 2) NOT NULL DEFAULT 0.00 CHECK (overdraft_limit >= 0),
     currency VARCHAR(3) DEFAULT 'USD',
     status VARCHAR(20) DEFAULT 'active',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (balance >= -overdraft_limit)
+);
+
+-- Pots: named savings goals inside an account. Money in a pot has left the
+-- account balance and is moved back by a pot transaction.
+CREATE TABLE pots (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    balance DECIMAL(15, 2) NOT NULL DEFAULT 0.00 CHECK (balance >= 0),
+    target_amount DECIMAL(15, 2) CHECK (target_amount > 0),
+    target_date DATE,
+    -- Withdrawals round up to the next whole unit into this pot
+    round_up BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP
 );
 
 -- Transactions table
@@ -26,6 +86,10 @@ CREATE TABLE transactions (
     type VARCHAR(20) NOT NULL,
     description TEXT,
     status VARCHAR(20) DEFAULT 'completed',
+    -- Links a fee to the transaction it was charged on
+    related_transaction_id INT REFERENCES transactions(id),
+    -- The pot a pot transaction moved money into or out of
+    pot_id INT REFERENCES pots(id),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     
     CHECK (from_account_id IS NOT NULL OR to_account_id IS NOT NULL),
@@ -40,13 +104,368 @@ CREATE TABLE sessions (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Payees: each account's address book of saved recipients
+CREATE TABLE payees (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    payee_account_id INT NOT NULL REFERENCES accounts(id),
+    nickname VARCHAR(100) NOT NULL,
+    verified_name VARCHAR(200) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_paid_at TIMESTAMP,
+
+    UNIQUE (account_id, payee_account_id),
+    CHECK (account_id != payee_account_id)
+);
+
+-- Batch transfers: one uploaded file of transfers from a single account
+CREATE TABLE transfer_batches (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('atomic', 'best_effort')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total_amount DECIMAL(15, 2) NOT NULL CHECK (total_amount > 0),
+    item_count INT NOT NULL,
+    succeeded_count INT NOT NULL DEFAULT 0,
+    failed_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE TABLE transfer_batch_items (
+    id SERIAL PRIMARY KEY,
+    batch_id INT NOT NULL REFERENCES transfer_batches(id) ON DELETE CASCADE,
+    row_number INT NOT NULL,
+    to_account_id INT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    transaction_id INT REFERENCES transactions(id),
+    error_code VARCHAR(50),
+    error_message TEXT,
+
+    UNIQUE (batch_id, row_number)
+);
+
+-- Interest accruals: one row per account per day, paid out monthly
+CREATE TABLE interest_accruals (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    accrual_date DATE NOT NULL,
+    balance DECIMAL(15, 2) NOT NULL,
+    annual_rate NUMERIC(9, 6) NOT NULL,
+    -- Negative for interest charged on an overdrawn balance
+    amount NUMERIC(20, 10) NOT NULL,
+    transaction_id INT REFERENCES transactions(id),
+    capitalized_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (account_id, accrual_date)
+);
+
+-- Fee rules: the fee schedule per transaction type and account product.
+-- A rule with no product applies to products without a rule of their own.
+CREATE TABLE fee_rules (
+    id SERIAL PRIMARY KEY,
+    transaction_type VARCHAR(20) NOT NULL CHECK (transaction_type IN ('withdraw', 'transfer', 'maintenance')),
+    product VARCHAR(20) REFERENCES account_products(code),
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('flat', 'percentage', 'tiered')),
+    flat_amount DECIMAL(15, 2) NOT NULL DEFAULT 0 CHECK (flat_amount >= 0),
+    percentage NUMERIC(9, 6) NOT NULL DEFAULT 0 CHECK (percentage >= 0 AND percentage <= 100),
+    min_fee DECIMAL(15, 2) CHECK (min_fee >= 0),
+    max_fee DECIMAL(15, 2) CHECK (max_fee >= 0),
+    -- [{"up_to": "100.00", "flat_amount": "0.50", "percentage": "0"}, ...]
+    tiers JSONB,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee)
+);
+
+-- Maintenance fees charged: one row per account per month
+CREATE TABLE maintenance_fee_charges (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    period DATE NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount >= 0),
+    transaction_id INT REFERENCES transactions(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (account_id, period)
+);
+
+-- Payment requests: one account asking another to pay it. Paying runs a
+-- transfer from the payer to the requester.
+CREATE TABLE payment_requests (
+    id SERIAL PRIMARY KEY,
+    requester_account_id INT NOT NULL REFERENCES accounts(id),
+    payer_account_id INT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    memo VARCHAR(140) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'paid', 'declined', 'cancelled', 'expired')),
+    transaction_id INT REFERENCES transactions(id),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+
+    CHECK (requester_account_id != payer_account_id)
+);
+
+-- Notification preferences: how each account holder wants to be told
+-- about things. Accounts without a row get email only.
+CREATE TABLE notification_preferences (
+    account_id INT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    sms_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    push_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    phone VARCHAR(20) NOT NULL DEFAULT '',
+    push_token VARCHAR(255) NOT NULL DEFAULT '',
+    muted_events TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Notification deliveries: the queue of rendered messages, one row per
+-- channel. The worker claims due rows, sends them and retries failures.
+CREATE TABLE notification_deliveries (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'sms', 'push')),
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+-- Alert rules: balance and spending thresholds an account holder wants to
+-- hear about. triggered marks a low_balance rule that has fired and is
+-- waiting for the balance to recover before it can fire again.
+CREATE TABLE alert_rules (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('low_balance', 'large_transaction')),
+    threshold DECIMAL(15, 2) NOT NULL CHECK (threshold > 0),
+    triggered BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_triggered_at TIMESTAMP,
+
+    UNIQUE (account_id, type, threshold)
+);
+
+-- Email verifications: tokens sent to confirm a new account's address.
+-- Only the SHA-256 of each token is kept; used_at makes a token single-use.
+CREATE TABLE email_verifications (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- KYC profiles: each account's identity check. status moves unverified ->
+-- submitted -> approved | rejected; level picks the account's limits tier.
+CREATE TABLE kyc_profiles (
+    account_id INT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'unverified' CHECK (status IN ('unverified', 'submitted', 'approved', 'rejected')),
+    level INT NOT NULL DEFAULT 0 CHECK (level BETWEEN 0 AND 2),
+    date_of_birth DATE,
+    nationality VARCHAR(2) NOT NULL DEFAULT '',
+    address_line1 VARCHAR(100) NOT NULL DEFAULT '',
+    address_line2 VARCHAR(100) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    rejection_reason TEXT NOT NULL DEFAULT '',
+    submitted_at TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    reviewed_by INT REFERENCES accounts(id),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (level = 0 OR status = 'approved')
+);
+
+-- KYC documents: uploaded identity and address documents. The files live in
+-- document storage; storage_key finds them there.
+CREATE TABLE kyc_documents (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('passport', 'driving_licence', 'national_id', 'proof_of_address')),
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) UNIQUE NOT NULL,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Account closures: when a customer closed their account and where the
+-- remaining balance went. Personal data is kept until retain_until and then
+-- anonymized; the transaction history is never removed.
+CREATE TABLE account_closures (
+    account_id INT PRIMARY KEY REFERENCES accounts(id),
+    payout_account_id INT REFERENCES accounts(id),
+    payout_transaction_id INT REFERENCES transactions(id),
+    closed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retain_until TIMESTAMP NOT NULL,
+    anonymized_at TIMESTAMP,
+
+    CHECK (payout_account_id IS DISTINCT FROM account_id),
+    CHECK ((payout_account_id IS NULL) = (payout_transaction_id IS NULL))
+);
+
+-- Reconciliation runs: each compares every account's stored balance with
+-- the sum of its completed transactions. Scheduled runs happen once a day;
+-- admins can start more.
+CREATE TABLE reconciliation_runs (
+    id SERIAL PRIMARY KEY,
+    trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    accounts_checked INT NOT NULL CHECK (accounts_checked >= 0),
+    discrepancies INT NOT NULL CHECK (discrepancies >= 0),
+    accounts_frozen INT NOT NULL CHECK (accounts_frozen >= 0)
+);
+
+-- An account whose stored balance did not match its ledger in a run
+CREATE TABLE balance_discrepancies (
+    id SERIAL PRIMARY KEY,
+    run_id INT NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    stored_balance DECIMAL(15, 2) NOT NULL,
+    ledger_balance DECIMAL(15, 2) NOT NULL,
+    -- stored_balance - ledger_balance
+    difference DECIMAL(15, 2) NOT NULL CHECK (difference != 0),
+    transaction_count INT NOT NULL,
+    last_transaction_at TIMESTAMP,
+    frozen BOOLEAN NOT NULL DEFAULT FALSE,
+
+    UNIQUE (run_id, account_id)
+);
+
+-- Balance snapshots: each account's balance at the end of a UTC day, so a
+-- past balance is the nearest snapshot plus the transactions after it
+CREATE TABLE balance_snapshots (
+    account_id INT NOT NULL REFERENCES accounts(id),
+    snapshot_date DATE NOT NULL,
+    balance DECIMAL(15, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (account_id, snapshot_date)
+);
+
+-- Category rules: file an account's transactions under a category by a
+-- description pattern, a counterparty, or both
+CREATE TABLE category_rules (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL,
+    pattern VARCHAR(100) NOT NULL DEFAULT '',
+    counterparty_account_id INT REFERENCES accounts(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (pattern != '' OR counterparty_account_id IS NOT NULL)
+);
+
+-- The category each account filed a transaction under. Both sides of a
+-- transfer have their own row; rows not set by the holder are rebuilt when
+-- the account's rules change.
+CREATE TABLE transaction_categories (
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    transaction_id INT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL,
+    source VARCHAR(10) NOT NULL CHECK (source IN ('default', 'rule', 'user')),
+    rule_id INT REFERENCES category_rules(id) ON DELETE SET NULL,
+
+    PRIMARY KEY (account_id, transaction_id)
+);
+
+-- Monthly spending budgets, one per account and category
+CREATE TABLE budgets (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL,
+    monthly_limit DECIMAL(15, 2) NOT NULL CHECK (monthly_limit > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (account_id, category)
+);
+
+-- Notes and tags an account put on a transaction. Both sides of a
+-- transfer have their own row and never see each other's.
+CREATE TABLE transaction_annotations (
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    transaction_id INT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    note VARCHAR(1000) NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (account_id, transaction_id)
+);
+
+-- Files, such as receipts, an account attached to a transaction. The files
+-- are kept in document storage under storage_key.
+CREATE TABLE transaction_attachments (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    transaction_id INT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) UNIQUE NOT NULL,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Indexes
 CREATE INDEX idx_transactions_from_account ON transactions(from_account_id);
 CREATE INDEX idx_transactions_to_account ON transactions(to_account_id);
 CREATE INDEX idx_transactions_created_at ON transactions(created_at);
+-- Keyset pagination of an account's history orders by (created_at, id)
+CREATE INDEX idx_transactions_from_account_created ON transactions(from_account_id, created_at, id);
+CREATE INDEX idx_transactions_to_account_created ON transactions(to_account_id, created_at, id);
+CREATE INDEX idx_payees_account_id ON payees(account_id);
+CREATE INDEX idx_transfer_batches_account_id ON transfer_batches(account_id);
 CREATE INDEX idx_sessions_account_id ON sessions(account_id);
 CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
 CREATE INDEX idx_accounts_email ON accounts(email);
+CREATE INDEX idx_accounts_product ON accounts(product);
+-- Capitalization looks for accruals not yet paid out
+CREATE INDEX idx_interest_accruals_uncapitalized ON interest_accruals(account_id, accrual_date) WHERE capitalized_at IS NULL;
+CREATE INDEX idx_transactions_related ON transactions(related_transaction_id) WHERE related_transaction_id IS NOT NULL;
+-- At most one active rule per transaction type and product
+CREATE UNIQUE INDEX idx_fee_rules_active ON fee_rules(transaction_type, COALESCE(product, '')) WHERE active;
+-- Open pot names are unique per account, and only one open pot takes round-ups
+CREATE UNIQUE INDEX idx_pots_name ON pots(account_id, LOWER(name)) WHERE closed_at IS NULL;
+CREATE UNIQUE INDEX idx_pots_round_up ON pots(account_id) WHERE round_up AND closed_at IS NULL;
+CREATE INDEX idx_transactions_pot ON transactions(pot_id) WHERE pot_id IS NOT NULL;
+CREATE INDEX idx_payment_requests_requester ON payment_requests(requester_account_id, created_at);
+CREATE INDEX idx_payment_requests_payer ON payment_requests(payer_account_id, created_at);
+-- The expiry job looks for pending requests past their expiry
+CREATE INDEX idx_payment_requests_pending ON payment_requests(expires_at) WHERE status = 'pending';
+-- The delivery worker claims pending rows in order of their next attempt
+CREATE INDEX idx_notification_deliveries_due ON notification_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_notification_deliveries_account ON notification_deliveries(account_id, created_at);
+-- Resends are rate limited by counting an account's recent tokens
+CREATE INDEX idx_email_verifications_account ON email_verifications(account_id, created_at);
+-- Reviewers work through submitted profiles oldest first
+CREATE INDEX idx_kyc_profiles_status ON kyc_profiles(status, submitted_at);
+CREATE INDEX idx_kyc_documents_account ON kyc_documents(account_id);
+-- The retention job looks for closures due to be anonymized
+CREATE INDEX idx_account_closures_due ON account_closures(retain_until) WHERE anonymized_at IS NULL;
+CREATE INDEX idx_reconciliation_runs_trigger ON reconciliation_runs(trigger, started_at DESC);
+CREATE INDEX idx_balance_discrepancies_account ON balance_discrepancies(account_id);
+CREATE INDEX idx_category_rules_account ON category_rules(account_id, id);
+CREATE INDEX idx_transaction_annotations_tags ON transaction_annotations USING GIN (tags);
+CREATE INDEX idx_transaction_attachments_transaction ON transaction_attachments(account_id, transaction_id);
 
 
 
@@ -4,15 +4,54 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/wizzyszn/go_bank/tracing"
 )
 
-type TxFunc func(*sql.Tx) error
+// TxFunc runs inside a transaction. Repositories called with the ctx it
+// receives automatically join that transaction.
+type TxFunc func(ctx context.Context) error
+
+// Querier is the part of *sql.DB and *sql.Tx that repositories use
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction started by WithTransaction, if any
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
 
+// Conn returns the transaction running in ctx, or the connection pool when
+// the caller is not inside a transaction
+func (db *DB) Conn(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// WithTransaction runs fn in a database transaction, committing if it returns
+// nil and rolling back otherwise. Calls made while a transaction is already
+// running in ctx join it instead of starting a new one.
 func (db *DB) WithTransaction(ctx context.Context, fn TxFunc) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracing.Start(ctx, "db.WithTransaction")
+	defer span.End()
+	span.SetAttribute("db.system", "postgresql")
 
 	tx, err := db.BeginTx(ctx, nil)
 
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("error beginning transaction: %w", err)
 	}
 
@@ -22,22 +61,49 @@ func (db *DB) WithTransaction(ctx context.Context, fn TxFunc) error {
 			panic(p)
 		}
 	}()
-	if err := fn(tx); err != nil {
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		span.RecordError(err)
+		span.SetAttribute("db.rolled_back", true)
 		if rbErr := tx.Rollback(); rbErr != nil {
 			return fmt.Errorf("error rolling back transaction: %v (original error: %w)", rbErr, err)
 		}
 		return err
 	}
 	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 	return nil
-	
+
+}
+
+// WithSavepoint runs fn so that a failing statement inside a transaction
+// does not abort it: an error rolls back to a savepoint taken before fn and
+// the transaction carries on. Outside a transaction fn simply runs.
+func (db *DB) WithSavepoint(ctx context.Context, name string, fn TxFunc) error {
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return fn(ctx)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("error creating savepoint: %w", err)
+	}
+	if err := fn(ctx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("error rolling back to savepoint: %v (original error: %w)", rbErr, err)
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("error releasing savepoint: %w", err)
+	}
+	return nil
 }
 
 func (db *DB) ExecutionInTransaction(ctx context.Context, query string, args ...any) error {
-	return db.WithTransaction(ctx, func(tx *sql.Tx) error {
-		_, err := tx.ExecContext(ctx, query, args...)
+	return db.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := db.Conn(ctx).ExecContext(ctx, query, args...)
 		return err
 	})
 }
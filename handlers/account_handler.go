@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/wizzyszn/go_bank/middleware"
 	"github.com/wizzyszn/go_bank/models"
@@ -12,13 +17,19 @@ import (
 type AccountHandler struct {
 	authService        *service.AuthService
 	transactionService *service.TransactionService
+	closureService     *service.ClosureService
+	exportService      *service.ExportService
+	snapshotService    *service.SnapshotService
 }
 
-func NewAccountHandler(authService *service.AuthService, transactionService *service.TransactionService) *AccountHandler {
+func NewAccountHandler(authService *service.AuthService, transactionService *service.TransactionService, closureService *service.ClosureService, exportService *service.ExportService, snapshotService *service.SnapshotService) *AccountHandler {
 
 	return &AccountHandler{
 		authService:        authService,
 		transactionService: transactionService,
+		closureService:     closureService,
+		exportService:      exportService,
+		snapshotService:    snapshotService,
 	}
 }
 
@@ -30,10 +41,10 @@ func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accountData, err := h.authService.GetAccount(account.ID)
+	accountData, err := h.authService.GetAccount(r.Context(), account.ID)
 
 	if err != nil {
-		utils.WriteNotFound(w, "account not found")
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -41,17 +52,37 @@ func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// GetBalance returns the current balance, or with ?as_of= the balance at
+// that time; a plain date means the end of that day (UTC)
 func (h *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	account := middleware.RequireAccount(w, r)
 	if account == nil {
 		return
 	}
 
-	balance, err := h.transactionService.GetBalance(account.ID)
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		asOf, dayOnly, err := parseDate(value)
+		if err != nil {
+			writeServiceError(w, r, &utils.ValidationError{Field: "as_of", Message: "as_of must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+			return
+		}
+		if dayOnly {
+			asOf = asOf.AddDate(0, 0, 1)
+		}
 
-	if err != nil {
+		balance, err := h.snapshotService.BalanceAsOf(r.Context(), account.ID, asOf)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		utils.WriteSuccess(w, balance)
+		return
+	}
 
-		utils.WriteNotFound(w, "Account not found")
+	balance, err := h.transactionService.GetBalance(r.Context(), account.ID)
+
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -67,17 +98,204 @@ func (h *AccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 
 	if err := utils.ParseJSON(r, &req); err != nil {
 		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
 	}
 
-	updated, err := h.authService.UpdateAccount(account.ID, &req)
+	updated, err := h.authService.UpdateAccount(r.Context(), account.ID, &req)
 	if err != nil {
-		if validationErr, ok := err.(*utils.ValidationError); ok {
-			utils.WriteBadRequest(w, validationErr.Error())
-			return
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, updated)
+}
+
+// CloseAccount closes the signed-in account. Every session, including the
+// one making the request, is signed out.
+func (h *AccountHandler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CloseAccountRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	closure, err := h.closureService.Close(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, closure)
+}
+
+// ExportData downloads the signed-in holder's personal data as a ZIP
+// archive, or as one JSON document with ?format=json
+func (h *AccountHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = models.ExportFormatZIP
+	case models.ExportFormatZIP, models.ExportFormatJSON:
+	default:
+		writeServiceError(w, r, &utils.ValidationError{Field: "format", Message: "format must be zip or json"})
+		return
+	}
+
+	export, err := h.exportService.Export(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("gobank-export-%s-%s.%s", account.AccountNumber, export.GeneratedAt.Format("20060102"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == models.ExportFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(export); err != nil {
+			log.Printf("data export for account %d failed mid-stream: %v", account.ID, err)
 		}
-		utils.WriteNotFound(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	if err := h.exportService.WriteArchive(r.Context(), w, export); err != nil {
+		// Headers are gone; all we can do is cut the download short
+		log.Printf("data export for account %d failed mid-stream: %v", account.ID, err)
+	}
+}
+
+// EraseAccount anonymizes the closed account in the path on its holder's
+// erasure request
+func (h *AccountHandler) EraseAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := h.authService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	closure, err := h.closureService.Erase(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, closure)
+}
+
+// BackfillSnapshots rebuilds the balance snapshots of the account in the
+// path for a range of past days
+func (h *AccountHandler) BackfillSnapshots(w http.ResponseWriter, r *http.Request) {
+	account, err := h.authService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	var req models.BackfillSnapshotsRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+	from, err := time.Parse(time.DateOnly, req.From)
+	if err != nil {
+		writeServiceError(w, r, &utils.ValidationError{Field: "from", Message: "from must be a date (YYYY-MM-DD)"})
+		return
+	}
+	to, err := time.Parse(time.DateOnly, req.To)
+	if err != nil {
+		writeServiceError(w, r, &utils.ValidationError{Field: "to", Message: "to must be a date (YYYY-MM-DD)"})
+		return
+	}
+
+	count, err := h.snapshotService.Backfill(r.Context(), account.ID, from, to)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, models.BackfillSnapshotsResponse{AccountNumber: account.AccountNumber, Snapshots: count})
+}
+
+// SetOverdraft sets the approved overdraft of the account in the path
+func (h *AccountHandler) SetOverdraft(w http.ResponseWriter, r *http.Request) {
+	account, err := h.authService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	var req models.SetOverdraftRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	updated, err := h.authService.SetOverdraftLimit(r.Context(), account.ID, req.OverdraftLimit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, updated)
+}
+
+// SetStatus suspends or reactivates the account in the path
+func (h *AccountHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	account, err := h.authService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	var req models.SetAccountStatusRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	updated, err := h.authService.SetStatus(r.Context(), account.ID, req.Status)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
 	utils.WriteSuccess(w, updated)
 }
+
+// ListAccounts is an admin endpoint listing every open account
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	limit := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	accounts, err := h.authService.ListAccounts(r.Context(), page, limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, accounts)
+}
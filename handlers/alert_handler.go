@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type AlertHandler struct {
+	alertService *service.AlertService
+}
+
+func NewAlertHandler(alertService *service.AlertService) *AlertHandler {
+	return &AlertHandler{alertService: alertService}
+}
+
+func (h *AlertHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	rules, err := h.alertService.List(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, rules)
+}
+
+func (h *AlertHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CreateAlertRuleRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	rule, err := h.alertService.Create(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, rule)
+}
+
+func (h *AlertHandler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid alert ID")
+		return
+	}
+
+	if err := h.alertService.Delete(r.Context(), account.ID, ruleID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Alert deleted"})
+}
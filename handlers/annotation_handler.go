@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxAttachmentUploadBytes bounds an attachment upload request: the file
+// plus room for the multipart headers
+const maxAttachmentUploadBytes = service.MaxAttachmentBytes + 64<<10
+
+type AnnotationHandler struct {
+	annotationService *service.AnnotationService
+}
+
+func NewAnnotationHandler(annotationService *service.AnnotationService) *AnnotationHandler {
+	return &AnnotationHandler{annotationService: annotationService}
+}
+
+func (h *AnnotationHandler) UpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	var req models.UpdateAnnotationRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	annotation, err := h.annotationService.Update(r.Context(), account.ID, transactionID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if annotation == nil {
+		utils.WriteSuccess(w, map[string]string{"message": "Annotation removed"})
+		return
+	}
+
+	utils.WriteSuccess(w, annotation)
+}
+
+// UploadAttachment accepts a multipart/form-data upload with the file in
+// the "file" field
+func (h *AnnotationHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentUploadBytes)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeServiceError(w, r, &utils.ValidationError{Field: "file", Message: fmt.Sprintf("upload cannot exceed %d bytes", maxBytesErr.Limit)})
+			return
+		}
+		utils.WriteBadRequest(w, "Invalid multipart form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeServiceError(w, r, &utils.ValidationError{Field: "file", Message: "file is required"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.annotationService.UploadAttachment(r.Context(), account.ID, transactionID, header.Filename, file)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, attachment)
+}
+
+// GetAttachment sends the attached file as an attachment
+func (h *AnnotationHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, attachmentID, ok := attachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, file, err := h.annotationService.OpenAttachment(r.Context(), account.ID, transactionID, attachmentID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+func (h *AnnotationHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, attachmentID, ok := attachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.annotationService.DeleteAttachment(r.Context(), account.ID, transactionID, attachmentID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Attachment deleted"})
+}
+
+// attachmentPath reads the transaction and attachment IDs from the path,
+// writing a 400 when either is not a number
+func attachmentPath(w http.ResponseWriter, r *http.Request) (transactionID, attachmentID int, ok bool) {
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid transaction ID")
+		return 0, 0, false
+	}
+	attachmentID, err = strconv.Atoi(r.PathValue("attachment_id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid attachment ID")
+		return 0, 0, false
+	}
+	return transactionID, attachmentID, true
+}
@@ -10,12 +10,14 @@ import (
 )
 
 type AuthHandler struct {
-	authService *service.AuthService
+	authService         *service.AuthService
+	verificationService *service.VerificationService
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, verificationService *service.VerificationService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:         authService,
+		verificationService: verificationService,
 	}
 }
 
@@ -27,14 +29,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, err := h.authService.Register(&req)
+	account, err := h.authService.Register(r.Context(), &req)
 
 	if err != nil {
-		if validationErr, ok := err.(*utils.ValidationError); ok {
-			utils.WriteBadRequest(w, validationErr.Error())
-			return
-		}
-		utils.WriteBadRequest(w, err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 	utils.WriteCreated(w, account)
@@ -50,9 +48,9 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.authService.Login(req)
+	res, err := h.authService.Login(r.Context(), req)
 	if err != nil {
-		utils.WriteUnAuthorized(w, err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 	utils.WriteSuccess(w, res)
@@ -77,8 +75,8 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.authService.Logout(sessionID); err != nil {
-		utils.WriteInternalError(w, err.Error())
+	if err := h.authService.Logout(r.Context(), sessionID); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 	utils.WriteSuccess(w, map[string]string{
@@ -92,5 +90,39 @@ func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteSuccess(w, account.ToResponse())
+	utils.WriteSuccess(w, h.authService.AccountResponse(account))
+}
+
+// VerifyEmail activates the account a verification token was sent to
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body:"+err.Error())
+		return
+	}
+
+	if err := h.verificationService.Verify(r.Context(), req.Token); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	utils.WriteSuccess(w, map[string]string{
+		"message": "Email verified",
+	})
+}
+
+// ResendVerification emails the signed-in holder a new verification link
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	if err := h.verificationService.Resend(r.Context(), account.ID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	utils.WriteAccepted(w, map[string]string{
+		"message": "Verification email sent",
+	})
 }
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxBatchUploadBytes bounds the size of an uploaded batch file
+const maxBatchUploadBytes = 2 << 20
+
+type BatchHandler struct {
+	batchService *service.BatchService
+}
+
+func NewBatchHandler(batchService *service.BatchService) *BatchHandler {
+	return &BatchHandler{
+		batchService: batchService,
+	}
+}
+
+// SubmitBatch accepts either a JSON BatchTransferRequest or, with
+// Content-Type: text/csv, a CSV file with a
+// to_account_number,amount,description header row and the mode in ?mode=
+func (h *BatchHandler) SubmitBatch(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchUploadBytes)
+
+	var req models.BatchTransferRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "text/csv" {
+		transfers, err := parseBatchCSV(r.Body)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		req.Mode = r.URL.Query().Get("mode")
+		req.Transfers = transfers
+	} else if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	batch, err := h.batchService.Submit(r.Context(), account.ID, req.Mode, req.Transfers)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteAccepted(w, batch)
+}
+
+func (h *BatchHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	batchID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid batch ID")
+		return
+	}
+
+	batch, err := h.batchService.Get(r.Context(), account.ID, batchID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, batch)
+}
+
+// parseBatchCSV reads transfer rows from a CSV upload. Rows are numbered
+// from 1, not counting the header, so they line up with the JSON array form.
+func parseBatchCSV(body io.Reader) ([]models.TransferRequest, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &utils.ValidationError{Field: "body", Message: "CSV file is empty"}
+	}
+	if err != nil {
+		return nil, csvReadError(err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, &utils.ValidationError{Field: "body", Message: "CSV header must include amount"}
+	}
+	accountNumberColumn, ok := columns["to_account_number"]
+	if !ok {
+		return nil, &utils.ValidationError{Field: "body", Message: "CSV header must include to_account_number"}
+	}
+	descriptionColumn, hasDescription := columns["description"]
+
+	field := func(record []string, column int) string {
+		if column < len(record) {
+			return strings.TrimSpace(record[column])
+		}
+		return ""
+	}
+
+	var transfers []models.TransferRequest
+	var rowErrors []models.BatchRowError
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvReadError(err)
+		}
+		if row > service.MaxBatchRows {
+			return nil, service.Validation("batch_too_large", fmt.Sprintf("batch cannot exceed %d transfers", service.MaxBatchRows))
+		}
+
+		transfer := models.TransferRequest{ToAccountNumber: field(record, accountNumberColumn)}
+
+		amount, err := strconv.ParseFloat(field(record, columns["amount"]), 64)
+		if err != nil {
+			rowErrors = append(rowErrors, models.BatchRowError{Row: row, Field: "amount", Message: "amount must be a number"})
+			continue
+		}
+
+		transfer.Amount = amount
+		if hasDescription {
+			transfer.Description = field(record, descriptionColumn)
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, &service.RowErrors{Rows: rowErrors}
+	}
+	return transfers, nil
+}
+
+func csvReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &utils.ValidationError{Field: "body", Message: fmt.Sprintf("upload cannot exceed %d bytes", maxBytesErr.Limit)}
+	}
+	return &utils.ValidationError{Field: "body", Message: "invalid CSV: " + err.Error()}
+}
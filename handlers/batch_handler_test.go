@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func TestParseBatchCSV(t *testing.T) {
+	transfers, err := parseBatchCSV(strings.NewReader("amount,to_account_number,description\n10.50,1000000002,March salary\n 5, 1000000003 ,\"Bonus, Q1\"\n"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %d", len(transfers))
+	}
+	if transfers[0].ToAccountNumber != "1000000002" || transfers[0].Amount != 10.5 || transfers[0].Description != "March salary" {
+		t.Errorf("unexpected first row: %+v", transfers[0])
+	}
+	if transfers[1].ToAccountNumber != "1000000003" || transfers[1].Description != "Bonus, Q1" {
+		t.Errorf("unexpected second row: %+v", transfers[1])
+	}
+}
+
+func TestParseBatchCSVErrors(t *testing.T) {
+	_, err := parseBatchCSV(strings.NewReader("to_account_number,amount\n1000000002,ten\n1000000003,5\n1000000004,\n"))
+	var rowErrors *service.RowErrors
+	if !errors.As(err, &rowErrors) || len(rowErrors.Rows) != 2 {
+		t.Fatalf("expected 2 row errors, got %v", err)
+	}
+	if rowErrors.Rows[0].Row != 1 || rowErrors.Rows[1].Row != 3 || rowErrors.Rows[1].Field != "amount" {
+		t.Errorf("unexpected row errors: %+v", rowErrors.Rows)
+	}
+
+	var validationErr *utils.ValidationError
+	if _, err := parseBatchCSV(strings.NewReader("recipient,amount\n2,1\n")); !errors.As(err, &validationErr) {
+		t.Errorf("expected header validation error, got %v", err)
+	}
+}
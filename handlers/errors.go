@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// writeServiceError maps an error returned by a service onto an HTTP status
+// and a machine-readable code. Clients sending Accept: application/problem+json
+// get an RFC 7807 document instead of the usual ApiResponse envelope.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code, message, field := classifyError(err)
+
+	// Per-row errors of an upload go back to the client in full
+	var details any
+	var rowErrors *service.RowErrors
+	if errors.As(err, &rowErrors) {
+		details = rowErrors.Rows
+	}
+
+	if status >= http.StatusInternalServerError {
+		// The cause may hold SQL details: log it, never send it
+		log.Printf("%s %s failed: %v", r.Method, r.URL.Path, err)
+	}
+
+	if wantsProblemJSON(r) {
+		utils.WriteProblem(w, models.ProblemDetails{
+			Status:   status,
+			Detail:   message,
+			Instance: r.URL.Path,
+			Code:     code,
+			Field:    field,
+			Errors:   details,
+		})
+		return
+	}
+
+	utils.WriteJSON(w, status, models.ApiResponse{
+		Success: false,
+		Data:    details,
+		Error:   message,
+		Code:    code,
+		Field:   field,
+	})
+}
+
+func classifyError(err error) (status int, code, message, field string) {
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		return statusForKind(domainErr.Kind), domainErr.Code, domainErr.Message, ""
+	}
+
+	var rowErrors *service.RowErrors
+	if errors.As(err, &rowErrors) {
+		return http.StatusBadRequest, "invalid_rows", rowErrors.Error(), ""
+	}
+
+	var validationErr *utils.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, "validation_failed", validationErr.Error(), validationErr.Field
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, "timeout", "request timed out", ""
+	}
+
+	return http.StatusInternalServerError, "internal_error", "internal server error", ""
+}
+
+func statusForKind(kind error) int {
+	switch {
+	case errors.Is(kind, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(kind, service.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(kind, service.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity
+	case errors.Is(kind, service.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(kind, service.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(kind, service.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	case errors.Is(kind, service.ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func wantsProblemJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/problem+json")
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"not found", service.NotFound("account_not_found", "account not found"), http.StatusNotFound, "account_not_found"},
+		{"conflict", service.Conflict("email_taken", "Email already in use"), http.StatusConflict, "email_taken"},
+		{"insufficient funds", service.InsufficientFunds(10, 20), http.StatusUnprocessableEntity, "insufficient_funds"},
+		{"forbidden", service.Forbidden("account_inactive", "account is frozen"), http.StatusForbidden, "account_inactive"},
+		{"unauthorized", service.Unauthorized("invalid_session", "Invalid session"), http.StatusUnauthorized, "invalid_session"},
+		{"too many requests", service.TooManyRequests("verification_throttled", "wait before asking again"), http.StatusTooManyRequests, "verification_throttled"},
+		{"validation", service.Validation("same_account", "cannot transfer to same account"), http.StatusBadRequest, "same_account"},
+		{"wrapped domain error", fmt.Errorf("outer: %w", service.NotFound("transaction_not_found", "transaction not found")), http.StatusNotFound, "transaction_not_found"},
+		{"field validation", &utils.ValidationError{Field: "amount", Message: "amount must be positive"}, http.StatusBadRequest, "validation_failed"},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout"},
+		{"internal", service.Internal("deposit failed", errors.New("pq: connection refused")), http.StatusInternalServerError, "internal_error"},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code, _, _ := classifyError(tt.err)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWriteServiceErrorHidesCause(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/deposit", nil)
+	w := httptest.NewRecorder()
+
+	writeServiceError(w, r, service.Internal("deposit failed", errors.New("pq: relation \"accounts\" does not exist")))
+
+	var resp models.ApiResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "deposit failed" {
+		t.Errorf("error = %q, want %q", resp.Error, "deposit failed")
+	}
+}
+
+func TestWriteServiceErrorProblemJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/withdraw", nil)
+	r.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+
+	writeServiceError(w, r, service.InsufficientFunds(5, 50))
+
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", got)
+	}
+
+	var problem models.ProblemDetails
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if problem.Status != http.StatusUnprocessableEntity || problem.Code != "insufficient_funds" || problem.Instance != "/api/withdraw" {
+		t.Errorf("unexpected problem document: %+v", problem)
+	}
+}
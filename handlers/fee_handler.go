@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type FeeHandler struct {
+	feeService *service.FeeService
+}
+
+func NewFeeHandler(feeService *service.FeeService) *FeeHandler {
+	return &FeeHandler{feeService: feeService}
+}
+
+// Quote returns the fee for ?type=withdraw|transfer&amount= before the
+// operation is confirmed
+func (h *FeeHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	amount, err := strconv.ParseFloat(query.Get("amount"), 64)
+	if err != nil {
+		writeServiceError(w, r, &utils.ValidationError{Field: "amount", Message: "amount must be a number"})
+		return
+	}
+
+	quote, err := h.feeService.Quote(r.Context(), account.ID, query.Get("type"), amount)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, quote)
+}
+
+func (h *FeeHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.feeService.ListRules(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, rules)
+}
+
+func (h *FeeHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateFeeRuleRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	rule, err := h.feeService.CreateRule(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, rule)
+}
+
+func (h *FeeHandler) DeactivateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid fee rule ID")
+		return
+	}
+
+	if err := h.feeService.DeactivateRule(r.Context(), id); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Fee rule deactivated"})
+}
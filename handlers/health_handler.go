@@ -19,7 +19,7 @@ func NewHealthHandler(database *db.DB) *HealthHandler {
 
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 
-	if err := h.db.Health(); err != nil {
+	if err := h.db.Health(r.Context()); err != nil {
 		utils.WriteError(w, http.StatusServiceUnavailable, "Database Unhealthy")
 		return
 	}
@@ -40,7 +40,7 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 
-	if err := h.db.Ping(); err != nil {
+	if err := h.db.PingContext(r.Context()); err != nil {
 		utils.WriteError(w, http.StatusServiceUnavailable, "Not ready")
 		return
 	}
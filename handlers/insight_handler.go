@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type InsightHandler struct {
+	categoryService *service.CategoryService
+	insightService  *service.InsightService
+}
+
+func NewInsightHandler(categoryService *service.CategoryService, insightService *service.InsightService) *InsightHandler {
+	return &InsightHandler{
+		categoryService: categoryService,
+		insightService:  insightService,
+	}
+}
+
+func (h *InsightHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, models.Categories)
+}
+
+func (h *InsightHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	rules, err := h.categoryService.ListRules(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, rules)
+}
+
+func (h *InsightHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CreateCategoryRuleRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	rule, err := h.categoryService.CreateRule(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, rule)
+}
+
+func (h *InsightHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid rule ID")
+		return
+	}
+
+	if err := h.categoryService.DeleteRule(r.Context(), account.ID, ruleID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Category rule deleted"})
+}
+
+func (h *InsightHandler) SetCategory(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	var req models.SetCategoryRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	category, err := h.categoryService.SetCategory(r.Context(), account.ID, transactionID, req.Category)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, category)
+}
+
+func (h *InsightHandler) ClearCategory(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid transaction ID")
+		return
+	}
+
+	if err := h.categoryService.ClearCategory(r.Context(), account.ID, transactionID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Category cleared"})
+}
+
+func (h *InsightHandler) GetInsights(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	insights, err := h.insightService.Insights(r.Context(), account.ID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, insights)
+}
+
+func (h *InsightHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	budgets, err := h.insightService.ListBudgets(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, budgets)
+}
+
+func (h *InsightHandler) SetBudget(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.SetBudgetRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	budget, err := h.insightService.SetBudget(r.Context(), account.ID, r.PathValue("category"), &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, budget)
+}
+
+func (h *InsightHandler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	if err := h.insightService.DeleteBudget(r.Context(), account.ID, r.PathValue("category")); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Budget deleted"})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type InterestHandler struct {
+	interestService *service.InterestService
+	accountService  *service.AuthService
+}
+
+func NewInterestHandler(interestService *service.InterestService, accountService *service.AuthService) *InterestHandler {
+	return &InterestHandler{
+		interestService: interestService,
+		accountService:  accountService,
+	}
+}
+
+func (h *InterestHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := h.interestService.ListProducts(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, products)
+}
+
+func (h *InterestHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateProductRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	product, err := h.interestService.UpdateProduct(r.Context(), r.PathValue("code"), &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, product)
+}
+
+func (h *InterestHandler) ChangeProduct(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.ChangeProductRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.interestService.ChangeProduct(r.Context(), account.ID, req.Product); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	updated, err := h.accountService.GetAccount(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	utils.WriteSuccess(w, updated)
+}
+
+// ListAccruals returns the caller's daily interest for ?from=&to= (both
+// YYYY-MM-DD and inclusive; default: this month so far)
+func (h *InterestHandler) ListAccruals(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	from, to, err := parseDayRange(r.URL.Query())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	accruals, err := h.interestService.ListAccruals(r.Context(), account.ID, from, to)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, accruals)
+}
+
+// AuditAccruals recomputes an account's accruals for ?from=&to= and reports
+// any that no longer match the ledger
+func (h *InterestHandler) AuditAccruals(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accountService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	from, to, err := parseDayRange(r.URL.Query())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	audits, err := h.interestService.Recompute(r.Context(), account.ID, from, to)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, audits)
+}
+
+// parseDayRange reads inclusive ?from= and ?to= dates and returns them as
+// [from, to) UTC days
+func parseDayRange(query url.Values) (from, to time.Time, err error) {
+	now := time.Now().UTC()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(time.DateOnly, value); err != nil {
+			return from, to, &utils.ValidationError{Field: "from", Message: "from must be a date (YYYY-MM-DD)"}
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.DateOnly, value); err != nil {
+			return from, to, &utils.ValidationError{Field: "to", Message: "to must be a date (YYYY-MM-DD)"}
+		}
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxKYCUploadBytes bounds a document upload request: the document plus room
+// for the multipart headers and the type field
+const maxKYCUploadBytes = service.MaxKYCDocumentBytes + 64<<10
+
+type KYCHandler struct {
+	kycService     *service.KYCService
+	accountService *service.AuthService
+}
+
+func NewKYCHandler(kycService *service.KYCService, accountService *service.AuthService) *KYCHandler {
+	return &KYCHandler{
+		kycService:     kycService,
+		accountService: accountService,
+	}
+}
+
+func (h *KYCHandler) GetKYC(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	profile, err := h.kycService.Get(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
+
+func (h *KYCHandler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.UpdateKYCDetailsRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	profile, err := h.kycService.UpdateDetails(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
+
+// UploadDocument accepts a multipart/form-data upload with the document
+// type in the "type" field and the file in the "file" field
+func (h *KYCHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxKYCUploadBytes)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeServiceError(w, r, &utils.ValidationError{Field: "file", Message: fmt.Sprintf("upload cannot exceed %d bytes", maxBytesErr.Limit)})
+			return
+		}
+		utils.WriteBadRequest(w, "Invalid multipart form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeServiceError(w, r, &utils.ValidationError{Field: "file", Message: "file is required"})
+		return
+	}
+	defer file.Close()
+
+	document, err := h.kycService.UploadDocument(r.Context(), account.ID, r.FormValue("type"), header.Filename, file)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, document)
+}
+
+func (h *KYCHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	profile, err := h.kycService.Submit(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
+
+// ListReviews returns profiles in ?status= (default submitted)
+func (h *KYCHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.kycService.ListForReview(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profiles)
+}
+
+func (h *KYCHandler) GetReview(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accountService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	profile, err := h.kycService.GetForReview(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
+
+// GetDocument sends the document file as an attachment
+func (h *KYCHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accountService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	documentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid document ID")
+		return
+	}
+
+	document, file, err := h.kycService.OpenDocument(r.Context(), account.ID, documentID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(document.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": document.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+func (h *KYCHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	reviewer := middleware.RequireAccount(w, r)
+	if reviewer == nil {
+		return
+	}
+
+	account, err := h.accountService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	var req models.ApproveKYCRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	profile, err := h.kycService.Approve(r.Context(), reviewer.ID, account.ID, req.Level)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
+
+func (h *KYCHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	reviewer := middleware.RequireAccount(w, r)
+	if reviewer == nil {
+		return
+	}
+
+	account, err := h.accountService.GetByAccountNumber(r.Context(), r.PathValue("account_number"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	var req models.RejectKYCRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	profile, err := h.kycService.Reject(r.Context(), reviewer.ID, account.ID, req.Reason)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, profile)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type PayeeHandler struct {
+	payeeService *service.PayeeService
+}
+
+func NewPayeeHandler(payeeService *service.PayeeService) *PayeeHandler {
+	return &PayeeHandler{
+		payeeService: payeeService,
+	}
+}
+
+func (h *PayeeHandler) ListPayees(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	payees, err := h.payeeService.List(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, payees)
+}
+
+func (h *PayeeHandler) CreatePayee(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CreatePayeeRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	payee, err := h.payeeService.Create(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, payee)
+}
+
+func (h *PayeeHandler) DeletePayee(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	payeeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid payee ID")
+		return
+	}
+
+	if err := h.payeeService.Delete(r.Context(), account.ID, payeeID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Payee deleted"})
+}
+
+// Lookup answers ?account_number= or ?email=, with an
+// optional ?name= to check against the account holder
+func (h *PayeeHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	req := models.PayeeLookupRequest{
+		AccountNumber: query.Get("account_number"),
+		Email:         query.Get("email"),
+		Name:          query.Get("name"),
+	}
+
+	result, err := h.payeeService.Lookup(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, result)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type PaymentRequestHandler struct {
+	requestService *service.PaymentRequestService
+}
+
+func NewPaymentRequestHandler(requestService *service.PaymentRequestService) *PaymentRequestHandler {
+	return &PaymentRequestHandler{requestService: requestService}
+}
+
+func (h *PaymentRequestHandler) CreateRequest(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CreatePaymentRequestRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	request, err := h.requestService.Create(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, request)
+}
+
+// ListRequests answers ?direction=incoming|outgoing (default incoming) with
+// an optional ?status=
+func (h *PaymentRequestHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	direction := query.Get("direction")
+	if direction == "" {
+		direction = models.TransactionDirectionIncoming
+	}
+
+	requests, err := h.requestService.List(r.Context(), account.ID, direction, query.Get("status"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, requests)
+}
+
+func (h *PaymentRequestHandler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	h.withRequest(w, r, func(ctx context.Context, accountID, requestID int) (any, error) {
+		return h.requestService.Get(ctx, accountID, requestID)
+	})
+}
+
+func (h *PaymentRequestHandler) PayRequest(w http.ResponseWriter, r *http.Request) {
+	h.withRequest(w, r, func(ctx context.Context, accountID, requestID int) (any, error) {
+		return h.requestService.Pay(ctx, accountID, requestID)
+	})
+}
+
+func (h *PaymentRequestHandler) DeclineRequest(w http.ResponseWriter, r *http.Request) {
+	h.withRequest(w, r, func(ctx context.Context, accountID, requestID int) (any, error) {
+		return h.requestService.Decline(ctx, accountID, requestID)
+	})
+}
+
+func (h *PaymentRequestHandler) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	h.withRequest(w, r, func(ctx context.Context, accountID, requestID int) (any, error) {
+		return h.requestService.Cancel(ctx, accountID, requestID)
+	})
+}
+
+func (h *PaymentRequestHandler) withRequest(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, accountID, requestID int) (any, error)) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	requestID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid payment request ID")
+		return
+	}
+
+	response, err := fn(r.Context(), account.ID, requestID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, response)
+}
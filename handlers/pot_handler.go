@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type PotHandler struct {
+	potService *service.PotService
+}
+
+func NewPotHandler(potService *service.PotService) *PotHandler {
+	return &PotHandler{potService: potService}
+}
+
+func (h *PotHandler) ListPots(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	pots, err := h.potService.List(r.Context(), account.ID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, pots)
+}
+
+func (h *PotHandler) CreatePot(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	var req models.CreatePotRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	pot, err := h.potService.Create(r.Context(), account.ID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, pot)
+}
+
+func (h *PotHandler) UpdatePot(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	potID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid pot ID")
+		return
+	}
+
+	var req models.UpdatePotRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	pot, err := h.potService.Update(r.Context(), account.ID, potID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, pot)
+}
+
+// ClosePot returns the pot's balance to the account and closes it
+func (h *PotHandler) ClosePot(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	potID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid pot ID")
+		return
+	}
+
+	if err := h.potService.Close(r.Context(), account.ID, potID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]string{"message": "Pot closed"})
+}
+
+func (h *PotHandler) Deposit(w http.ResponseWriter, r *http.Request) {
+	h.move(w, r, h.potService.Deposit)
+}
+
+func (h *PotHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	h.move(w, r, h.potService.Withdraw)
+}
+
+func (h *PotHandler) move(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, accountID, potID int, req *models.PotMoveRequest) (*models.PotMoveResponse, error)) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
+		return
+	}
+
+	potID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid pot ID")
+		return
+	}
+
+	var req models.PotMoveRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	response, err := fn(r.Context(), account.ID, potID, &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, response)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/wizzyszn/go_bank/metrics"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *service.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *service.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliationService: reconciliationService}
+}
+
+// Run reconciles every account now. The body is optional.
+func (h *ReconciliationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req models.RunReconciliationRequest
+	if err := utils.ParseJSON(r, &req); err != nil && err != io.EOF {
+		utils.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	result, err := h.reconciliationService.RunManual(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteCreated(w, result)
+}
+
+func (h *ReconciliationHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil {
+			utils.WriteBadRequest(w, "Invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	runs, err := h.reconciliationService.List(r.Context(), limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, runs)
+}
+
+func (h *ReconciliationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteBadRequest(w, "Invalid reconciliation ID")
+		return
+	}
+
+	result, err := h.reconciliationService.Get(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	utils.WriteSuccess(w, result)
+}
+
+// Metrics handles GET /api/admin/metrics in the Prometheus text format
+func (h *ReconciliationHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	samples, err := h.reconciliationService.Metrics(r.Context())
+	if err != nil {
+		log.Printf("failed to read metrics: %v", err)
+		utils.WriteError(w, http.StatusServiceUnavailable, "Metrics unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := metrics.Write(w, samples); err != nil {
+		log.Printf("failed to write metrics: %v", err)
+	}
+}
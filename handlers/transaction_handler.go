@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/wizzyszn/go_bank/middleware"
 	"github.com/wizzyszn/go_bank/models"
 	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/statement"
 	"github.com/wizzyszn/go_bank/utils"
 )
 
@@ -34,14 +40,10 @@ func (h *TransactionHandler) Deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transaction, err := h.transactionService.Deposit(account.ID, &req)
+	transaction, err := h.transactionService.Deposit(r.Context(), account.ID, &req)
 
 	if err != nil {
-		if Validation, ok := err.(*utils.ValidationError); ok {
-			utils.WriteBadRequest(w, Validation.Error())
-			return
-		}
-		utils.WriteBadRequest(w, err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 	utils.WriteCreated(w, transaction)
@@ -61,13 +63,9 @@ func (h *TransactionHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transaction, err := h.transactionService.WithDraw(account.ID, &req)
+	transaction, err := h.transactionService.WithDraw(r.Context(), account.ID, &req)
 	if err != nil {
-		if ValidationErr, ok := err.(*utils.ValidationError); ok {
-			utils.WriteBadRequest(w, ValidationErr.Error())
-			return
-		}
-		utils.WriteBadRequest(w, err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -88,13 +86,9 @@ func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transaction, err := h.transactionService.Transfer(account.ID, &req)
+	transaction, err := h.transactionService.Transfer(r.Context(), account.ID, &req)
 	if err != nil {
-		if ValidationErr, ok := err.(*utils.ValidationError); ok {
-			utils.WriteBadRequest(w, ValidationErr.Error())
-			return
-		}
-		utils.WriteBadRequest(w, err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -107,55 +101,193 @@ func (h *TransactionHandler) GetTransations(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	page := 1
-	limit := 20
+	query := r.URL.Query()
 
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err != nil && p > 0 {
-			page = p
+	limit := 20
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil {
+			writeServiceError(w, r, &utils.ValidationError{Field: "limit", Message: "limit must be a number"})
+			return
 		}
+		limit = l
 	}
 
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err != nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	filter, err := parseTransactionFilter(query)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
 	}
 
-	transactions, err := h.transactionService.GetTransactions(account.ID, page, limit)
+	transactions, err := h.transactionService.ListTransactions(r.Context(), account.ID, filter, query.Get("cursor"), limit)
 
 	if err != nil {
-		utils.WriteInternalError(w, "")
+		writeServiceError(w, r, err)
 		return
 	}
 	utils.WriteSuccess(w, transactions)
 }
 
-func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+// ExportTransactions streams a statement for ?from=&to= (defaulting to the
+// last 30 days) in ?format=csv|ofx|camt053
+func (h *TransactionHandler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
 	account := middleware.RequireAccount(w, r)
 	if account == nil {
 		return
 	}
 
-	path := r.URL.Path
+	query := r.URL.Query()
+
+	format := statement.FormatCSV
+	if value := query.Get("format"); value != "" {
+		parsed, err := statement.ParseFormat(value)
+		if err != nil {
+			writeServiceError(w, r, &utils.ValidationError{Field: "format", Message: err.Error()})
+			return
+		}
+		format = parsed
+	}
+
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, dayOnly, err := parseDate(value)
+		if err != nil {
+			writeServiceError(w, r, &utils.ValidationError{Field: "to", Message: "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+			return
+		}
+		if dayOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if value := query.Get("from"); value != "" {
+		parsed, _, err := parseDate(value)
+		if err != nil {
+			writeServiceError(w, r, &utils.ValidationError{Field: "from", Message: "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+			return
+		}
+		from = parsed
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format.Extension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
 
-	parts := strings.Split(strings.Trim(path, "/"), "/")
+	out := &trackingWriter{w: w}
+	enc, err := statement.NewEncoder(format, out)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
 
-	if len(parts) < 3 {
-		utils.WriteBadRequest(w, "Invalid transaction ID")
+	if err := h.transactionService.ExportStatement(r.Context(), account.ID, from, to, enc); err != nil {
+		if out.written {
+			// Headers are gone; all we can do is cut the download short
+			log.Printf("statement export for account %d failed mid-stream: %v", account.ID, err)
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		writeServiceError(w, r, err)
+	}
+}
+
+// trackingWriter records whether any of the response body has been written
+type trackingWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
+
+// parseTransactionFilter reads the history filters from the query string.
+// Dates may be RFC 3339 timestamps or plain YYYY-MM-DD days; a plain "to"
+// day includes the whole of that day.
+func parseTransactionFilter(query url.Values) (models.TransactionFilter, error) {
+	filter := models.TransactionFilter{
+		Type:      query.Get("type"),
+		Status:    query.Get("status"),
+		Direction: query.Get("direction"),
+		Search:    strings.TrimSpace(query.Get("q")),
+		Tag:       utils.NormalizeTag(query.Get("tag")),
+	}
+
+	switch query.Get("sort") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, &utils.ValidationError{Field: "sort", Message: "sort must be asc or desc"}
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **float64
+	}{
+		{"min_amount", &filter.MinAmount},
+		{"max_amount", &filter.MaxAmount},
+	} {
+		if value := query.Get(param.name); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return filter, &utils.ValidationError{Field: param.name, Message: param.name + " must be a number"}
+			}
+			*param.dest = &amount
+		}
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, _, err := parseDate(value)
+		if err != nil {
+			return filter, &utils.ValidationError{Field: "from", Message: "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp"}
+		}
+		filter.From = &from
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, dayOnly, err := parseDate(value)
+		if err != nil {
+			return filter, &utils.ValidationError{Field: "to", Message: "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp"}
+		}
+		if dayOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	filter.CounterpartyAccountNumber = query.Get("counterparty")
+
+	return filter, nil
+}
+
+func parseDate(value string) (t time.Time, dayOnly bool, err error) {
+	if t, err = time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	account := middleware.RequireAccount(w, r)
+	if account == nil {
 		return
 	}
 
-	transactionID, err := strconv.Atoi(parts[2])
+	transactionID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		utils.WriteBadRequest(w, "Invalid transaction ID")
 		return
 	}
 
-	transaction, err := h.transactionService.GetTransaction(account.ID, transactionID)
+	transaction, err := h.transactionService.GetTransaction(r.Context(), account.ID, transactionID)
 
 	if err != nil {
-		utils.WriteNotFound(w, "Transaction not found")
+		writeServiceError(w, r, err)
 		return
 	}
 
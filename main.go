@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -13,8 +16,14 @@ import (
 	"github.com/wizzyszn/go_bank/db"
 	"github.com/wizzyszn/go_bank/handlers"
 	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
 	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/router"
 	"github.com/wizzyszn/go_bank/service"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
 )
 
 func main() {
@@ -24,6 +33,20 @@ func main() {
 		log.Fatal("Failed to load config: ", err)
 	}
 
+	switch cfg.Tracing.Exporter {
+	case "stdout":
+		tracing.SetTracer(tracing.NewTracer(tracing.NewStdoutExporter()))
+		log.Println("Tracing enabled: exporting spans to stdout")
+	case "file":
+		exporter, err := tracing.NewFileExporter(cfg.Tracing.FilePath)
+		if err != nil {
+			log.Fatal("Failed to set up tracing: ", err)
+		}
+		tracing.SetTracer(tracing.NewTracer(exporter))
+		log.Printf("Tracing enabled: exporting spans to %s", cfg.Tracing.FilePath)
+	}
+	defer tracing.GetTracer().Shutdown()
+
 	log.Println("Connecting to database...")
 
 	dbConfig := db.NewConfig(cfg.GetDNS())
@@ -41,17 +64,93 @@ func main() {
 	accountRepo := repository.NewAccountRepository(database)
 	transactionRepo := repository.NewTransactionRepository(database)
 	sessionRepo := repository.NewSessionRepository(database)
+	batchRepo := repository.NewBatchRepository(database)
+	payeeRepo := repository.NewPayeeRepository(database)
+	productRepo := repository.NewProductRepository(database)
+	interestRepo := repository.NewInterestRepository(database)
+	feeRepo := repository.NewFeeRepository(database)
+	potRepo := repository.NewPotRepository(database)
+	paymentRequestRepo := repository.NewPaymentRequestRepository(database)
+	notificationRepo := repository.NewNotificationRepository(database)
+	alertRepo := repository.NewAlertRepository(database)
+	verificationRepo := repository.NewVerificationRepository(database)
+	kycRepo := repository.NewKYCRepository(database)
+	closureRepo := repository.NewClosureRepository(database)
+	reconciliationRepo := repository.NewReconciliationRepository(database)
+	snapshotRepo := repository.NewSnapshotRepository(database)
+	categoryRepo := repository.NewCategoryRepository(database)
+	insightRepo := repository.NewInsightRepository(database)
+	budgetRepo := repository.NewBudgetRepository(database)
+	annotationRepo := repository.NewAnnotationRepository(database)
+
+	// Notifications are queued by the dispatcher and sent by the worker below
+	renderer, err := notifications.NewRenderer()
+	if err != nil {
+		log.Fatal("Failed to load notification templates: ", err)
+	}
+	channels, err := notificationChannels(cfg.Notify)
+	if err != nil {
+		log.Fatal("Failed to set up notification channels: ", err)
+	}
+	notifier := notifications.NewDispatcher(accountRepo, notificationRepo, renderer)
+	notificationWorker := notifications.NewWorker(notificationRepo, channels)
+	// Verification emails skip the queue so their links are never stored
+	mailer := notifications.NewTemplateMailer(channels[models.NotificationChannelEmail], renderer)
 
 	// Initializing Services
-	authService := service.NewAuthService(database, accountRepo, sessionRepo, cfg.Security.SessionDuration)
-	transactionService := service.NewTransactionService(database, accountRepo, transactionRepo)
+	ibanFormat := utils.IBANFormat{
+		CountryCode: cfg.Bank.IBANCountryCode,
+		BankCode:    cfg.Bank.IBANBankCode,
+	}
+	verificationService := service.NewVerificationService(database, accountRepo, verificationRepo, mailer, cfg.Security.SessionSecret, cfg.Security.VerifyEmailURL)
+	authService := service.NewAuthService(database, accountRepo, sessionRepo, cfg.Security.SessionDuration, ibanFormat, notifier, verificationService)
+	transactionService := service.NewTransactionService(database, accountRepo, transactionRepo, payeeRepo, feeRepo, potRepo, alertRepo, kycRepo, annotationRepo, ibanFormat, notifier)
+	payeeService := service.NewPayeeService(accountRepo, payeeRepo, transactionRepo, ibanFormat)
+	potService := service.NewPotService(database, accountRepo, potRepo, transactionRepo)
+	interestService := service.NewInterestService(database, accountRepo, productRepo, interestRepo, transactionRepo)
+	feeService := service.NewFeeService(database, accountRepo, productRepo, feeRepo, transactionRepo)
+	batchService := service.NewBatchService(database, accountRepo, batchRepo, transactionService)
+	paymentRequestService := service.NewPaymentRequestService(database, accountRepo, payeeRepo, paymentRequestRepo, transactionService, notifier)
+	notificationService := service.NewNotificationService(notificationRepo)
+	alertService := service.NewAlertService(accountRepo, alertRepo)
+	documents := storage.NewLocalStorage(cfg.Storage.Dir)
+	kycService := service.NewKYCService(database, accountRepo, kycRepo, documents, notifier)
+	exportService := service.NewExportService(accountRepo, sessionRepo, transactionRepo, kycRepo, annotationRepo, notificationRepo, documents, ibanFormat)
+	closureService := service.NewClosureService(database, accountRepo, transactionRepo, potRepo, paymentRequestRepo, payeeRepo, sessionRepo, kycRepo, annotationRepo, notificationRepo, closureRepo, interestService, documents, ibanFormat, notifier, cfg.Bank.ClosedAccountRetention)
+	snapshotService := service.NewSnapshotService(accountRepo, transactionRepo, snapshotRepo)
+	annotationService := service.NewAnnotationService(database, transactionRepo, annotationRepo, documents)
+	categoryService := service.NewCategoryService(database, accountRepo, transactionRepo, categoryRepo, ibanFormat)
+	insightService := service.NewInsightService(categoryService, insightRepo, budgetRepo)
+	reconciliationService := service.NewReconciliationService(database, accountRepo, transactionRepo, reconciliationRepo, notifier, cfg.Bank.FreezeUnreconciled)
+
+	// `go_bank backfill-snapshots -from YYYY-MM-DD -to YYYY-MM-DD` rebuilds
+	// balance snapshots for every open account and exits
+	if len(os.Args) > 1 && os.Args[1] == "backfill-snapshots" {
+		if err := backfillSnapshots(snapshotService, os.Args[2:]); err != nil {
+			log.Printf("Backfill failed: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Initializing Handlers
 	log.Println("Initializing Handlers...")
 
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, verificationService)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
-	accountHandler := handlers.NewAccountHandler(authService, transactionService)
+	accountHandler := handlers.NewAccountHandler(authService, transactionService, closureService, exportService, snapshotService)
+	batchHandler := handlers.NewBatchHandler(batchService)
+	payeeHandler := handlers.NewPayeeHandler(payeeService)
+	potHandler := handlers.NewPotHandler(potService)
+	paymentRequestHandler := handlers.NewPaymentRequestHandler(paymentRequestService)
+	interestHandler := handlers.NewInterestHandler(interestService, authService)
+	feeHandler := handlers.NewFeeHandler(feeService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	alertHandler := handlers.NewAlertHandler(alertService)
+	kycHandler := handlers.NewKYCHandler(kycService, authService)
+	annotationHandler := handlers.NewAnnotationHandler(annotationService)
+	insightHandler := handlers.NewInsightHandler(categoryService, insightService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
 	healthHandler := handlers.NewHealthHandler(database)
 
 	// Initializing middlewares
@@ -59,6 +158,7 @@ func main() {
 
 	authMiddleware := middleware.NewAuthMiddleware(authService)
 	rateLimtiter := middleware.NewRateLimiter(30, 100)
+	dbTimeout := middleware.Timeout(cfg.Database.QueryTimeout)
 
 	var corsConfig middleware.CORSConfig
 
@@ -70,82 +170,122 @@ func main() {
 		})
 	}
 
-	mux := http.NewServeMux()
-
-	//HEALTH ENDPOINTS
-	mux.HandleFunc("/health", middleware.Chain(healthHandler.Health, middleware.Logger))
+	r := router.New(middleware.Logger, middleware.CORS(corsConfig))
 
-	mux.HandleFunc("/ready", middleware.Chain(healthHandler.Ready, middleware.Logger))
+	health := r.Group()
+	public := r.Group(middleware.Tracing, dbTimeout, rateLimtiter.RateLimit)
+	authenticated := r.Group(middleware.Tracing, dbTimeout, authMiddleware.Authenticate)
+	limited := authenticated.With(rateLimtiter.RateLimit)
+	admin := authenticated.With(middleware.RequireAdmin(cfg.Security.AdminEmails))
 
-	mux.HandleFunc("/live", middleware.Chain(healthHandler.Live, middleware.Logger))
+	//HEALTH ENDPOINTS
+	health.Get("/health", healthHandler.Health)
+	health.Get("/ready", healthHandler.Ready)
+	health.Get("/live", healthHandler.Live)
 
 	//PUBLIC AUTHENTICATION ENDPOINTS
-	mux.HandleFunc("/api/register", middleware.Chain(authHandler.Register, middleware.Logger, middleware.CORS(corsConfig), rateLimtiter.RateLimit))
-
-	mux.HandleFunc("/api/login", middleware.Chain(authHandler.Login, middleware.Logger, middleware.CORS(corsConfig), rateLimtiter.RateLimit))
+	public.Post("/api/register", authHandler.Register)
+	public.Post("/api/login", authHandler.Login)
+	public.Post("/api/verify-email", authHandler.VerifyEmail)
 
 	//PROTECTED AUTHENTICATION ENDPOINTS
-	mux.HandleFunc("/api/logout", middleware.Chain(authHandler.Logout, middleware.Logger, middleware.CORS(corsConfig), authMiddleware.Authenticate))
-
-	mux.HandleFunc("/api/me", middleware.Chain(authHandler.GetMe, middleware.Logger, middleware.CORS(corsConfig), authMiddleware.Authenticate))
+	authenticated.Post("/api/logout", authHandler.Logout)
+	authenticated.Get("/api/me", authHandler.GetMe)
+	limited.Post("/api/verify-email/resend", authHandler.ResendVerification)
 
 	//PROTECTED ACCOUNT ENDPOINTS
-	mux.HandleFunc("/api/account", func(w http.ResponseWriter, r *http.Request) {
-		handler := middleware.Chain(accountHandler.GetAccount, middleware.Logger, middleware.CORS(corsConfig), authMiddleware.Authenticate)
-
-		switch r.Method {
-		case http.MethodGet:
-			handler(w, r)
-		case http.MethodPatch:
-			middleware.Chain(accountHandler.UpdateAccount, middleware.Logger, middleware.CORS(corsConfig), authMiddleware.Authenticate)(w, r)
-		case http.MethodOptions:
-			middleware.CORS(corsConfig)(handler)(w, r)
-		default:
-			http.Error(w, r.Method+" Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-
-	mux.HandleFunc("/api/account/balance", middleware.Chain(
-		accountHandler.GetBalance,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-	))
-	mux.HandleFunc("/api/account/balance", middleware.Chain(
-		accountHandler.GetBalance,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-	))
-
-	// PROTECTED TRANSACTION ENDPOIINTS
-	mux.HandleFunc("/api/deposit", middleware.Chain(
-		transactionHandler.Deposit,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-		rateLimtiter.RateLimit,
-	))
-	mux.HandleFunc("/api/withdraw", middleware.Chain(
-		transactionHandler.Withdraw,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-		rateLimtiter.RateLimit,
-	))
-	mux.HandleFunc("/api/transfer", middleware.Chain(
-		transactionHandler.Transfer,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-		rateLimtiter.RateLimit,
-	))
-	mux.HandleFunc("/api/transactions", middleware.Chain(
-		transactionHandler.GetTransations,
-		middleware.Logger,
-		middleware.CORS(corsConfig),
-		authMiddleware.Authenticate,
-	))
+	authenticated.Get("/api/account", accountHandler.GetAccount)
+	authenticated.Patch("/api/account", accountHandler.UpdateAccount)
+	authenticated.Get("/api/account/balance", accountHandler.GetBalance)
+	limited.Post("/api/account/close", accountHandler.CloseAccount)
+	limited.Get("/api/account/export", accountHandler.ExportData)
+	authenticated.Put("/api/account/product", interestHandler.ChangeProduct)
+	authenticated.Get("/api/account/interest", interestHandler.ListAccruals)
+	authenticated.Get("/api/products", interestHandler.ListProducts)
+	authenticated.Get("/api/fees/quote", feeHandler.Quote)
+
+	// PROTECTED TRANSACTION ENDPOINTS
+	limited.Post("/api/deposit", transactionHandler.Deposit)
+	limited.Post("/api/withdraw", transactionHandler.Withdraw)
+	limited.Post("/api/transfer", transactionHandler.Transfer)
+	authenticated.Get("/api/transactions", transactionHandler.GetTransations)
+	authenticated.Get("/api/transactions/export", transactionHandler.ExportTransactions)
+	authenticated.Get("/api/transactions/{id}", transactionHandler.GetTransaction)
+	authenticated.Put("/api/transactions/{id}/category", insightHandler.SetCategory)
+	authenticated.Delete("/api/transactions/{id}/category", insightHandler.ClearCategory)
+	authenticated.Put("/api/transactions/{id}/annotation", annotationHandler.UpdateAnnotation)
+	limited.Post("/api/transactions/{id}/attachments", annotationHandler.UploadAttachment)
+	authenticated.Get("/api/transactions/{id}/attachments/{attachment_id}", annotationHandler.GetAttachment)
+	authenticated.Delete("/api/transactions/{id}/attachments/{attachment_id}", annotationHandler.DeleteAttachment)
+	limited.Post("/api/transfers/batches", batchHandler.SubmitBatch)
+	authenticated.Get("/api/transfers/batches/{id}", batchHandler.GetBatch)
+
+	// PROTECTED PAYEE ENDPOINTS
+	authenticated.Get("/api/payees", payeeHandler.ListPayees)
+	authenticated.Post("/api/payees", payeeHandler.CreatePayee)
+	limited.Get("/api/payees/lookup", payeeHandler.Lookup)
+	authenticated.Delete("/api/payees/{id}", payeeHandler.DeletePayee)
+
+	// PROTECTED POT ENDPOINTS
+	authenticated.Get("/api/pots", potHandler.ListPots)
+	authenticated.Post("/api/pots", potHandler.CreatePot)
+	authenticated.Patch("/api/pots/{id}", potHandler.UpdatePot)
+	authenticated.Delete("/api/pots/{id}", potHandler.ClosePot)
+	limited.Post("/api/pots/{id}/deposit", potHandler.Deposit)
+	limited.Post("/api/pots/{id}/withdraw", potHandler.Withdraw)
+
+	// PROTECTED PAYMENT REQUEST ENDPOINTS
+	limited.Post("/api/payment-requests", paymentRequestHandler.CreateRequest)
+	authenticated.Get("/api/payment-requests", paymentRequestHandler.ListRequests)
+	authenticated.Get("/api/payment-requests/{id}", paymentRequestHandler.GetRequest)
+	limited.Post("/api/payment-requests/{id}/pay", paymentRequestHandler.PayRequest)
+	authenticated.Post("/api/payment-requests/{id}/decline", paymentRequestHandler.DeclineRequest)
+	authenticated.Post("/api/payment-requests/{id}/cancel", paymentRequestHandler.CancelRequest)
+
+	// PROTECTED NOTIFICATION ENDPOINTS
+	authenticated.Get("/api/notifications", notificationHandler.ListNotifications)
+	authenticated.Get("/api/notifications/preferences", notificationHandler.GetPreferences)
+	authenticated.Put("/api/notifications/preferences", notificationHandler.UpdatePreferences)
+	authenticated.Get("/api/alerts", alertHandler.ListAlerts)
+	authenticated.Post("/api/alerts", alertHandler.CreateAlert)
+	authenticated.Delete("/api/alerts/{id}", alertHandler.DeleteAlert)
+
+	// PROTECTED INSIGHT ENDPOINTS
+	authenticated.Get("/api/insights", insightHandler.GetInsights)
+	authenticated.Get("/api/categories", insightHandler.ListCategories)
+	authenticated.Get("/api/categories/rules", insightHandler.ListRules)
+	authenticated.Post("/api/categories/rules", insightHandler.CreateRule)
+	authenticated.Delete("/api/categories/rules/{id}", insightHandler.DeleteRule)
+	authenticated.Get("/api/budgets", insightHandler.ListBudgets)
+	authenticated.Put("/api/budgets/{category}", insightHandler.SetBudget)
+	authenticated.Delete("/api/budgets/{category}", insightHandler.DeleteBudget)
+
+	// PROTECTED KYC ENDPOINTS
+	authenticated.Get("/api/kyc", kycHandler.GetKYC)
+	authenticated.Put("/api/kyc/details", kycHandler.UpdateDetails)
+	limited.Post("/api/kyc/documents", kycHandler.UploadDocument)
+	authenticated.Post("/api/kyc/submit", kycHandler.Submit)
+
+	// ADMIN ENDPOINTS
+	admin.Get("/api/admin/accounts", accountHandler.ListAccounts)
+	admin.Get("/api/admin/accounts/{account_number}/interest", interestHandler.AuditAccruals)
+	admin.Put("/api/admin/accounts/{account_number}/overdraft", accountHandler.SetOverdraft)
+	admin.Put("/api/admin/accounts/{account_number}/status", accountHandler.SetStatus)
+	admin.Post("/api/admin/accounts/{account_number}/erase", accountHandler.EraseAccount)
+	admin.Post("/api/admin/accounts/{account_number}/snapshots/backfill", accountHandler.BackfillSnapshots)
+	admin.Patch("/api/admin/products/{code}", interestHandler.UpdateProduct)
+	admin.Get("/api/admin/fees", feeHandler.ListRules)
+	admin.Post("/api/admin/fees", feeHandler.CreateRule)
+	admin.Delete("/api/admin/fees/{id}", feeHandler.DeactivateRule)
+	admin.Get("/api/admin/kyc", kycHandler.ListReviews)
+	admin.Get("/api/admin/kyc/{account_number}", kycHandler.GetReview)
+	admin.Get("/api/admin/kyc/{account_number}/documents/{id}", kycHandler.GetDocument)
+	admin.Post("/api/admin/kyc/{account_number}/approve", kycHandler.Approve)
+	admin.Post("/api/admin/kyc/{account_number}/reject", kycHandler.Reject)
+	admin.Get("/api/admin/reconciliation", reconciliationHandler.List)
+	admin.Post("/api/admin/reconciliation", reconciliationHandler.Run)
+	admin.Get("/api/admin/reconciliation/{id}", reconciliationHandler.Get)
+	admin.Get("/api/admin/metrics", reconciliationHandler.Metrics)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -156,7 +296,9 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				count, err := authService.CleanupExpiredSessions()
+				cleanupCtx, cleanupCancel := context.WithTimeout(ctx, cfg.Database.QueryTimeout)
+				count, err := authService.CleanupExpiredSessions(cleanupCtx)
+				cleanupCancel()
 				if err != nil {
 					log.Printf("Error cleaning up sessions: %v", err)
 				} else {
@@ -168,9 +310,179 @@ func main() {
 		}
 	}()
 
+	// Interest accrues once a day; running hourly lets the job catch up
+	// promptly after a restart, and each run only does work not yet done
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			accrued, capitalized, err := interestService.RunDaily(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error running interest job: %v", err)
+			} else if accrued > 0 || capitalized > 0 {
+				log.Printf("Accrued %d days of interest, paid interest to %d accounts", accrued, capitalized)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Maintenance fees are charged for the month just ended. Accounts already
+	// charged are skipped, so the hourly runs after the first do little work
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			charged, err := feeService.RunMonthly(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error charging maintenance fees: %v", err)
+			} else if charged > 0 {
+				log.Printf("Charged maintenance fees to %d accounts", charged)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Payment requests past their expiry are marked expired and both sides
+	// told; a request is also refused once expired even before this runs
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			expired, err := paymentRequestService.ExpireDue(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error expiring payment requests: %v", err)
+			} else if expired > 0 {
+				log.Printf("Expired %d payment requests", expired)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Closed accounts are anonymized once their retention period is over;
+	// each run handles a batch, so a backlog clears over a few hours
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			anonymized, err := closureService.AnonymizeDue(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error anonymizing closed accounts: %v", err)
+			} else if anonymized > 0 {
+				log.Printf("Anonymized %d closed accounts", anonymized)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// End-of-day balances are snapshotted for every day that has ended;
+	// accounts missing days after downtime catch up on the next run
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			count, err := snapshotService.RunDaily(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error snapshotting balances: %v", err)
+			} else if count > 0 {
+				log.Printf("Recorded %d balance snapshots", count)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Balances are reconciled against the ledger once a day, on the first
+	// run after midnight UTC
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			result, err := reconciliationService.RunScheduled(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error reconciling balances: %v", err)
+			} else if result != nil && result.Discrepancies > 0 {
+				log.Printf("Reconciliation found %d accounts out of balance, froze %d", result.Discrepancies, result.AccountsFrozen)
+			} else if result != nil {
+				log.Printf("Reconciled %d accounts", result.AccountsChecked)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Queued notifications are sent every few seconds; failures are retried
+	// with backoff by later runs
+	go func() {
+		ticker := time.NewTicker(cfg.Notify.WorkerInterval)
+		defer ticker.Stop()
+
+		for {
+			jobCtx, jobCancel := context.WithTimeout(ctx, 10*time.Minute)
+			sent, failed, err := notificationWorker.RunOnce(jobCtx, time.Now())
+			jobCancel()
+			if err != nil {
+				log.Printf("Error sending notifications: %v", err)
+			} else if sent > 0 || failed > 0 {
+				log.Printf("Sent %d notifications, gave up on %d", sent, failed)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	server := http.Server{
 		Addr:         ":" + cfg.Server.Port,
-		Handler:      mux,
+		Handler:      r,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -196,6 +508,69 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Let running transfer batches finish rather than leave them half done
+	batchService.Wait()
+
 	log.Println("Server stopped gracefully")
 
 }
+
+// backfillSnapshots runs the backfill-snapshots command
+func backfillSnapshots(snapshotService *service.SnapshotService, args []string) error {
+	flags := flag.NewFlagSet("backfill-snapshots", flag.ContinueOnError)
+	fromStr := flags.String("from", "", "first day to rebuild (YYYY-MM-DD)")
+	toStr := flags.String("to", time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly), "last day to rebuild (YYYY-MM-DD)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	from, err := time.Parse(time.DateOnly, *fromStr)
+	if err != nil {
+		return fmt.Errorf("-from must be a date (YYYY-MM-DD)")
+	}
+	to, err := time.Parse(time.DateOnly, *toStr)
+	if err != nil {
+		return fmt.Errorf("-to must be a date (YYYY-MM-DD)")
+	}
+
+	count, err := snapshotService.BackfillAll(context.Background(), from, to)
+	if err != nil {
+		return err
+	}
+	log.Printf("Wrote %d balance snapshots from %s to %s", count, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	return nil
+}
+
+// notificationChannels sends through the providers configured and uses the
+// development sink for any channel without one
+func notificationChannels(cfg config.NotificationsConfig) (map[string]notifications.Channel, error) {
+	channels := make(map[string]notifications.Channel)
+	if cfg.SMTPHost != "" {
+		channels[models.NotificationChannelEmail] = notifications.NewSMTPChannel(cfg.SMTPHost+":"+cfg.SMTPPort, cfg.EmailFrom, cfg.SMTPUser, cfg.SMTPPassword)
+	}
+	if cfg.SMSWebhookURL != "" {
+		channels[models.NotificationChannelSMS] = notifications.NewWebhookChannel(cfg.SMSWebhookURL)
+	}
+	if cfg.PushWebhookURL != "" {
+		channels[models.NotificationChannelPush] = notifications.NewWebhookChannel(cfg.PushWebhookURL)
+	}
+
+	for _, name := range []string{models.NotificationChannelEmail, models.NotificationChannelSMS, models.NotificationChannelPush} {
+		if _, ok := channels[name]; ok {
+			continue
+		}
+		if cfg.Sink == "log" {
+			channels[name] = notifications.LogChannel{Name: name}
+			continue
+		}
+		if err := os.MkdirAll(cfg.SinkDir, 0o755); err != nil {
+			return nil, err
+		}
+		sink, err := notifications.NewFileChannel(filepath.Join(cfg.SinkDir, name+".jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		channels[name] = sink
+		log.Printf("Notifications: %s is written to %s", name, filepath.Join(cfg.SinkDir, name+".jsonl"))
+	}
+	return channels, nil
+}
@@ -0,0 +1,39 @@
+// Package metrics writes gauges in the Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Metric is a single unlabelled sample
+type Metric struct {
+	Name  string
+	Help  string
+	Type  string
+	Value float64
+}
+
+// Write writes each metric with its HELP and TYPE lines
+func Write(w io.Writer, metrics []Metric) error {
+	for _, m := range metrics {
+		help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.Help)
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			m.Name, help, m.Name, m.Type, m.Name, strconv.FormatFloat(m.Value, 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics []Metric
+		want    string
+	}{
+		{
+			name:    "no metrics",
+			metrics: nil,
+			want:    "",
+		},
+		{
+			name: "gauge",
+			metrics: []Metric{
+				{Name: "gobank_reconciliation_discrepancies", Help: "Accounts that didn't reconcile.", Type: Gauge, Value: 2},
+			},
+			want: "# HELP gobank_reconciliation_discrepancies Accounts that didn't reconcile.\n" +
+				"# TYPE gobank_reconciliation_discrepancies gauge\n" +
+				"gobank_reconciliation_discrepancies 2\n",
+		},
+		{
+			name: "fractional value and escaped help",
+			metrics: []Metric{
+				{Name: "amount", Help: "line one\nline \\ two", Type: Gauge, Value: 12.5},
+			},
+			want: "# HELP amount line one\\nline \\\\ two\n# TYPE amount gauge\namount 12.5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := Write(&b, tt.metrics); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
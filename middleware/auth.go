@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+
 	"github.com/wizzyszn/go_bank/models"
 	"github.com/wizzyszn/go_bank/service"
 	"github.com/wizzyszn/go_bank/utils"
@@ -48,10 +51,18 @@ func (m *AuthMiddleware) Authenticate(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		account, err := m.authService.ValidateSession(sessionID)
+		account, err := m.authService.ValidateSession(r.Context(), sessionID)
 
 		if err != nil {
-			utils.WriteUnAuthorized(w, "Invalid or expired session")
+			switch {
+			case errors.Is(err, service.ErrForbidden):
+				utils.WriteErrorCode(w, http.StatusForbidden, "account_inactive", err.Error())
+			case errors.Is(err, service.ErrUnauthorized), errors.Is(err, service.ErrNotFound):
+				utils.WriteUnAuthorized(w, "Invalid or expired session")
+			default:
+				log.Printf("session validation failed: %v", err)
+				utils.WriteInternalError(w, "internal server error")
+			}
 			return
 		}
 		ctx := context.WithValue(r.Context(), ContextKeyAccount, account)
@@ -62,6 +73,26 @@ func (m *AuthMiddleware) Authenticate(next http.HandlerFunc) http.HandlerFunc {
 
 }
 
+// RequireAdmin only lets through authenticated accounts whose email is in
+// adminEmails. It must run after Authenticate.
+func RequireAdmin(adminEmails []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			account := RequireAccount(w, r)
+			if account == nil {
+				return
+			}
+			for _, email := range adminEmails {
+				if strings.EqualFold(email, account.Email) {
+					next(w, r)
+					return
+				}
+			}
+			utils.WriteForbidden(w, "Admin access required")
+		}
+	}
+}
+
 func GetAccountFromContext(ctx context.Context) (*models.Account, bool) {
 	account, ok := ctx.Value(ContextKeyAccount).(*models.Account)
 
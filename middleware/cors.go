@@ -45,7 +45,7 @@ func CORS(config CORSConfig) func(http.HandlerFunc) http.HandlerFunc {
 			}
 			if allowed {
 				if origin != "" {
-					w.Header().Set("Access-Control-Allowed-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Origin", origin)
 				} else if len(config.AllowedOrigins) == 1 && config.AllowedOrigins[0] == "*" {
 					w.Header().Set("Access-Control-Allow-Origin", "*")
 				}
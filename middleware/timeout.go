@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout puts a deadline on the request context. Every repository call runs
+// with that context, so slow SQL is cancelled in Postgres instead of running
+// on after the server's WriteTimeout has already dropped the client.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
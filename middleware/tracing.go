@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/wizzyszn/go_bank/tracing"
+)
+
+// Tracing starts a server span for every request. If the caller sent a W3C
+// traceparent header the span joins that trace, otherwise a new one starts.
+func Tracing(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if header := r.Header.Get("traceparent"); header != "" {
+			if sc, err := tracing.ParseTraceparent(header); err == nil {
+				ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+			}
+		}
+
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path)
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("http.client_ip", getClientIP(r))
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			w.Header().Set("traceresponse", sc.Traceparent())
+		}
+
+		wrapped := newResponseWriter(w)
+		next(wrapped, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", wrapped.statusCode)
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("server responded with %d", wrapped.statusCode))
+		}
+	}
+}
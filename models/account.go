@@ -1,20 +1,27 @@
 package models
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
-// Account represents a Bank Account
+// Account represents a Bank Account. OverdraftLimit is how far below zero
+// the balance may go.
 
 type Account struct {
-	ID           int       `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	FirstName    string    `json:"first_name" db:"fisrt_name"`
-	LastName     string    `json:"last_name" db:"last_name"`
-	Balance      float64   `json:"balance" db:"balance"`
-	Currency     string    `json:"currency" db:"currency"`
-	Status       string    `json:"status" db:"status"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID             int       `json:"id" db:"id"`
+	AccountNumber  string    `json:"account_number" db:"account_number"`
+	Product        string    `json:"product" db:"product"`
+	Email          string    `json:"email" db:"email"`
+	PasswordHash   string    `json:"-" db:"password_hash"`
+	FirstName      string    `json:"first_name" db:"fisrt_name"`
+	LastName       string    `json:"last_name" db:"last_name"`
+	Balance        float64   `json:"balance" db:"balance"`
+	OverdraftLimit float64   `json:"overdraft_limit" db:"overdraft_limit"`
+	Currency       string    `json:"currency" db:"currency"`
+	Status         string    `json:"status" db:"status"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // CreateAccountRequest represents the request body for creating an account
@@ -33,6 +40,24 @@ type LoginAccountRequest struct {
 	Password string `json:"password"`
 }
 
+// ChangeProductRequest moves an account onto another product
+
+type ChangeProductRequest struct {
+	Product string `json:"product"`
+}
+
+// SetOverdraftRequest sets an account's approved overdraft; 0 removes it
+
+type SetOverdraftRequest struct {
+	OverdraftLimit float64 `json:"overdraft_limit"`
+}
+
+// SetAccountStatusRequest suspends or reactivates an account
+
+type SetAccountStatusRequest struct {
+	Status string `json:"status"`
+}
+
 // UpdateAccountRequest represents the request body for updating account details
 
 type UpdateAccountRequest struct {
@@ -41,34 +66,70 @@ type UpdateAccountRequest struct {
 	Password  string `json:"password,omitempty"`
 }
 
-// AccountResponse is what we return to the client (without sensitive data)
+// AccountResponse is what we return to the client (without sensitive data).
+// Accounts are identified externally by AccountNumber; the internal ID
+// is never serialized. AvailableBalance is the balance plus any unused
+// overdraft.
 type AccountResponse struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Balance   float64   `json:"balance"`
-	Status    string    `json:"status"`
-	Currency  string    `json:"currency"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               int       `json:"-"`
+	AccountNumber    string    `json:"account_number"`
+	IBAN             string    `json:"iban,omitempty"`
+	Product          string    `json:"product"`
+	Email            string    `json:"email"`
+	FirstName        string    `json:"first_name"`
+	LastName         string    `json:"last_name"`
+	Balance          float64   `json:"balance"`
+	AvailableBalance float64   `json:"available_balance"`
+	OverdraftLimit   float64   `json:"overdraft_limit"`
+	OverdraftUsed    float64   `json:"overdraft_used"`
+	Status           string    `json:"status"`
+	Currency         string    `json:"currency"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 // ToResponse converts Account to AccountResponse (removes sensitive fields)
 func (a *Account) ToResponse() *AccountResponse {
 	return &AccountResponse{
-		ID:        a.ID,
-		Email:     a.Email,
-		FirstName: a.FirstName,
-		LastName:  a.LastName,
-		Balance:   a.Balance,
-		Status:    a.Status,
-		Currency:  a.Currency,
-		CreatedAt: a.CreatedAt,
+		ID:               a.ID,
+		AccountNumber:    a.AccountNumber,
+		Product:          a.Product,
+		Email:            a.Email,
+		FirstName:        a.FirstName,
+		LastName:         a.LastName,
+		Balance:          a.Balance,
+		AvailableBalance: a.AvailableBalance(),
+		OverdraftLimit:   a.OverdraftLimit,
+		OverdraftUsed:    a.OverdraftUsed(),
+		Status:           a.Status,
+		Currency:         a.Currency,
+		CreatedAt:        a.CreatedAt,
+	}
+}
+
+// AvailableBalance is what the account can spend: its balance plus the
+// unused part of its overdraft
+func (a *Account) AvailableBalance() float64 {
+	return AvailableBalance(a.Balance, a.OverdraftLimit)
+}
+
+// OverdraftUsed is how far the balance is below zero
+func (a *Account) OverdraftUsed() float64 {
+	if a.Balance >= 0 {
+		return 0
 	}
+	return -a.Balance
+}
+
+// AvailableBalance adds an overdraft limit to a balance, to the cent
+func AvailableBalance(balance, overdraftLimit float64) float64 {
+	return math.Round((balance+overdraftLimit)*100) / 100
 }
 
+// New accounts are pending verification until their email address is
+// verified; they can sign in but not move money.
 const (
-	AccountStatusActice    string = "active"
-	AccountStatusSuspended string = "suspended"
-	AccountStatusClosed    string = "close"
+	AccountStatusActice              string = "active"
+	AccountStatusPendingVerification string = "pending_verification"
+	AccountStatusSuspended           string = "suspended"
+	AccountStatusClosed              string = "close"
 )
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AlertRule tells an account holder about their balance or spending.
+// A low_balance rule fires when the balance drops below Threshold and
+// Triggered then stays set until the balance is back at or above it, so each
+// crossing alerts once. A large_transaction rule fires for every withdrawal
+// or transfer out of more than Threshold.
+
+type AlertRule struct {
+	ID              int        `json:"id" db:"id"`
+	AccountID       int        `json:"-" db:"account_id"`
+	Type            string     `json:"type" db:"type"`
+	Threshold       float64    `json:"threshold" db:"threshold"`
+	Triggered       bool       `json:"triggered" db:"triggered"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty" db:"last_triggered_at"`
+}
+
+// CreateAlertRuleRequest adds an alert rule
+
+type CreateAlertRuleRequest struct {
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Alert rule types
+const (
+	AlertLowBalance       = "low_balance"
+	AlertLargeTransaction = "large_transaction"
+)
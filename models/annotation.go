@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// TransactionAnnotation is an account's own note and tags on a transaction.
+// Each side of a transfer annotates it separately and only sees its own.
+
+type TransactionAnnotation struct {
+	AccountID     int       `json:"-" db:"account_id"`
+	TransactionID int       `json:"transaction_id" db:"transaction_id"`
+	Note          string    `json:"note" db:"note"`
+	Tags          []string  `json:"tags" db:"tags"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateAnnotationRequest replaces a transaction's note and tags. An empty
+// note and no tags remove the annotation.
+
+type UpdateAnnotationRequest struct {
+	Note string   `json:"note"`
+	Tags []string `json:"tags"`
+}
+
+// TransactionAttachment is a file, such as a receipt, an account attached
+// to a transaction. The file itself is kept in document storage.
+
+type TransactionAttachment struct {
+	ID            int       `json:"id" db:"id"`
+	AccountID     int       `json:"-" db:"account_id"`
+	TransactionID int       `json:"transaction_id" db:"transaction_id"`
+	FileName      string    `json:"file_name" db:"file_name"`
+	ContentType   string    `json:"content_type" db:"content_type"`
+	Size          int64     `json:"size" db:"size_bytes"`
+	SHA256        string    `json:"sha256" db:"sha256"`
+	StorageKey    string    `json:"-" db:"storage_key"`
+	UploadedAt    time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
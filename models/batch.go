@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// TransferBatch is a set of transfers from one account submitted together
+
+type TransferBatch struct {
+	ID             int                  `json:"id" db:"id"`
+	AccountID      int                  `json:"account_id" db:"account_id"`
+	Mode           string               `json:"mode" db:"mode"`
+	Status         string               `json:"status" db:"status"`
+	TotalAmount    float64              `json:"total_amount" db:"total_amount"`
+	ItemCount      int                  `json:"item_count" db:"item_count"`
+	SucceededCount int                  `json:"succeeded_count" db:"succeeded_count"`
+	FailedCount    int                  `json:"failed_count" db:"failed_count"`
+	CreatedAt      time.Time            `json:"created_at" db:"created_at"`
+	CompletedAt    *time.Time           `json:"completed_at,omitempty" db:"completed_at"`
+	Items          []*TransferBatchItem `json:"items,omitempty"`
+}
+
+// TransferBatchItem is one row of a batch and its outcome
+
+type TransferBatchItem struct {
+	ID          int `json:"id" db:"id"`
+	BatchID     int `json:"-" db:"batch_id"`
+	Row         int `json:"row" db:"row_number"`
+	ToAccountID int `json:"-" db:"to_account_id"`
+	// ToAccountNumber is read from the recipient's account, not stored
+	ToAccountNumber string  `json:"to_account_number" db:"-"`
+	Amount          float64 `json:"amount" db:"amount"`
+	Description     string  `json:"description" db:"description"`
+	Status          string  `json:"status" db:"status"`
+	TransactionID   *int    `json:"transaction_id,omitempty" db:"transaction_id"`
+	ErrorCode       string  `json:"error_code,omitempty" db:"error_code"`
+	ErrorMessage    string  `json:"error_message,omitempty" db:"error_message"`
+}
+
+// BatchTransferRequest represents the JSON body of a batch upload
+type BatchTransferRequest struct {
+	Mode      string            `json:"mode"`
+	Transfers []TransferRequest `json:"transfers"`
+}
+
+// BatchRowError explains why a row of an upload was rejected. Row is 1-based.
+type BatchRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Batch modes
+const (
+	// BatchModeAtomic moves money for every row or for none
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort runs each row on its own and reports per-row results
+	BatchModeBestEffort = "best_effort"
+)
+
+// Batch and batch item statuses
+const (
+	BatchStatusPending            = "pending"
+	BatchStatusProcessing         = "processing"
+	BatchStatusCompleted          = "completed"
+	BatchStatusPartiallyCompleted = "partially_completed"
+	BatchStatusFailed             = "failed"
+
+	BatchItemStatusPending   = "pending"
+	BatchItemStatusCompleted = "completed"
+	BatchItemStatusFailed    = "failed"
+)
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// CategoryRule files an account's transactions under Category. Pattern
+// matches descriptions containing it, ignoring case, and
+// CounterpartyAccountID the other account of a transfer; a rule with both
+// needs both to match. Rules are tried oldest first.
+
+type CategoryRule struct {
+	ID                    int    `json:"id" db:"id"`
+	AccountID             int    `json:"-" db:"account_id"`
+	Category              string `json:"category" db:"category"`
+	Pattern               string `json:"pattern,omitempty" db:"pattern"`
+	CounterpartyAccountID *int   `json:"-" db:"counterparty_account_id"`
+	// CounterpartyAccountNumber is read from the counterparty's account, not stored
+	CounterpartyAccountNumber string    `json:"counterparty_account_number,omitempty" db:"counterparty_account_number"`
+	CreatedAt                 time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateCategoryRuleRequest adds a rule matching a description pattern, a
+// counterparty account number, or both
+
+type CreateCategoryRuleRequest struct {
+	Category                  string `json:"category"`
+	Pattern                   string `json:"pattern,omitempty"`
+	CounterpartyAccountNumber string `json:"counterparty_account_number,omitempty"`
+}
+
+// TransactionCategory is the category an account filed a transaction
+// under. Both sides of a transfer categorize it separately.
+
+type TransactionCategory struct {
+	AccountID     int    `json:"-" db:"account_id"`
+	TransactionID int    `json:"transaction_id" db:"transaction_id"`
+	Category      string `json:"category" db:"category"`
+	Source        string `json:"source" db:"source"`
+	// RuleID is the rule that matched when Source is "rule"
+	RuleID *int `json:"rule_id,omitempty" db:"rule_id"`
+}
+
+// SetCategoryRequest overrides a transaction's category
+type SetCategoryRequest struct {
+	Category string `json:"category"`
+}
+
+// Where a transaction's category came from: its type, a rule, or the holder
+const (
+	CategorySourceDefault = "default"
+	CategorySourceRule    = "rule"
+	CategorySourceUser    = "user"
+)
+
+// Spending categories
+const (
+	CategoryIncome        = "income"
+	CategoryTransfers     = "transfers"
+	CategoryCash          = "cash"
+	CategoryFees          = "fees"
+	CategoryBills         = "bills"
+	CategoryGroceries     = "groceries"
+	CategoryDining        = "dining"
+	CategoryShopping      = "shopping"
+	CategoryTransport     = "transport"
+	CategoryTravel        = "travel"
+	CategoryEntertainment = "entertainment"
+	CategoryHealth        = "health"
+	CategoryGeneral       = "general"
+)
+
+// Categories lists every category a transaction can be filed under
+var Categories = []string{
+	CategoryIncome, CategoryTransfers, CategoryCash, CategoryFees, CategoryBills,
+	CategoryGroceries, CategoryDining, CategoryShopping, CategoryTransport,
+	CategoryTravel, CategoryEntertainment, CategoryHealth, CategoryGeneral,
+}
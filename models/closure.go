@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// AccountClosure records a customer closing their account. The account's
+// personal data is kept until RetainUntil and then anonymized; its
+// transactions are kept for good.
+
+type AccountClosure struct {
+	AccountID           int        `json:"-" db:"account_id"`
+	PayoutAccountID     *int       `json:"-" db:"payout_account_id"`
+	PayoutTransactionID *int       `json:"payout_transaction_id,omitempty" db:"payout_transaction_id"`
+	ClosedAt            time.Time  `json:"closed_at" db:"closed_at"`
+	RetainUntil         time.Time  `json:"retain_until" db:"retain_until"`
+	AnonymizedAt        *time.Time `json:"anonymized_at,omitempty" db:"anonymized_at"`
+}
+
+// CloseAccountRequest closes the caller's account. The password is asked
+// for again; a balance left after closing the pots is paid to
+// PayoutAccountNumber, which is required unless the balance is zero.
+
+type CloseAccountRequest struct {
+	Password            string `json:"password"`
+	PayoutAccountNumber string `json:"payout_account_number,omitempty"`
+}
+
+// AccountClosureResponse is what we return once an account is closed
+type AccountClosureResponse struct {
+	ClosedAt                 time.Time            `json:"closed_at"`
+	RetainUntil              time.Time            `json:"retain_until"`
+	PotsClosed               int                  `json:"pots_closed"`
+	PaymentRequestsCancelled int                  `json:"payment_requests_cancelled"`
+	Payout                   *TransactionResponse `json:"payout,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DataExport is the personal data held about an account holder, as given
+// to them on request. Events is the account's notification history, which
+// records sign-ins, status changes and payments.
+type DataExport struct {
+	GeneratedAt             time.Time                `json:"generated_at"`
+	Account                 *AccountResponse         `json:"account"`
+	KYC                     *KYCResponse             `json:"kyc"`
+	NotificationPreferences *NotificationPreferences `json:"notification_preferences"`
+	Sessions                []*ExportedSession       `json:"sessions"`
+	Transactions            []*TransactionResponse   `json:"transactions"`
+	Attachments             []*TransactionAttachment `json:"attachments"`
+	Events                  []*NotificationDelivery  `json:"events"`
+}
+
+// ExportedSession is a signed-in session. Session IDs are bearer tokens, so
+// they are left out.
+type ExportedSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Data export formats
+const (
+	ExportFormatZIP  = "zip"
+	ExportFormatJSON = "json"
+)
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// FeeRule is one entry of the fee schedule. Amounts are decimal strings so
+// percentages and caps are applied exactly.
+type FeeRule struct {
+	ID              int    `json:"id"`
+	TransactionType string `json:"transaction_type"`
+	// Product is the account tier the rule applies to; nil applies to every
+	// product without a rule of its own
+	Product    *string   `json:"product"`
+	Kind       string    `json:"kind"`
+	FlatAmount string    `json:"flat_amount"`
+	Percentage string    `json:"percentage"`
+	MinFee     *string   `json:"min_fee"`
+	MaxFee     *string   `json:"max_fee"`
+	Tiers      []FeeTier `json:"tiers,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FeeTier prices amounts up to and including UpTo. The last tier has no
+// UpTo and covers everything above the previous one.
+type FeeTier struct {
+	UpTo       *string `json:"up_to,omitempty"`
+	FlatAmount string  `json:"flat_amount"`
+	Percentage string  `json:"percentage"`
+}
+
+// CreateFeeRuleRequest represents the request body for adding a fee rule
+type CreateFeeRuleRequest struct {
+	TransactionType string    `json:"transaction_type"`
+	Product         *string   `json:"product,omitempty"`
+	Kind            string    `json:"kind"`
+	FlatAmount      string    `json:"flat_amount,omitempty"`
+	Percentage      string    `json:"percentage,omitempty"`
+	MinFee          *string   `json:"min_fee,omitempty"`
+	MaxFee          *string   `json:"max_fee,omitempty"`
+	Tiers           []FeeTier `json:"tiers,omitempty"`
+}
+
+// FeeQuote is what an operation would cost before it is confirmed
+type FeeQuote struct {
+	TransactionType string  `json:"transaction_type"`
+	Amount          float64 `json:"amount"`
+	Fee             float64 `json:"fee"`
+	Total           float64 `json:"total"`
+	RuleID          *int    `json:"rule_id,omitempty"`
+}
+
+// Fee rule kinds
+const (
+	FeeKindFlat       = "flat"
+	FeeKindPercentage = "percentage"
+	FeeKindTiered     = "tiered"
+)
+
+// FeeTypeMaintenance is the fee schedule entry for the monthly account fee;
+// withdrawals and transfers use their transaction types
+const FeeTypeMaintenance = "maintenance"
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Insights summarizes an account's money in and out over whole months.
+// Moves to and from pots are left out; they stay within the account.
+
+type Insights struct {
+	From              string               `json:"from"`
+	To                string               `json:"to"`
+	Months            []*MonthlyFlow       `json:"months"`
+	TopCounterparties []*CounterpartyTotal `json:"top_counterparties"`
+	Categories        []*CategoryTotal     `json:"categories"`
+}
+
+// MonthlyFlow is the money in and out in one month (YYYY-MM, UTC)
+type MonthlyFlow struct {
+	Month   string  `json:"month"`
+	Inflow  float64 `json:"inflow"`
+	Outflow float64 `json:"outflow"`
+	Net     float64 `json:"net"`
+}
+
+// CounterpartyTotal is the transfers exchanged with one other account. Its
+// holder's name is masked.
+type CounterpartyTotal struct {
+	AccountID     int     `json:"-"`
+	AccountNumber string  `json:"account_number"`
+	Name          string  `json:"name"`
+	FirstName     string  `json:"-"`
+	LastName      string  `json:"-"`
+	Inflow        float64 `json:"inflow"`
+	Outflow       float64 `json:"outflow"`
+	Count         int     `json:"count"`
+}
+
+// CategoryTotal is the money in and out filed under one category
+type CategoryTotal struct {
+	Category string  `json:"category"`
+	Inflow   float64 `json:"inflow"`
+	Outflow  float64 `json:"outflow"`
+	Count    int     `json:"count"`
+}
+
+// Budget caps what an account means to spend in a category each month
+
+type Budget struct {
+	ID           int       `json:"id" db:"id"`
+	AccountID    int       `json:"-" db:"account_id"`
+	Category     string    `json:"category" db:"category"`
+	MonthlyLimit float64   `json:"monthly_limit" db:"monthly_limit"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SetBudgetRequest creates or changes the budget for a category
+type SetBudgetRequest struct {
+	MonthlyLimit float64 `json:"monthly_limit"`
+}
+
+// BudgetProgress is a budget with the money that left the account in its
+// category this month
+type BudgetProgress struct {
+	*Budget
+	Month       string  `json:"month"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	PercentUsed float64 `json:"percent_used"`
+	Exceeded    bool    `json:"exceeded"`
+}
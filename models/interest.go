@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Product is an account type and the interest it pays
+
+type Product struct {
+	Code string `json:"code" db:"code"`
+	Name string `json:"name" db:"name"`
+	// AnnualRate is a percentage kept as a decimal string, e.g. "2.500000"
+	AnnualRate string `json:"annual_rate" db:"annual_rate"`
+	// OverdraftRate is charged on overdrawn balances, in the same form
+	OverdraftRate string    `json:"overdraft_rate" db:"overdraft_rate"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// UpdateProductRequest changes a product's interest rates. Empty fields
+// are left unchanged.
+
+type UpdateProductRequest struct {
+	AnnualRate    string `json:"annual_rate,omitempty"`
+	OverdraftRate string `json:"overdraft_rate,omitempty"`
+}
+
+// InterestAccrual is one day's interest on one account. Amounts are decimal
+// strings so no precision is lost between accrual and capitalization.
+
+type InterestAccrual struct {
+	ID          int       `json:"id" db:"id"`
+	AccountID   int       `json:"-" db:"account_id"`
+	AccrualDate time.Time `json:"accrual_date" db:"accrual_date"`
+	// Balance is the end-of-day balance the interest was earned on
+	Balance string `json:"balance" db:"balance"`
+	// AnnualRate is the overdraft rate on days the balance was negative
+	AnnualRate string `json:"annual_rate" db:"annual_rate"`
+	// Amount is negative for interest charged on an overdrawn balance
+	Amount string `json:"amount" db:"amount"`
+	// TransactionID is the interest transaction the accrual was paid out in
+	TransactionID *int       `json:"transaction_id,omitempty" db:"transaction_id"`
+	CapitalizedAt *time.Time `json:"capitalized_at,omitempty" db:"capitalized_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// InterestAudit compares a stored accrual with the same day recomputed from
+// the ledger
+
+type InterestAudit struct {
+	Date       string `json:"date"`
+	AnnualRate string `json:"annual_rate"`
+	Balance    string `json:"balance"`
+	Recorded   string `json:"recorded"`
+	Recomputed string `json:"recomputed"`
+	// RecomputedBalance differs from Balance when the ledger has changed
+	// since the accrual was recorded
+	RecomputedBalance string `json:"recomputed_balance"`
+	Matches           bool   `json:"matches"`
+}
+
+// Account products
+const (
+	ProductChecking = "checking"
+	ProductSavings  = "savings"
+)
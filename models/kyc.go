@@ -0,0 +1,161 @@
+package models
+
+import "time"
+
+// KYCProfile is an account holder's identity check. Status moves from
+// unverified to submitted, then to approved or rejected by a reviewer; a
+// rejected profile can be corrected and submitted again. Level is the limits
+// tier and stays 0 until the profile is approved.
+
+type KYCProfile struct {
+	AccountID       int        `json:"-" db:"account_id"`
+	Status          string     `json:"status" db:"status"`
+	Level           int        `json:"level" db:"level"`
+	DateOfBirth     *time.Time `json:"date_of_birth" db:"date_of_birth"`
+	Nationality     string     `json:"nationality" db:"nationality"`
+	AddressLine1    string     `json:"address_line1" db:"address_line1"`
+	AddressLine2    string     `json:"address_line2" db:"address_line2"`
+	City            string     `json:"city" db:"city"`
+	PostalCode      string     `json:"postal_code" db:"postal_code"`
+	Country         string     `json:"country" db:"country"`
+	RejectionReason string     `json:"rejection_reason" db:"rejection_reason"`
+	SubmittedAt     *time.Time `json:"submitted_at" db:"submitted_at"`
+	ReviewedAt      *time.Time `json:"reviewed_at" db:"reviewed_at"`
+	ReviewedBy      *int       `json:"-" db:"reviewed_by"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// KYCDocument is an uploaded identity or address document. The file itself
+// lives in document storage under StorageKey.
+
+type KYCDocument struct {
+	ID          int       `json:"id" db:"id"`
+	AccountID   int       `json:"-" db:"account_id"`
+	Type        string    `json:"type" db:"type"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size_bytes"`
+	SHA256      string    `json:"sha256" db:"sha256"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	UploadedAt  time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
+
+// UpdateKYCDetailsRequest sets the personal details checked by KYC.
+// DateOfBirth is YYYY-MM-DD; Nationality and Country are ISO 3166 alpha-2.
+
+type UpdateKYCDetailsRequest struct {
+	DateOfBirth  string `json:"date_of_birth"`
+	Nationality  string `json:"nationality"`
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2,omitempty"`
+	City         string `json:"city"`
+	PostalCode   string `json:"postal_code"`
+	Country      string `json:"country"`
+}
+
+// ApproveKYCRequest approves a submitted profile at a limits tier
+
+type ApproveKYCRequest struct {
+	Level int `json:"level"`
+}
+
+// RejectKYCRequest rejects a submitted profile, telling the holder why
+
+type RejectKYCRequest struct {
+	Reason string `json:"reason"`
+}
+
+// KYCLimits caps the money an account can send: any single withdrawal or
+// transfer, and the total sent over the last 24 hours
+type KYCLimits struct {
+	PerTransaction float64 `json:"per_transaction"`
+	Daily          float64 `json:"daily"`
+}
+
+// KYCResponse is a profile with its documents and the limits its level
+// gives. The account fields are only filled in for reviewers.
+type KYCResponse struct {
+	AccountNumber   string         `json:"account_number,omitempty"`
+	FirstName       string         `json:"first_name,omitempty"`
+	LastName        string         `json:"last_name,omitempty"`
+	Status          string         `json:"status"`
+	Level           int            `json:"level"`
+	Limits          KYCLimits      `json:"limits"`
+	DateOfBirth     string         `json:"date_of_birth,omitempty"`
+	Nationality     string         `json:"nationality,omitempty"`
+	AddressLine1    string         `json:"address_line1,omitempty"`
+	AddressLine2    string         `json:"address_line2,omitempty"`
+	City            string         `json:"city,omitempty"`
+	PostalCode      string         `json:"postal_code,omitempty"`
+	Country         string         `json:"country,omitempty"`
+	RejectionReason string         `json:"rejection_reason,omitempty"`
+	SubmittedAt     *time.Time     `json:"submitted_at,omitempty"`
+	ReviewedAt      *time.Time     `json:"reviewed_at,omitempty"`
+	Documents       []*KYCDocument `json:"documents"`
+}
+
+// ToResponse converts KYCProfile to KYCResponse
+func (p *KYCProfile) ToResponse(documents []*KYCDocument) *KYCResponse {
+	response := &KYCResponse{
+		Status:          p.Status,
+		Level:           p.Level,
+		Limits:          KYCLimitsFor(p.Level),
+		Nationality:     p.Nationality,
+		AddressLine1:    p.AddressLine1,
+		AddressLine2:    p.AddressLine2,
+		City:            p.City,
+		PostalCode:      p.PostalCode,
+		Country:         p.Country,
+		RejectionReason: p.RejectionReason,
+		SubmittedAt:     p.SubmittedAt,
+		ReviewedAt:      p.ReviewedAt,
+		Documents:       documents,
+	}
+	if p.DateOfBirth != nil {
+		response.DateOfBirth = p.DateOfBirth.Format(time.DateOnly)
+	}
+	if response.Documents == nil {
+		response.Documents = []*KYCDocument{}
+	}
+	return response
+}
+
+// NewKYCProfile is the profile of an account that has not started KYC
+func NewKYCProfile(accountID int) *KYCProfile {
+	return &KYCProfile{AccountID: accountID, Status: KYCUnverified}
+}
+
+// kycTiers holds the limits for each KYC level, from unverified (0) up
+var kycTiers = []KYCLimits{
+	{PerTransaction: 500, Daily: 1000},
+	{PerTransaction: 10000, Daily: 25000},
+	{PerTransaction: 100000, Daily: 250000},
+}
+
+// MaxKYCLevel is the highest level a reviewer can approve
+const MaxKYCLevel = 2
+
+// KYCLimitsFor returns the limits for a KYC level
+func KYCLimitsFor(level int) KYCLimits {
+	if level < 0 || level >= len(kycTiers) {
+		return kycTiers[0]
+	}
+	return kycTiers[level]
+}
+
+// KYC statuses
+const (
+	KYCUnverified = "unverified"
+	KYCSubmitted  = "submitted"
+	KYCApproved   = "approved"
+	KYCRejected   = "rejected"
+)
+
+// KYC document types. The first three prove identity; a submission needs
+// at least one of them.
+const (
+	KYCDocumentPassport       = "passport"
+	KYCDocumentDrivingLicence = "driving_licence"
+	KYCDocumentNationalID     = "national_id"
+	KYCDocumentProofOfAddress = "proof_of_address"
+)
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// NotificationPreferences are an account holder's choices of how to be
+// told about things. Accounts without saved preferences get email only.
+
+type NotificationPreferences struct {
+	AccountID    int    `json:"-" db:"account_id"`
+	EmailEnabled bool   `json:"email_enabled" db:"email_enabled"`
+	SMSEnabled   bool   `json:"sms_enabled" db:"sms_enabled"`
+	PushEnabled  bool   `json:"push_enabled" db:"push_enabled"`
+	Phone        string `json:"phone,omitempty" db:"phone"`
+	PushToken    string `json:"push_token,omitempty" db:"push_token"`
+	// MutedEvents lists event types not to be sent on any channel. Security
+	// events are sent regardless.
+	MutedEvents []string  `json:"muted_events" db:"muted_events"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UpdateNotificationPreferencesRequest changes preferences. Nil fields are
+// left unchanged.
+
+type UpdateNotificationPreferencesRequest struct {
+	EmailEnabled *bool    `json:"email_enabled,omitempty"`
+	SMSEnabled   *bool    `json:"sms_enabled,omitempty"`
+	PushEnabled  *bool    `json:"push_enabled,omitempty"`
+	Phone        *string  `json:"phone,omitempty"`
+	PushToken    *string  `json:"push_token,omitempty"`
+	MutedEvents  []string `json:"muted_events,omitempty"`
+}
+
+// NotificationDelivery is one rendered message queued for one channel. The
+// delivery worker retries it until it is sent or runs out of attempts.
+
+type NotificationDelivery struct {
+	ID            int        `json:"id" db:"id"`
+	AccountID     int        `json:"-" db:"account_id"`
+	EventType     string     `json:"event_type" db:"event_type"`
+	Channel       string     `json:"channel" db:"channel"`
+	Recipient     string     `json:"-" db:"recipient"`
+	Subject       string     `json:"subject" db:"subject"`
+	Body          string     `json:"body" db:"body"`
+	HTMLBody      string     `json:"-" db:"html_body"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time  `json:"-" db:"next_attempt_at"`
+	LastError     string     `json:"-" db:"last_error"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+}
+
+// DefaultNotificationPreferences applies to accounts that have never saved
+// any
+func DefaultNotificationPreferences(accountID int) *NotificationPreferences {
+	return &NotificationPreferences{
+		AccountID:    accountID,
+		EmailEnabled: true,
+		MutedEvents:  []string{},
+	}
+}
+
+// Notification channels
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+	NotificationChannelPush  = "push"
+)
+
+// Notification delivery statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
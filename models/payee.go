@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Payee is a saved transfer recipient in an account's address book
+
+type Payee struct {
+	ID             int `json:"id" db:"id"`
+	AccountID      int `json:"account_id" db:"account_id"`
+	PayeeAccountID int `json:"payee_account_id" db:"payee_account_id"`
+	// PayeeAccountNumber is read from the payee's account, not stored
+	PayeeAccountNumber string     `json:"payee_account_number" db:"payee_account_number"`
+	Nickname           string     `json:"nickname" db:"nickname"`
+	VerifiedName       string     `json:"-" db:"verified_name"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	LastPaidAt         *time.Time `json:"last_paid_at" db:"last_paid_at"`
+}
+
+// CreatePayeeRequest saves a recipient found by account number or email
+
+type CreatePayeeRequest struct {
+	Nickname      string `json:"nickname"`
+	AccountNumber string `json:"account_number,omitempty"`
+	Email         string `json:"email,omitempty"`
+}
+
+// PayeeLookupRequest names the account to confirm by exactly one of
+// AccountNumber or Email. Name is the holder name the payer expects.
+type PayeeLookupRequest struct {
+	AccountNumber string
+	Email         string
+	Name          string
+}
+
+// PayeeResponse is what we return to the client. The recipient's name is
+// masked; it is only there so the user can recognise who they are paying.
+type PayeeResponse struct {
+	ID             int        `json:"id"`
+	Nickname       string     `json:"nickname"`
+	AccountNumber  string     `json:"account_number"`
+	MaskedName     string     `json:"masked_name"`
+	FirstTimePayee bool       `json:"first_time_payee"`
+	LastPaidAt     *time.Time `json:"last_paid_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PayeeLookupResponse is the confirmation-of-payee answer shown before
+// sending money
+type PayeeLookupResponse struct {
+	AccountNumber string `json:"account_number"`
+	MaskedName    string `json:"masked_name"`
+	// NameMatch is set when the caller supplied the name they expect
+	NameMatch      string `json:"name_match,omitempty"`
+	FirstTimePayee bool   `json:"first_time_payee"`
+}
+
+// Confirmation-of-payee name match results
+const (
+	NameMatchExact = "match"
+	NameMatchClose = "close_match"
+	NameMatchNone  = "no_match"
+)
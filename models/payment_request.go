@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// PaymentRequest asks another account holder to pay the requester. Paying
+// it executes a transfer from the payer to the requester.
+
+type PaymentRequest struct {
+	ID                 int     `json:"id" db:"id"`
+	RequesterAccountID int     `json:"requester_account_id" db:"requester_account_id"`
+	PayerAccountID     int     `json:"payer_account_id" db:"payer_account_id"`
+	Amount             float64 `json:"amount" db:"amount"`
+	Memo               string  `json:"memo" db:"memo"`
+	Status             string  `json:"status" db:"status"`
+	// TransactionID is the transfer that paid the request
+	TransactionID *int       `json:"transaction_id" db:"transaction_id"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt    *time.Time `json:"resolved_at" db:"resolved_at"`
+	// The parties' account numbers and names are read from their accounts,
+	// not stored
+	RequesterAccountNumber string `json:"-" db:"requester_account_number"`
+	RequesterName          string `json:"-" db:"requester_name"`
+	PayerAccountNumber     string `json:"-" db:"payer_account_number"`
+	PayerName              string `json:"-" db:"payer_name"`
+}
+
+// CreatePaymentRequestRequest names the payer by exactly one of
+// FromAccountNumber, FromEmail or PayeeID. ExpiresAt defaults to a week.
+
+type CreatePaymentRequestRequest struct {
+	FromAccountNumber string     `json:"from_account_number,omitempty"`
+	FromEmail         string     `json:"from_email,omitempty"`
+	PayeeID           int        `json:"payee_id,omitempty"`
+	Amount            float64    `json:"amount"`
+	Memo              string     `json:"memo"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+}
+
+// PaymentRequestParty is the other side of a request, with the name masked
+type PaymentRequestParty struct {
+	AccountNumber string `json:"account_number"`
+	MaskedName    string `json:"masked_name"`
+}
+
+// PaymentRequestResponse is what we return to the client. Direction is
+// incoming when the caller is the one asked to pay.
+type PaymentRequestResponse struct {
+	ID            int                  `json:"id"`
+	Direction     string               `json:"direction"`
+	Requester     *PaymentRequestParty `json:"requester"`
+	Payer         *PaymentRequestParty `json:"payer"`
+	Amount        float64              `json:"amount"`
+	Memo          string               `json:"memo,omitempty"`
+	Status        string               `json:"status"`
+	TransactionID *int                 `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time            `json:"expires_at"`
+	CreatedAt     time.Time            `json:"created_at"`
+	ResolvedAt    *time.Time           `json:"resolved_at,omitempty"`
+}
+
+// PaymentRequestPayResponse is the paid request and the transfer that paid it
+type PaymentRequestPayResponse struct {
+	Request     *PaymentRequestResponse `json:"request"`
+	Transaction *TransactionResponse    `json:"transaction"`
+}
+
+// Payment request statuses
+const (
+	PaymentRequestPending   = "pending"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestDeclined  = "declined"
+	PaymentRequestCancelled = "cancelled"
+	PaymentRequestExpired   = "expired"
+)
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Pot is a named savings goal inside an account. Money moved into a pot
+// leaves the account balance until it is moved back.
+
+type Pot struct {
+	ID           int        `json:"id" db:"id"`
+	AccountID    int        `json:"account_id" db:"account_id"`
+	Name         string     `json:"name" db:"name"`
+	Balance      float64    `json:"balance" db:"balance"`
+	TargetAmount *float64   `json:"target_amount" db:"target_amount"`
+	TargetDate   *time.Time `json:"target_date" db:"target_date"`
+	RoundUp      bool       `json:"round_up" db:"round_up"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at" db:"closed_at"`
+}
+
+// CreatePotRequest opens a pot. TargetDate is YYYY-MM-DD.
+
+type CreatePotRequest struct {
+	Name         string   `json:"name"`
+	TargetAmount *float64 `json:"target_amount,omitempty"`
+	TargetDate   string   `json:"target_date,omitempty"`
+	RoundUp      bool     `json:"round_up"`
+}
+
+// UpdatePotRequest changes a pot's settings. Nil fields are left unchanged;
+// an empty TargetDate or a zero TargetAmount clears the target.
+
+type UpdatePotRequest struct {
+	Name         *string  `json:"name,omitempty"`
+	TargetAmount *float64 `json:"target_amount,omitempty"`
+	TargetDate   *string  `json:"target_date,omitempty"`
+	RoundUp      *bool    `json:"round_up,omitempty"`
+}
+
+// PotMoveRequest moves money between the account balance and a pot
+
+type PotMoveRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+// PotResponse is what we return to the client
+type PotResponse struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Balance      float64  `json:"balance"`
+	TargetAmount *float64 `json:"target_amount,omitempty"`
+	TargetDate   string   `json:"target_date,omitempty"`
+	// Progress is the balance as a percentage of the target, capped at 100
+	Progress  *float64  `json:"progress,omitempty"`
+	RoundUp   bool      `json:"round_up"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PotMoveResponse is the pot after a move and the transaction recording it
+type PotMoveResponse struct {
+	Pot         *PotResponse         `json:"pot"`
+	Transaction *TransactionResponse `json:"transaction"`
+}
+
+// ToResponse converts Pot to PotResponse
+func (p *Pot) ToResponse() *PotResponse {
+	response := &PotResponse{
+		ID:           p.ID,
+		Name:         p.Name,
+		Balance:      p.Balance,
+		TargetAmount: p.TargetAmount,
+		RoundUp:      p.RoundUp,
+		CreatedAt:    p.CreatedAt,
+	}
+	if p.TargetDate != nil {
+		response.TargetDate = p.TargetDate.Format(time.DateOnly)
+	}
+	if p.TargetAmount != nil && *p.TargetAmount > 0 {
+		progress := p.Balance / *p.TargetAmount * 100
+		if progress > 100 {
+			progress = 100
+		}
+		progress = math.Round(progress*100) / 100
+		response.Progress = &progress
+	}
+	return response
+}
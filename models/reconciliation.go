@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// ReconciliationRun is one check of every account's stored balance against
+// the sum of its completed transactions
+
+type ReconciliationRun struct {
+	ID              int       `json:"id" db:"id"`
+	Trigger         string    `json:"trigger" db:"trigger"`
+	StartedAt       time.Time `json:"started_at" db:"started_at"`
+	FinishedAt      time.Time `json:"finished_at" db:"finished_at"`
+	AccountsChecked int       `json:"accounts_checked" db:"accounts_checked"`
+	Discrepancies   int       `json:"discrepancies" db:"discrepancies"`
+	AccountsFrozen  int       `json:"accounts_frozen" db:"accounts_frozen"`
+}
+
+// BalanceDiscrepancy is an account whose stored balance did not match its
+// ledger. Difference is the stored balance less the ledger balance.
+
+type BalanceDiscrepancy struct {
+	ID        int `json:"id" db:"id"`
+	RunID     int `json:"run_id" db:"run_id"`
+	AccountID int `json:"account_id" db:"account_id"`
+	// AccountNumber is read from the account, not stored
+	AccountNumber     string     `json:"account_number" db:"account_number"`
+	StoredBalance     float64    `json:"stored_balance" db:"stored_balance"`
+	LedgerBalance     float64    `json:"ledger_balance" db:"ledger_balance"`
+	Difference        float64    `json:"difference" db:"difference"`
+	TransactionCount  int        `json:"transaction_count" db:"transaction_count"`
+	LastTransactionAt *time.Time `json:"last_transaction_at,omitempty" db:"last_transaction_at"`
+	Frozen            bool       `json:"frozen" db:"frozen"`
+}
+
+// RunReconciliationRequest starts a reconciliation. Freeze overrides the
+// configured choice of suspending accounts that don't reconcile.
+type RunReconciliationRequest struct {
+	Freeze *bool `json:"freeze,omitempty"`
+}
+
+// ReconciliationResponse is a run with the discrepancies it found
+type ReconciliationResponse struct {
+	*ReconciliationRun
+	Details []*BalanceDiscrepancy `json:"details"`
+}
+
+// Reconciliation triggers
+const (
+	ReconciliationScheduled = "scheduled"
+	ReconciliationManual    = "manual"
+)
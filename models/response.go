@@ -4,9 +4,25 @@ type ApiResponse struct {
 	Success bool   `json:"success"`
 	Data    any    `json:"data,omitempty"`
 	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Field   string `json:"field,omitempty"`
 	Message string `json:"message,omitempty"`
 }
 
+// ProblemDetails is an RFC 7807 error document, sent as application/problem+json
+// to clients that ask for it in their Accept header
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Field    string `json:"field,omitempty"`
+	// Errors lists individual problems, e.g. the rejected rows of an upload
+	Errors any `json:"errors,omitempty"`
+}
+
 type PaginationParams struct {
 	Page  int `json:"page"`
 	Limit int `json:"limit"`
@@ -21,6 +37,11 @@ type PaginatedResponse struct {
 }
 
 type BalanceResponse struct {
-	Balance  float64 `json:"balance"`
-	Currency string  `json:"currency"`
+	Balance          float64 `json:"balance"`
+	AvailableBalance float64 `json:"available_balance"`
+	OverdraftLimit   float64 `json:"overdraft_limit"`
+	// PotsTotal is held in pots, outside Balance; TotalBalance adds the two
+	PotsTotal    float64 `json:"pots_total"`
+	TotalBalance float64 `json:"total_balance"`
+	Currency     string  `json:"currency"`
 }
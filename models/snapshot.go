@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// BalanceSnapshot is an account's balance at the end of SnapshotDate (UTC)
+
+type BalanceSnapshot struct {
+	AccountID    int       `json:"account_id" db:"account_id"`
+	SnapshotDate time.Time `json:"snapshot_date" db:"snapshot_date"`
+	Balance      float64   `json:"balance" db:"balance"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// HistoricalBalanceResponse is the balance at AsOf. SnapshotDate is the day
+// of the snapshot it was worked out from, if there was one.
+type HistoricalBalanceResponse struct {
+	Balance      float64    `json:"balance"`
+	Currency     string     `json:"currency"`
+	AsOf         time.Time  `json:"as_of"`
+	SnapshotDate *time.Time `json:"snapshot_date,omitempty"`
+}
+
+// BackfillSnapshotsRequest rebuilds snapshots for the days from From to To
+// (YYYY-MM-DD, inclusive)
+type BackfillSnapshotsRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BackfillSnapshotsResponse reports how many snapshots a backfill wrote
+type BackfillSnapshotsResponse struct {
+	AccountNumber string `json:"account_number"`
+	Snapshots     int    `json:"snapshots"`
+}
@@ -5,68 +5,151 @@ import "time"
 // Transaction represents a financial transaction
 
 type Transaction struct {
-	ID            int       `json:"id" db:"id"`
-	FromAccountID *int       `json:"from_account_id" db:"from_account_id"`
-	ToAccountID   *int       `json:"to_account_id" db:"to_account_id"`
-	Amount        float64   `json:"amount" db:"amount"`
-	Type          string    `json:"type" db:"type"`
-	Description   string    `json:"description" db:"description"`
-	Status        string    `json:"status" db:"status"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	ID            int     `json:"id" db:"id"`
+	FromAccountID *int    `json:"from_account_id" db:"from_account_id"`
+	ToAccountID   *int    `json:"to_account_id" db:"to_account_id"`
+	Amount        float64 `json:"amount" db:"amount"`
+	Type          string  `json:"type" db:"type"`
+	Description   string  `json:"description" db:"description"`
+	Status        string  `json:"status" db:"status"`
+	// RelatedTransactionID links a fee to the transaction it was charged on
+	RelatedTransactionID *int `json:"related_transaction_id,omitempty" db:"related_transaction_id"`
+	// PotID is the pot money moved into or out of, for pot transactions
+	PotID     *int      `json:"pot_id,omitempty" db:"pot_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 // DepositRequest represents a deposit request
 
 type DepositRequest struct {
 	Amount      float64 `json:"amount"`
-	Description string `json:"description"`
+	Description string  `json:"description"`
 }
 
 // WithdrawRequest represents a withdrawal request
 
 type WitdrawRequest struct {
 	Amount      float64 `json:"amount"`
-	Description string `json:"description"`
+	Description string  `json:"description"`
 }
 
-// TransferRequest represents a transfer request
+// TransferRequest represents a transfer request. The recipient is given by
+// exactly one of ToAccountNumber, PayeeID or ToEmail.
 type TransferRequest struct {
-	ToAccountID int     `json:"to_account_id"`
+	// ToAccountNumber also accepts the IBAN form of an account number
+	ToAccountNumber string `json:"to_account_number,omitempty"`
+	// ToAccountID is for callers inside the service that already resolved
+	// the recipient; clients never name accounts by ID
+	ToAccountID int     `json:"-"`
+	PayeeID     int     `json:"payee_id,omitempty"`
+	ToEmail     string  `json:"to_email,omitempty"`
 	Amount      float64 `json:"amount"`
 	Description string  `json:"description"`
 }
 
 // TransactionResponse is what we return to the client
 type TransactionResponse struct {
-	ID            int       `json:"id"`
-	FromAccountID *int      `json:"from_account_id,omitempty"`
-	ToAccountID   *int      `json:"to_account_id,omitempty"`
-	Amount        float64   `json:"amount"`
-	Type          string    `json:"type"`
-	Description   string    `json:"description"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID                   int       `json:"id"`
+	FromAccountID        *int      `json:"-"`
+	ToAccountID          *int      `json:"-"`
+	FromAccountNumber    string    `json:"from_account_number,omitempty"`
+	ToAccountNumber      string    `json:"to_account_number,omitempty"`
+	Amount               float64   `json:"amount"`
+	Type                 string    `json:"type"`
+	Description          string    `json:"description"`
+	Status               string    `json:"status"`
+	RelatedTransactionID *int      `json:"related_transaction_id,omitempty"`
+	PotID                *int      `json:"pot_id,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	// Fee is the fee transaction charged with this one, if any
+	Fee *TransactionResponse `json:"fee,omitempty"`
+	// RoundUp is the spare change swept into the round-up pot, if any
+	RoundUp *TransactionResponse `json:"round_up,omitempty"`
+	// FirstTimePayee is set on transfers to a recipient the sender has never
+	// paid before, for fraud checks
+	FirstTimePayee bool `json:"first_time_payee,omitempty"`
+	// Note and Tags are the viewing account's own annotation
+	Note string   `json:"note,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	// Attachments are the viewing account's files, on single transactions
+	Attachments []*TransactionAttachment `json:"attachments,omitempty"`
+}
+
+// Annotate sets the viewing account's note and tags on the response
+func (r *TransactionResponse) Annotate(annotation *TransactionAnnotation) {
+	if annotation != nil {
+		r.Note = annotation.Note
+		r.Tags = annotation.Tags
+	}
 }
 
 // ToResponse converts Transaction to TransactionResponse
 func (t *Transaction) ToResponse() *TransactionResponse {
 	return &TransactionResponse{
-		ID:            t.ID,
-		FromAccountID: t.FromAccountID,
-		ToAccountID:   t.ToAccountID,
-		Amount:        t.Amount,
-		Type:          t.Type,
-		Description:   t.Description,
-		Status:        t.Status,
-		CreatedAt:     t.CreatedAt,
+		ID:                   t.ID,
+		FromAccountID:        t.FromAccountID,
+		ToAccountID:          t.ToAccountID,
+		Amount:               t.Amount,
+		Type:                 t.Type,
+		Description:          t.Description,
+		Status:               t.Status,
+		RelatedTransactionID: t.RelatedTransactionID,
+		PotID:                t.PotID,
+		CreatedAt:            t.CreatedAt,
 	}
 }
 
+// TransactionFilter narrows an account's transaction history. Zero values
+// mean "no filter".
+type TransactionFilter struct {
+	Type      string
+	Status    string
+	Direction string
+	MinAmount *float64
+	MaxAmount *float64
+	// From is inclusive, To is exclusive
+	From           *time.Time
+	To             *time.Time
+	CounterpartyID *int
+	// CounterpartyAccountNumber is resolved into CounterpartyID
+	CounterpartyAccountNumber string
+	Search                    string
+	// Tag keeps transactions the account tagged with it
+	Tag string
+	// Ascending lists oldest first; the default is newest first
+	Ascending bool
+}
+
+// TransactionCursor is the position of the last row of a page. Rows are
+// ordered by (created_at, id) so the position is unique and stable.
+type TransactionCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// TransactionPage is one page of cursor-paginated history
+type TransactionPage struct {
+	Data       []*TransactionResponse `json:"data"`
+	Limit      int                    `json:"limit"`
+	HasMore    bool                   `json:"has_more"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
 // Transaction types
 const (
 	TransactionTypeDeposit  string = "deposit"
 	TransactionTypeTransfer string = "transfer"
 	TransactionTypeWithdraw string = "withdraw"
+	TransactionTypeInterest string = "interest"
+	TransactionTypeFee      string = "fee"
+	TransactionTypePot      string = "pot"
+)
+
+// Transaction directions, relative to the account viewing the history
+
+const (
+	TransactionDirectionIncoming = "incoming"
+	TransactionDirectionOutgoing = "outgoing"
 )
 
 // Transaction statuses
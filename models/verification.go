@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// EmailVerification is a verification token sent to a new account's email
+// address. Only a hash of the token is stored; UsedAt is set once it has
+// verified the account.
+
+type EmailVerification struct {
+	ID        int        `json:"id" db:"id"`
+	AccountID int        `json:"-" db:"account_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// VerifyEmailRequest carries the token from a verification email
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,199 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one rendered notification addressed to one recipient: an email
+// address, a phone number or a push token depending on the channel
+type Message struct {
+	To       string `json:"to"`
+	Subject  string `json:"subject,omitempty"`
+	Body     string `json:"body"`
+	HTMLBody string `json:"html_body,omitempty"`
+}
+
+// Channel sends messages over one medium. An error means the message may be
+// retried.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPChannel sends email through an SMTP server, as multipart text and HTML
+// when the message has an HTML body
+type SMTPChannel struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPChannel sends from the given address through the server at addr
+// (host:port). Leave username empty for servers without authentication.
+func NewSMTPChannel(addr, from, username, password string) *SMTPChannel {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPChannel{addr: addr, from: from, auth: auth}
+}
+
+func (c *SMTPChannel) Send(ctx context.Context, msg Message) error {
+	body, err := c.compose(msg)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(c.addr, c.auth, c.from, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+func (c *SMTPChannel) compose(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		c.from, msg.To, msg.Subject, time.Now().Format(time.RFC1123Z))
+
+	if msg.HTMLBody == "" {
+		fmt.Fprintf(&buf, "Content-Type: text/plain; charset=UTF-8\r\n\r\n%s", msg.Body)
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Body},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose email: %w", err)
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("failed to compose email: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compose email: %w", err)
+	}
+	buf.Write(parts.Bytes())
+	return buf.Bytes(), nil
+}
+
+// WebhookChannel posts messages as JSON to an HTTP gateway. SMS and push
+// providers are reached this way; any non-2xx answer is a failure.
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookChannel(url string) *WebhookChannel {
+	return &WebhookChannel{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build gateway request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach gateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("gateway answered %s", resp.Status)
+	}
+	return nil
+}
+
+// FileChannel appends messages as JSON lines to a file, a development sink
+// that stands in for a real provider
+type FileChannel struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileChannel appends to the file at path, creating it if needed
+func NewFileChannel(path string) (*FileChannel, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notification sink: %w", err)
+	}
+	return &FileChannel{file: f}, nil
+}
+
+func (c *FileChannel) Send(ctx context.Context, msg Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	b = append(b, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.Write(b); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+func (c *FileChannel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// LogChannel writes messages to the server log, a development sink
+type LogChannel struct {
+	Name string
+}
+
+func (c LogChannel) Send(ctx context.Context, msg Message) error {
+	log.Printf("%s to %s: %s %s", c.Name, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// MemoryChannel keeps messages in memory so tests can assert on them. Fail,
+// when set, is returned instead of sending.
+type MemoryChannel struct {
+	mu       sync.Mutex
+	messages []Message
+	Fail     error
+}
+
+func (c *MemoryChannel) Send(ctx context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Fail != nil {
+		return c.Fail
+	}
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (c *MemoryChannel) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.messages...)
+}
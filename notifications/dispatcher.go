@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+// Dispatcher is the Notifier used in production. It renders each event for
+// the channels the account holder has chosen and queues the messages; the
+// Worker sends them, so a slow provider never holds up a request.
+type Dispatcher struct {
+	accountRepo repository.AccountStore
+	store       repository.NotificationStore
+	renderer    *Renderer
+}
+
+func NewDispatcher(accountRepo repository.AccountStore, store repository.NotificationStore, renderer *Renderer) *Dispatcher {
+	return &Dispatcher{
+		accountRepo: accountRepo,
+		store:       store,
+		renderer:    renderer,
+	}
+}
+
+func (d *Dispatcher) Notify(ctx context.Context, event Event) error {
+	prefs, err := d.store.GetPreferences(ctx, event.AccountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		prefs = models.DefaultNotificationPreferences(event.AccountID)
+	} else if err != nil {
+		return err
+	}
+
+	security := IsSecurityEvent(event.Type)
+	if !security && slices.Contains(prefs.MutedEvents, event.Type) {
+		return nil
+	}
+
+	account, err := d.accountRepo.GetByID(ctx, event.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to look up account %d: %w", event.AccountID, err)
+	}
+	rendered, err := d.renderer.Render(TemplateData{Name: account.FirstName, Type: event.Type, Data: event.Data})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*models.NotificationDelivery, 0, 3)
+	add := func(channel, recipient, htmlBody string) {
+		deliveries = append(deliveries, &models.NotificationDelivery{
+			AccountID: event.AccountID,
+			EventType: event.Type,
+			Channel:   channel,
+			Recipient: recipient,
+			Subject:   rendered.Subject,
+			Body:      rendered.Text,
+			HTMLBody:  htmlBody,
+		})
+	}
+	if prefs.EmailEnabled || security {
+		add(models.NotificationChannelEmail, account.Email, rendered.HTML)
+	}
+	if prefs.SMSEnabled && prefs.Phone != "" {
+		add(models.NotificationChannelSMS, prefs.Phone, "")
+	}
+	if prefs.PushEnabled && prefs.PushToken != "" {
+		add(models.NotificationChannelPush, prefs.PushToken, "")
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.store.Enqueue(ctx, deliveries)
+}
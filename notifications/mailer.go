@@ -0,0 +1,66 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// EmailVerifyAddress is the template for the email sent to confirm a new
+// account's address
+const EmailVerifyAddress = "auth.verify_email"
+
+// Mailer sends an email straight away instead of queueing it like a
+// Dispatcher. It is for mail that must reach an address whatever its
+// preferences and must not be stored, such as verification links.
+type Mailer interface {
+	SendEmail(ctx context.Context, to string, data TemplateData) error
+}
+
+// TemplateMailer renders emails with the notification templates and sends
+// them through an email Channel
+type TemplateMailer struct {
+	channel  Channel
+	renderer *Renderer
+}
+
+func NewTemplateMailer(channel Channel, renderer *Renderer) *TemplateMailer {
+	return &TemplateMailer{channel: channel, renderer: renderer}
+}
+
+func (m *TemplateMailer) SendEmail(ctx context.Context, to string, data TemplateData) error {
+	rendered, err := m.renderer.Render(data)
+	if err != nil {
+		return err
+	}
+	if err := m.channel.Send(ctx, Message{To: to, Subject: rendered.Subject, Body: rendered.Text, HTMLBody: rendered.HTML}); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", data.Type, err)
+	}
+	return nil
+}
+
+// Email is one email sent by a MemoryMailer
+type Email struct {
+	To   string
+	Data TemplateData
+}
+
+// MemoryMailer keeps emails in memory so tests can assert on them
+type MemoryMailer struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+func (m *MemoryMailer) SendEmail(ctx context.Context, to string, data TemplateData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.emails = append(m.emails, Email{To: to, Data: data})
+	return nil
+}
+
+// Emails returns the emails sent so far, oldest first
+func (m *MemoryMailer) Emails() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Email(nil), m.emails...)
+}
@@ -0,0 +1,256 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+)
+
+func TestRendererRendersEveryEvent(t *testing.T) {
+	renderer, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+
+	data := map[string]any{
+		"at":        "1 Jan 2026 09:00 UTC",
+		"status":    models.AccountStatusSuspended,
+		"amount":    12.5,
+		"balance":   87.5,
+		"to":        "J*** D***",
+		"from":      "J*** D***",
+		"requester": "J*** D***",
+		"payer":     "A*** B***",
+		"memo":      "<b>Dinner</b>",
+		"level":     1,
+		"reason":    "Document is blurred",
+		"until":     "1 Jan 2033",
+	}
+	for _, eventType := range append(EventTypes, "unknown.event") {
+		t.Run(eventType, func(t *testing.T) {
+			rendered, err := renderer.Render(TemplateData{Name: "Jane", Type: eventType, Data: data})
+			if err != nil {
+				t.Fatalf("render failed: %v", err)
+			}
+			if rendered.Subject == "" || rendered.Text == "" {
+				t.Errorf("expected a subject and text, got %+v", rendered)
+			}
+			for _, part := range []string{rendered.Subject, rendered.Text, rendered.HTML} {
+				if strings.Contains(part, "<no value>") {
+					t.Errorf("expected every placeholder to be filled, got %q", part)
+				}
+			}
+			if !strings.Contains(rendered.HTML, "Hi Jane,") {
+				t.Errorf("expected the HTML to use the layout, got %q", rendered.HTML)
+			}
+			if strings.Contains(rendered.HTML, "<b>Dinner</b>") {
+				t.Errorf("expected event data to be escaped in HTML, got %q", rendered.HTML)
+			}
+		})
+	}
+
+	rendered, err := renderer.Render(TemplateData{Name: "Jane", Type: EventWithdrawal, Data: map[string]any{"amount": 20.0, "fee": 0.0, "balance": 80.0}})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if want := "20.00 was withdrawn from your account. Your balance is 80.00."; rendered.Text != want {
+		t.Errorf("expected %q, got %q", want, rendered.Text)
+	}
+}
+
+func newTestDispatcher(t *testing.T) (*Dispatcher, *memory.Store, *models.Account) {
+	t.Helper()
+	renderer, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	store := memory.NewStore()
+	account, err := store.Accounts().Create(context.Background(), "jane@example.com", "hash", "Jane", "Doe")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	return NewDispatcher(store.Accounts(), store.Notifications(), renderer), store, account
+}
+
+func deliveryChannels(t *testing.T, store *memory.Store, accountID int) []string {
+	t.Helper()
+	deliveries, err := store.Notifications().ListDeliveries(context.Background(), accountID, 100)
+	if err != nil {
+		t.Fatalf("failed to list deliveries: %v", err)
+	}
+	channels := make([]string, len(deliveries))
+	for i, d := range deliveries {
+		channels[i] = d.EventType + "/" + d.Channel
+	}
+	return channels
+}
+
+func TestDispatcherFollowsPreferences(t *testing.T) {
+	dispatcher, store, account := newTestDispatcher(t)
+	ctx := context.Background()
+	deposit := Event{AccountID: account.ID, Type: EventDeposit, Data: map[string]any{"amount": 10.0, "balance": 10.0}}
+
+	// Without saved preferences only email is sent
+	if err := dispatcher.Notify(ctx, deposit); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	if got := deliveryChannels(t, store, account.ID); len(got) != 1 || got[0] != "transaction.deposit/email" {
+		t.Fatalf("expected one email, got %v", got)
+	}
+
+	err := store.Notifications().SavePreferences(ctx, &models.NotificationPreferences{
+		AccountID:   account.ID,
+		SMSEnabled:  true,
+		PushEnabled: true,
+		Phone:       "+447700900123",
+		PushToken:   "device-token",
+		MutedEvents: []string{EventDeposit, EventLogin},
+	})
+	if err != nil {
+		t.Fatalf("failed to save preferences: %v", err)
+	}
+
+	if err := dispatcher.Notify(ctx, deposit); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	if err := dispatcher.Notify(ctx, Event{AccountID: account.ID, Type: EventTransferReceived, Data: map[string]any{"amount": 5.0, "from": "J*** D***"}}); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	if err := dispatcher.Notify(ctx, Event{AccountID: account.ID, Type: EventLogin, Data: map[string]any{"at": "now"}}); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+
+	// Newest first: the muted deposit is skipped, the transfer goes by SMS
+	// and push, and the login also goes by email despite being muted and
+	// email being off
+	want := []string{
+		"auth.login/push",
+		"auth.login/sms",
+		"auth.login/email",
+		"transfer.received/push",
+		"transfer.received/sms",
+		"transaction.deposit/email",
+	}
+	got := deliveryChannels(t, store, account.ID)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected deliveries %v, got %v", want, got)
+	}
+}
+
+func TestWorkerRetriesWithBackoff(t *testing.T) {
+	dispatcher, store, account := newTestDispatcher(t)
+	ctx := context.Background()
+	email := &MemoryChannel{Fail: errors.New("connection refused")}
+	worker := NewWorker(store.Notifications(), map[string]Channel{models.NotificationChannelEmail: email})
+
+	if err := dispatcher.Notify(ctx, Event{AccountID: account.ID, Type: EventLogin, Data: map[string]any{"at": "now"}}); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+
+	now := time.Now().Add(time.Second)
+	for attempt := 1; attempt < MaxAttempts; attempt++ {
+		sent, failed, err := worker.RunOnce(ctx, now)
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if sent != 0 || failed != 0 {
+			t.Fatalf("attempt %d: expected a retry, got %d sent and %d failed", attempt, sent, failed)
+		}
+		// Nothing is due again until the backoff has passed
+		if sent, _, _ := worker.RunOnce(ctx, now.Add(retryDelay<<(attempt-1)-time.Second)); sent != 0 {
+			t.Fatalf("attempt %d: expected the delivery to wait out its backoff", attempt)
+		}
+		now = now.Add(retryDelay << (attempt - 1))
+	}
+
+	email.Fail = nil
+	sent, failed, err := worker.RunOnce(ctx, now)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if sent != 1 || failed != 0 {
+		t.Fatalf("expected the last attempt to send, got %d sent and %d failed", sent, failed)
+	}
+	messages := email.Messages()
+	if len(messages) != 1 || messages[0].To != "jane@example.com" || messages[0].HTMLBody == "" {
+		t.Errorf("expected one HTML email to jane@example.com, got %+v", messages)
+	}
+
+	deliveries, _ := store.Notifications().ListDeliveries(ctx, account.ID, 10)
+	if deliveries[0].Status != models.NotificationSent || deliveries[0].Attempts != MaxAttempts || deliveries[0].SentAt == nil {
+		t.Errorf("expected a sent delivery after %d attempts, got %+v", MaxAttempts, deliveries[0])
+	}
+}
+
+func TestWorkerGivesUp(t *testing.T) {
+	dispatcher, store, account := newTestDispatcher(t)
+	ctx := context.Background()
+	// No email channel is configured, so every attempt fails
+	worker := NewWorker(store.Notifications(), map[string]Channel{})
+
+	if err := dispatcher.Notify(ctx, Event{AccountID: account.ID, Type: EventLogin, Data: map[string]any{"at": "now"}}); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+
+	now := time.Now().Add(time.Second)
+	failed := 0
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		_, f, err := worker.RunOnce(ctx, now)
+		if err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		failed += f
+		now = now.Add(retryDelay << (attempt - 1))
+	}
+	if failed != 1 {
+		t.Errorf("expected the delivery to be given up on once, got %d", failed)
+	}
+
+	deliveries, _ := store.Notifications().ListDeliveries(ctx, account.ID, 10)
+	if deliveries[0].Status != models.NotificationFailed || deliveries[0].LastError == "" {
+		t.Errorf("expected a failed delivery with its last error, got %+v", deliveries[0])
+	}
+	if sent, f, _ := worker.RunOnce(ctx, now.Add(24*time.Hour)); sent != 0 || f != 0 {
+		t.Errorf("expected a failed delivery not to be retried")
+	}
+}
+
+func TestTemplateMailerSendsVerificationEmail(t *testing.T) {
+	renderer, err := NewRenderer()
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	channel := &MemoryChannel{}
+	mailer := NewTemplateMailer(channel, renderer)
+
+	link := "https://bank.example/verify-email?token=abc.def"
+	err = mailer.SendEmail(context.Background(), "jane@example.com", TemplateData{
+		Name: "Jane",
+		Type: EmailVerifyAddress,
+		Data: map[string]any{"link": link, "expires_at": "2 Jan 2026 09:00 UTC"},
+	})
+	if err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	messages := channel.Messages()
+	if len(messages) != 1 || messages[0].To != "jane@example.com" {
+		t.Fatalf("expected one email to jane@example.com, got %+v", messages)
+	}
+	if messages[0].Subject != "Verify your email address" {
+		t.Errorf("expected the verification template, got subject %q", messages[0].Subject)
+	}
+	if !strings.Contains(messages[0].Body, link) || !strings.Contains(messages[0].HTMLBody, `href="`+link+`"`) {
+		t.Errorf("expected the link in both bodies, got %+v", messages[0])
+	}
+
+	channel.Fail = errors.New("connection refused")
+	if err := mailer.SendEmail(context.Background(), "jane@example.com", TemplateData{Type: EmailVerifyAddress}); err == nil {
+		t.Errorf("expected a channel failure to be returned")
+	}
+}
@@ -0,0 +1,95 @@
+// Package notifications tells account holders about things that happened
+// to their accounts. Services publish events to a Notifier after the change
+// is committed; delivery never fails the operation that raised the event.
+//
+// In production the Notifier is a Dispatcher, which renders each event from
+// its templates for the holder's chosen channels and queues the messages in
+// Postgres. A Worker sends them over email, SMS or push, retrying failures.
+package notifications
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Event is one thing an account holder should hear about
+type Event struct {
+	AccountID int
+	Type      string
+	// Data carries the event's details, e.g. the amount and memo of a
+	// payment request
+	Data map[string]any
+}
+
+// Notifier delivers events to account holders
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier writes events to the server log instead of delivering them
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, event Event) error {
+	log.Printf("notification for account %d: %s %v", event.AccountID, event.Type, event.Data)
+	return nil
+}
+
+// MemoryNotifier keeps events in memory so tests can assert on them
+type MemoryNotifier struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (n *MemoryNotifier) Notify(ctx context.Context, event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+// Events returns the events delivered so far, oldest first
+func (n *MemoryNotifier) Events() []Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Event(nil), n.events...)
+}
+
+// Payment request events
+const (
+	EventPaymentRequested        = "payment_request.created"
+	EventPaymentRequestPaid      = "payment_request.paid"
+	EventPaymentRequestDeclined  = "payment_request.declined"
+	EventPaymentRequestCancelled = "payment_request.cancelled"
+	EventPaymentRequestExpired   = "payment_request.expired"
+)
+
+// Account events
+const (
+	EventLogin                = "auth.login"
+	EventAccountStatusChanged = "account.status_changed"
+	EventAccountClosed        = "account.closed"
+	EventDeposit              = "transaction.deposit"
+	EventWithdrawal           = "transaction.withdrawal"
+	EventTransferSent         = "transfer.sent"
+	EventTransferReceived     = "transfer.received"
+	EventLowBalance           = "alert.low_balance"
+	EventLargeTransaction     = "alert.large_transaction"
+	EventKYCApproved          = "kyc.approved"
+	EventKYCRejected          = "kyc.rejected"
+)
+
+// EventTypes lists every event an account holder can hear about
+var EventTypes = []string{
+	EventLogin, EventAccountStatusChanged, EventAccountClosed,
+	EventDeposit, EventWithdrawal, EventTransferSent, EventTransferReceived,
+	EventLowBalance, EventLargeTransaction, EventKYCApproved, EventKYCRejected,
+	EventPaymentRequested, EventPaymentRequestPaid, EventPaymentRequestDeclined, EventPaymentRequestCancelled,
+	EventPaymentRequestExpired,
+}
+
+// IsSecurityEvent reports whether an event cannot be muted. Security events
+// always go out by email, whatever the holder's preferences.
+func IsSecurityEvent(eventType string) bool {
+	return eventType == EventLogin || eventType == EventAccountStatusChanged || eventType == EventAccountClosed
+}
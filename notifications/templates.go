@@ -0,0 +1,130 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+const (
+	layoutTemplate  = "layout.tmpl"
+	defaultTemplate = "default"
+)
+
+// TemplateData is what event templates are executed with
+type TemplateData struct {
+	// Name is the account holder's first name
+	Name string
+	Type string
+	Data map[string]any
+}
+
+// Rendered is an event turned into words. Text is short enough for SMS and
+// push; HTML is a full email document.
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Renderer renders events with the embedded templates. Each event type has
+// a file templates/<type>.tmpl defining "subject", "text" and "body" (the
+// HTML inside the shared layout); events without one use default.tmpl.
+type Renderer struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+var templateFuncs = map[string]any{
+	"money": money,
+}
+
+func NewRenderer() (*Renderer, error) {
+	layout, err := fs.ReadFile(templateFiles, "templates/"+layoutTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification layout: %w", err)
+	}
+	base, err := htmltemplate.New(layoutTemplate).Funcs(templateFuncs).Parse(string(layout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification layout: %w", err)
+	}
+
+	names, err := fs.Glob(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, name := range names {
+		eventType := strings.TrimSuffix(strings.TrimPrefix(name, "templates/"), ".tmpl")
+		if eventType+".tmpl" == layoutTemplate {
+			continue
+		}
+		src, err := fs.ReadFile(templateFiles, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+
+		text, err := texttemplate.New(eventType).Funcs(templateFuncs).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		html, err := htmltemplate.Must(base.Clone()).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		r.text[eventType] = text
+		r.html[eventType] = html
+	}
+	if _, ok := r.text[defaultTemplate]; !ok {
+		return nil, fmt.Errorf("missing %s notification template", defaultTemplate)
+	}
+	return r, nil
+}
+
+func (r *Renderer) Render(data TemplateData) (*Rendered, error) {
+	name := data.Type
+	if _, ok := r.text[name]; !ok {
+		name = defaultTemplate
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := r.text[name].ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", data.Type, err)
+	}
+	if err := r.text[name].ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", data.Type, err)
+	}
+	if err := r.html[name].ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %w", data.Type, err)
+	}
+	return &Rendered{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    html.String(),
+	}, nil
+}
+
+// money formats an amount from event data, which may hold any number type
+func money(v any) string {
+	switch n := v.(type) {
+	case float64:
+		return fmt.Sprintf("%.2f", n)
+	case float32:
+		return fmt.Sprintf("%.2f", n)
+	case int:
+		return fmt.Sprintf("%d.00", n)
+	default:
+		return fmt.Sprint(v)
+	}
+}
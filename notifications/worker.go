@@ -0,0 +1,82 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked failed
+	MaxAttempts = 5
+	// claimLease hides a claimed delivery from other workers while it is
+	// being sent; a worker that dies mid-send leaves it to be retried
+	claimLease = 5 * time.Minute
+	claimBatch = 50
+	retryDelay = time.Minute
+)
+
+// Worker sends queued deliveries through the configured channels, retrying
+// failures with exponential backoff
+type Worker struct {
+	store    repository.NotificationStore
+	channels map[string]Channel
+}
+
+// NewWorker sends deliveries for each channel name (models.NotificationChannel*)
+// through the given Channel. Deliveries for a channel without one fail.
+func NewWorker(store repository.NotificationStore, channels map[string]Channel) *Worker {
+	return &Worker{store: store, channels: channels}
+}
+
+// RunOnce sends the deliveries due at now and reports how many were sent
+// and how many were given up on
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) (sent, failed int, err error) {
+	for {
+		deliveries, err := w.store.ClaimDue(ctx, now, claimLease, claimBatch)
+		if err != nil {
+			return sent, failed, fmt.Errorf("failed to claim notifications: %w", err)
+		}
+		for _, d := range deliveries {
+			ok, err := w.deliver(ctx, d, now)
+			if err != nil {
+				return sent, failed, err
+			}
+			switch {
+			case ok:
+				sent++
+			case d.Attempts >= MaxAttempts:
+				failed++
+			}
+		}
+		if len(deliveries) < claimBatch {
+			return sent, failed, nil
+		}
+	}
+}
+
+// deliver sends one claimed delivery and records the outcome. The error is
+// only for failing to record it.
+func (w *Worker) deliver(ctx context.Context, d *models.NotificationDelivery, now time.Time) (bool, error) {
+	sendErr := fmt.Errorf("no %s channel configured", d.Channel)
+	if channel, ok := w.channels[d.Channel]; ok {
+		sendErr = channel.Send(ctx, Message{To: d.Recipient, Subject: d.Subject, Body: d.Body, HTMLBody: d.HTMLBody})
+	}
+	if sendErr == nil {
+		return true, w.store.MarkSent(ctx, d.ID, time.Now())
+	}
+
+	var retryAt *time.Time
+	if d.Attempts < MaxAttempts {
+		at := now.Add(retryDelay << (d.Attempts - 1))
+		retryAt = &at
+	} else {
+		log.Printf("notification %d: giving up on %s after %d attempts: %v", d.ID, d.Channel, d.Attempts, sendErr)
+	}
+	return false, w.store.MarkFailed(ctx, d.ID, sendErr.Error(), retryAt)
+}
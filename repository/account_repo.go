@@ -1,14 +1,21 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/wizzyszn/go_bank/db"
 	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/utils"
 )
 
+// maxAccountNumberAttempts bounds retries when a generated account number
+// is already taken
+const maxAccountNumberAttempts = 5
+
 type AccountRepository struct {
 	db *db.DB
 }
@@ -19,62 +26,280 @@ func NewAccountRepository(database *db.DB) *AccountRepository {
 	}
 }
 
-func (r *AccountRepository) Create(email, passwordHash, firstName, lastName string) (*models.Account, error) {
+func (r *AccountRepository) Create(ctx context.Context, email, passwordHash, firstName, lastName string) (*models.Account, error) {
 
 	query := `
-	INSERT INTO accounts (email,password_hash,first_name,last_name,balance,currency,status)
-	VALUES ($1,$2,$3,$4,$5,$6,$7)
-	RETURNING id,email,first_name,last_name,balance,currency,status,created_at,updated_at
+	INSERT INTO accounts (account_number,email,password_hash,first_name,last_name,balance,currency,status)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	RETURNING id,account_number,product,email,first_name,last_name,balance,overdraft_limit,currency,status,created_at,updated_at
 	`
-	account := &models.Account{}
+	ctx, span := startSpan(ctx, "AccountRepository.Create", query)
+	defer span.End()
+
+	// A freshly generated number can collide with an existing one; draw
+	// another rather than failing the registration. Each attempt gets a
+	// savepoint so a collision doesn't abort the caller's transaction.
+	for attempt := 1; ; attempt++ {
+		accountNumber, err := utils.GenerateAccountNumber()
+		if err != nil {
+			return nil, err
+		}
 
-	err := r.db.QueryRow(query, email, passwordHash, firstName, lastName, 0.00, "USD", models.AccountStatusActice).Scan(&account.ID, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create account: %w", err)
+		account := &models.Account{}
+		err = r.db.WithSavepoint(ctx, "create_account", func(ctx context.Context) error {
+			return r.db.Conn(ctx).QueryRowContext(ctx, query, accountNumber, email, passwordHash, firstName, lastName, 0.00, "USD", models.AccountStatusActice).Scan(&account.ID, &account.AccountNumber, &account.Product, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.OverdraftLimit, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
+		})
+		if isUniqueViolationOn(err, "accounts_account_number_key") && attempt < maxAccountNumberAttempts {
+			continue
+		}
+		if err != nil {
+			span.RecordError(err)
+			if isUniqueViolation(err) {
+				return nil, fmt.Errorf("failed to create account: %w", ErrDuplicate)
+			}
+			return nil, fmt.Errorf("failed to create account: %w", err)
+		}
+		return account, nil
 	}
-
-	return account, nil
 }
 
-func (r *AccountRepository) GetByID(id int) (*models.Account, error) {
+func (r *AccountRepository) GetByID(ctx context.Context, id int) (*models.Account, error) {
 	query := `
-	SELECT id,email,first_name,last_name,balance,currency,status,created_at,updated_at
+	SELECT id,account_number,product,email,first_name,last_name,balance,overdraft_limit,currency,status,created_at,updated_at
 	FROM accounts
 	WHERE id = $1
 	`
+	ctx, span := startSpan(ctx, "AccountRepository.GetByID", query)
+	defer span.End()
+
 	account := &models.Account{}
-	err := r.db.QueryRow(query, id).Scan(&account.ID, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&account.ID, &account.AccountNumber, &account.Product, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.OverdraftLimit, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get an account: %w", ErrNotFound)
+	}
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get an account: %w", err)
 	}
 	return account, nil
 }
 
-func (r *AccountRepository) GeyByEmail(email string) (*models.Account, error) {
+func (r *AccountRepository) GeyByEmail(ctx context.Context, email string) (*models.Account, error) {
 	query := `
-	SELECT id,email,first_name,last_name,balance,currency,status,created_at,updated_at
+	SELECT id,account_number,product,email,password_hash,first_name,last_name,balance,overdraft_limit,currency,status,created_at,updated_at
 	FROM accounts
 	WHERE email = $1
 	`
+	ctx, span := startSpan(ctx, "AccountRepository.GeyByEmail", query)
+	defer span.End()
+
 	account := &models.Account{}
-	err := r.db.QueryRow(query, email).Scan(&account.ID, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, email).Scan(&account.ID, &account.AccountNumber, &account.Product, &account.Email, &account.PasswordHash, &account.FirstName, &account.LastName, &account.Balance, &account.OverdraftLimit, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
 
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get an account: %w", ErrNotFound)
+	}
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get an account: %w", err)
 	}
 
 	return account, nil
 }
 
-func (r *AccountRepository) Update(id int, firstName, lastName string) error {
+func (r *AccountRepository) GetByAccountNumber(ctx context.Context, accountNumber string) (*models.Account, error) {
+	query := `
+	SELECT id,account_number,product,email,first_name,last_name,balance,overdraft_limit,currency,status,created_at,updated_at
+	FROM accounts
+	WHERE account_number = $1
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.GetByAccountNumber", query)
+	defer span.End()
+
+	account := &models.Account{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountNumber).Scan(&account.ID, &account.AccountNumber, &account.Product, &account.Email, &account.FirstName, &account.LastName, &account.Balance, &account.OverdraftLimit, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get an account: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get an account: %w", err)
+	}
+	return account, nil
+}
+
+func (r *AccountRepository) GetAccountNumbers(ctx context.Context, ids []int) (map[int]string, error) {
+	query := `SELECT id, account_number FROM accounts WHERE id = ANY($1)`
+	ctx, span := startSpan(ctx, "AccountRepository.GetAccountNumbers", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get account numbers: %w", err)
+	}
+	defer rows.Close()
+
+	numbers := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var number string
+		if err := rows.Scan(&id, &number); err != nil {
+			return nil, fmt.Errorf("failed to scan account number: %w", err)
+		}
+		numbers[id] = number
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accounts: %w", err)
+	}
+	return numbers, nil
+}
+
+// SetProduct moves an account onto another product, e.g. checking to savings
+func (r *AccountRepository) SetProduct(ctx context.Context, id int, product string) error {
+	query := `
+	UPDATE accounts
+	SET product = $1, updated_at = $2
+	WHERE id = $3
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.SetProduct", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, product, time.Now(), id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to set account product: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("account not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// SetOverdraftLimit changes the approved overdraft. The balance check
+// rejects a limit below what the account is already overdrawn by.
+func (r *AccountRepository) SetOverdraftLimit(ctx context.Context, id int, limit float64) error {
+	query := `
+	UPDATE accounts
+	SET overdraft_limit = $1, updated_at = $2
+	WHERE id = $3
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.SetOverdraftLimit", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, limit, time.Now(), id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to set overdraft limit: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("account not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *AccountRepository) SetStatus(ctx context.Context, id int, status string) error {
+	query := `
+	UPDATE accounts
+	SET status = $1, updated_at = $2
+	WHERE id = $3
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.SetStatus", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, status, time.Now(), id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to set account status: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("account not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// ListIDsByProduct returns the IDs of the product's accounts that are not closed
+func (r *AccountRepository) ListIDsByProduct(ctx context.Context, product string) ([]int, error) {
+	query := `
+	SELECT id FROM accounts
+	WHERE product = $1 AND status != $2
+	ORDER BY id
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.ListIDsByProduct", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, product, models.AccountStatusClosed)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list accounts by product: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan account id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accounts: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *AccountRepository) ListOpenIDs(ctx context.Context) ([]int, error) {
+	query := `SELECT id FROM accounts WHERE status != $1 ORDER BY id`
+	ctx, span := startSpan(ctx, "AccountRepository.ListOpenIDs", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, models.AccountStatusClosed)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan account id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accounts: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *AccountRepository) Update(ctx context.Context, id int, firstName, lastName string) error {
 	query := `
 	UPDATE accounts
 	SET first_name = $1 ,last_name = $2, updated_at = $3
 	WHERE id = $4
 	`
-	result, err := r.db.Exec(query, firstName, lastName, time.Now(), id)
+	ctx, span := startSpan(ctx, "AccountRepository.Update", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, firstName, lastName, time.Now(), id)
 
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to update account: %w", err)
 	}
 
@@ -83,28 +308,25 @@ func (r *AccountRepository) Update(id int, firstName, lastName string) error {
 		return fmt.Errorf("failed to check update result: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("account not found")
+		return fmt.Errorf("account not found: %w", ErrNotFound)
 	}
 
 	return nil
 }
 
-func (r *AccountRepository) UpdateBalance(tx *sql.Tx, accountID int, newBalace float64) error {
+func (r *AccountRepository) UpdateBalance(ctx context.Context, accountID int, newBalace float64) error {
 
 	query := `
 	UPDATE accounts
 	SET balance = $1 , updated_at = $2
 	WHERE id = $3
 	`
-	var err error
-	var result sql.Result
+	ctx, span := startSpan(ctx, "AccountRepository.UpdateBalance", query)
+	defer span.End()
 
-	if tx != nil {
-		result, err = tx.Exec(query, newBalace, time.Now(), accountID)
-	} else {
-		result, err = r.db.Exec(query, newBalace, time.Now(), accountID)
-	}
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, newBalace, time.Now(), accountID)
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to update balance: %w", err)
 	}
 	rowsAffected, err := result.RowsAffected()
@@ -113,92 +335,168 @@ func (r *AccountRepository) UpdateBalance(tx *sql.Tx, accountID int, newBalace f
 
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("account not found %w", err)
+		return fmt.Errorf("account not found: %w", ErrNotFound)
 	}
 	return nil
 }
 
-func (r *AccountRepository) GetBalanceForUpdate(tx *sql.Tx, accountID int) (float64, error) {
+// GetBalanceForUpdate locks the account row until the surrounding transaction ends
+func (r *AccountRepository) GetBalanceForUpdate(ctx context.Context, accountID int) (float64, error) {
 	query := `
 	SELECT balance 
 	FROM accounts
 	WHERE id = $1
 	FOR NO KEY UPDATE
 	`
+	ctx, span := startSpan(ctx, "AccountRepository.GetBalanceForUpdate", query)
+	defer span.End()
+
 	var balance float64
 
-	err := tx.QueryRow(query, accountID).Scan(&balance)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(&balance)
 
 	if err == sql.ErrNoRows {
-		return 0, fmt.Errorf("No account found")
+		return 0, fmt.Errorf("No account found: %w", ErrNotFound)
 	}
 
 	if err != nil {
+		span.RecordError(err)
 		return 0, fmt.Errorf("failed to get balance %w", err)
 	}
 
 	return balance, nil
 }
 
-func (r *AccountRepository) EmailExists(email string) (bool, error) {
+// GetFundsForUpdate is GetBalanceForUpdate that also returns the overdraft
+// limit, read under the same lock
+func (r *AccountRepository) GetFundsForUpdate(ctx context.Context, accountID int) (float64, float64, error) {
+	query := `
+	SELECT balance, overdraft_limit
+	FROM accounts
+	WHERE id = $1
+	FOR NO KEY UPDATE
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.GetFundsForUpdate", query)
+	defer span.End()
+
+	var balance, overdraftLimit float64
+
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(&balance, &overdraftLimit)
+
+	if err == sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("No account found: %w", ErrNotFound)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		return 0, 0, fmt.Errorf("failed to get balance %w", err)
+	}
+
+	return balance, overdraftLimit, nil
+}
+
+func (r *AccountRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	query := `
 	SELECT EXISTS(
 	SELECT 1 FROM accounts
 	WHERE email = $1
 	)
 	`
+	ctx, span := startSpan(ctx, "AccountRepository.EmailExists", query)
+	defer span.End()
+
 	var exists bool
 
-	err := r.db.QueryRow(query, email).Scan(&exists)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, email).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("account not found: %w", err)
+		span.RecordError(err)
+		return false, fmt.Errorf("failed to check email: %w", err)
 	}
 	return exists, nil
 }
 
-func (r *AccountRepository) Delete(id int) error {
+// Close marks the account closed. The row and its history are kept.
+func (r *AccountRepository) Close(ctx context.Context, id int) error {
 	query := `
-	INSERT accounts
-	SET status = $1 , updated_at = $2
+	UPDATE accounts
+	SET status = $1, updated_at = $2
 	WHERE id = $3
 	`
+	ctx, span := startSpan(ctx, "AccountRepository.Close", query)
+	defer span.End()
 
-	result, err := r.db.Exec(query, models.AccountStatusClosed, time.Now(), id)
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, models.AccountStatusClosed, time.Now(), id)
 
 	if err != nil {
-		return fmt.Errorf("Failed to delete account: %w", err)
+		span.RecordError(err)
+		return fmt.Errorf("Failed to close account: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("Failed to check delete result: %w", err)
+		return fmt.Errorf("Failed to check close result: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("No account found")
+		return fmt.Errorf("No account found: %w", ErrNotFound)
 	}
 
 	return nil
 }
 
-func (r *AccountRepository) List(page, limit int) ([]*models.Account, int, error) {
+// Anonymize replaces a closed account's email, names and password hash.
+// The email becomes a unique placeholder so the address can be registered
+// again. It fails with ErrNotFound unless the account is closed.
+func (r *AccountRepository) Anonymize(ctx context.Context, id int) error {
+	query := `
+	UPDATE accounts
+	SET email = 'anonymized-' || id || '@anonymized.invalid', password_hash = '', first_name = '', last_name = '',
+		updated_at = $1
+	WHERE id = $2 AND status = $3
+	`
+	ctx, span := startSpan(ctx, "AccountRepository.Anonymize", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, time.Now(), id, models.AccountStatusClosed)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to anonymize account: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("closed account not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *AccountRepository) List(ctx context.Context, page, limit int) ([]*models.Account, int, error) {
 	offset := (page - 1) * limit
 	var totalCount int
 	countQuery := `
-	SELECT * FROM accounts WHERE status != $1
+	SELECT COUNT(*) FROM accounts WHERE status != $1
 	`
-	err := r.db.QueryRow(countQuery, models.AccountStatusClosed).Scan(&totalCount)
+	countCtx, countSpan := startSpan(ctx, "AccountRepository.List.count", countQuery)
+	err := r.db.Conn(countCtx).QueryRowContext(countCtx, countQuery, models.AccountStatusClosed).Scan(&totalCount)
+	countSpan.RecordError(err)
+	countSpan.End()
 	if err != nil {
 		return nil, 0, fmt.Errorf("Failed to get total count: %w", err)
 	}
 	query := `
-	SELECT id, email, password_hash, first_name, last_name, balance, currency, status, created_at, updated_at
+	SELECT id, account_number, product, email, password_hash, first_name, last_name, balance, overdraft_limit, currency, status, created_at, updated_at
 	FROM accounts
 	WHERE status != $1
 	ORDER BY created_at DESC
 	LIMIT $2 OFFSET $3
 	`
-	rows, err := r.db.Query(query, models.AccountStatusClosed, limit, offset)
+	ctx, span := startSpan(ctx, "AccountRepository.List", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, models.AccountStatusClosed, limit, offset)
 	if err != nil {
+		span.RecordError(err)
 		return nil, 0, fmt.Errorf("failed to list accounts: %w", err)
 	}
 	defer rows.Close()
@@ -207,7 +505,7 @@ func (r *AccountRepository) List(page, limit int) ([]*models.Account, int, error
 
 	for rows.Next() {
 		account := &models.Account{}
-		err := rows.Scan(&account.ID, account.FirstName, account.LastName, account.Balance, account.Currency, account.Email, account.PasswordHash, account.Status, account.CreatedAt, account.UpdatedAt)
+		err := rows.Scan(&account.ID, &account.AccountNumber, &account.Product, &account.Email, &account.PasswordHash, &account.FirstName, &account.LastName, &account.Balance, &account.OverdraftLimit, &account.Currency, &account.Status, &account.CreatedAt, &account.UpdatedAt)
 
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan account: %w", err)
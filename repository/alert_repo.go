@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type AlertRepository struct {
+	db *db.DB
+}
+
+func NewAlertRepository(db *db.DB) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const alertColumns = `id, account_id, type, threshold, triggered, created_at, last_triggered_at`
+
+func (r *AlertRepository) Create(ctx context.Context, rule *models.AlertRule) (*models.AlertRule, error) {
+	query := `
+	INSERT INTO alert_rules (account_id, type, threshold, triggered)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + alertColumns
+	ctx, span := startSpan(ctx, "AlertRepository.Create", query)
+	defer span.End()
+
+	created, err := scanAlertRule(r.db.Conn(ctx).QueryRowContext(ctx, query, rule.AccountID, rule.Type, rule.Threshold, rule.Triggered))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create alert rule: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return created, nil
+}
+
+func (r *AlertRepository) GetByID(ctx context.Context, id int) (*models.AlertRule, error) {
+	query := `SELECT ` + alertColumns + ` FROM alert_rules WHERE id = $1`
+	ctx, span := startSpan(ctx, "AlertRepository.GetByID", query)
+	defer span.End()
+
+	rule, err := scanAlertRule(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert rule not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (r *AlertRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.AlertRule, error) {
+	query := `
+	SELECT ` + alertColumns + `
+	FROM alert_rules
+	WHERE account_id = $1
+	ORDER BY type, threshold, id
+	`
+	ctx, span := startSpan(ctx, "AlertRepository.ListByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alert rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *AlertRepository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM alert_rules WHERE id = $1`
+	ctx, span := startSpan(ctx, "AlertRepository.Delete", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("alert rule not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *AlertRepository) SetTriggered(ctx context.Context, id int, triggered bool, at time.Time) (bool, error) {
+	query := `
+	UPDATE alert_rules
+	SET triggered = $2, last_triggered_at = CASE WHEN $2 THEN $3 ELSE last_triggered_at END
+	WHERE id = $1 AND triggered <> $2
+	`
+	ctx, span := startSpan(ctx, "AlertRepository.SetTriggered", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id, triggered, at)
+	if err != nil {
+		span.RecordError(err)
+		return false, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *AlertRepository) RecordTriggered(ctx context.Context, id int, at time.Time) error {
+	query := `UPDATE alert_rules SET last_triggered_at = $2 WHERE id = $1`
+	ctx, span := startSpan(ctx, "AlertRepository.RecordTriggered", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, id, at); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	return nil
+}
+
+// scanAlertRule reads a row of alertColumns. sql.ErrNoRows is returned
+// unwrapped.
+func scanAlertRule(row rowScanner) (*models.AlertRule, error) {
+	rule := &models.AlertRule{}
+	err := row.Scan(&rule.ID, &rule.AccountID, &rule.Type, &rule.Threshold, &rule.Triggered, &rule.CreatedAt, &rule.LastTriggeredAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
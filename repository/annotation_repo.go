@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type AnnotationRepository struct {
+	db *db.DB
+}
+
+func NewAnnotationRepository(db *db.DB) *AnnotationRepository {
+	return &AnnotationRepository{db: db}
+}
+
+const annotationColumns = `account_id, transaction_id, note, tags, updated_at`
+
+const attachmentColumns = `id, account_id, transaction_id, file_name, content_type, size_bytes, sha256, storage_key, uploaded_at`
+
+func (r *AnnotationRepository) Save(ctx context.Context, annotation *models.TransactionAnnotation) (*models.TransactionAnnotation, error) {
+	query := `
+	INSERT INTO transaction_annotations (account_id, transaction_id, note, tags)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (account_id, transaction_id)
+	DO UPDATE SET note = EXCLUDED.note, tags = EXCLUDED.tags, updated_at = CURRENT_TIMESTAMP
+	RETURNING ` + annotationColumns
+	ctx, span := startSpan(ctx, "AnnotationRepository.Save", query)
+	defer span.End()
+
+	saved, err := scanAnnotation(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		annotation.AccountID, annotation.TransactionID, annotation.Note, pq.Array(annotation.Tags),
+	))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to save annotation: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *AnnotationRepository) Get(ctx context.Context, accountID, transactionID int) (*models.TransactionAnnotation, error) {
+	query := `SELECT ` + annotationColumns + ` FROM transaction_annotations WHERE account_id = $1 AND transaction_id = $2`
+	ctx, span := startSpan(ctx, "AnnotationRepository.Get", query)
+	defer span.End()
+
+	annotation, err := scanAnnotation(r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, transactionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("annotation not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get annotation: %w", err)
+	}
+	return annotation, nil
+}
+
+func (r *AnnotationRepository) Delete(ctx context.Context, accountID, transactionID int) error {
+	query := `DELETE FROM transaction_annotations WHERE account_id = $1 AND transaction_id = $2`
+	ctx, span := startSpan(ctx, "AnnotationRepository.Delete", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, transactionID); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete annotation: %w", err)
+	}
+	return nil
+}
+
+func (r *AnnotationRepository) ListByTransactions(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAnnotation, error) {
+	query := `
+	SELECT ` + annotationColumns + `
+	FROM transaction_annotations
+	WHERE account_id = $1 AND transaction_id = ANY($2)
+	ORDER BY transaction_id
+	`
+	ctx, span := startSpan(ctx, "AnnotationRepository.ListByTransactions", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, pq.Array(transactionIDs))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := make([]*models.TransactionAnnotation, 0)
+	for rows.Next() {
+		annotation, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan annotation: %w", err)
+		}
+		annotations = append(annotations, annotation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+func (r *AnnotationRepository) AddAttachment(ctx context.Context, attachment *models.TransactionAttachment) (*models.TransactionAttachment, error) {
+	query := `
+	INSERT INTO transaction_attachments (account_id, transaction_id, file_name, content_type, size_bytes, sha256, storage_key)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING ` + attachmentColumns
+	ctx, span := startSpan(ctx, "AnnotationRepository.AddAttachment", query)
+	defer span.End()
+
+	created, err := scanAttachment(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		attachment.AccountID, attachment.TransactionID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.SHA256, attachment.StorageKey,
+	))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to add attachment: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to add attachment: %w", err)
+	}
+	return created, nil
+}
+
+func (r *AnnotationRepository) GetAttachment(ctx context.Context, id int) (*models.TransactionAttachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM transaction_attachments WHERE id = $1`
+	ctx, span := startSpan(ctx, "AnnotationRepository.GetAttachment", query)
+	defer span.End()
+
+	attachment, err := scanAttachment(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("attachment not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (r *AnnotationRepository) ListAttachments(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAttachment, error) {
+	query := `
+	SELECT ` + attachmentColumns + `
+	FROM transaction_attachments
+	WHERE account_id = $1 AND transaction_id = ANY($2)
+	ORDER BY uploaded_at, id
+	`
+	ctx, span := startSpan(ctx, "AnnotationRepository.ListAttachments", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, pq.Array(transactionIDs))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := make([]*models.TransactionAttachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+func (r *AnnotationRepository) DeleteAttachment(ctx context.Context, id int) error {
+	query := `DELETE FROM transaction_attachments WHERE id = $1`
+	ctx, span := startSpan(ctx, "AnnotationRepository.DeleteAttachment", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("attachment not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *AnnotationRepository) DeleteByAccount(ctx context.Context, accountID int) ([]string, error) {
+	query := `
+	WITH annotations AS (
+		DELETE FROM transaction_annotations WHERE account_id = $1
+	)
+	DELETE FROM transaction_attachments
+	WHERE account_id = $1
+	RETURNING storage_key
+	`
+	ctx, span := startSpan(ctx, "AnnotationRepository.DeleteByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to delete annotations: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan storage key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+	return keys, nil
+}
+
+// scanAnnotation reads a row of annotationColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanAnnotation(row rowScanner) (*models.TransactionAnnotation, error) {
+	annotation := &models.TransactionAnnotation{}
+	err := row.Scan(&annotation.AccountID, &annotation.TransactionID, &annotation.Note, pq.Array(&annotation.Tags), &annotation.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return annotation, nil
+}
+
+// scanAttachment reads a row of attachmentColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanAttachment(row rowScanner) (*models.TransactionAttachment, error) {
+	attachment := &models.TransactionAttachment{}
+	err := row.Scan(&attachment.ID, &attachment.AccountID, &attachment.TransactionID, &attachment.FileName,
+		&attachment.ContentType, &attachment.Size, &attachment.SHA256, &attachment.StorageKey, &attachment.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
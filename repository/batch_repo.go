@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type BatchRepository struct {
+	db *db.DB
+}
+
+func NewBatchRepository(db *db.DB) *BatchRepository {
+	return &BatchRepository{db: db}
+}
+
+// Create inserts a pending batch and its items. Call it inside a transaction
+// so a batch is never visible without all of its rows.
+func (r *BatchRepository) Create(ctx context.Context, accountID int, mode string, totalAmount float64, items []*models.TransferBatchItem) (*models.TransferBatch, error) {
+	query := `
+	INSERT INTO transfer_batches (account_id, mode, status, total_amount, item_count)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, account_id, mode, status, total_amount, item_count, succeeded_count, failed_count, created_at, completed_at
+	`
+	ctx, span := startSpan(ctx, "BatchRepository.Create", query)
+	defer span.End()
+
+	batch := &models.TransferBatch{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, mode, models.BatchStatusPending, totalAmount, len(items)).Scan(
+		&batch.ID, &batch.AccountID, &batch.Mode, &batch.Status, &batch.TotalAmount, &batch.ItemCount,
+		&batch.SucceededCount, &batch.FailedCount, &batch.CreatedAt, &batch.CompletedAt,
+	)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	itemQuery := `
+	INSERT INTO transfer_batch_items (batch_id, row_number, to_account_id, amount, description, status)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`
+	for _, item := range items {
+		itemCtx, itemSpan := startSpan(ctx, "BatchRepository.Create.item", itemQuery)
+		err := r.db.Conn(itemCtx).QueryRowContext(itemCtx, itemQuery, batch.ID, item.Row, item.ToAccountID, item.Amount, item.Description, models.BatchItemStatusPending).Scan(&item.ID)
+		itemSpan.RecordError(err)
+		itemSpan.End()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch item %d: %w", item.Row, err)
+		}
+		item.BatchID = batch.ID
+		item.Status = models.BatchItemStatusPending
+	}
+	batch.Items = items
+
+	return batch, nil
+}
+
+// GetByID returns a batch with its items in row order
+func (r *BatchRepository) GetByID(ctx context.Context, id int) (*models.TransferBatch, error) {
+	query := `
+	SELECT id, account_id, mode, status, total_amount, item_count, succeeded_count, failed_count, created_at, completed_at
+	FROM transfer_batches
+	WHERE id = $1
+	`
+	ctx, span := startSpan(ctx, "BatchRepository.GetByID", query)
+	defer span.End()
+
+	batch := &models.TransferBatch{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(
+		&batch.ID, &batch.AccountID, &batch.Mode, &batch.Status, &batch.TotalAmount, &batch.ItemCount,
+		&batch.SucceededCount, &batch.FailedCount, &batch.CreatedAt, &batch.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("batch not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	itemQuery := `
+	SELECT id, batch_id, row_number, to_account_id, amount, description, status, transaction_id, error_code, error_message
+	FROM transfer_batch_items
+	WHERE batch_id = $1
+	ORDER BY row_number
+	`
+	itemCtx, itemSpan := startSpan(ctx, "BatchRepository.GetByID.items", itemQuery)
+	defer itemSpan.End()
+
+	rows, err := r.db.Conn(itemCtx).QueryContext(itemCtx, itemQuery, id)
+	if err != nil {
+		itemSpan.RecordError(err)
+		return nil, fmt.Errorf("failed to get batch items: %w", err)
+	}
+	defer rows.Close()
+
+	batch.Items = make([]*models.TransferBatchItem, 0, batch.ItemCount)
+	for rows.Next() {
+		item := &models.TransferBatchItem{}
+		var errorCode, errorMessage sql.NullString
+		err := rows.Scan(&item.ID, &item.BatchID, &item.Row, &item.ToAccountID, &item.Amount, &item.Description, &item.Status, &item.TransactionID, &errorCode, &errorMessage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan batch item: %w", err)
+		}
+		item.ErrorCode = errorCode.String
+		item.ErrorMessage = errorMessage.String
+		batch.Items = append(batch.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batch items: %w", err)
+	}
+
+	return batch, nil
+}
+
+// UpdateItem records the outcome of one row
+func (r *BatchRepository) UpdateItem(ctx context.Context, item *models.TransferBatchItem) error {
+	query := `
+	UPDATE transfer_batch_items
+	SET status = $1, transaction_id = $2, error_code = NULLIF($3, ''), error_message = NULLIF($4, '')
+	WHERE id = $5
+	`
+	ctx, span := startSpan(ctx, "BatchRepository.UpdateItem", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, item.Status, item.TransactionID, item.ErrorCode, item.ErrorMessage, item.ID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update batch item: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("batch item not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateStatus moves a batch to status with the given item counts.
+// completedAt is nil while the batch is still running.
+func (r *BatchRepository) UpdateStatus(ctx context.Context, id int, status string, succeeded, failed int, completedAt *time.Time) error {
+	query := `
+	UPDATE transfer_batches
+	SET status = $1, succeeded_count = $2, failed_count = $3, completed_at = $4
+	WHERE id = $5
+	`
+	ctx, span := startSpan(ctx, "BatchRepository.UpdateStatus", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, status, succeeded, failed, completedAt, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update batch: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("batch not found: %w", ErrNotFound)
+	}
+	return nil
+}
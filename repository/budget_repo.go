@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type BudgetRepository struct {
+	db *db.DB
+}
+
+func NewBudgetRepository(db *db.DB) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+func (r *BudgetRepository) Save(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	query := `
+	INSERT INTO budgets (account_id, category, monthly_limit)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (account_id, category)
+	DO UPDATE SET monthly_limit = EXCLUDED.monthly_limit, updated_at = CURRENT_TIMESTAMP
+	RETURNING id, account_id, category, monthly_limit, created_at, updated_at
+	`
+	ctx, span := startSpan(ctx, "BudgetRepository.Save", query)
+	defer span.End()
+
+	saved := &models.Budget{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, budget.AccountID, budget.Category, budget.MonthlyLimit).
+		Scan(&saved.ID, &saved.AccountID, &saved.Category, &saved.MonthlyLimit, &saved.CreatedAt, &saved.UpdatedAt)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to save budget: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *BudgetRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Budget, error) {
+	query := `
+	SELECT id, account_id, category, monthly_limit, created_at, updated_at
+	FROM budgets
+	WHERE account_id = $1
+	ORDER BY category
+	`
+	ctx, span := startSpan(ctx, "BudgetRepository.ListByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := make([]*models.Budget, 0)
+	for rows.Next() {
+		b := &models.Budget{}
+		if err := rows.Scan(&b.ID, &b.AccountID, &b.Category, &b.MonthlyLimit, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+func (r *BudgetRepository) Delete(ctx context.Context, accountID int, category string) error {
+	query := `DELETE FROM budgets WHERE account_id = $1 AND category = $2`
+	ctx, span := startSpan(ctx, "BudgetRepository.Delete", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, category)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("budget not found: %w", ErrNotFound)
+	}
+	return nil
+}
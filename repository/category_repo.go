@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type CategoryRepository struct {
+	db *db.DB
+}
+
+func NewCategoryRepository(db *db.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (r *CategoryRepository) CreateRule(ctx context.Context, rule *models.CategoryRule) (*models.CategoryRule, error) {
+	query := `
+	INSERT INTO category_rules (account_id, category, pattern, counterparty_account_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at
+	`
+	ctx, span := startSpan(ctx, "CategoryRepository.CreateRule", query)
+	defer span.End()
+
+	created := *rule
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, rule.AccountID, rule.Category, rule.Pattern, rule.CounterpartyAccountID).
+		Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create category rule: %w", err)
+	}
+	return &created, nil
+}
+
+func (r *CategoryRepository) ListRules(ctx context.Context, accountID int) ([]*models.CategoryRule, error) {
+	query := `
+	SELECT c.id, c.account_id, c.category, c.pattern, c.counterparty_account_id, COALESCE(a.account_number, ''), c.created_at
+	FROM category_rules c
+	LEFT JOIN accounts a ON a.id = c.counterparty_account_id
+	WHERE c.account_id = $1
+	ORDER BY c.id
+	`
+	ctx, span := startSpan(ctx, "CategoryRepository.ListRules", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list category rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.CategoryRule, 0)
+	for rows.Next() {
+		rule := &models.CategoryRule{}
+		err := rows.Scan(&rule.ID, &rule.AccountID, &rule.Category, &rule.Pattern, &rule.CounterpartyAccountID, &rule.CounterpartyAccountNumber, &rule.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *CategoryRepository) DeleteRule(ctx context.Context, accountID, ruleID int) error {
+	query := `DELETE FROM category_rules WHERE id = $1 AND account_id = $2`
+	ctx, span := startSpan(ctx, "CategoryRepository.DeleteRule", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, ruleID, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete category rule: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("category rule not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *CategoryRepository) ListUncategorized(ctx context.Context, accountID, limit int) ([]*models.Transaction, error) {
+	query := `
+	SELECT t.id, t.from_account_id, t.to_account_id, t.amount, t.type, t.description, t.status, t.related_transaction_id, t.pot_id, t.created_at
+	FROM transactions t
+	WHERE (t.from_account_id = $1 OR t.to_account_id = $1)
+	AND t.status = $2
+	AND t.pot_id IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM transaction_categories c
+		WHERE c.account_id = $1 AND c.transaction_id = t.id
+	)
+	ORDER BY t.id
+	LIMIT $3
+	`
+	ctx, span := startSpan(ctx, "CategoryRepository.ListUncategorized", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, models.TransactionStatusCompleted, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list uncategorized transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := make([]*models.Transaction, 0)
+	for rows.Next() {
+		t := &models.Transaction{}
+		err := rows.Scan(&t.ID, &t.FromAccountID, &t.ToAccountID, &t.Amount, &t.Type, &t.Description, &t.Status, &t.RelatedTransactionID, &t.PotID, &t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+func (r *CategoryRepository) Assign(ctx context.Context, category *models.TransactionCategory) error {
+	query := `
+	INSERT INTO transaction_categories (account_id, transaction_id, category, source, rule_id)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (account_id, transaction_id)
+	DO UPDATE SET category = EXCLUDED.category, source = EXCLUDED.source, rule_id = EXCLUDED.rule_id
+	WHERE transaction_categories.source != $6 OR EXCLUDED.source = $6
+	`
+	ctx, span := startSpan(ctx, "CategoryRepository.Assign", query)
+	defer span.End()
+
+	_, err := r.db.Conn(ctx).ExecContext(ctx, query, category.AccountID, category.TransactionID, category.Category, category.Source, category.RuleID, models.CategorySourceUser)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to assign category: %w", err)
+	}
+	return nil
+}
+
+func (r *CategoryRepository) GetAssignment(ctx context.Context, accountID, transactionID int) (*models.TransactionCategory, error) {
+	query := `
+	SELECT account_id, transaction_id, category, source, rule_id
+	FROM transaction_categories
+	WHERE account_id = $1 AND transaction_id = $2
+	`
+	ctx, span := startSpan(ctx, "CategoryRepository.GetAssignment", query)
+	defer span.End()
+
+	c := &models.TransactionCategory{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, transactionID).
+		Scan(&c.AccountID, &c.TransactionID, &c.Category, &c.Source, &c.RuleID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("transaction category not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get transaction category: %w", err)
+	}
+	return c, nil
+}
+
+func (r *CategoryRepository) DeleteAssignment(ctx context.Context, accountID, transactionID int) error {
+	query := `DELETE FROM transaction_categories WHERE account_id = $1 AND transaction_id = $2`
+	ctx, span := startSpan(ctx, "CategoryRepository.DeleteAssignment", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, transactionID); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete transaction category: %w", err)
+	}
+	return nil
+}
+
+func (r *CategoryRepository) ClearAssigned(ctx context.Context, accountID int) error {
+	query := `DELETE FROM transaction_categories WHERE account_id = $1 AND source != $2`
+	ctx, span := startSpan(ctx, "CategoryRepository.ClearAssigned", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, models.CategorySourceUser); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to clear transaction categories: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type ClosureRepository struct {
+	db *db.DB
+}
+
+func NewClosureRepository(db *db.DB) *ClosureRepository {
+	return &ClosureRepository{db: db}
+}
+
+const closureColumns = `account_id, payout_account_id, payout_transaction_id, closed_at, retain_until, anonymized_at`
+
+func (r *ClosureRepository) Create(ctx context.Context, closure *models.AccountClosure) (*models.AccountClosure, error) {
+	query := `
+	INSERT INTO account_closures (account_id, payout_account_id, payout_transaction_id, closed_at, retain_until)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + closureColumns
+	ctx, span := startSpan(ctx, "ClosureRepository.Create", query)
+	defer span.End()
+
+	created, err := scanClosure(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		closure.AccountID, closure.PayoutAccountID, closure.PayoutTransactionID, closure.ClosedAt, closure.RetainUntil,
+	))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to record account closure: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to record account closure: %w", err)
+	}
+	return created, nil
+}
+
+func (r *ClosureRepository) GetByAccount(ctx context.Context, accountID int) (*models.AccountClosure, error) {
+	query := `SELECT ` + closureColumns + ` FROM account_closures WHERE account_id = $1`
+	ctx, span := startSpan(ctx, "ClosureRepository.GetByAccount", query)
+	defer span.End()
+
+	closure, err := scanClosure(r.db.Conn(ctx).QueryRowContext(ctx, query, accountID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account closure not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get account closure: %w", err)
+	}
+	return closure, nil
+}
+
+func (r *ClosureRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.AccountClosure, error) {
+	query := `
+	SELECT ` + closureColumns + `
+	FROM account_closures
+	WHERE anonymized_at IS NULL AND retain_until <= $1
+	ORDER BY retain_until, account_id
+	LIMIT $2
+	`
+	ctx, span := startSpan(ctx, "ClosureRepository.ListDue", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, now, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list due account closures: %w", err)
+	}
+	defer rows.Close()
+
+	closures := make([]*models.AccountClosure, 0)
+	for rows.Next() {
+		closure, err := scanClosure(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account closure: %w", err)
+		}
+		closures = append(closures, closure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating account closures: %w", err)
+	}
+	return closures, nil
+}
+
+func (r *ClosureRepository) MarkAnonymized(ctx context.Context, accountID int, at time.Time) error {
+	query := `
+	UPDATE account_closures
+	SET anonymized_at = $1
+	WHERE account_id = $2 AND anonymized_at IS NULL
+	`
+	ctx, span := startSpan(ctx, "ClosureRepository.MarkAnonymized", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, at, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to mark account anonymized: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("account closure not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// scanClosure reads a row of closureColumns. sql.ErrNoRows is returned
+// unwrapped.
+func scanClosure(row rowScanner) (*models.AccountClosure, error) {
+	closure := &models.AccountClosure{}
+	err := row.Scan(&closure.AccountID, &closure.PayoutAccountID, &closure.PayoutTransactionID,
+		&closure.ClosedAt, &closure.RetainUntil, &closure.AnonymizedAt)
+	if err != nil {
+		return nil, err
+	}
+	return closure, nil
+}
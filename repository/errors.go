@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is wrapped by every store method that finds no matching row
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is wrapped when an insert violates a unique constraint
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// isUniqueViolation reports whether err is Postgres error 23505
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isUniqueViolationOn reports whether err is a unique violation of the named constraint
+func isUniqueViolationOn(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type FeeRepository struct {
+	db *db.DB
+}
+
+func NewFeeRepository(db *db.DB) *FeeRepository {
+	return &FeeRepository{db: db}
+}
+
+const feeRuleColumns = `id, transaction_type, product, kind, flat_amount, percentage, min_fee, max_fee, tiers, active, created_at`
+
+// ListRules returns the active fee schedule
+func (r *FeeRepository) ListRules(ctx context.Context) ([]*models.FeeRule, error) {
+	query := `
+	SELECT ` + feeRuleColumns + `
+	FROM fee_rules
+	WHERE active
+	ORDER BY transaction_type, product NULLS FIRST
+	`
+	ctx, span := startSpan(ctx, "FeeRepository.ListRules", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list fee rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.FeeRule, 0)
+	for rows.Next() {
+		rule, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fee rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *FeeRepository) FindRule(ctx context.Context, transactionType, product string) (*models.FeeRule, error) {
+	query := `
+	SELECT ` + feeRuleColumns + `
+	FROM fee_rules
+	WHERE active AND transaction_type = $1 AND (product = $2 OR product IS NULL)
+	ORDER BY product IS NULL
+	LIMIT 1
+	`
+	ctx, span := startSpan(ctx, "FeeRepository.FindRule", query)
+	defer span.End()
+
+	rule, err := scanFeeRule(r.db.Conn(ctx).QueryRowContext(ctx, query, transactionType, product))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("fee rule not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+// CreateRule adds an active rule. A second active rule for the same
+// transaction type and product fails with ErrDuplicate.
+func (r *FeeRepository) CreateRule(ctx context.Context, rule *models.FeeRule) (*models.FeeRule, error) {
+	query := `
+	INSERT INTO fee_rules (transaction_type, product, kind, flat_amount, percentage, min_fee, max_fee, tiers)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING ` + feeRuleColumns
+	ctx, span := startSpan(ctx, "FeeRepository.CreateRule", query)
+	defer span.End()
+
+	var tiers []byte
+	if len(rule.Tiers) > 0 {
+		encoded, err := json.Marshal(rule.Tiers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode fee tiers: %w", err)
+		}
+		tiers = encoded
+	}
+
+	created, err := scanFeeRule(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		rule.TransactionType, rule.Product, rule.Kind, rule.FlatAmount, rule.Percentage, rule.MinFee, rule.MaxFee, tiers,
+	))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create fee rule: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeactivateRule retires a rule; fees already charged keep referring to it
+// only through their transactions
+func (r *FeeRepository) DeactivateRule(ctx context.Context, id int) error {
+	query := `UPDATE fee_rules SET active = FALSE WHERE id = $1 AND active`
+	ctx, span := startSpan(ctx, "FeeRepository.DeactivateRule", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to deactivate fee rule: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("fee rule not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *FeeRepository) RecordMaintenance(ctx context.Context, accountID int, period time.Time, amount float64, transactionID *int) error {
+	query := `
+	INSERT INTO maintenance_fee_charges (account_id, period, amount, transaction_id)
+	VALUES ($1, $2, $3, $4)
+	`
+	ctx, span := startSpan(ctx, "FeeRepository.RecordMaintenance", query)
+	defer span.End()
+
+	_, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, period.Format(dateLayout), amount, transactionID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("failed to record maintenance fee: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to record maintenance fee: %w", err)
+	}
+	return nil
+}
+
+// ListMaintenanceCharged lists the accounts already charged for period
+func (r *FeeRepository) ListMaintenanceCharged(ctx context.Context, period time.Time) ([]int, error) {
+	query := `SELECT account_id FROM maintenance_fee_charges WHERE period = $1 ORDER BY account_id`
+	ctx, span := startSpan(ctx, "FeeRepository.ListMaintenanceCharged", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, period.Format(dateLayout))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list maintenance charges: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan account id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating maintenance charges: %w", err)
+	}
+	return ids, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanFeeRule reads a row selected with feeRuleColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanFeeRule(row rowScanner) (*models.FeeRule, error) {
+	rule := &models.FeeRule{}
+	var tiers []byte
+	err := row.Scan(&rule.ID, &rule.TransactionType, &rule.Product, &rule.Kind, &rule.FlatAmount, &rule.Percentage,
+		&rule.MinFee, &rule.MaxFee, &tiers, &rule.Active, &rule.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan fee rule: %w", err)
+	}
+	if len(tiers) > 0 {
+		if err := json.Unmarshal(tiers, &rule.Tiers); err != nil {
+			return nil, fmt.Errorf("failed to decode fee tiers: %w", err)
+		}
+	}
+	return rule, nil
+}
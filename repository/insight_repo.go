@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type InsightRepository struct {
+	db *db.DB
+}
+
+func NewInsightRepository(db *db.DB) *InsightRepository {
+	return &InsightRepository{db: db}
+}
+
+// insightMoves lists the account's money movements in [$2, $3) with status
+// $4 (completed), with the amount signed from its point of view and the
+// other account of a transfer. Pot moves stay within the account and are
+// left out.
+const insightMoves = `
+	SELECT t.id, t.created_at,
+		CASE WHEN t.to_account_id = $1 THEN t.amount ELSE -t.amount END AS amount,
+		CASE WHEN t.to_account_id = $1 THEN t.from_account_id ELSE t.to_account_id END AS counterparty_id
+	FROM transactions t
+	WHERE (t.from_account_id = $1 OR t.to_account_id = $1)
+	AND t.status = $4
+	AND t.pot_id IS NULL
+	AND t.created_at >= $2 AND t.created_at < $3
+`
+
+func (r *InsightRepository) MonthlyFlows(ctx context.Context, accountID int, from, to time.Time) ([]*models.MonthlyFlow, error) {
+	query := `
+	WITH moves AS (` + insightMoves + `)
+	SELECT to_char(date_trunc('month', created_at), 'YYYY-MM') AS month,
+		COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0) AS inflow,
+		COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0) AS outflow,
+		SUM(amount) AS net
+	FROM moves
+	GROUP BY month
+	ORDER BY month
+	`
+	ctx, span := startSpan(ctx, "InsightRepository.MonthlyFlows", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, from, to, models.TransactionStatusCompleted)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to sum monthly flows: %w", err)
+	}
+	defer rows.Close()
+
+	months := make([]*models.MonthlyFlow, 0)
+	for rows.Next() {
+		m := &models.MonthlyFlow{}
+		if err := rows.Scan(&m.Month, &m.Inflow, &m.Outflow, &m.Net); err != nil {
+			return nil, fmt.Errorf("failed to scan monthly flow: %w", err)
+		}
+		months = append(months, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating monthly flows: %w", err)
+	}
+	return months, nil
+}
+
+func (r *InsightRepository) TopCounterparties(ctx context.Context, accountID int, from, to time.Time, limit int) ([]*models.CounterpartyTotal, error) {
+	query := `
+	WITH moves AS (` + insightMoves + `)
+	SELECT a.id, a.account_number, a.first_name, a.last_name,
+		COALESCE(SUM(m.amount) FILTER (WHERE m.amount > 0), 0) AS inflow,
+		COALESCE(-SUM(m.amount) FILTER (WHERE m.amount < 0), 0) AS outflow,
+		COUNT(*) AS count
+	FROM moves m
+	JOIN accounts a ON a.id = m.counterparty_id
+	GROUP BY a.id, a.account_number, a.first_name, a.last_name
+	ORDER BY SUM(ABS(m.amount)) DESC, a.id
+	LIMIT $5
+	`
+	ctx, span := startSpan(ctx, "InsightRepository.TopCounterparties", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, from, to, models.TransactionStatusCompleted, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to sum counterparties: %w", err)
+	}
+	defer rows.Close()
+
+	counterparties := make([]*models.CounterpartyTotal, 0)
+	for rows.Next() {
+		c := &models.CounterpartyTotal{}
+		if err := rows.Scan(&c.AccountID, &c.AccountNumber, &c.FirstName, &c.LastName, &c.Inflow, &c.Outflow, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan counterparty: %w", err)
+		}
+		counterparties = append(counterparties, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating counterparties: %w", err)
+	}
+	return counterparties, nil
+}
+
+func (r *InsightRepository) CategoryTotals(ctx context.Context, accountID int, from, to time.Time) ([]*models.CategoryTotal, error) {
+	query := `
+	WITH moves AS (` + insightMoves + `)
+	SELECT c.category,
+		COALESCE(SUM(m.amount) FILTER (WHERE m.amount > 0), 0) AS inflow,
+		COALESCE(-SUM(m.amount) FILTER (WHERE m.amount < 0), 0) AS outflow,
+		COUNT(*) AS count
+	FROM moves m
+	JOIN transaction_categories c ON c.account_id = $1 AND c.transaction_id = m.id
+	GROUP BY c.category
+	ORDER BY outflow DESC, inflow DESC, c.category
+	`
+	ctx, span := startSpan(ctx, "InsightRepository.CategoryTotals", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, from, to, models.TransactionStatusCompleted)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to sum categories: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make([]*models.CategoryTotal, 0)
+	for rows.Next() {
+		c := &models.CategoryTotal{}
+		if err := rows.Scan(&c.Category, &c.Inflow, &c.Outflow, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan category total: %w", err)
+		}
+		totals = append(totals, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category totals: %w", err)
+	}
+	return totals, nil
+}
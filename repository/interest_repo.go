@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+// dateLayout formats values for DATE columns so the session time zone
+// can't shift them to another day
+const dateLayout = "2006-01-02"
+
+type InterestRepository struct {
+	db *db.DB
+}
+
+func NewInterestRepository(db *db.DB) *InterestRepository {
+	return &InterestRepository{db: db}
+}
+
+// CreateAccrual records one day's interest. A second accrual for the same
+// account and day fails with ErrDuplicate.
+func (r *InterestRepository) CreateAccrual(ctx context.Context, accrual *models.InterestAccrual) (*models.InterestAccrual, error) {
+	query := `
+	INSERT INTO interest_accruals (account_id, accrual_date, balance, annual_rate, amount)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`
+	ctx, span := startSpan(ctx, "InterestRepository.CreateAccrual", query)
+	defer span.End()
+
+	created := *accrual
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query,
+		accrual.AccountID, accrual.AccrualDate.Format(dateLayout), accrual.Balance, accrual.AnnualRate, accrual.Amount,
+	).Scan(&created.ID, &created.CreatedAt)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create accrual: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create accrual: %w", err)
+	}
+	return &created, nil
+}
+
+// LastAccrualDate is nil when the account has never accrued interest
+func (r *InterestRepository) LastAccrualDate(ctx context.Context, accountID int) (*time.Time, error) {
+	query := `SELECT MAX(accrual_date) FROM interest_accruals WHERE account_id = $1`
+	ctx, span := startSpan(ctx, "InterestRepository.LastAccrualDate", query)
+	defer span.End()
+
+	var last sql.NullTime
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(&last); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get last accrual date: %w", err)
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
+
+// ListAccruals returns the account's accruals dated in [from, to), oldest first
+func (r *InterestRepository) ListAccruals(ctx context.Context, accountID int, from, to time.Time) ([]*models.InterestAccrual, error) {
+	query := `
+	SELECT id, account_id, accrual_date, balance, annual_rate, amount, transaction_id, capitalized_at, created_at
+	FROM interest_accruals
+	WHERE account_id = $1 AND accrual_date >= $2 AND accrual_date < $3
+	ORDER BY accrual_date
+	`
+	return r.queryAccruals(ctx, "InterestRepository.ListAccruals", query, accountID, from.Format(dateLayout), to.Format(dateLayout))
+}
+
+// ListUncapitalized returns the accruals dated before before that have not
+// been paid out yet, oldest first
+func (r *InterestRepository) ListUncapitalized(ctx context.Context, accountID int, before time.Time) ([]*models.InterestAccrual, error) {
+	query := `
+	SELECT id, account_id, accrual_date, balance, annual_rate, amount, transaction_id, capitalized_at, created_at
+	FROM interest_accruals
+	WHERE account_id = $1 AND accrual_date < $2 AND capitalized_at IS NULL
+	ORDER BY accrual_date
+	`
+	return r.queryAccruals(ctx, "InterestRepository.ListUncapitalized", query, accountID, before.Format(dateLayout))
+}
+
+// AccountsWithUncapitalized lists the accounts holding accruals dated
+// before before that have not been paid out
+func (r *InterestRepository) AccountsWithUncapitalized(ctx context.Context, before time.Time) ([]int, error) {
+	query := `
+	SELECT DISTINCT account_id
+	FROM interest_accruals
+	WHERE accrual_date < $1 AND capitalized_at IS NULL
+	ORDER BY account_id
+	`
+	ctx, span := startSpan(ctx, "InterestRepository.AccountsWithUncapitalized", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, before.Format(dateLayout))
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list accounts with uncapitalized interest: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan account id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accounts: %w", err)
+	}
+	return ids, nil
+}
+
+// MarkCapitalized records that accruals were paid out. transactionID is nil
+// when the total rounded to less than a cent and nothing was posted.
+func (r *InterestRepository) MarkCapitalized(ctx context.Context, ids []int, transactionID *int, at time.Time) error {
+	query := `
+	UPDATE interest_accruals
+	SET transaction_id = $1, capitalized_at = $2
+	WHERE id = ANY($3) AND capitalized_at IS NULL
+	`
+	ctx, span := startSpan(ctx, "InterestRepository.MarkCapitalized", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, transactionID, at, pq.Array(ids))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to mark accruals capitalized: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if int(rows) != len(ids) {
+		return fmt.Errorf("failed to mark accruals capitalized: %d of %d already capitalized: %w", len(ids)-int(rows), len(ids), ErrDuplicate)
+	}
+	return nil
+}
+
+func (r *InterestRepository) queryAccruals(ctx context.Context, name, query string, args ...any) ([]*models.InterestAccrual, error) {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list accruals: %w", err)
+	}
+	defer rows.Close()
+
+	accruals := make([]*models.InterestAccrual, 0)
+	for rows.Next() {
+		a := &models.InterestAccrual{}
+		err := rows.Scan(&a.ID, &a.AccountID, &a.AccrualDate, &a.Balance, &a.AnnualRate, &a.Amount, &a.TransactionID, &a.CapitalizedAt, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan accrual: %w", err)
+		}
+		accruals = append(accruals, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accruals: %w", err)
+	}
+	return accruals, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type KYCRepository struct {
+	db *db.DB
+}
+
+func NewKYCRepository(db *db.DB) *KYCRepository {
+	return &KYCRepository{db: db}
+}
+
+const kycProfileColumns = `account_id, status, level, date_of_birth, nationality, address_line1, address_line2, city,
+	postal_code, country, rejection_reason, submitted_at, reviewed_at, reviewed_by, updated_at`
+
+const kycDocumentColumns = `id, account_id, type, file_name, content_type, size_bytes, sha256, storage_key, uploaded_at`
+
+func (r *KYCRepository) GetProfile(ctx context.Context, accountID int) (*models.KYCProfile, error) {
+	query := `SELECT ` + kycProfileColumns + ` FROM kyc_profiles WHERE account_id = $1`
+	ctx, span := startSpan(ctx, "KYCRepository.GetProfile", query)
+	defer span.End()
+
+	profile, err := scanKYCProfile(r.db.Conn(ctx).QueryRowContext(ctx, query, accountID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("kyc profile not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get kyc profile: %w", err)
+	}
+	return profile, nil
+}
+
+func (r *KYCRepository) SaveProfile(ctx context.Context, profile *models.KYCProfile) error {
+	query := `
+	INSERT INTO kyc_profiles (account_id, status, level, date_of_birth, nationality, address_line1, address_line2, city,
+		postal_code, country, rejection_reason, submitted_at, reviewed_at, reviewed_by, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, CURRENT_TIMESTAMP)
+	ON CONFLICT (account_id) DO UPDATE
+	SET status = EXCLUDED.status, level = EXCLUDED.level, date_of_birth = EXCLUDED.date_of_birth,
+		nationality = EXCLUDED.nationality, address_line1 = EXCLUDED.address_line1, address_line2 = EXCLUDED.address_line2,
+		city = EXCLUDED.city, postal_code = EXCLUDED.postal_code, country = EXCLUDED.country,
+		rejection_reason = EXCLUDED.rejection_reason, submitted_at = EXCLUDED.submitted_at,
+		reviewed_at = EXCLUDED.reviewed_at, reviewed_by = EXCLUDED.reviewed_by, updated_at = EXCLUDED.updated_at
+	RETURNING updated_at
+	`
+	ctx, span := startSpan(ctx, "KYCRepository.SaveProfile", query)
+	defer span.End()
+
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query,
+		profile.AccountID, profile.Status, profile.Level, nullableDate(profile.DateOfBirth), profile.Nationality,
+		profile.AddressLine1, profile.AddressLine2, profile.City, profile.PostalCode, profile.Country,
+		profile.RejectionReason, profile.SubmittedAt, profile.ReviewedAt, profile.ReviewedBy,
+	).Scan(&profile.UpdatedAt)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to save kyc profile: %w", err)
+	}
+	return nil
+}
+
+func (r *KYCRepository) ListByStatus(ctx context.Context, status string, limit int) ([]*models.KYCProfile, error) {
+	query := `
+	SELECT ` + kycProfileColumns + `
+	FROM kyc_profiles
+	WHERE status = $1
+	ORDER BY submitted_at NULLS LAST, account_id
+	LIMIT $2
+	`
+	ctx, span := startSpan(ctx, "KYCRepository.ListByStatus", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, status, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list kyc profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := make([]*models.KYCProfile, 0)
+	for rows.Next() {
+		profile, err := scanKYCProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan kyc profile: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating kyc profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+func (r *KYCRepository) AddDocument(ctx context.Context, document *models.KYCDocument) (*models.KYCDocument, error) {
+	query := `
+	INSERT INTO kyc_documents (account_id, type, file_name, content_type, size_bytes, sha256, storage_key)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING ` + kycDocumentColumns
+	ctx, span := startSpan(ctx, "KYCRepository.AddDocument", query)
+	defer span.End()
+
+	created, err := scanKYCDocument(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		document.AccountID, document.Type, document.FileName, document.ContentType, document.Size, document.SHA256, document.StorageKey,
+	))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to add kyc document: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to add kyc document: %w", err)
+	}
+	return created, nil
+}
+
+func (r *KYCRepository) GetDocument(ctx context.Context, id int) (*models.KYCDocument, error) {
+	query := `SELECT ` + kycDocumentColumns + ` FROM kyc_documents WHERE id = $1`
+	ctx, span := startSpan(ctx, "KYCRepository.GetDocument", query)
+	defer span.End()
+
+	document, err := scanKYCDocument(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("kyc document not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get kyc document: %w", err)
+	}
+	return document, nil
+}
+
+func (r *KYCRepository) ListDocuments(ctx context.Context, accountID int) ([]*models.KYCDocument, error) {
+	query := `
+	SELECT ` + kycDocumentColumns + `
+	FROM kyc_documents
+	WHERE account_id = $1
+	ORDER BY uploaded_at, id
+	`
+	ctx, span := startSpan(ctx, "KYCRepository.ListDocuments", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list kyc documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := make([]*models.KYCDocument, 0)
+	for rows.Next() {
+		document, err := scanKYCDocument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan kyc document: %w", err)
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating kyc documents: %w", err)
+	}
+	return documents, nil
+}
+
+func (r *KYCRepository) DeleteByAccount(ctx context.Context, accountID int) ([]string, error) {
+	query := `
+	WITH profile AS (
+		DELETE FROM kyc_profiles WHERE account_id = $1
+	)
+	DELETE FROM kyc_documents
+	WHERE account_id = $1
+	RETURNING storage_key
+	`
+	ctx, span := startSpan(ctx, "KYCRepository.DeleteByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to delete kyc data: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan storage key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating kyc documents: %w", err)
+	}
+	return keys, nil
+}
+
+// scanKYCProfile reads a row of kycProfileColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanKYCProfile(row rowScanner) (*models.KYCProfile, error) {
+	profile := &models.KYCProfile{}
+	err := row.Scan(&profile.AccountID, &profile.Status, &profile.Level, &profile.DateOfBirth, &profile.Nationality,
+		&profile.AddressLine1, &profile.AddressLine2, &profile.City, &profile.PostalCode, &profile.Country,
+		&profile.RejectionReason, &profile.SubmittedAt, &profile.ReviewedAt, &profile.ReviewedBy, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// scanKYCDocument reads a row of kycDocumentColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanKYCDocument(row rowScanner) (*models.KYCDocument, error) {
+	document := &models.KYCDocument{}
+	err := row.Scan(&document.ID, &document.AccountID, &document.Type, &document.FileName, &document.ContentType,
+		&document.Size, &document.SHA256, &document.StorageKey, &document.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
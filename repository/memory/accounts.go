@@ -0,0 +1,393 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+type AccountRepository struct {
+	store *Store
+}
+
+func (r *AccountRepository) Create(ctx context.Context, email, passwordHash, firstName, lastName string) (*models.Account, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.accounts {
+		if existing.Email == email {
+			return nil, fmt.Errorf("failed to create account: %w", repository.ErrDuplicate)
+		}
+	}
+
+	accountNumber, err := r.newAccountNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	account := &models.Account{
+		ID:            s.nextAccountID,
+		AccountNumber: accountNumber,
+		Product:       models.ProductChecking,
+		Email:         email,
+		PasswordHash:  passwordHash,
+		FirstName:     firstName,
+		LastName:      lastName,
+		Balance:       0,
+		Currency:      "USD",
+		Status:        models.AccountStatusActice,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.nextAccountID++
+	s.accounts[account.ID] = account
+	s.record(ctx, func() { delete(s.accounts, account.ID) })
+
+	copied := *account
+	return &copied, nil
+}
+
+func (r *AccountRepository) GetByID(ctx context.Context, id int) (*models.Account, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get an account: %w", repository.ErrNotFound)
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *AccountRepository) GeyByEmail(ctx context.Context, email string) (*models.Account, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.accounts {
+		if account.Email == email {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get an account: %w", repository.ErrNotFound)
+}
+
+func (r *AccountRepository) GetByAccountNumber(ctx context.Context, accountNumber string) (*models.Account, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.accounts {
+		if account.AccountNumber == accountNumber {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get an account: %w", repository.ErrNotFound)
+}
+
+func (r *AccountRepository) GetAccountNumbers(ctx context.Context, ids []int) (map[int]string, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	numbers := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if account, ok := s.accounts[id]; ok {
+			numbers[id] = account.AccountNumber
+		}
+	}
+	return numbers, nil
+}
+
+// newAccountNumber draws account numbers until one is unused, mirroring the
+// UNIQUE constraint on accounts.account_number. The caller holds s.mu.
+func (r *AccountRepository) newAccountNumber() (string, error) {
+	for {
+		number, err := utils.GenerateAccountNumber()
+		if err != nil {
+			return "", err
+		}
+		taken := false
+		for _, existing := range r.store.accounts {
+			if existing.AccountNumber == number {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return number, nil
+		}
+	}
+}
+
+func (r *AccountRepository) SetOverdraftLimit(ctx context.Context, id int, limit float64) error {
+	release, err := r.store.lockRow(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to set overdraft limit: %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account not found: %w", repository.ErrNotFound)
+	}
+	// Mirrors CHECK (overdraft_limit >= 0) and CHECK (balance >= -overdraft_limit)
+	if limit < 0 || account.Balance < -limit {
+		return fmt.Errorf("failed to set overdraft limit: new row violates check constraint \"accounts_check\"")
+	}
+	prev := *account
+	account.OverdraftLimit = limit
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) SetStatus(ctx context.Context, id int, status string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account not found: %w", repository.ErrNotFound)
+	}
+	prev := *account
+	account.Status = status
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) SetProduct(ctx context.Context, id int, product string) error {
+	release, err := r.store.lockRow(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to set account product: %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account not found: %w", repository.ErrNotFound)
+	}
+	// Mirrors the foreign key to account_products
+	if _, ok := s.products[product]; !ok {
+		return fmt.Errorf("failed to set account product: violates foreign key constraint \"accounts_product_fkey\"")
+	}
+	prev := *account
+	account.Product = product
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) ListIDsByProduct(ctx context.Context, product string) ([]int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0)
+	for id, account := range s.accounts {
+		if account.Product == product && account.Status != models.AccountStatusClosed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (r *AccountRepository) ListOpenIDs(ctx context.Context) ([]int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0)
+	for id, account := range s.accounts {
+		if account.Status != models.AccountStatusClosed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (r *AccountRepository) Update(ctx context.Context, id int, firstName, lastName string) error {
+	release, err := r.store.lockRow(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to update account: %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account not found: %w", repository.ErrNotFound)
+	}
+	prev := *account
+	account.FirstName = firstName
+	account.LastName = lastName
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) UpdateBalance(ctx context.Context, accountID int, newBalance float64) error {
+	release, err := r.store.lockRow(ctx, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to update balance: %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok {
+		return fmt.Errorf("account not found: %w", repository.ErrNotFound)
+	}
+	// Mirrors CHECK (balance >= -overdraft_limit) on the accounts table
+	if newBalance < -account.OverdraftLimit {
+		return fmt.Errorf("failed to update balance: new row violates check constraint \"accounts_check\"")
+	}
+	prev := *account
+	account.Balance = newBalance
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) GetBalanceForUpdate(ctx context.Context, accountID int) (float64, error) {
+	balance, _, err := r.GetFundsForUpdate(ctx, accountID)
+	return balance, err
+}
+
+func (r *AccountRepository) GetFundsForUpdate(ctx context.Context, accountID int) (float64, float64, error) {
+	release, err := r.store.lockRow(ctx, accountID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get balance %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok {
+		return 0, 0, fmt.Errorf("No account found: %w", repository.ErrNotFound)
+	}
+	return account.Balance, account.OverdraftLimit, nil
+}
+
+func (r *AccountRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.accounts {
+		if account.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *AccountRepository) Close(ctx context.Context, id int) error {
+	release, err := r.store.lockRow(ctx, id)
+	if err != nil {
+		return fmt.Errorf("Failed to close account: %w", err)
+	}
+	defer release()
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("No account found: %w", repository.ErrNotFound)
+	}
+	prev := *account
+	account.Status = models.AccountStatusClosed
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) Anonymize(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok || account.Status != models.AccountStatusClosed {
+		return fmt.Errorf("closed account not found: %w", repository.ErrNotFound)
+	}
+	prev := *account
+	account.Email = fmt.Sprintf("anonymized-%d@anonymized.invalid", id)
+	account.PasswordHash = ""
+	account.FirstName = ""
+	account.LastName = ""
+	account.UpdatedAt = time.Now()
+	s.record(ctx, func() { *account = prev })
+	return nil
+}
+
+func (r *AccountRepository) List(ctx context.Context, page, limit int) ([]*models.Account, int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := make([]*models.Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		if account.Status == models.AccountStatusClosed {
+			continue
+		}
+		copied := *account
+		accounts = append(accounts, &copied)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].CreatedAt.Equal(accounts[j].CreatedAt) {
+			return accounts[i].ID > accounts[j].ID
+		}
+		return accounts[i].CreatedAt.After(accounts[j].CreatedAt)
+	})
+
+	return paginate(accounts, page, limit), len(accounts), nil
+}
+
+func paginate[T any](items []T, page, limit int) []T {
+	offset := (page - 1) * limit
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return make([]T, 0)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
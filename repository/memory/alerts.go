@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type AlertRepository struct {
+	store *Store
+}
+
+func (r *AlertRepository) Create(ctx context.Context, rule *models.AlertRule) (*models.AlertRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key, CHECK and UNIQUE constraints on alert_rules
+	if _, ok := s.accounts[rule.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to create alert rule: violates foreign key constraint")
+	}
+	if rule.Type != models.AlertLowBalance && rule.Type != models.AlertLargeTransaction {
+		return nil, fmt.Errorf("failed to create alert rule: violates check constraint \"alert_rules_type_check\"")
+	}
+	if rule.Threshold <= 0 {
+		return nil, fmt.Errorf("failed to create alert rule: violates check constraint \"alert_rules_threshold_check\"")
+	}
+	for _, existing := range s.alertRules {
+		if existing.AccountID == rule.AccountID && existing.Type == rule.Type && existing.Threshold == rule.Threshold {
+			return nil, fmt.Errorf("failed to create alert rule: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := &models.AlertRule{
+		ID:        s.nextAlertRuleID,
+		AccountID: rule.AccountID,
+		Type:      rule.Type,
+		Threshold: rule.Threshold,
+		Triggered: rule.Triggered,
+		CreatedAt: time.Now(),
+	}
+	s.nextAlertRuleID++
+	s.alertRules[created.ID] = created
+	s.record(ctx, func() { delete(s.alertRules, created.ID) })
+
+	return copyAlertRule(created), nil
+}
+
+func (r *AlertRepository) GetByID(ctx context.Context, id int) (*models.AlertRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.alertRules[id]
+	if !ok {
+		return nil, fmt.Errorf("alert rule not found: %w", repository.ErrNotFound)
+	}
+	return copyAlertRule(rule), nil
+}
+
+func (r *AlertRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.AlertRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]*models.AlertRule, 0)
+	for _, rule := range s.alertRules {
+		if rule.AccountID == accountID {
+			rules = append(rules, copyAlertRule(rule))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Type != rules[j].Type {
+			return rules[i].Type < rules[j].Type
+		}
+		if rules[i].Threshold != rules[j].Threshold {
+			return rules[i].Threshold < rules[j].Threshold
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+func (r *AlertRepository) Delete(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.alertRules[id]
+	if !ok {
+		return fmt.Errorf("alert rule not found: %w", repository.ErrNotFound)
+	}
+	delete(s.alertRules, id)
+	s.record(ctx, func() { s.alertRules[id] = rule })
+	return nil
+}
+
+func (r *AlertRepository) SetTriggered(ctx context.Context, id int, triggered bool, at time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.alertRules[id]
+	if !ok || rule.Triggered == triggered {
+		return false, nil
+	}
+	previous := *rule
+	rule.Triggered = triggered
+	if triggered {
+		rule.LastTriggeredAt = &at
+	}
+	s.record(ctx, func() { *rule = previous })
+	return true, nil
+}
+
+func (r *AlertRepository) RecordTriggered(ctx context.Context, id int, at time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rule, ok := s.alertRules[id]; ok {
+		previous := rule.LastTriggeredAt
+		rule.LastTriggeredAt = &at
+		s.record(ctx, func() { rule.LastTriggeredAt = previous })
+	}
+	return nil
+}
+
+func copyAlertRule(rule *models.AlertRule) *models.AlertRule {
+	copied := *rule
+	copied.LastTriggeredAt = copyTime(rule.LastTriggeredAt)
+	return &copied
+}
@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type AnnotationRepository struct {
+	store *Store
+}
+
+func (r *AnnotationRepository) Save(ctx context.Context, annotation *models.TransactionAnnotation) (*models.TransactionAnnotation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign keys on transaction_annotations
+	if _, ok := s.accounts[annotation.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to save annotation: violates foreign key constraint")
+	}
+	if _, ok := s.transactions[annotation.TransactionID]; !ok {
+		return nil, fmt.Errorf("failed to save annotation: violates foreign key constraint")
+	}
+
+	annotations, ok := s.annotations[annotation.AccountID]
+	if !ok {
+		annotations = make(map[int]*models.TransactionAnnotation)
+		s.annotations[annotation.AccountID] = annotations
+	}
+	id := annotation.TransactionID
+	prev, existed := annotations[id]
+	saved := copyAnnotation(annotation)
+	saved.UpdatedAt = time.Now()
+	annotations[id] = saved
+	s.record(ctx, func() {
+		if existed {
+			annotations[id] = prev
+		} else {
+			delete(annotations, id)
+		}
+	})
+	return copyAnnotation(saved), nil
+}
+
+func (r *AnnotationRepository) Get(ctx context.Context, accountID, transactionID int) (*models.TransactionAnnotation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotation, ok := s.annotations[accountID][transactionID]
+	if !ok {
+		return nil, fmt.Errorf("annotation not found: %w", repository.ErrNotFound)
+	}
+	return copyAnnotation(annotation), nil
+}
+
+func (r *AnnotationRepository) Delete(ctx context.Context, accountID, transactionID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations := s.annotations[accountID]
+	prev, ok := annotations[transactionID]
+	if !ok {
+		return nil
+	}
+	delete(annotations, transactionID)
+	s.record(ctx, func() { annotations[transactionID] = prev })
+	return nil
+}
+
+func (r *AnnotationRepository) ListByTransactions(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAnnotation, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations := make([]*models.TransactionAnnotation, 0)
+	for id, annotation := range s.annotations[accountID] {
+		if slices.Contains(transactionIDs, id) {
+			annotations = append(annotations, copyAnnotation(annotation))
+		}
+	}
+	sort.Slice(annotations, func(i, j int) bool { return annotations[i].TransactionID < annotations[j].TransactionID })
+	return annotations, nil
+}
+
+func (r *AnnotationRepository) AddAttachment(ctx context.Context, attachment *models.TransactionAttachment) (*models.TransactionAttachment, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the constraints on transaction_attachments
+	if _, ok := s.accounts[attachment.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to add attachment: violates foreign key constraint")
+	}
+	if _, ok := s.transactions[attachment.TransactionID]; !ok {
+		return nil, fmt.Errorf("failed to add attachment: violates foreign key constraint")
+	}
+	if attachment.Size <= 0 {
+		return nil, fmt.Errorf("failed to add attachment: violates check constraint \"transaction_attachments_size_bytes_check\"")
+	}
+	for _, existing := range s.attachments {
+		if existing.StorageKey == attachment.StorageKey {
+			return nil, fmt.Errorf("failed to add attachment: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := *attachment
+	created.ID = s.nextAttachmentID
+	created.UploadedAt = time.Now()
+	s.nextAttachmentID++
+	s.attachments[created.ID] = &created
+	s.record(ctx, func() { delete(s.attachments, created.ID) })
+
+	copied := created
+	return &copied, nil
+}
+
+func (r *AnnotationRepository) GetAttachment(ctx context.Context, id int) (*models.TransactionAttachment, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachment, ok := s.attachments[id]
+	if !ok {
+		return nil, fmt.Errorf("attachment not found: %w", repository.ErrNotFound)
+	}
+	copied := *attachment
+	return &copied, nil
+}
+
+func (r *AnnotationRepository) ListAttachments(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAttachment, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachments := make([]*models.TransactionAttachment, 0)
+	for _, attachment := range s.attachments {
+		if attachment.AccountID == accountID && slices.Contains(transactionIDs, attachment.TransactionID) {
+			copied := *attachment
+			attachments = append(attachments, &copied)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].ID < attachments[j].ID })
+	return attachments, nil
+}
+
+func (r *AnnotationRepository) DeleteAttachment(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachment, ok := s.attachments[id]
+	if !ok {
+		return fmt.Errorf("attachment not found: %w", repository.ErrNotFound)
+	}
+	delete(s.attachments, id)
+	s.record(ctx, func() { s.attachments[id] = attachment })
+	return nil
+}
+
+func (r *AnnotationRepository) DeleteByAccount(ctx context.Context, accountID int) ([]string, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations, hadAnnotations := s.annotations[accountID]
+	delete(s.annotations, accountID)
+
+	keys := make([]string, 0)
+	removed := make([]*models.TransactionAttachment, 0)
+	for id, attachment := range s.attachments {
+		if attachment.AccountID == accountID {
+			keys = append(keys, attachment.StorageKey)
+			removed = append(removed, attachment)
+			delete(s.attachments, id)
+		}
+	}
+	s.record(ctx, func() {
+		if hadAnnotations {
+			s.annotations[accountID] = annotations
+		}
+		for _, attachment := range removed {
+			s.attachments[attachment.ID] = attachment
+		}
+	})
+	return keys, nil
+}
+
+func copyAnnotation(annotation *models.TransactionAnnotation) *models.TransactionAnnotation {
+	copied := *annotation
+	copied.Tags = slices.Clone(annotation.Tags)
+	if copied.Tags == nil {
+		copied.Tags = []string{}
+	}
+	return &copied
+}
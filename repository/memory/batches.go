@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type BatchRepository struct {
+	store *Store
+}
+
+func (r *BatchRepository) Create(ctx context.Context, accountID int, mode string, totalAmount float64, items []*models.TransferBatchItem) (*models.TransferBatch, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[accountID]; !ok {
+		return nil, fmt.Errorf("failed to create batch: violates foreign key constraint")
+	}
+
+	batch := &models.TransferBatch{
+		ID:          s.nextBatchID,
+		AccountID:   accountID,
+		Mode:        mode,
+		Status:      models.BatchStatusPending,
+		TotalAmount: totalAmount,
+		ItemCount:   len(items),
+		CreatedAt:   time.Now(),
+		Items:       make([]*models.TransferBatchItem, len(items)),
+	}
+	s.nextBatchID++
+
+	for i, item := range items {
+		item.ID = s.nextBatchItemID
+		item.BatchID = batch.ID
+		item.Status = models.BatchItemStatusPending
+		s.nextBatchItemID++
+		copied := *item
+		batch.Items[i] = &copied
+	}
+
+	s.batches[batch.ID] = batch
+	s.record(ctx, func() { delete(s.batches, batch.ID) })
+
+	return copyBatch(batch), nil
+}
+
+func (r *BatchRepository) GetByID(ctx context.Context, id int) (*models.TransferBatch, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, ok := s.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("batch not found: %w", repository.ErrNotFound)
+	}
+	return copyBatch(batch), nil
+}
+
+func (r *BatchRepository) UpdateItem(ctx context.Context, item *models.TransferBatchItem) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, ok := s.batches[item.BatchID]
+	if !ok {
+		return fmt.Errorf("batch item not found: %w", repository.ErrNotFound)
+	}
+	for _, stored := range batch.Items {
+		if stored.ID == item.ID {
+			prev := *stored
+			stored.Status = item.Status
+			stored.TransactionID = copyInt(item.TransactionID)
+			stored.ErrorCode = item.ErrorCode
+			stored.ErrorMessage = item.ErrorMessage
+			s.record(ctx, func() { *stored = prev })
+			return nil
+		}
+	}
+	return fmt.Errorf("batch item not found: %w", repository.ErrNotFound)
+}
+
+func (r *BatchRepository) UpdateStatus(ctx context.Context, id int, status string, succeeded, failed int, completedAt *time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, ok := s.batches[id]
+	if !ok {
+		return fmt.Errorf("batch not found: %w", repository.ErrNotFound)
+	}
+	prev := *batch
+	batch.Status = status
+	batch.SucceededCount = succeeded
+	batch.FailedCount = failed
+	batch.CompletedAt = completedAt
+	s.record(ctx, func() { *batch = prev })
+	return nil
+}
+
+func copyBatch(batch *models.TransferBatch) *models.TransferBatch {
+	copied := *batch
+	copied.Items = make([]*models.TransferBatchItem, len(batch.Items))
+	for i, item := range batch.Items {
+		itemCopy := *item
+		itemCopy.TransactionID = copyInt(item.TransactionID)
+		copied.Items[i] = &itemCopy
+	}
+	return &copied
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type BudgetRepository struct {
+	store *Store
+}
+
+func (r *BudgetRepository) Save(ctx context.Context, budget *models.Budget) (*models.Budget, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the CHECK constraint and foreign key on budgets
+	if budget.MonthlyLimit <= 0 {
+		return nil, fmt.Errorf("failed to save budget: violates check constraint \"budgets_monthly_limit_check\"")
+	}
+	if _, ok := s.accounts[budget.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to save budget: violates foreign key constraint")
+	}
+
+	now := time.Now()
+	for _, existing := range s.budgets {
+		if existing.AccountID == budget.AccountID && existing.Category == budget.Category {
+			prev := *existing
+			existing.MonthlyLimit = roundCents(budget.MonthlyLimit)
+			existing.UpdatedAt = now
+			s.record(ctx, func() { *existing = prev })
+			copied := *existing
+			return &copied, nil
+		}
+	}
+
+	created := &models.Budget{
+		ID:           s.nextBudgetID,
+		AccountID:    budget.AccountID,
+		Category:     budget.Category,
+		MonthlyLimit: roundCents(budget.MonthlyLimit),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.nextBudgetID++
+	s.budgets[created.ID] = created
+	s.record(ctx, func() { delete(s.budgets, created.ID) })
+
+	copied := *created
+	return &copied, nil
+}
+
+func (r *BudgetRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Budget, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budgets := make([]*models.Budget, 0)
+	for _, budget := range s.budgets {
+		if budget.AccountID == accountID {
+			copied := *budget
+			budgets = append(budgets, &copied)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Category < budgets[j].Category })
+	return budgets, nil
+}
+
+func (r *BudgetRepository) Delete(ctx context.Context, accountID int, category string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, budget := range s.budgets {
+		if budget.AccountID == accountID && budget.Category == category {
+			delete(s.budgets, id)
+			s.record(ctx, func() { s.budgets[id] = budget })
+			return nil
+		}
+	}
+	return fmt.Errorf("budget not found: %w", repository.ErrNotFound)
+}
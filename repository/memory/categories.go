@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type CategoryRepository struct {
+	store *Store
+}
+
+func (r *CategoryRepository) CreateRule(ctx context.Context, rule *models.CategoryRule) (*models.CategoryRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the CHECK constraint and foreign keys on category_rules
+	if rule.Pattern == "" && rule.CounterpartyAccountID == nil {
+		return nil, fmt.Errorf("failed to create category rule: violates check constraint \"category_rules_check\"")
+	}
+	if _, ok := s.accounts[rule.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to create category rule: violates foreign key constraint")
+	}
+	if rule.CounterpartyAccountID != nil {
+		if _, ok := s.accounts[*rule.CounterpartyAccountID]; !ok {
+			return nil, fmt.Errorf("failed to create category rule: violates foreign key constraint")
+		}
+	}
+
+	created := *rule
+	created.ID = s.nextCategoryRuleID
+	created.CounterpartyAccountID = copyInt(rule.CounterpartyAccountID)
+	created.CounterpartyAccountNumber = ""
+	created.CreatedAt = time.Now()
+	s.nextCategoryRuleID++
+	s.categoryRules[created.ID] = &created
+	s.record(ctx, func() { delete(s.categoryRules, created.ID) })
+
+	return r.copyRule(&created), nil
+}
+
+func (r *CategoryRepository) ListRules(ctx context.Context, accountID int) ([]*models.CategoryRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]*models.CategoryRule, 0)
+	for _, rule := range s.categoryRules {
+		if rule.AccountID == accountID {
+			rules = append(rules, r.copyRule(rule))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (r *CategoryRepository) DeleteRule(ctx context.Context, accountID, ruleID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.categoryRules[ruleID]
+	if !ok || rule.AccountID != accountID {
+		return fmt.Errorf("category rule not found: %w", repository.ErrNotFound)
+	}
+	delete(s.categoryRules, ruleID)
+
+	// Mirrors ON DELETE SET NULL on transaction_categories.rule_id
+	cleared := make([]*models.TransactionCategory, 0)
+	for _, c := range s.assignments[accountID] {
+		if equalInt(c.RuleID, ruleID) {
+			c.RuleID = nil
+			cleared = append(cleared, c)
+		}
+	}
+	s.record(ctx, func() {
+		s.categoryRules[ruleID] = rule
+		for _, c := range cleared {
+			id := ruleID
+			c.RuleID = &id
+		}
+	})
+	return nil
+}
+
+func (r *CategoryRepository) ListUncategorized(ctx context.Context, accountID, limit int) ([]*models.Transaction, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := make([]*models.Transaction, 0)
+	for _, t := range s.transactions {
+		if !involves(t, accountID) || t.Status != models.TransactionStatusCompleted || t.PotID != nil {
+			continue
+		}
+		if _, ok := s.assignments[accountID][t.ID]; ok {
+			continue
+		}
+		transactions = append(transactions, copyTransaction(t))
+	}
+	sort.Slice(transactions, func(i, j int) bool { return transactions[i].ID < transactions[j].ID })
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+	return transactions, nil
+}
+
+func (r *CategoryRepository) Assign(ctx context.Context, category *models.TransactionCategory) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the CHECK constraint and foreign keys on transaction_categories
+	switch category.Source {
+	case models.CategorySourceDefault, models.CategorySourceRule, models.CategorySourceUser:
+	default:
+		return fmt.Errorf("failed to assign category: violates check constraint \"transaction_categories_source_check\"")
+	}
+	if _, ok := s.accounts[category.AccountID]; !ok {
+		return fmt.Errorf("failed to assign category: violates foreign key constraint")
+	}
+	if _, ok := s.transactions[category.TransactionID]; !ok {
+		return fmt.Errorf("failed to assign category: violates foreign key constraint")
+	}
+	if category.RuleID != nil {
+		if _, ok := s.categoryRules[*category.RuleID]; !ok {
+			return fmt.Errorf("failed to assign category: violates foreign key constraint")
+		}
+	}
+
+	assigned, ok := s.assignments[category.AccountID]
+	if !ok {
+		assigned = make(map[int]*models.TransactionCategory)
+		s.assignments[category.AccountID] = assigned
+	}
+	id := category.TransactionID
+	prev, existed := assigned[id]
+	if existed && prev.Source == models.CategorySourceUser && category.Source != models.CategorySourceUser {
+		return nil
+	}
+	saved := *category
+	saved.RuleID = copyInt(category.RuleID)
+	assigned[id] = &saved
+	s.record(ctx, func() {
+		if existed {
+			assigned[id] = prev
+		} else {
+			delete(assigned, id)
+		}
+	})
+	return nil
+}
+
+func (r *CategoryRepository) GetAssignment(ctx context.Context, accountID, transactionID int) (*models.TransactionCategory, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.assignments[accountID][transactionID]
+	if !ok {
+		return nil, fmt.Errorf("transaction category not found: %w", repository.ErrNotFound)
+	}
+	copied := *c
+	copied.RuleID = copyInt(c.RuleID)
+	return &copied, nil
+}
+
+func (r *CategoryRepository) DeleteAssignment(ctx context.Context, accountID, transactionID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assigned := s.assignments[accountID]
+	prev, ok := assigned[transactionID]
+	if !ok {
+		return nil
+	}
+	delete(assigned, transactionID)
+	s.record(ctx, func() { assigned[transactionID] = prev })
+	return nil
+}
+
+func (r *CategoryRepository) ClearAssigned(ctx context.Context, accountID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assigned := s.assignments[accountID]
+	removed := make(map[int]*models.TransactionCategory)
+	for id, c := range assigned {
+		if c.Source != models.CategorySourceUser {
+			removed[id] = c
+			delete(assigned, id)
+		}
+	}
+	s.record(ctx, func() {
+		for id, c := range removed {
+			assigned[id] = c
+		}
+	})
+	return nil
+}
+
+// copyRule copies the rule and fills in the counterparty's account
+// number, as the join in the Postgres store does. The caller holds s.mu.
+func (r *CategoryRepository) copyRule(rule *models.CategoryRule) *models.CategoryRule {
+	copied := *rule
+	copied.CounterpartyAccountID = copyInt(rule.CounterpartyAccountID)
+	if rule.CounterpartyAccountID != nil {
+		if account, ok := r.store.accounts[*rule.CounterpartyAccountID]; ok {
+			copied.CounterpartyAccountNumber = account.AccountNumber
+		}
+	}
+	return &copied
+}
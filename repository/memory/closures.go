@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type ClosureRepository struct {
+	store *Store
+}
+
+func (r *ClosureRepository) Create(ctx context.Context, closure *models.AccountClosure) (*models.AccountClosure, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the primary key, foreign keys and CHECKs on account_closures
+	if _, ok := s.accounts[closure.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to record account closure: violates foreign key constraint")
+	}
+	if _, ok := s.closures[closure.AccountID]; ok {
+		return nil, fmt.Errorf("failed to record account closure: %w", repository.ErrDuplicate)
+	}
+	if closure.PayoutAccountID != nil {
+		if _, ok := s.accounts[*closure.PayoutAccountID]; !ok || *closure.PayoutAccountID == closure.AccountID {
+			return nil, fmt.Errorf("failed to record account closure: violates check constraint")
+		}
+	}
+	if (closure.PayoutAccountID == nil) != (closure.PayoutTransactionID == nil) {
+		return nil, fmt.Errorf("failed to record account closure: violates check constraint")
+	}
+
+	created := copyClosure(closure)
+	s.closures[closure.AccountID] = created
+	s.record(ctx, func() { delete(s.closures, closure.AccountID) })
+	return copyClosure(created), nil
+}
+
+func (r *ClosureRepository) GetByAccount(ctx context.Context, accountID int) (*models.AccountClosure, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closure, ok := s.closures[accountID]
+	if !ok {
+		return nil, fmt.Errorf("account closure not found: %w", repository.ErrNotFound)
+	}
+	return copyClosure(closure), nil
+}
+
+func (r *ClosureRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.AccountClosure, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closures := make([]*models.AccountClosure, 0)
+	for _, closure := range s.closures {
+		if closure.AnonymizedAt == nil && !closure.RetainUntil.After(now) {
+			closures = append(closures, copyClosure(closure))
+		}
+	}
+	sort.Slice(closures, func(i, j int) bool {
+		if !closures[i].RetainUntil.Equal(closures[j].RetainUntil) {
+			return closures[i].RetainUntil.Before(closures[j].RetainUntil)
+		}
+		return closures[i].AccountID < closures[j].AccountID
+	})
+	if len(closures) > limit {
+		closures = closures[:limit]
+	}
+	return closures, nil
+}
+
+func (r *ClosureRepository) MarkAnonymized(ctx context.Context, accountID int, at time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closure, ok := s.closures[accountID]
+	if !ok || closure.AnonymizedAt != nil {
+		return fmt.Errorf("account closure not found: %w", repository.ErrNotFound)
+	}
+	prev := closure.AnonymizedAt
+	closure.AnonymizedAt = &at
+	s.record(ctx, func() { closure.AnonymizedAt = prev })
+	return nil
+}
+
+func copyClosure(closure *models.AccountClosure) *models.AccountClosure {
+	copied := *closure
+	copied.PayoutAccountID = copyInt(closure.PayoutAccountID)
+	copied.PayoutTransactionID = copyInt(closure.PayoutTransactionID)
+	copied.AnonymizedAt = copyTime(closure.AnonymizedAt)
+	return &copied
+}
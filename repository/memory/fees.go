@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type FeeRepository struct {
+	store *Store
+}
+
+func (r *FeeRepository) ListRules(ctx context.Context) ([]*models.FeeRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]*models.FeeRule, 0)
+	for _, rule := range s.feeRules {
+		if rule.Active {
+			rules = append(rules, copyFeeRule(rule))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (r *FeeRepository) FindRule(ctx context.Context, transactionType, product string) (*models.FeeRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fallback *models.FeeRule
+	for _, rule := range s.feeRules {
+		if !rule.Active || rule.TransactionType != transactionType {
+			continue
+		}
+		if rule.Product != nil && *rule.Product == product {
+			return copyFeeRule(rule), nil
+		}
+		if rule.Product == nil {
+			fallback = rule
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("fee rule not found: %w", repository.ErrNotFound)
+	}
+	return copyFeeRule(fallback), nil
+}
+
+func (r *FeeRepository) CreateRule(ctx context.Context, rule *models.FeeRule) (*models.FeeRule, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key to account_products and idx_fee_rules_active
+	if rule.Product != nil {
+		if _, ok := s.products[*rule.Product]; !ok {
+			return nil, fmt.Errorf("failed to create fee rule: violates foreign key constraint")
+		}
+	}
+	for _, existing := range s.feeRules {
+		if existing.Active && existing.TransactionType == rule.TransactionType && sameProduct(existing.Product, rule.Product) {
+			return nil, fmt.Errorf("failed to create fee rule: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := copyFeeRule(rule)
+	created.ID = s.nextFeeRuleID
+	created.Active = true
+	created.CreatedAt = time.Now()
+	s.nextFeeRuleID++
+	s.feeRules[created.ID] = created
+	s.record(ctx, func() { delete(s.feeRules, created.ID) })
+
+	return copyFeeRule(created), nil
+}
+
+func (r *FeeRepository) DeactivateRule(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.feeRules[id]
+	if !ok || !rule.Active {
+		return fmt.Errorf("fee rule not found: %w", repository.ErrNotFound)
+	}
+	rule.Active = false
+	s.record(ctx, func() { rule.Active = true })
+	return nil
+}
+
+func (r *FeeRepository) RecordMaintenance(ctx context.Context, accountID int, period time.Time, amount float64, transactionID *int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	charged, ok := s.maintenance[accountID]
+	if !ok {
+		charged = make(map[time.Time]float64)
+		s.maintenance[accountID] = charged
+	}
+	if _, ok := charged[period]; ok {
+		return fmt.Errorf("failed to record maintenance fee: %w", repository.ErrDuplicate)
+	}
+	charged[period] = amount
+	s.record(ctx, func() { delete(charged, period) })
+	return nil
+}
+
+func (r *FeeRepository) ListMaintenanceCharged(ctx context.Context, period time.Time) ([]int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0)
+	for accountID, charged := range s.maintenance {
+		if _, ok := charged[period]; ok {
+			ids = append(ids, accountID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func sameProduct(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func copyFeeRule(rule *models.FeeRule) *models.FeeRule {
+	copied := *rule
+	copied.Product = copyString(rule.Product)
+	copied.MinFee = copyString(rule.MinFee)
+	copied.MaxFee = copyString(rule.MaxFee)
+	if rule.Tiers != nil {
+		copied.Tiers = make([]models.FeeTier, len(rule.Tiers))
+		for i, tier := range rule.Tiers {
+			copied.Tiers[i] = tier
+			copied.Tiers[i].UpTo = copyString(tier.UpTo)
+		}
+	}
+	return &copied
+}
+
+func copyString(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
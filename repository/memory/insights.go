@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type InsightRepository struct {
+	store *Store
+}
+
+// insightMove is a transaction as seen from one account: the amount signed
+// from its point of view and the other account of a transfer
+type insightMove struct {
+	transaction    *models.Transaction
+	amount         float64
+	counterpartyID *int
+}
+
+// moves returns the account's completed transactions in [from, to),
+// leaving out pot moves. The caller holds s.mu.
+func (r *InsightRepository) moves(accountID int, from, to time.Time) []insightMove {
+	moves := make([]insightMove, 0)
+	for _, t := range r.store.transactions {
+		if !involves(t, accountID) || t.Status != models.TransactionStatusCompleted || t.PotID != nil {
+			continue
+		}
+		if t.CreatedAt.Before(from) || !t.CreatedAt.Before(to) {
+			continue
+		}
+		if equalInt(t.ToAccountID, accountID) {
+			moves = append(moves, insightMove{transaction: t, amount: t.Amount, counterpartyID: t.FromAccountID})
+		} else {
+			moves = append(moves, insightMove{transaction: t, amount: -t.Amount, counterpartyID: t.ToAccountID})
+		}
+	}
+	return moves
+}
+
+func (r *InsightRepository) MonthlyFlows(ctx context.Context, accountID int, from, to time.Time) ([]*models.MonthlyFlow, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byMonth := make(map[string]*models.MonthlyFlow)
+	for _, m := range r.moves(accountID, from, to) {
+		month := m.transaction.CreatedAt.UTC().Format("2006-01")
+		flow, ok := byMonth[month]
+		if !ok {
+			flow = &models.MonthlyFlow{Month: month}
+			byMonth[month] = flow
+		}
+		addFlow(&flow.Inflow, &flow.Outflow, m.amount)
+	}
+
+	months := make([]*models.MonthlyFlow, 0, len(byMonth))
+	for _, flow := range byMonth {
+		flow.Inflow = roundCents(flow.Inflow)
+		flow.Outflow = roundCents(flow.Outflow)
+		flow.Net = roundCents(flow.Inflow - flow.Outflow)
+		months = append(months, flow)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Month < months[j].Month })
+	return months, nil
+}
+
+func (r *InsightRepository) TopCounterparties(ctx context.Context, accountID int, from, to time.Time, limit int) ([]*models.CounterpartyTotal, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byAccount := make(map[int]*models.CounterpartyTotal)
+	for _, m := range r.moves(accountID, from, to) {
+		if m.counterpartyID == nil {
+			continue
+		}
+		total, ok := byAccount[*m.counterpartyID]
+		if !ok {
+			account, found := s.accounts[*m.counterpartyID]
+			if !found {
+				continue
+			}
+			total = &models.CounterpartyTotal{
+				AccountID:     account.ID,
+				AccountNumber: account.AccountNumber,
+				FirstName:     account.FirstName,
+				LastName:      account.LastName,
+			}
+			byAccount[account.ID] = total
+		}
+		addFlow(&total.Inflow, &total.Outflow, m.amount)
+		total.Count++
+	}
+
+	counterparties := make([]*models.CounterpartyTotal, 0, len(byAccount))
+	for _, total := range byAccount {
+		total.Inflow = roundCents(total.Inflow)
+		total.Outflow = roundCents(total.Outflow)
+		counterparties = append(counterparties, total)
+	}
+	sort.Slice(counterparties, func(i, j int) bool {
+		a, b := counterparties[i], counterparties[j]
+		if a.Inflow+a.Outflow != b.Inflow+b.Outflow {
+			return a.Inflow+a.Outflow > b.Inflow+b.Outflow
+		}
+		return a.AccountID < b.AccountID
+	})
+	if len(counterparties) > limit {
+		counterparties = counterparties[:limit]
+	}
+	return counterparties, nil
+}
+
+func (r *InsightRepository) CategoryTotals(ctx context.Context, accountID int, from, to time.Time) ([]*models.CategoryTotal, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byCategory := make(map[string]*models.CategoryTotal)
+	for _, m := range r.moves(accountID, from, to) {
+		assigned, ok := s.assignments[accountID][m.transaction.ID]
+		if !ok {
+			continue
+		}
+		total, ok := byCategory[assigned.Category]
+		if !ok {
+			total = &models.CategoryTotal{Category: assigned.Category}
+			byCategory[assigned.Category] = total
+		}
+		addFlow(&total.Inflow, &total.Outflow, m.amount)
+		total.Count++
+	}
+
+	totals := make([]*models.CategoryTotal, 0, len(byCategory))
+	for _, total := range byCategory {
+		total.Inflow = roundCents(total.Inflow)
+		total.Outflow = roundCents(total.Outflow)
+		totals = append(totals, total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i], totals[j]
+		if a.Outflow != b.Outflow {
+			return a.Outflow > b.Outflow
+		}
+		if a.Inflow != b.Inflow {
+			return a.Inflow > b.Inflow
+		}
+		return a.Category < b.Category
+	})
+	return totals, nil
+}
+
+// addFlow adds a signed amount to the inflow or outflow it belongs to
+func addFlow(inflow, outflow *float64, amount float64) {
+	if amount > 0 {
+		*inflow += amount
+	} else {
+		*outflow -= amount
+	}
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+// defaultProducts mirrors the rows seeded by schema.sql
+func defaultProducts() map[string]*models.Product {
+	now := time.Now()
+	return map[string]*models.Product{
+		models.ProductChecking: {Code: models.ProductChecking, Name: "Checking", AnnualRate: "0.000000", OverdraftRate: "19.900000", CreatedAt: now},
+		models.ProductSavings:  {Code: models.ProductSavings, Name: "Savings", AnnualRate: "2.500000", OverdraftRate: "19.900000", CreatedAt: now},
+	}
+}
+
+type ProductRepository struct {
+	store *Store
+}
+
+func (r *ProductRepository) List(ctx context.Context) ([]*models.Product, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products := make([]*models.Product, 0, len(s.products))
+	for _, product := range s.products {
+		copied := *product
+		products = append(products, &copied)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Code < products[j].Code })
+	return products, nil
+}
+
+func (r *ProductRepository) GetByCode(ctx context.Context, code string) (*models.Product, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[code]
+	if !ok {
+		return nil, fmt.Errorf("product not found: %w", repository.ErrNotFound)
+	}
+	copied := *product
+	return &copied, nil
+}
+
+func (r *ProductRepository) UpdateRates(ctx context.Context, code, annualRate, overdraftRate string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[code]
+	if !ok {
+		return fmt.Errorf("product not found: %w", repository.ErrNotFound)
+	}
+	prev := *product
+	product.AnnualRate = annualRate
+	product.OverdraftRate = overdraftRate
+	s.record(ctx, func() { *product = prev })
+	return nil
+}
+
+type InterestRepository struct {
+	store *Store
+}
+
+func (r *InterestRepository) CreateAccrual(ctx context.Context, accrual *models.InterestAccrual) (*models.InterestAccrual, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors UNIQUE (account_id, accrual_date)
+	for _, existing := range s.accruals {
+		if existing.AccountID == accrual.AccountID && existing.AccrualDate.Equal(accrual.AccrualDate) {
+			return nil, fmt.Errorf("failed to create accrual: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := *accrual
+	created.ID = s.nextAccrualID
+	created.CreatedAt = time.Now()
+	s.nextAccrualID++
+	s.accruals[created.ID] = &created
+	s.record(ctx, func() { delete(s.accruals, created.ID) })
+
+	copied := created
+	return &copied, nil
+}
+
+func (r *InterestRepository) LastAccrualDate(ctx context.Context, accountID int) (*time.Time, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *time.Time
+	for _, accrual := range s.accruals {
+		if accrual.AccountID == accountID && (last == nil || accrual.AccrualDate.After(*last)) {
+			date := accrual.AccrualDate
+			last = &date
+		}
+	}
+	return last, nil
+}
+
+func (r *InterestRepository) ListAccruals(ctx context.Context, accountID int, from, to time.Time) ([]*models.InterestAccrual, error) {
+	return r.list(func(a *models.InterestAccrual) bool {
+		return a.AccountID == accountID && !a.AccrualDate.Before(from) && a.AccrualDate.Before(to)
+	}), nil
+}
+
+func (r *InterestRepository) ListUncapitalized(ctx context.Context, accountID int, before time.Time) ([]*models.InterestAccrual, error) {
+	return r.list(func(a *models.InterestAccrual) bool {
+		return a.AccountID == accountID && a.AccrualDate.Before(before) && a.CapitalizedAt == nil
+	}), nil
+}
+
+func (r *InterestRepository) AccountsWithUncapitalized(ctx context.Context, before time.Time) ([]int, error) {
+	seen := make(map[int]bool)
+	ids := make([]int, 0)
+	for _, a := range r.list(func(a *models.InterestAccrual) bool {
+		return a.AccrualDate.Before(before) && a.CapitalizedAt == nil
+	}) {
+		if !seen[a.AccountID] {
+			seen[a.AccountID] = true
+			ids = append(ids, a.AccountID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (r *InterestRepository) MarkCapitalized(ctx context.Context, ids []int, transactionID *int, at time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		if accrual, ok := s.accruals[id]; !ok || accrual.CapitalizedAt != nil {
+			return fmt.Errorf("failed to mark accruals capitalized: accrual %d already capitalized: %w", id, repository.ErrDuplicate)
+		}
+	}
+	for _, id := range ids {
+		accrual := s.accruals[id]
+		prev := *accrual
+		capitalizedAt := at
+		accrual.TransactionID = copyInt(transactionID)
+		accrual.CapitalizedAt = &capitalizedAt
+		s.record(ctx, func() { *accrual = prev })
+	}
+	return nil
+}
+
+// list returns copies of the accruals matching keep, oldest first
+func (r *InterestRepository) list(keep func(*models.InterestAccrual) bool) []*models.InterestAccrual {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accruals := make([]*models.InterestAccrual, 0)
+	for _, accrual := range s.accruals {
+		if keep(accrual) {
+			copied := *accrual
+			copied.TransactionID = copyInt(accrual.TransactionID)
+			copied.CapitalizedAt = copyTime(accrual.CapitalizedAt)
+			accruals = append(accruals, &copied)
+		}
+	}
+	sort.Slice(accruals, func(i, j int) bool {
+		if accruals[i].AccrualDate.Equal(accruals[j].AccrualDate) {
+			return accruals[i].AccountID < accruals[j].AccountID
+		}
+		return accruals[i].AccrualDate.Before(accruals[j].AccrualDate)
+	})
+	return accruals
+}
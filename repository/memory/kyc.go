@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type KYCRepository struct {
+	store *Store
+}
+
+func (r *KYCRepository) GetProfile(ctx context.Context, accountID int) (*models.KYCProfile, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, ok := s.kycProfiles[accountID]
+	if !ok {
+		return nil, fmt.Errorf("kyc profile not found: %w", repository.ErrNotFound)
+	}
+	return copyKYCProfile(profile), nil
+}
+
+func (r *KYCRepository) SaveProfile(ctx context.Context, profile *models.KYCProfile) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key and CHECK constraints on kyc_profiles
+	if _, ok := s.accounts[profile.AccountID]; !ok {
+		return fmt.Errorf("failed to save kyc profile: violates foreign key constraint")
+	}
+	if profile.Level < 0 || profile.Level > models.MaxKYCLevel || (profile.Level > 0 && profile.Status != models.KYCApproved) {
+		return fmt.Errorf("failed to save kyc profile: violates check constraint \"kyc_profiles_check\"")
+	}
+
+	profile.UpdatedAt = time.Now()
+	previous, existed := s.kycProfiles[profile.AccountID]
+	s.kycProfiles[profile.AccountID] = copyKYCProfile(profile)
+	s.record(ctx, func() {
+		if existed {
+			s.kycProfiles[profile.AccountID] = previous
+		} else {
+			delete(s.kycProfiles, profile.AccountID)
+		}
+	})
+	return nil
+}
+
+func (r *KYCRepository) ListByStatus(ctx context.Context, status string, limit int) ([]*models.KYCProfile, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profiles := make([]*models.KYCProfile, 0)
+	for _, profile := range s.kycProfiles {
+		if profile.Status == status {
+			profiles = append(profiles, copyKYCProfile(profile))
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		a, b := profiles[i].SubmittedAt, profiles[j].SubmittedAt
+		if (a == nil) != (b == nil) {
+			return b == nil
+		}
+		if a != nil && !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		return profiles[i].AccountID < profiles[j].AccountID
+	})
+	if len(profiles) > limit {
+		profiles = profiles[:limit]
+	}
+	return profiles, nil
+}
+
+func (r *KYCRepository) AddDocument(ctx context.Context, document *models.KYCDocument) (*models.KYCDocument, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key and UNIQUE constraints on kyc_documents
+	if _, ok := s.accounts[document.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to add kyc document: violates foreign key constraint")
+	}
+	for _, existing := range s.kycDocuments {
+		if existing.StorageKey == document.StorageKey {
+			return nil, fmt.Errorf("failed to add kyc document: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := *document
+	created.ID = s.nextKYCDocumentID
+	created.UploadedAt = time.Now()
+	s.nextKYCDocumentID++
+	s.kycDocuments[created.ID] = &created
+	s.record(ctx, func() { delete(s.kycDocuments, created.ID) })
+
+	copied := created
+	return &copied, nil
+}
+
+func (r *KYCRepository) GetDocument(ctx context.Context, id int) (*models.KYCDocument, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	document, ok := s.kycDocuments[id]
+	if !ok {
+		return nil, fmt.Errorf("kyc document not found: %w", repository.ErrNotFound)
+	}
+	copied := *document
+	return &copied, nil
+}
+
+func (r *KYCRepository) ListDocuments(ctx context.Context, accountID int) ([]*models.KYCDocument, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	documents := make([]*models.KYCDocument, 0)
+	for _, document := range s.kycDocuments {
+		if document.AccountID == accountID {
+			copied := *document
+			documents = append(documents, &copied)
+		}
+	}
+	sort.Slice(documents, func(i, j int) bool { return documents[i].ID < documents[j].ID })
+	return documents, nil
+}
+
+func (r *KYCRepository) DeleteByAccount(ctx context.Context, accountID int) ([]string, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0)
+	removed := make([]*models.KYCDocument, 0)
+	for id, document := range s.kycDocuments {
+		if document.AccountID == accountID {
+			keys = append(keys, document.StorageKey)
+			removed = append(removed, document)
+			delete(s.kycDocuments, id)
+		}
+	}
+	profile, hadProfile := s.kycProfiles[accountID]
+	delete(s.kycProfiles, accountID)
+	s.record(ctx, func() {
+		for _, document := range removed {
+			s.kycDocuments[document.ID] = document
+		}
+		if hadProfile {
+			s.kycProfiles[accountID] = profile
+		}
+	})
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func copyKYCProfile(profile *models.KYCProfile) *models.KYCProfile {
+	copied := *profile
+	copied.DateOfBirth = copyTime(profile.DateOfBirth)
+	copied.SubmittedAt = copyTime(profile.SubmittedAt)
+	copied.ReviewedAt = copyTime(profile.ReviewedAt)
+	copied.ReviewedBy = copyInt(profile.ReviewedBy)
+	return &copied
+}
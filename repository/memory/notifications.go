@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type NotificationRepository struct {
+	store *Store
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, accountID int) (*models.NotificationPreferences, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, ok := s.notificationPrefs[accountID]
+	if !ok {
+		return nil, fmt.Errorf("notification preferences not found: %w", repository.ErrNotFound)
+	}
+	return copyPreferences(prefs), nil
+}
+
+func (r *NotificationRepository) SavePreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[prefs.AccountID]; !ok {
+		return fmt.Errorf("failed to save notification preferences: violates foreign key constraint")
+	}
+
+	previous, existed := s.notificationPrefs[prefs.AccountID]
+	prefs.UpdatedAt = time.Now()
+	s.notificationPrefs[prefs.AccountID] = copyPreferences(prefs)
+	s.record(ctx, func() {
+		if existed {
+			s.notificationPrefs[prefs.AccountID] = previous
+		} else {
+			delete(s.notificationPrefs, prefs.AccountID)
+		}
+	})
+	return nil
+}
+
+func (r *NotificationRepository) Enqueue(ctx context.Context, deliveries []*models.NotificationDelivery) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key and CHECK constraint on notification_deliveries
+	for _, d := range deliveries {
+		if _, ok := s.accounts[d.AccountID]; !ok {
+			return fmt.Errorf("failed to enqueue notification: violates foreign key constraint")
+		}
+		switch d.Channel {
+		case models.NotificationChannelEmail, models.NotificationChannelSMS, models.NotificationChannelPush:
+		default:
+			return fmt.Errorf("failed to enqueue notification: violates check constraint \"notification_deliveries_channel_check\"")
+		}
+	}
+
+	now := time.Now()
+	for _, d := range deliveries {
+		d.ID = s.nextDeliveryID
+		d.Status = models.NotificationPending
+		d.Attempts = 0
+		d.NextAttemptAt = now
+		d.CreatedAt = now
+		s.nextDeliveryID++
+
+		stored := copyDelivery(d)
+		s.deliveries[stored.ID] = stored
+		s.record(ctx, func() { delete(s.deliveries, stored.ID) })
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.NotificationDelivery, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]*models.NotificationDelivery, 0)
+	for _, d := range s.deliveries {
+		if d.Status == models.NotificationPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.NotificationDelivery, 0, len(due))
+	for _, d := range due {
+		previous := *d
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		s.record(ctx, func() { *d = previous })
+		claimed = append(claimed, copyDelivery(d))
+	}
+	return claimed, nil
+}
+
+func (r *NotificationRepository) MarkSent(ctx context.Context, id int, at time.Time) error {
+	return r.update(ctx, id, func(d *models.NotificationDelivery) {
+		d.Status = models.NotificationSent
+		d.SentAt = &at
+		d.LastError = ""
+	})
+}
+
+func (r *NotificationRepository) MarkFailed(ctx context.Context, id int, lastError string, retryAt *time.Time) error {
+	return r.update(ctx, id, func(d *models.NotificationDelivery) {
+		d.LastError = lastError
+		if retryAt == nil {
+			d.Status = models.NotificationFailed
+			return
+		}
+		d.Status = models.NotificationPending
+		d.NextAttemptAt = *retryAt
+	})
+}
+
+func (r *NotificationRepository) ListDeliveries(ctx context.Context, accountID, limit int) ([]*models.NotificationDelivery, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]*models.NotificationDelivery, 0)
+	for _, d := range s.deliveries {
+		if d.AccountID == accountID {
+			deliveries = append(deliveries, copyDelivery(d))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *NotificationRepository) DeleteByAccount(ctx context.Context, accountID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make([]*models.NotificationDelivery, 0)
+	for id, d := range s.deliveries {
+		if d.AccountID == accountID {
+			removed = append(removed, d)
+			delete(s.deliveries, id)
+		}
+	}
+	prefs, hadPrefs := s.notificationPrefs[accountID]
+	delete(s.notificationPrefs, accountID)
+	s.record(ctx, func() {
+		for _, d := range removed {
+			s.deliveries[d.ID] = d
+		}
+		if hadPrefs {
+			s.notificationPrefs[accountID] = prefs
+		}
+	})
+	return nil
+}
+
+func (r *NotificationRepository) update(ctx context.Context, id int, apply func(*models.NotificationDelivery)) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[id]
+	if !ok {
+		return fmt.Errorf("notification delivery not found: %w", repository.ErrNotFound)
+	}
+	previous := *d
+	apply(d)
+	s.record(ctx, func() { *d = previous })
+	return nil
+}
+
+func copyPreferences(prefs *models.NotificationPreferences) *models.NotificationPreferences {
+	copied := *prefs
+	copied.MutedEvents = append([]string{}, prefs.MutedEvents...)
+	return &copied
+}
+
+func copyDelivery(d *models.NotificationDelivery) *models.NotificationDelivery {
+	copied := *d
+	copied.SentAt = copyTime(d.SentAt)
+	return &copied
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type PayeeRepository struct {
+	store *Store
+}
+
+func (r *PayeeRepository) Create(ctx context.Context, accountID, payeeAccountID int, nickname, verifiedName string, lastPaidAt *time.Time) (*models.Payee, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign keys, CHECK and UNIQUE constraints on payees
+	for _, id := range []int{accountID, payeeAccountID} {
+		if _, ok := s.accounts[id]; !ok {
+			return nil, fmt.Errorf("failed to create payee: violates foreign key constraint")
+		}
+	}
+	if accountID == payeeAccountID {
+		return nil, fmt.Errorf("failed to create payee: violates check constraint \"payees_check\"")
+	}
+	for _, existing := range s.payees {
+		if existing.AccountID == accountID && existing.PayeeAccountID == payeeAccountID {
+			return nil, fmt.Errorf("failed to create payee: %w", repository.ErrDuplicate)
+		}
+	}
+
+	payee := &models.Payee{
+		ID:             s.nextPayeeID,
+		AccountID:      accountID,
+		PayeeAccountID: payeeAccountID,
+		Nickname:       nickname,
+		VerifiedName:   verifiedName,
+		CreatedAt:      time.Now(),
+		LastPaidAt:     copyTime(lastPaidAt),
+	}
+	s.nextPayeeID++
+	s.payees[payee.ID] = payee
+	s.record(ctx, func() { delete(s.payees, payee.ID) })
+
+	return r.copyPayee(payee), nil
+}
+
+func (r *PayeeRepository) GetByID(ctx context.Context, id int) (*models.Payee, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payee, ok := s.payees[id]
+	if !ok {
+		return nil, fmt.Errorf("payee not found: %w", repository.ErrNotFound)
+	}
+	return r.copyPayee(payee), nil
+}
+
+func (r *PayeeRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Payee, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payees := make([]*models.Payee, 0)
+	for _, payee := range s.payees {
+		if payee.AccountID == accountID {
+			payees = append(payees, r.copyPayee(payee))
+		}
+	}
+	sort.Slice(payees, func(i, j int) bool {
+		a, b := strings.ToLower(payees[i].Nickname), strings.ToLower(payees[j].Nickname)
+		if a == b {
+			return payees[i].ID < payees[j].ID
+		}
+		return a < b
+	})
+	return payees, nil
+}
+
+func (r *PayeeRepository) Delete(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payee, ok := s.payees[id]
+	if !ok {
+		return fmt.Errorf("payee not found: %w", repository.ErrNotFound)
+	}
+	delete(s.payees, id)
+	s.record(ctx, func() { s.payees[id] = payee })
+	return nil
+}
+
+func (r *PayeeRepository) MarkPaid(ctx context.Context, accountID, payeeAccountID int, at time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, payee := range s.payees {
+		if payee.AccountID == accountID && payee.PayeeAccountID == payeeAccountID {
+			prev := payee.LastPaidAt
+			payee.LastPaidAt = &at
+			s.record(ctx, func() { payee.LastPaidAt = prev })
+		}
+	}
+	return nil
+}
+
+func (r *PayeeRepository) ClearVerifiedNames(ctx context.Context, payeeAccountID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, payee := range s.payees {
+		if payee.PayeeAccountID == payeeAccountID {
+			prev := payee.VerifiedName
+			payee.VerifiedName = ""
+			s.record(ctx, func() { payee.VerifiedName = prev })
+		}
+	}
+	return nil
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+// copyPayee copies a stored payee, filling in the payee's account number as
+// the join in the Postgres store does. The caller holds s.mu.
+func (r *PayeeRepository) copyPayee(payee *models.Payee) *models.Payee {
+	copied := *payee
+	copied.LastPaidAt = copyTime(payee.LastPaidAt)
+	if account, ok := r.store.accounts[payee.PayeeAccountID]; ok {
+		copied.PayeeAccountNumber = account.AccountNumber
+	}
+	return &copied
+}
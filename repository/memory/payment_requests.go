@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type PaymentRequestRepository struct {
+	store *Store
+}
+
+func (r *PaymentRequestRepository) Create(ctx context.Context, request *models.PaymentRequest) (*models.PaymentRequest, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign keys and CHECK constraints on payment_requests
+	for _, id := range []int{request.RequesterAccountID, request.PayerAccountID} {
+		if _, ok := s.accounts[id]; !ok {
+			return nil, fmt.Errorf("failed to create payment request: violates foreign key constraint")
+		}
+	}
+	if request.RequesterAccountID == request.PayerAccountID {
+		return nil, fmt.Errorf("failed to create payment request: violates check constraint \"payment_requests_check\"")
+	}
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("failed to create payment request: violates check constraint \"payment_requests_amount_check\"")
+	}
+
+	created := &models.PaymentRequest{
+		ID:                 s.nextPaymentRequestID,
+		RequesterAccountID: request.RequesterAccountID,
+		PayerAccountID:     request.PayerAccountID,
+		Amount:             request.Amount,
+		Memo:               request.Memo,
+		Status:             models.PaymentRequestPending,
+		ExpiresAt:          request.ExpiresAt,
+		CreatedAt:          time.Now(),
+	}
+	s.nextPaymentRequestID++
+	s.paymentRequests[created.ID] = created
+	s.record(ctx, func() { delete(s.paymentRequests, created.ID) })
+
+	return r.copyPaymentRequest(created), nil
+}
+
+func (r *PaymentRequestRepository) GetByID(ctx context.Context, id int) (*models.PaymentRequest, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.paymentRequests[id]
+	if !ok {
+		return nil, fmt.Errorf("payment request not found: %w", repository.ErrNotFound)
+	}
+	return r.copyPaymentRequest(request), nil
+}
+
+func (r *PaymentRequestRepository) ListByAccount(ctx context.Context, accountID int, incoming bool, status string) ([]*models.PaymentRequest, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]*models.PaymentRequest, 0)
+	for _, request := range s.paymentRequests {
+		party := request.RequesterAccountID
+		if incoming {
+			party = request.PayerAccountID
+		}
+		if party == accountID && (status == "" || request.Status == status) {
+			requests = append(requests, r.copyPaymentRequest(request))
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID > requests[j].ID })
+	return requests, nil
+}
+
+func (r *PaymentRequestRepository) Resolve(ctx context.Context, id int, status string, transactionID *int, at time.Time) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.paymentRequests[id]
+	if !ok || request.Status != models.PaymentRequestPending {
+		return fmt.Errorf("pending payment request not found: %w", repository.ErrNotFound)
+	}
+	if transactionID != nil {
+		if _, ok := s.transactions[*transactionID]; !ok {
+			return fmt.Errorf("failed to resolve payment request: violates foreign key constraint")
+		}
+	}
+
+	previous := *request
+	request.Status = status
+	request.TransactionID = copyInt(transactionID)
+	request.ResolvedAt = &at
+	s.record(ctx, func() { *request = previous })
+	return nil
+}
+
+func (r *PaymentRequestRepository) ListExpired(ctx context.Context, now time.Time) ([]*models.PaymentRequest, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]*models.PaymentRequest, 0)
+	for _, request := range s.paymentRequests {
+		if request.Status == models.PaymentRequestPending && !request.ExpiresAt.After(now) {
+			requests = append(requests, r.copyPaymentRequest(request))
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return requests, nil
+}
+
+// copyPaymentRequest copies a stored request, filling in both parties'
+// account numbers and names as the joins in the Postgres store do. The
+// caller holds s.mu.
+func (r *PaymentRequestRepository) copyPaymentRequest(request *models.PaymentRequest) *models.PaymentRequest {
+	copied := *request
+	copied.TransactionID = copyInt(request.TransactionID)
+	copied.ResolvedAt = copyTime(request.ResolvedAt)
+	if account, ok := r.store.accounts[request.RequesterAccountID]; ok {
+		copied.RequesterAccountNumber = account.AccountNumber
+		copied.RequesterName = strings.TrimSpace(account.FirstName + " " + account.LastName)
+	}
+	if account, ok := r.store.accounts[request.PayerAccountID]; ok {
+		copied.PayerAccountNumber = account.AccountNumber
+		copied.PayerName = strings.TrimSpace(account.FirstName + " " + account.LastName)
+	}
+	return &copied
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type PotRepository struct {
+	store *Store
+}
+
+func (r *PotRepository) Create(ctx context.Context, pot *models.Pot) (*models.Pot, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[pot.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to create pot: violates foreign key constraint")
+	}
+	if s.potConflict(pot) {
+		return nil, fmt.Errorf("failed to create pot: %w", repository.ErrDuplicate)
+	}
+
+	created := copyPot(pot)
+	created.ID = s.nextPotID
+	created.Balance = 0
+	created.CreatedAt = time.Now()
+	created.ClosedAt = nil
+	s.nextPotID++
+	s.pots[created.ID] = created
+	s.record(ctx, func() { delete(s.pots, created.ID) })
+
+	return copyPot(created), nil
+}
+
+func (r *PotRepository) GetByID(ctx context.Context, id int) (*models.Pot, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, ok := s.pots[id]
+	if !ok {
+		return nil, fmt.Errorf("pot not found: %w", repository.ErrNotFound)
+	}
+	return copyPot(pot), nil
+}
+
+func (r *PotRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Pot, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pots := make([]*models.Pot, 0)
+	for _, pot := range s.pots {
+		if pot.AccountID == accountID && pot.ClosedAt == nil {
+			pots = append(pots, copyPot(pot))
+		}
+	}
+	sort.Slice(pots, func(i, j int) bool { return pots[i].ID < pots[j].ID })
+	return pots, nil
+}
+
+func (r *PotRepository) Update(ctx context.Context, pot *models.Pot) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.pots[pot.ID]
+	if !ok || existing.ClosedAt != nil {
+		return fmt.Errorf("pot not found: %w", repository.ErrNotFound)
+	}
+	if s.potConflict(pot) {
+		return fmt.Errorf("failed to update pot: %w", repository.ErrDuplicate)
+	}
+
+	previous := *existing
+	existing.Name = pot.Name
+	existing.TargetAmount = copyFloat(pot.TargetAmount)
+	existing.TargetDate = copyTime(pot.TargetDate)
+	existing.RoundUp = pot.RoundUp
+	s.record(ctx, func() { *existing = previous })
+	return nil
+}
+
+func (r *PotRepository) UpdateBalance(ctx context.Context, id int, balance float64) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, ok := s.pots[id]
+	if !ok || pot.ClosedAt != nil {
+		return fmt.Errorf("pot not found: %w", repository.ErrNotFound)
+	}
+	// Mirrors the CHECK constraint on pots.balance
+	if balance < 0 {
+		return fmt.Errorf("failed to update pot balance: violates check constraint \"pots_balance_check\"")
+	}
+	previous := pot.Balance
+	pot.Balance = balance
+	s.record(ctx, func() { pot.Balance = previous })
+	return nil
+}
+
+func (r *PotRepository) GetRoundUp(ctx context.Context, accountID int) (*models.Pot, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pot := range s.pots {
+		if pot.AccountID == accountID && pot.RoundUp && pot.ClosedAt == nil {
+			return copyPot(pot), nil
+		}
+	}
+	return nil, fmt.Errorf("round-up pot not found: %w", repository.ErrNotFound)
+}
+
+func (r *PotRepository) ClearRoundUp(ctx context.Context, accountID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pot := range s.pots {
+		if pot.AccountID == accountID && pot.RoundUp {
+			pot.RoundUp = false
+			s.record(ctx, func() { pot.RoundUp = true })
+		}
+	}
+	return nil
+}
+
+func (r *PotRepository) Close(ctx context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pot, ok := s.pots[id]
+	if !ok || pot.ClosedAt != nil || pot.Balance != 0 {
+		return fmt.Errorf("pot not found: %w", repository.ErrNotFound)
+	}
+	previous := *pot
+	now := time.Now()
+	pot.ClosedAt = &now
+	pot.RoundUp = false
+	s.record(ctx, func() { *pot = previous })
+	return nil
+}
+
+func (r *PotRepository) TotalByAccount(ctx context.Context, accountID int) (float64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total float64
+	for _, pot := range s.pots {
+		if pot.AccountID == accountID && pot.ClosedAt == nil {
+			total += pot.Balance
+		}
+	}
+	return total, nil
+}
+
+// potConflict mirrors idx_pots_name and idx_pots_round_up. Must be called
+// with s.mu held.
+func (s *Store) potConflict(pot *models.Pot) bool {
+	for _, existing := range s.pots {
+		if existing.ID == pot.ID || existing.AccountID != pot.AccountID || existing.ClosedAt != nil {
+			continue
+		}
+		if strings.EqualFold(existing.Name, pot.Name) || (existing.RoundUp && pot.RoundUp) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyPot(pot *models.Pot) *models.Pot {
+	copied := *pot
+	copied.TargetAmount = copyFloat(pot.TargetAmount)
+	copied.TargetDate = copyTime(pot.TargetDate)
+	copied.ClosedAt = copyTime(pot.ClosedAt)
+	return &copied
+}
+
+func copyFloat(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type ReconciliationRepository struct {
+	store *Store
+}
+
+func (r *ReconciliationRepository) CheckBalances(ctx context.Context) (int, []*models.BalanceDiscrepancy, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ledgers := make(map[int]*models.BalanceDiscrepancy, len(s.accounts))
+	for id, account := range s.accounts {
+		ledgers[id] = &models.BalanceDiscrepancy{
+			AccountID:     id,
+			AccountNumber: account.AccountNumber,
+			StoredBalance: account.Balance,
+		}
+	}
+	apply := func(accountID *int, delta float64, t *models.Transaction) {
+		if accountID == nil {
+			return
+		}
+		d, ok := ledgers[*accountID]
+		if !ok {
+			return
+		}
+		d.LedgerBalance += delta
+		d.TransactionCount++
+		if d.LastTransactionAt == nil || t.CreatedAt.After(*d.LastTransactionAt) {
+			d.LastTransactionAt = copyTime(&t.CreatedAt)
+		}
+	}
+	for _, t := range s.transactions {
+		if t.Status != models.TransactionStatusCompleted {
+			continue
+		}
+		apply(t.ToAccountID, t.Amount, t)
+		apply(t.FromAccountID, -t.Amount, t)
+	}
+
+	// Rounded to cents, as NUMERIC(15,2) sums are exact in Postgres
+	discrepancies := make([]*models.BalanceDiscrepancy, 0)
+	for _, d := range ledgers {
+		d.LedgerBalance = roundCents(d.LedgerBalance)
+		d.Difference = roundCents(d.StoredBalance - d.LedgerBalance)
+		if d.Difference != 0 {
+			discrepancies = append(discrepancies, d)
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].AccountID < discrepancies[j].AccountID })
+	return len(s.accounts), discrepancies, nil
+}
+
+func (r *ReconciliationRepository) CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.BalanceDiscrepancy) (*models.ReconciliationRun, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the CHECKs, foreign keys and UNIQUE (run_id, account_id) on balance_discrepancies
+	if run.Trigger != models.ReconciliationScheduled && run.Trigger != models.ReconciliationManual {
+		return nil, fmt.Errorf("failed to record reconciliation run: violates check constraint")
+	}
+	seen := make(map[int]bool, len(discrepancies))
+	for _, d := range discrepancies {
+		if _, ok := s.accounts[d.AccountID]; !ok {
+			return nil, fmt.Errorf("failed to record reconciliation run: violates foreign key constraint")
+		}
+		if d.Difference == 0 {
+			return nil, fmt.Errorf("failed to record reconciliation run: violates check constraint")
+		}
+		if seen[d.AccountID] {
+			return nil, fmt.Errorf("failed to record reconciliation run: %w", repository.ErrDuplicate)
+		}
+		seen[d.AccountID] = true
+	}
+
+	created := *run
+	created.ID = s.nextReconRunID
+	s.nextReconRunID++
+	s.reconRuns[created.ID] = &created
+
+	ids := make([]int, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		stored := copyDiscrepancy(d)
+		stored.ID = s.nextDiscrepancyID
+		stored.RunID = created.ID
+		stored.AccountNumber = ""
+		s.nextDiscrepancyID++
+		s.discrepancies[stored.ID] = stored
+		ids = append(ids, stored.ID)
+		d.ID = stored.ID
+		d.RunID = created.ID
+	}
+	s.record(ctx, func() {
+		delete(s.reconRuns, created.ID)
+		for _, id := range ids {
+			delete(s.discrepancies, id)
+		}
+	})
+
+	result := created
+	return &result, nil
+}
+
+func (r *ReconciliationRepository) GetRun(ctx context.Context, id int) (*models.ReconciliationRun, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.reconRuns[id]
+	if !ok {
+		return nil, fmt.Errorf("reconciliation run not found: %w", repository.ErrNotFound)
+	}
+	copied := *run
+	return &copied, nil
+}
+
+func (r *ReconciliationRepository) GetLatestRun(ctx context.Context, trigger string) (*models.ReconciliationRun, error) {
+	runs := r.sortedRuns(func(run *models.ReconciliationRun) bool {
+		return trigger == "" || run.Trigger == trigger
+	})
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("reconciliation run not found: %w", repository.ErrNotFound)
+	}
+	return runs[0], nil
+}
+
+func (r *ReconciliationRepository) ListRuns(ctx context.Context, limit int) ([]*models.ReconciliationRun, error) {
+	runs := r.sortedRuns(func(*models.ReconciliationRun) bool { return true })
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (r *ReconciliationRepository) ListDiscrepancies(ctx context.Context, runID int) ([]*models.BalanceDiscrepancy, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	discrepancies := make([]*models.BalanceDiscrepancy, 0)
+	for _, d := range s.discrepancies {
+		if d.RunID != runID {
+			continue
+		}
+		copied := copyDiscrepancy(d)
+		if account, ok := s.accounts[d.AccountID]; ok {
+			copied.AccountNumber = account.AccountNumber
+		}
+		discrepancies = append(discrepancies, copied)
+	}
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].AccountID < discrepancies[j].AccountID })
+	return discrepancies, nil
+}
+
+// sortedRuns returns copies of the matching runs, newest first
+func (r *ReconciliationRepository) sortedRuns(match func(*models.ReconciliationRun) bool) []*models.ReconciliationRun {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]*models.ReconciliationRun, 0)
+	for _, run := range s.reconRuns {
+		if match(run) {
+			copied := *run
+			runs = append(runs, &copied)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	return runs
+}
+
+func copyDiscrepancy(d *models.BalanceDiscrepancy) *models.BalanceDiscrepancy {
+	copied := *d
+	copied.LastTransactionAt = copyTime(d.LastTransactionAt)
+	return &copied
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type SessionRepository struct {
+	store *Store
+}
+
+func (r *SessionRepository) Create(ctx context.Context, sessionID string, accountID int, expiresAt time.Time) (*models.Session, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[sessionID]; exists {
+		return nil, fmt.Errorf("failed to create a session: %w", repository.ErrDuplicate)
+	}
+	if _, ok := s.accounts[accountID]; !ok {
+		return nil, fmt.Errorf("failed to create a session: violates foreign key constraint")
+	}
+
+	session := &models.Session{
+		ID:        sessionID,
+		AccountID: accountID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	s.sessions[sessionID] = session
+	s.record(ctx, func() { delete(s.sessions, sessionID) })
+
+	copied := *session
+	return &copied, nil
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (*models.Session, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %w", repository.ErrNotFound)
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *SessionRepository) GetByAccountID(ctx context.Context, accountID string) ([]*models.Session, error) {
+	id, err := strconv.Atoi(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*models.Session, 0)
+	for _, session := range s.sessions {
+		if session.AccountID == id {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Delete(ctx context.Context, sessionID string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session not found: %w", repository.ErrNotFound)
+	}
+	delete(s.sessions, sessionID)
+	s.record(ctx, func() { s.sessions[sessionID] = session })
+	return nil
+}
+
+func (r *SessionRepository) DeleteAccountByID(ctx context.Context, accountID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, session := range s.sessions {
+		if session.AccountID == accountID {
+			delete(s.sessions, id)
+			s.record(ctx, func() { s.sessions[id] = session })
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return fmt.Errorf("session not found: %w", repository.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *SessionRepository) DeleteExpired(ctx context.Context) (int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for id, session := range s.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(s.sessions, id)
+			s.record(ctx, func() { s.sessions[id] = session })
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *SessionRepository) IsValid(ctx context.Context, sessionID string) (bool, error) {
+	session, err := r.GetByID(ctx, sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	if session.IsExpired() {
+		r.Delete(ctx, sessionID)
+		return false, nil
+	}
+	return true, nil
+}
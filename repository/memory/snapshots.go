@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type SnapshotRepository struct {
+	store *Store
+}
+
+func (r *SnapshotRepository) Save(ctx context.Context, snapshot *models.BalanceSnapshot) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key on balance_snapshots
+	if _, ok := s.accounts[snapshot.AccountID]; !ok {
+		return fmt.Errorf("failed to save balance snapshot: violates foreign key constraint")
+	}
+	days, ok := s.snapshots[snapshot.AccountID]
+	if !ok {
+		days = make(map[time.Time]*models.BalanceSnapshot)
+		s.snapshots[snapshot.AccountID] = days
+	}
+
+	day := snapshot.SnapshotDate
+	prev, existed := days[day]
+	saved := *snapshot
+	saved.Balance = roundCents(saved.Balance)
+	saved.CreatedAt = time.Now()
+	days[day] = &saved
+	s.record(ctx, func() {
+		if existed {
+			days[day] = prev
+		} else {
+			delete(days, day)
+		}
+	})
+	return nil
+}
+
+func (r *SnapshotRepository) LastDate(ctx context.Context, accountID int) (*time.Time, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *time.Time
+	for day := range s.snapshots[accountID] {
+		if last == nil || day.After(*last) {
+			d := day
+			last = &d
+		}
+	}
+	return last, nil
+}
+
+func (r *SnapshotRepository) GetLatest(ctx context.Context, accountID int, day time.Time) (*models.BalanceSnapshot, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest *models.BalanceSnapshot
+	for date, snapshot := range s.snapshots[accountID] {
+		if date.After(day) {
+			continue
+		}
+		if latest == nil || date.After(latest.SnapshotDate) {
+			latest = snapshot
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("balance snapshot not found: %w", repository.ErrNotFound)
+	}
+	copied := *latest
+	return &copied, nil
+}
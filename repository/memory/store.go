@@ -0,0 +1,326 @@
+// Package memory is an in-memory implementation of the repository stores.
+// It mimics the Postgres behaviour the services rely on (row locks taken by
+// GetBalanceForUpdate, rollback on error, constraint checks) so services can
+// be unit tested without a database.
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type Store struct {
+	mu sync.Mutex
+
+	accounts          map[int]*models.Account
+	transactions      map[int]*models.Transaction
+	sessions          map[string]*models.Session
+	batches           map[int]*models.TransferBatch
+	payees            map[int]*models.Payee
+	pots              map[int]*models.Pot
+	paymentRequests   map[int]*models.PaymentRequest
+	notificationPrefs map[int]*models.NotificationPreferences
+	deliveries        map[int]*models.NotificationDelivery
+	alertRules        map[int]*models.AlertRule
+	verifications     map[int]*models.EmailVerification
+	kycProfiles       map[int]*models.KYCProfile
+	kycDocuments      map[int]*models.KYCDocument
+	closures          map[int]*models.AccountClosure
+	reconRuns         map[int]*models.ReconciliationRun
+	discrepancies     map[int]*models.BalanceDiscrepancy
+	products          map[string]*models.Product
+	accruals          map[int]*models.InterestAccrual
+	feeRules          map[int]*models.FeeRule
+	// maintenance holds the periods each account has been charged for
+	maintenance map[int]map[time.Time]float64
+	// snapshots holds each account's end-of-day balances by day
+	snapshots map[int]map[time.Time]*models.BalanceSnapshot
+	// assignments holds each account's transaction categories by transaction ID
+	assignments   map[int]map[int]*models.TransactionCategory
+	categoryRules map[int]*models.CategoryRule
+	budgets       map[int]*models.Budget
+	// annotations holds each account's notes and tags by transaction ID
+	annotations map[int]map[int]*models.TransactionAnnotation
+	attachments map[int]*models.TransactionAttachment
+
+	nextAccountID        int
+	nextTransactionID    int
+	nextBatchID          int
+	nextBatchItemID      int
+	nextPayeeID          int
+	nextPotID            int
+	nextPaymentRequestID int
+	nextDeliveryID       int
+	nextAlertRuleID      int
+	nextVerificationID   int
+	nextKYCDocumentID    int
+	nextAccrualID        int
+	nextFeeRuleID        int
+	nextReconRunID       int
+	nextDiscrepancyID    int
+	nextCategoryRuleID   int
+	nextBudgetID         int
+	nextAttachmentID     int
+
+	// rowLocks emulates SELECT ... FOR UPDATE: one slot per account id
+	rowLocks map[int]chan struct{}
+}
+
+func NewStore() *Store {
+	return &Store{
+		accounts:             make(map[int]*models.Account),
+		transactions:         make(map[int]*models.Transaction),
+		sessions:             make(map[string]*models.Session),
+		batches:              make(map[int]*models.TransferBatch),
+		payees:               make(map[int]*models.Payee),
+		pots:                 make(map[int]*models.Pot),
+		paymentRequests:      make(map[int]*models.PaymentRequest),
+		notificationPrefs:    make(map[int]*models.NotificationPreferences),
+		deliveries:           make(map[int]*models.NotificationDelivery),
+		alertRules:           make(map[int]*models.AlertRule),
+		verifications:        make(map[int]*models.EmailVerification),
+		kycProfiles:          make(map[int]*models.KYCProfile),
+		kycDocuments:         make(map[int]*models.KYCDocument),
+		closures:             make(map[int]*models.AccountClosure),
+		reconRuns:            make(map[int]*models.ReconciliationRun),
+		discrepancies:        make(map[int]*models.BalanceDiscrepancy),
+		snapshots:            make(map[int]map[time.Time]*models.BalanceSnapshot),
+		assignments:          make(map[int]map[int]*models.TransactionCategory),
+		categoryRules:        make(map[int]*models.CategoryRule),
+		budgets:              make(map[int]*models.Budget),
+		annotations:          make(map[int]map[int]*models.TransactionAnnotation),
+		attachments:          make(map[int]*models.TransactionAttachment),
+		products:             defaultProducts(),
+		accruals:             make(map[int]*models.InterestAccrual),
+		feeRules:             make(map[int]*models.FeeRule),
+		maintenance:          make(map[int]map[time.Time]float64),
+		nextAccountID:        1,
+		nextTransactionID:    1,
+		nextBatchID:          1,
+		nextBatchItemID:      1,
+		nextPayeeID:          1,
+		nextPotID:            1,
+		nextPaymentRequestID: 1,
+		nextDeliveryID:       1,
+		nextAlertRuleID:      1,
+		nextVerificationID:   1,
+		nextKYCDocumentID:    1,
+		nextAccrualID:        1,
+		nextFeeRuleID:        1,
+		nextReconRunID:       1,
+		nextDiscrepancyID:    1,
+		nextCategoryRuleID:   1,
+		nextBudgetID:         1,
+		nextAttachmentID:     1,
+		rowLocks:             make(map[int]chan struct{}),
+	}
+}
+
+func (s *Store) Accounts() *AccountRepository {
+	return &AccountRepository{store: s}
+}
+
+func (s *Store) Transactions() *TransactionRepository {
+	return &TransactionRepository{store: s}
+}
+
+func (s *Store) Sessions() *SessionRepository {
+	return &SessionRepository{store: s}
+}
+
+func (s *Store) Batches() *BatchRepository {
+	return &BatchRepository{store: s}
+}
+
+func (s *Store) Payees() *PayeeRepository {
+	return &PayeeRepository{store: s}
+}
+
+func (s *Store) Pots() *PotRepository {
+	return &PotRepository{store: s}
+}
+
+func (s *Store) PaymentRequests() *PaymentRequestRepository {
+	return &PaymentRequestRepository{store: s}
+}
+
+func (s *Store) Notifications() *NotificationRepository {
+	return &NotificationRepository{store: s}
+}
+
+func (s *Store) Alerts() *AlertRepository {
+	return &AlertRepository{store: s}
+}
+
+func (s *Store) Verifications() *VerificationRepository {
+	return &VerificationRepository{store: s}
+}
+
+func (s *Store) KYC() *KYCRepository {
+	return &KYCRepository{store: s}
+}
+
+func (s *Store) Closures() *ClosureRepository {
+	return &ClosureRepository{store: s}
+}
+
+func (s *Store) Reconciliations() *ReconciliationRepository {
+	return &ReconciliationRepository{store: s}
+}
+
+func (s *Store) Snapshots() *SnapshotRepository {
+	return &SnapshotRepository{store: s}
+}
+
+func (s *Store) Categories() *CategoryRepository {
+	return &CategoryRepository{store: s}
+}
+
+func (s *Store) Insights() *InsightRepository {
+	return &InsightRepository{store: s}
+}
+
+func (s *Store) Budgets() *BudgetRepository {
+	return &BudgetRepository{store: s}
+}
+
+func (s *Store) Annotations() *AnnotationRepository {
+	return &AnnotationRepository{store: s}
+}
+
+func (s *Store) Products() *ProductRepository {
+	return &ProductRepository{store: s}
+}
+
+func (s *Store) Interest() *InterestRepository {
+	return &InterestRepository{store: s}
+}
+
+func (s *Store) Fees() *FeeRepository {
+	return &FeeRepository{store: s}
+}
+
+// memTx tracks the row locks held and the writes to undo on rollback
+type memTx struct {
+	held map[int]bool
+	undo []func()
+}
+
+type txKey struct{}
+
+func txFromContext(ctx context.Context) *memTx {
+	tx, _ := ctx.Value(txKey{}).(*memTx)
+	return tx
+}
+
+// WithTransaction runs fn atomically. Like db.DB, nested calls join the
+// outer transaction.
+func (s *Store) WithTransaction(ctx context.Context, fn db.TxFunc) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx := &memTx{held: make(map[int]bool)}
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.rollback(tx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		s.rollback(tx)
+		return err
+	}
+	s.releaseLocks(tx)
+	return nil
+}
+
+func (s *Store) rollback(tx *memTx) {
+	s.mu.Lock()
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	s.mu.Unlock()
+	s.releaseLocks(tx)
+}
+
+func (s *Store) releaseLocks(tx *memTx) {
+	for id := range tx.held {
+		<-s.rowLock(id)
+	}
+	tx.held = nil
+}
+
+// record registers an undo step for the transaction in ctx. Must be called
+// with s.mu held.
+func (s *Store) record(ctx context.Context, undo func()) {
+	if tx := txFromContext(ctx); tx != nil {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+func (s *Store) rowLock(id int) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.rowLocks[id]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		s.rowLocks[id] = ch
+	}
+	return ch
+}
+
+// lockRow blocks until the account row is free. Inside a transaction the lock
+// is kept until commit or rollback; outside one the returned func releases it.
+func (s *Store) lockRow(ctx context.Context, id int) (func(), error) {
+	tx := txFromContext(ctx)
+	if tx != nil && tx.held[id] {
+		return func() {}, nil
+	}
+
+	ch := s.rowLock(id)
+	select {
+	case ch <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if tx != nil {
+		tx.held[id] = true
+		return func() {}, nil
+	}
+	return func() { <-ch }, nil
+}
+
+var (
+	_ repository.TxRunner            = (*Store)(nil)
+	_ repository.AccountStore        = (*AccountRepository)(nil)
+	_ repository.TransactionStore    = (*TransactionRepository)(nil)
+	_ repository.SessionStore        = (*SessionRepository)(nil)
+	_ repository.BatchStore          = (*BatchRepository)(nil)
+	_ repository.PayeeStore          = (*PayeeRepository)(nil)
+	_ repository.PotStore            = (*PotRepository)(nil)
+	_ repository.PaymentRequestStore = (*PaymentRequestRepository)(nil)
+	_ repository.NotificationStore   = (*NotificationRepository)(nil)
+	_ repository.AlertStore          = (*AlertRepository)(nil)
+	_ repository.VerificationStore   = (*VerificationRepository)(nil)
+	_ repository.KYCStore            = (*KYCRepository)(nil)
+	_ repository.ClosureStore        = (*ClosureRepository)(nil)
+	_ repository.ReconciliationStore = (*ReconciliationRepository)(nil)
+	_ repository.SnapshotStore       = (*SnapshotRepository)(nil)
+	_ repository.CategoryStore       = (*CategoryRepository)(nil)
+	_ repository.InsightStore        = (*InsightRepository)(nil)
+	_ repository.BudgetStore         = (*BudgetRepository)(nil)
+	_ repository.AnnotationStore     = (*AnnotationRepository)(nil)
+	_ repository.ProductStore        = (*ProductRepository)(nil)
+	_ repository.InterestStore       = (*InterestRepository)(nil)
+	_ repository.FeeStore            = (*FeeRepository)(nil)
+)
@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type TransactionRepository struct {
+	store *Store
+}
+
+func (r *TransactionRepository) Create(ctx context.Context, fromAccountID, toAccountID *int, amount float64, transactionType, description string) (*models.Transaction, error) {
+	return r.Insert(ctx, &models.Transaction{
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        amount,
+		Type:          transactionType,
+		Description:   description,
+	})
+}
+
+func (r *TransactionRepository) Insert(ctx context.Context, fields *models.Transaction) (*models.Transaction, error) {
+	fromAccountID, toAccountID, amount := fields.FromAccountID, fields.ToAccountID, fields.Amount
+	relatedTransactionID, potID := fields.RelatedTransactionID, fields.PotID
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the CHECK constraints and foreign keys on the transactions table
+	if amount <= 0 {
+		return nil, fmt.Errorf("failed to create transaction: violates check constraint \"transactions_amount_check\"")
+	}
+	if fromAccountID == nil && toAccountID == nil {
+		return nil, fmt.Errorf("failed to create transaction: violates check constraint \"transactions_check\"")
+	}
+	if fromAccountID != nil && toAccountID != nil && *fromAccountID == *toAccountID {
+		return nil, fmt.Errorf("failed to create transaction: violates check constraint \"transactions_check1\"")
+	}
+	for _, id := range []*int{fromAccountID, toAccountID} {
+		if id != nil {
+			if _, ok := s.accounts[*id]; !ok {
+				return nil, fmt.Errorf("failed to create transaction: violates foreign key constraint")
+			}
+		}
+	}
+	if relatedTransactionID != nil {
+		if _, ok := s.transactions[*relatedTransactionID]; !ok {
+			return nil, fmt.Errorf("failed to create transaction: violates foreign key constraint")
+		}
+	}
+	if potID != nil {
+		if _, ok := s.pots[*potID]; !ok {
+			return nil, fmt.Errorf("failed to create transaction: violates foreign key constraint")
+		}
+	}
+
+	transaction := &models.Transaction{
+		ID:                   s.nextTransactionID,
+		FromAccountID:        copyInt(fromAccountID),
+		ToAccountID:          copyInt(toAccountID),
+		Amount:               amount,
+		Type:                 fields.Type,
+		Description:          fields.Description,
+		Status:               models.TransactionStatusCompleted,
+		RelatedTransactionID: copyInt(relatedTransactionID),
+		PotID:                copyInt(potID),
+		CreatedAt:            time.Now(),
+	}
+	s.nextTransactionID++
+	s.transactions[transaction.ID] = transaction
+	s.record(ctx, func() { delete(s.transactions, transaction.ID) })
+
+	return copyTransaction(transaction), nil
+}
+
+func (r *TransactionRepository) GetByID(ctx context.Context, id int) (*models.Transaction, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.transactions[id]
+	if !ok {
+		return nil, fmt.Errorf("transactions not found: %w", repository.ErrNotFound)
+	}
+	return copyTransaction(transaction), nil
+}
+
+func (r *TransactionRepository) ListByAccount(ctx context.Context, accountID int, filter models.TransactionFilter, after *models.TransactionCursor, limit int) ([]*models.Transaction, error) {
+	search := strings.ToLower(filter.Search)
+	transactions := r.filter(func(t *models.Transaction) bool {
+		switch {
+		case !involves(t, accountID):
+			return false
+		case filter.Direction == models.TransactionDirectionIncoming && !equalInt(t.ToAccountID, accountID):
+			return false
+		case filter.Direction == models.TransactionDirectionOutgoing && !equalInt(t.FromAccountID, accountID):
+			return false
+		case filter.Type != "" && t.Type != filter.Type:
+			return false
+		case filter.Status != "" && t.Status != filter.Status:
+			return false
+		case filter.MinAmount != nil && t.Amount < *filter.MinAmount:
+			return false
+		case filter.MaxAmount != nil && t.Amount > *filter.MaxAmount:
+			return false
+		case filter.From != nil && t.CreatedAt.Before(*filter.From):
+			return false
+		case filter.To != nil && !t.CreatedAt.Before(*filter.To):
+			return false
+		case search != "" && !strings.Contains(strings.ToLower(t.Description), search):
+			return false
+		}
+		// filter holds s.mu while matching
+		if filter.Tag != "" {
+			annotation, ok := r.store.annotations[accountID][t.ID]
+			if !ok || !slices.Contains(annotation.Tags, filter.Tag) {
+				return false
+			}
+		}
+		if filter.CounterpartyID != nil {
+			cp := *filter.CounterpartyID
+			if !(equalInt(t.FromAccountID, accountID) && equalInt(t.ToAccountID, cp)) &&
+				!(equalInt(t.ToAccountID, accountID) && equalInt(t.FromAccountID, cp)) {
+				return false
+			}
+		}
+		if after != nil {
+			if filter.Ascending {
+				return isBefore(after.CreatedAt, after.ID, t)
+			}
+			return isBefore(t.CreatedAt, t.ID, &models.Transaction{CreatedAt: after.CreatedAt, ID: after.ID})
+		}
+		return true
+	})
+
+	if filter.Ascending {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+	return transactions, nil
+}
+
+func (r *TransactionRepository) GetRecent(ctx context.Context, accountID, limit int) ([]*models.Transaction, error) {
+	transactions := r.filter(func(t *models.Transaction) bool {
+		return involves(t, accountID)
+	})
+	return paginate(transactions, 1, limit), nil
+}
+
+func (r *TransactionRepository) GetByDateRange(ctx context.Context, accountID int, startDate, endDate time.Time, fn func(*models.Transaction) error) error {
+	transactions := r.filter(func(t *models.Transaction) bool {
+		return involves(t, accountID) && !t.CreatedAt.Before(startDate) && t.CreatedAt.Before(endDate)
+	})
+	for i := len(transactions) - 1; i >= 0; i-- {
+		if err := fn(transactions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *TransactionRepository) GetBalanceAt(ctx context.Context, accountID int, at time.Time) (float64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var balance float64
+	for _, t := range s.transactions {
+		if t.Status != models.TransactionStatusCompleted || !t.CreatedAt.Before(at) {
+			continue
+		}
+		if equalInt(t.ToAccountID, accountID) {
+			balance += t.Amount
+		}
+		if equalInt(t.FromAccountID, accountID) {
+			balance -= t.Amount
+		}
+	}
+	return balance, nil
+}
+
+func (r *TransactionRepository) GetTotalBalance(ctx context.Context, accountID int) (float64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total float64
+	for _, t := range s.transactions {
+		if t.Status != models.TransactionStatusCompleted {
+			continue
+		}
+		if t.ToAccountID != nil && *t.ToAccountID == accountID {
+			total += t.Amount
+		}
+		if t.FromAccountID != nil && *t.FromAccountID == accountID {
+			total -= t.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *TransactionRepository) LastTransferAt(ctx context.Context, fromAccountID, toAccountID int) (*time.Time, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *time.Time
+	for _, t := range s.transactions {
+		if t.Status != models.TransactionStatusCompleted || !equalInt(t.FromAccountID, fromAccountID) || !equalInt(t.ToAccountID, toAccountID) {
+			continue
+		}
+		if last == nil || t.CreatedAt.After(*last) {
+			createdAt := t.CreatedAt
+			last = &createdAt
+		}
+	}
+	return last, nil
+}
+
+func (r *TransactionRepository) SumSentSince(ctx context.Context, accountID int, since time.Time) (float64, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total float64
+	for _, t := range s.transactions {
+		if t.Status != models.TransactionStatusCompleted || !equalInt(t.FromAccountID, accountID) || t.CreatedAt.Before(since) {
+			continue
+		}
+		if t.Type == models.TransactionTypeWithdraw || t.Type == models.TransactionTypeTransfer {
+			total += t.Amount
+		}
+	}
+	return total, nil
+}
+
+// filter returns copies of the matching transactions, newest first
+func (r *TransactionRepository) filter(match func(*models.Transaction) bool) []*models.Transaction {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := make([]*models.Transaction, 0)
+	for _, t := range s.transactions {
+		if match(t) {
+			transactions = append(transactions, copyTransaction(t))
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		if transactions[i].CreatedAt.Equal(transactions[j].CreatedAt) {
+			return transactions[i].ID > transactions[j].ID
+		}
+		return transactions[i].CreatedAt.After(transactions[j].CreatedAt)
+	})
+	return transactions
+}
+
+// isBefore reports whether (createdAt, id) sorts before t
+func isBefore(createdAt time.Time, id int, t *models.Transaction) bool {
+	if createdAt.Equal(t.CreatedAt) {
+		return id < t.ID
+	}
+	return createdAt.Before(t.CreatedAt)
+}
+
+func equalInt(v *int, want int) bool {
+	return v != nil && *v == want
+}
+
+func involves(t *models.Transaction, accountID int) bool {
+	return (t.FromAccountID != nil && *t.FromAccountID == accountID) ||
+		(t.ToAccountID != nil && *t.ToAccountID == accountID)
+}
+
+func copyInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
+
+func copyTransaction(t *models.Transaction) *models.Transaction {
+	copied := *t
+	copied.FromAccountID = copyInt(t.FromAccountID)
+	copied.ToAccountID = copyInt(t.ToAccountID)
+	copied.RelatedTransactionID = copyInt(t.RelatedTransactionID)
+	copied.PotID = copyInt(t.PotID)
+	return &copied
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+type VerificationRepository struct {
+	store *Store
+}
+
+func (r *VerificationRepository) Create(ctx context.Context, verification *models.EmailVerification) (*models.EmailVerification, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the foreign key and UNIQUE constraints on email_verifications
+	if _, ok := s.accounts[verification.AccountID]; !ok {
+		return nil, fmt.Errorf("failed to create email verification: violates foreign key constraint")
+	}
+	for _, existing := range s.verifications {
+		if existing.TokenHash == verification.TokenHash {
+			return nil, fmt.Errorf("failed to create email verification: %w", repository.ErrDuplicate)
+		}
+	}
+
+	created := &models.EmailVerification{
+		ID:        s.nextVerificationID,
+		AccountID: verification.AccountID,
+		TokenHash: verification.TokenHash,
+		ExpiresAt: verification.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	s.nextVerificationID++
+	s.verifications[created.ID] = created
+	s.record(ctx, func() { delete(s.verifications, created.ID) })
+
+	return copyVerification(created), nil
+}
+
+func (r *VerificationRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.EmailVerification, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, verification := range s.verifications {
+		if verification.TokenHash == tokenHash {
+			return copyVerification(verification), nil
+		}
+	}
+	return nil, fmt.Errorf("email verification not found: %w", repository.ErrNotFound)
+}
+
+func (r *VerificationRepository) MarkUsed(ctx context.Context, id int, at time.Time) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.verifications[id]
+	if !ok || verification.UsedAt != nil {
+		return false, nil
+	}
+	verification.UsedAt = &at
+	s.record(ctx, func() { verification.UsedAt = nil })
+	return true, nil
+}
+
+func (r *VerificationRepository) CountSince(ctx context.Context, accountID int, since time.Time) (int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, verification := range s.verifications {
+		if verification.AccountID == accountID && !verification.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func copyVerification(verification *models.EmailVerification) *models.EmailVerification {
+	copied := *verification
+	copied.UsedAt = copyTime(verification.UsedAt)
+	return &copied
+}
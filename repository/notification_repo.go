@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type NotificationRepository struct {
+	db *db.DB
+}
+
+func NewNotificationRepository(db *db.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+const deliveryColumns = `id, account_id, event_type, channel, recipient, subject, body, html_body, status,
+	attempts, next_attempt_at, last_error, created_at, sent_at`
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, accountID int) (*models.NotificationPreferences, error) {
+	query := `
+	SELECT account_id, email_enabled, sms_enabled, push_enabled, phone, push_token, muted_events, updated_at
+	FROM notification_preferences
+	WHERE account_id = $1
+	`
+	ctx, span := startSpan(ctx, "NotificationRepository.GetPreferences", query)
+	defer span.End()
+
+	prefs := &models.NotificationPreferences{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(
+		&prefs.AccountID, &prefs.EmailEnabled, &prefs.SMSEnabled, &prefs.PushEnabled,
+		&prefs.Phone, &prefs.PushToken, pq.Array(&prefs.MutedEvents), &prefs.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("notification preferences not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	if prefs.MutedEvents == nil {
+		prefs.MutedEvents = []string{}
+	}
+	return prefs, nil
+}
+
+func (r *NotificationRepository) SavePreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	query := `
+	INSERT INTO notification_preferences (account_id, email_enabled, sms_enabled, push_enabled, phone, push_token, muted_events, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+	ON CONFLICT (account_id) DO UPDATE
+	SET email_enabled = EXCLUDED.email_enabled, sms_enabled = EXCLUDED.sms_enabled, push_enabled = EXCLUDED.push_enabled,
+		phone = EXCLUDED.phone, push_token = EXCLUDED.push_token, muted_events = EXCLUDED.muted_events,
+		updated_at = EXCLUDED.updated_at
+	RETURNING updated_at
+	`
+	ctx, span := startSpan(ctx, "NotificationRepository.SavePreferences", query)
+	defer span.End()
+
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query,
+		prefs.AccountID, prefs.EmailEnabled, prefs.SMSEnabled, prefs.PushEnabled,
+		prefs.Phone, prefs.PushToken, pq.Array(prefs.MutedEvents),
+	).Scan(&prefs.UpdatedAt)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) Enqueue(ctx context.Context, deliveries []*models.NotificationDelivery) error {
+	query := `
+	INSERT INTO notification_deliveries (account_id, event_type, channel, recipient, subject, body, html_body)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, status, next_attempt_at, created_at
+	`
+	ctx, span := startSpan(ctx, "NotificationRepository.Enqueue", query)
+	defer span.End()
+
+	return r.db.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, d := range deliveries {
+			err := r.db.Conn(ctx).QueryRowContext(ctx, query,
+				d.AccountID, d.EventType, d.Channel, d.Recipient, d.Subject, d.Body, d.HTMLBody,
+			).Scan(&d.ID, &d.Status, &d.NextAttemptAt, &d.CreatedAt)
+			if err != nil {
+				span.RecordError(err)
+				return fmt.Errorf("failed to enqueue notification: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *NotificationRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.NotificationDelivery, error) {
+	// SKIP LOCKED lets several workers claim disjoint batches
+	query := `
+	UPDATE notification_deliveries
+	SET attempts = attempts + 1, next_attempt_at = $2
+	WHERE id IN (
+		SELECT id FROM notification_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at, id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + deliveryColumns
+	return r.list(ctx, "NotificationRepository.ClaimDue", query, now, now.Add(lease), limit)
+}
+
+func (r *NotificationRepository) MarkSent(ctx context.Context, id int, at time.Time) error {
+	query := `
+	UPDATE notification_deliveries
+	SET status = 'sent', sent_at = $2, last_error = ''
+	WHERE id = $1
+	`
+	return r.update(ctx, "NotificationRepository.MarkSent", query, id, at)
+}
+
+func (r *NotificationRepository) MarkFailed(ctx context.Context, id int, lastError string, retryAt *time.Time) error {
+	query := `
+	UPDATE notification_deliveries
+	SET last_error = $2,
+		status = CASE WHEN $3::timestamp IS NULL THEN 'failed' ELSE 'pending' END,
+		next_attempt_at = COALESCE($3, next_attempt_at)
+	WHERE id = $1
+	`
+	return r.update(ctx, "NotificationRepository.MarkFailed", query, id, lastError, retryAt)
+}
+
+func (r *NotificationRepository) ListDeliveries(ctx context.Context, accountID, limit int) ([]*models.NotificationDelivery, error) {
+	query := `
+	SELECT ` + deliveryColumns + `
+	FROM notification_deliveries
+	WHERE account_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2
+	`
+	return r.list(ctx, "NotificationRepository.ListDeliveries", query, accountID, limit)
+}
+
+func (r *NotificationRepository) DeleteByAccount(ctx context.Context, accountID int) error {
+	query := `
+	WITH deliveries AS (
+		DELETE FROM notification_deliveries WHERE account_id = $1
+	)
+	DELETE FROM notification_preferences WHERE account_id = $1
+	`
+	ctx, span := startSpan(ctx, "NotificationRepository.DeleteByAccount", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete notification data: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) update(ctx context.Context, name, query string, args ...any) error {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update notification delivery: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("notification delivery not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) list(ctx context.Context, name, query string, args ...any) ([]*models.NotificationDelivery, error) {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.NotificationDelivery, 0)
+	for rows.Next() {
+		d := &models.NotificationDelivery{}
+		err := rows.Scan(&d.ID, &d.AccountID, &d.EventType, &d.Channel, &d.Recipient, &d.Subject, &d.Body, &d.HTMLBody,
+			&d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification deliveries: %w", err)
+	}
+	return deliveries, nil
+}
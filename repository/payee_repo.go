@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type PayeeRepository struct {
+	db *db.DB
+}
+
+func NewPayeeRepository(db *db.DB) *PayeeRepository {
+	return &PayeeRepository{db: db}
+}
+
+func (r *PayeeRepository) Create(ctx context.Context, accountID, payeeAccountID int, nickname, verifiedName string, lastPaidAt *time.Time) (*models.Payee, error) {
+	query := `
+	WITH inserted AS (
+		INSERT INTO payees (account_id, payee_account_id, nickname, verified_name, last_paid_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, account_id, payee_account_id, nickname, verified_name, created_at, last_paid_at
+	)
+	SELECT i.id, i.account_id, i.payee_account_id, a.account_number, i.nickname, i.verified_name, i.created_at, i.last_paid_at
+	FROM inserted i
+	JOIN accounts a ON a.id = i.payee_account_id
+	`
+	ctx, span := startSpan(ctx, "PayeeRepository.Create", query)
+	defer span.End()
+
+	payee := &models.Payee{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, payeeAccountID, nickname, verifiedName, lastPaidAt).Scan(
+		&payee.ID, &payee.AccountID, &payee.PayeeAccountID, &payee.PayeeAccountNumber, &payee.Nickname, &payee.VerifiedName, &payee.CreatedAt, &payee.LastPaidAt,
+	)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create payee: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create payee: %w", err)
+	}
+	return payee, nil
+}
+
+func (r *PayeeRepository) GetByID(ctx context.Context, id int) (*models.Payee, error) {
+	query := `
+	SELECT p.id, p.account_id, p.payee_account_id, a.account_number, p.nickname, p.verified_name, p.created_at, p.last_paid_at
+	FROM payees p
+	JOIN accounts a ON a.id = p.payee_account_id
+	WHERE p.id = $1
+	`
+	ctx, span := startSpan(ctx, "PayeeRepository.GetByID", query)
+	defer span.End()
+
+	payee := &models.Payee{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(
+		&payee.ID, &payee.AccountID, &payee.PayeeAccountID, &payee.PayeeAccountNumber, &payee.Nickname, &payee.VerifiedName, &payee.CreatedAt, &payee.LastPaidAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payee not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get payee: %w", err)
+	}
+	return payee, nil
+}
+
+// ListByAccount returns an account's payees ordered by nickname
+func (r *PayeeRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Payee, error) {
+	query := `
+	SELECT p.id, p.account_id, p.payee_account_id, a.account_number, p.nickname, p.verified_name, p.created_at, p.last_paid_at
+	FROM payees p
+	JOIN accounts a ON a.id = p.payee_account_id
+	WHERE p.account_id = $1
+	ORDER BY LOWER(p.nickname), p.id
+	`
+	ctx, span := startSpan(ctx, "PayeeRepository.ListByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list payees: %w", err)
+	}
+	defer rows.Close()
+
+	payees := make([]*models.Payee, 0)
+	for rows.Next() {
+		payee := &models.Payee{}
+		err := rows.Scan(&payee.ID, &payee.AccountID, &payee.PayeeAccountID, &payee.PayeeAccountNumber, &payee.Nickname, &payee.VerifiedName, &payee.CreatedAt, &payee.LastPaidAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payee: %w", err)
+		}
+		payees = append(payees, payee)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payees: %w", err)
+	}
+	return payees, nil
+}
+
+func (r *PayeeRepository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM payees WHERE id = $1`
+	ctx, span := startSpan(ctx, "PayeeRepository.Delete", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to delete payee: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("payee not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// MarkPaid records a payment to payeeAccountID if it is in accountID's
+// address book. Paying someone who is not a saved payee is not an error.
+func (r *PayeeRepository) MarkPaid(ctx context.Context, accountID, payeeAccountID int, at time.Time) error {
+	query := `
+	UPDATE payees
+	SET last_paid_at = $3
+	WHERE account_id = $1 AND payee_account_id = $2
+	`
+	ctx, span := startSpan(ctx, "PayeeRepository.MarkPaid", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID, payeeAccountID, at); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to mark payee paid: %w", err)
+	}
+	return nil
+}
+
+func (r *PayeeRepository) ClearVerifiedNames(ctx context.Context, payeeAccountID int) error {
+	query := `UPDATE payees SET verified_name = '' WHERE payee_account_id = $1`
+	ctx, span := startSpan(ctx, "PayeeRepository.ClearVerifiedNames", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, payeeAccountID); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to clear payee names: %w", err)
+	}
+	return nil
+}
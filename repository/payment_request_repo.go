@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type PaymentRequestRepository struct {
+	db *db.DB
+}
+
+func NewPaymentRequestRepository(db *db.DB) *PaymentRequestRepository {
+	return &PaymentRequestRepository{db: db}
+}
+
+// paymentRequestSelect joins both parties' accounts for their numbers and
+// names
+const paymentRequestSelect = `
+	SELECT p.id, p.requester_account_id, p.payer_account_id, p.amount, p.memo, p.status, p.transaction_id,
+		p.expires_at, p.created_at, p.resolved_at,
+		r.account_number, r.first_name || ' ' || r.last_name, y.account_number, y.first_name || ' ' || y.last_name
+	FROM payment_requests p
+	JOIN accounts r ON r.id = p.requester_account_id
+	JOIN accounts y ON y.id = p.payer_account_id
+	`
+
+func (r *PaymentRequestRepository) Create(ctx context.Context, request *models.PaymentRequest) (*models.PaymentRequest, error) {
+	query := `
+	INSERT INTO payment_requests (requester_account_id, payer_account_id, amount, memo, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+	`
+	ctx, span := startSpan(ctx, "PaymentRequestRepository.Create", query)
+	defer span.End()
+
+	var id int
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query,
+		request.RequesterAccountID, request.PayerAccountID, request.Amount, request.Memo, request.ExpiresAt,
+	).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create payment request: %w", err)
+	}
+	return r.GetByID(ctx, id)
+}
+
+func (r *PaymentRequestRepository) GetByID(ctx context.Context, id int) (*models.PaymentRequest, error) {
+	query := paymentRequestSelect + `WHERE p.id = $1`
+	ctx, span := startSpan(ctx, "PaymentRequestRepository.GetByID", query)
+	defer span.End()
+
+	request, err := scanPaymentRequest(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("payment request not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return request, nil
+}
+
+func (r *PaymentRequestRepository) ListByAccount(ctx context.Context, accountID int, incoming bool, status string) ([]*models.PaymentRequest, error) {
+	column := "p.requester_account_id"
+	if incoming {
+		column = "p.payer_account_id"
+	}
+	query := paymentRequestSelect + `
+	WHERE ` + column + ` = $1 AND ($2 = '' OR p.status = $2)
+	ORDER BY p.created_at DESC, p.id DESC
+	`
+	return r.list(ctx, "PaymentRequestRepository.ListByAccount", query, accountID, status)
+}
+
+func (r *PaymentRequestRepository) Resolve(ctx context.Context, id int, status string, transactionID *int, at time.Time) error {
+	query := `
+	UPDATE payment_requests
+	SET status = $2, transaction_id = $3, resolved_at = $4
+	WHERE id = $1 AND status = 'pending'
+	`
+	ctx, span := startSpan(ctx, "PaymentRequestRepository.Resolve", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id, status, transactionID, at)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to resolve payment request: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("pending payment request not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *PaymentRequestRepository) ListExpired(ctx context.Context, now time.Time) ([]*models.PaymentRequest, error) {
+	query := paymentRequestSelect + `
+	WHERE p.status = 'pending' AND p.expires_at <= $1
+	ORDER BY p.expires_at, p.id
+	`
+	return r.list(ctx, "PaymentRequestRepository.ListExpired", query, now)
+}
+
+func (r *PaymentRequestRepository) list(ctx context.Context, name, query string, args ...any) ([]*models.PaymentRequest, error) {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]*models.PaymentRequest, 0)
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment requests: %w", err)
+	}
+	return requests, nil
+}
+
+// scanPaymentRequest reads a row selected with paymentRequestSelect.
+// sql.ErrNoRows is returned unwrapped.
+func scanPaymentRequest(row rowScanner) (*models.PaymentRequest, error) {
+	request := &models.PaymentRequest{}
+	err := row.Scan(&request.ID, &request.RequesterAccountID, &request.PayerAccountID, &request.Amount, &request.Memo,
+		&request.Status, &request.TransactionID, &request.ExpiresAt, &request.CreatedAt, &request.ResolvedAt,
+		&request.RequesterAccountNumber, &request.RequesterName, &request.PayerAccountNumber, &request.PayerName)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan payment request: %w", err)
+	}
+	return request, nil
+}
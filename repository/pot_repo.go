@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type PotRepository struct {
+	db *db.DB
+}
+
+func NewPotRepository(db *db.DB) *PotRepository {
+	return &PotRepository{db: db}
+}
+
+const potColumns = `id, account_id, name, balance, target_amount, target_date, round_up, created_at, closed_at`
+
+// Create opens a pot. An open pot with the same name, or a second round-up
+// pot, fails with ErrDuplicate.
+func (r *PotRepository) Create(ctx context.Context, pot *models.Pot) (*models.Pot, error) {
+	query := `
+	INSERT INTO pots (account_id, name, target_amount, target_date, round_up)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + potColumns
+	ctx, span := startSpan(ctx, "PotRepository.Create", query)
+	defer span.End()
+
+	created, err := scanPot(r.db.Conn(ctx).QueryRowContext(ctx, query,
+		pot.AccountID, pot.Name, pot.TargetAmount, nullableDate(pot.TargetDate), pot.RoundUp,
+	))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create pot: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *PotRepository) GetByID(ctx context.Context, id int) (*models.Pot, error) {
+	query := `SELECT ` + potColumns + ` FROM pots WHERE id = $1`
+	ctx, span := startSpan(ctx, "PotRepository.GetByID", query)
+	defer span.End()
+
+	pot, err := scanPot(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("pot not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return pot, nil
+}
+
+func (r *PotRepository) ListByAccount(ctx context.Context, accountID int) ([]*models.Pot, error) {
+	query := `
+	SELECT ` + potColumns + `
+	FROM pots
+	WHERE account_id = $1 AND closed_at IS NULL
+	ORDER BY created_at, id
+	`
+	ctx, span := startSpan(ctx, "PotRepository.ListByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list pots: %w", err)
+	}
+	defer rows.Close()
+
+	pots := make([]*models.Pot, 0)
+	for rows.Next() {
+		pot, err := scanPot(rows)
+		if err != nil {
+			return nil, err
+		}
+		pots = append(pots, pot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pots: %w", err)
+	}
+	return pots, nil
+}
+
+// Update saves a pot's name, target and round-up setting
+func (r *PotRepository) Update(ctx context.Context, pot *models.Pot) error {
+	query := `
+	UPDATE pots
+	SET name = $2, target_amount = $3, target_date = $4, round_up = $5
+	WHERE id = $1 AND closed_at IS NULL
+	`
+	ctx, span := startSpan(ctx, "PotRepository.Update", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, pot.ID, pot.Name, pot.TargetAmount, nullableDate(pot.TargetDate), pot.RoundUp)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("failed to update pot: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update pot: %w", err)
+	}
+	return expectOnePot(result)
+}
+
+func (r *PotRepository) UpdateBalance(ctx context.Context, id int, balance float64) error {
+	query := `UPDATE pots SET balance = $2 WHERE id = $1 AND closed_at IS NULL`
+	ctx, span := startSpan(ctx, "PotRepository.UpdateBalance", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id, balance)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update pot balance: %w", err)
+	}
+	return expectOnePot(result)
+}
+
+func (r *PotRepository) GetRoundUp(ctx context.Context, accountID int) (*models.Pot, error) {
+	query := `SELECT ` + potColumns + ` FROM pots WHERE account_id = $1 AND round_up AND closed_at IS NULL`
+	ctx, span := startSpan(ctx, "PotRepository.GetRoundUp", query)
+	defer span.End()
+
+	pot, err := scanPot(r.db.Conn(ctx).QueryRowContext(ctx, query, accountID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("round-up pot not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return pot, nil
+}
+
+// ClearRoundUp turns round-ups off for all of the account's pots
+func (r *PotRepository) ClearRoundUp(ctx context.Context, accountID int) error {
+	query := `UPDATE pots SET round_up = FALSE WHERE account_id = $1 AND round_up`
+	ctx, span := startSpan(ctx, "PotRepository.ClearRoundUp", query)
+	defer span.End()
+
+	if _, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to clear round-up pot: %w", err)
+	}
+	return nil
+}
+
+// Close retires an empty pot. Its transactions keep referring to it.
+func (r *PotRepository) Close(ctx context.Context, id int) error {
+	query := `
+	UPDATE pots
+	SET closed_at = CURRENT_TIMESTAMP, round_up = FALSE
+	WHERE id = $1 AND closed_at IS NULL AND balance = 0
+	`
+	ctx, span := startSpan(ctx, "PotRepository.Close", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to close pot: %w", err)
+	}
+	return expectOnePot(result)
+}
+
+func (r *PotRepository) TotalByAccount(ctx context.Context, accountID int) (float64, error) {
+	query := `SELECT COALESCE(SUM(balance), 0) FROM pots WHERE account_id = $1 AND closed_at IS NULL`
+	ctx, span := startSpan(ctx, "PotRepository.TotalByAccount", query)
+	defer span.End()
+
+	var total float64
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(&total); err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to total pots: %w", err)
+	}
+	return total, nil
+}
+
+func expectOnePot(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("pot not found: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// nullableDate formats an optional date for a DATE column
+func nullableDate(date *time.Time) any {
+	if date == nil {
+		return nil
+	}
+	return date.Format(dateLayout)
+}
+
+// scanPot reads a row selected with potColumns. sql.ErrNoRows is returned
+// unwrapped.
+func scanPot(row rowScanner) (*models.Pot, error) {
+	pot := &models.Pot{}
+	err := row.Scan(&pot.ID, &pot.AccountID, &pot.Name, &pot.Balance, &pot.TargetAmount, &pot.TargetDate,
+		&pot.RoundUp, &pot.CreatedAt, &pot.ClosedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan pot: %w", err)
+	}
+	return pot, nil
+}
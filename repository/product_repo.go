@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type ProductRepository struct {
+	db *db.DB
+}
+
+func NewProductRepository(db *db.DB) *ProductRepository {
+	return &ProductRepository{db: db}
+}
+
+func (r *ProductRepository) List(ctx context.Context) ([]*models.Product, error) {
+	query := `
+	SELECT code, name, annual_rate, overdraft_rate, created_at
+	FROM account_products
+	ORDER BY code
+	`
+	ctx, span := startSpan(ctx, "ProductRepository.List", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]*models.Product, 0)
+	for rows.Next() {
+		product := &models.Product{}
+		if err := rows.Scan(&product.Code, &product.Name, &product.AnnualRate, &product.OverdraftRate, &product.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+	return products, nil
+}
+
+func (r *ProductRepository) GetByCode(ctx context.Context, code string) (*models.Product, error) {
+	query := `
+	SELECT code, name, annual_rate, overdraft_rate, created_at
+	FROM account_products
+	WHERE code = $1
+	`
+	ctx, span := startSpan(ctx, "ProductRepository.GetByCode", query)
+	defer span.End()
+
+	product := &models.Product{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, code).Scan(&product.Code, &product.Name, &product.AnnualRate, &product.OverdraftRate, &product.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return product, nil
+}
+
+// UpdateRates changes the rates used for accruals from now on. Accruals
+// already recorded keep the rate they were computed with.
+func (r *ProductRepository) UpdateRates(ctx context.Context, code, annualRate, overdraftRate string) error {
+	query := `UPDATE account_products SET annual_rate = $1, overdraft_rate = $2 WHERE code = $3`
+	ctx, span := startSpan(ctx, "ProductRepository.UpdateRates", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, annualRate, overdraftRate, code)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to update product rate: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("product not found: %w", ErrNotFound)
+	}
+	return nil
+}
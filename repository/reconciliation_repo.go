@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type ReconciliationRepository struct {
+	db *db.DB
+}
+
+func NewReconciliationRepository(db *db.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+const reconciliationRunColumns = `id, trigger, started_at, finished_at, accounts_checked, discrepancies, accounts_frozen`
+
+func (r *ReconciliationRepository) CheckBalances(ctx context.Context) (int, []*models.BalanceDiscrepancy, error) {
+	// One statement, so every account is compared as of the same snapshot
+	query := `
+	WITH ledger AS (
+		SELECT account_id, SUM(delta) AS balance, COUNT(*) AS transaction_count, MAX(created_at) AS last_transaction_at
+		FROM (
+			SELECT to_account_id AS account_id, amount AS delta, created_at
+			FROM transactions
+			WHERE status = $1 AND to_account_id IS NOT NULL
+			UNION ALL
+			SELECT from_account_id, -amount, created_at
+			FROM transactions
+			WHERE status = $1 AND from_account_id IS NOT NULL
+		) moves
+		GROUP BY account_id
+	),
+	checked AS (
+		SELECT a.id, a.account_number, COALESCE(a.balance, 0) AS stored_balance, COALESCE(l.balance, 0) AS ledger_balance,
+			COALESCE(l.transaction_count, 0) AS transaction_count, l.last_transaction_at
+		FROM accounts a
+		LEFT JOIN ledger l ON l.account_id = a.id
+	)
+	SELECT (SELECT COUNT(*) FROM checked), id, account_number, stored_balance, ledger_balance,
+		stored_balance - ledger_balance, transaction_count, last_transaction_at
+	FROM checked
+	WHERE stored_balance != ledger_balance
+	ORDER BY id
+	`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.CheckBalances", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, models.TransactionStatusCompleted)
+	if err != nil {
+		span.RecordError(err)
+		return 0, nil, fmt.Errorf("failed to check balances: %w", err)
+	}
+	defer rows.Close()
+
+	checked := -1
+	discrepancies := make([]*models.BalanceDiscrepancy, 0)
+	for rows.Next() {
+		d := &models.BalanceDiscrepancy{}
+		err := rows.Scan(&checked, &d.AccountID, &d.AccountNumber, &d.StoredBalance, &d.LedgerBalance,
+			&d.Difference, &d.TransactionCount, &d.LastTransactionAt)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to scan discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("error iterating discrepancies: %w", err)
+	}
+	if checked >= 0 {
+		return checked, discrepancies, nil
+	}
+
+	// Everything reconciled, so the count came back with no rows
+	countQuery := `SELECT COUNT(*) FROM accounts`
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, countQuery).Scan(&checked); err != nil {
+		span.RecordError(err)
+		return 0, nil, fmt.Errorf("failed to count accounts: %w", err)
+	}
+	return checked, discrepancies, nil
+}
+
+func (r *ReconciliationRepository) CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.BalanceDiscrepancy) (*models.ReconciliationRun, error) {
+	query := `
+	INSERT INTO reconciliation_runs (trigger, started_at, finished_at, accounts_checked, discrepancies, accounts_frozen)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + reconciliationRunColumns
+	itemQuery := `
+	INSERT INTO balance_discrepancies (run_id, account_id, stored_balance, ledger_balance, difference,
+		transaction_count, last_transaction_at, frozen)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id
+	`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.CreateRun", query)
+	defer span.End()
+
+	var created *models.ReconciliationRun
+	err := r.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = scanReconciliationRun(r.db.Conn(ctx).QueryRowContext(ctx, query,
+			run.Trigger, run.StartedAt, run.FinishedAt, run.AccountsChecked, run.Discrepancies, run.AccountsFrozen,
+		))
+		if err != nil {
+			return err
+		}
+		for _, d := range discrepancies {
+			err := r.db.Conn(ctx).QueryRowContext(ctx, itemQuery,
+				created.ID, d.AccountID, d.StoredBalance, d.LedgerBalance, d.Difference,
+				d.TransactionCount, d.LastTransactionAt, d.Frozen,
+			).Scan(&d.ID)
+			if err != nil {
+				return err
+			}
+			d.RunID = created.ID
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to record reconciliation run: %w", err)
+	}
+	return created, nil
+}
+
+func (r *ReconciliationRepository) GetRun(ctx context.Context, id int) (*models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM reconciliation_runs WHERE id = $1`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.GetRun", query)
+	defer span.End()
+
+	run, err := scanReconciliationRun(r.db.Conn(ctx).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reconciliation run not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get reconciliation run: %w", err)
+	}
+	return run, nil
+}
+
+func (r *ReconciliationRepository) GetLatestRun(ctx context.Context, trigger string) (*models.ReconciliationRun, error) {
+	query := `
+	SELECT ` + reconciliationRunColumns + `
+	FROM reconciliation_runs
+	WHERE $1 = '' OR trigger = $1
+	ORDER BY started_at DESC, id DESC
+	LIMIT 1
+	`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.GetLatestRun", query)
+	defer span.End()
+
+	run, err := scanReconciliationRun(r.db.Conn(ctx).QueryRowContext(ctx, query, trigger))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reconciliation run not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get latest reconciliation run: %w", err)
+	}
+	return run, nil
+}
+
+func (r *ReconciliationRepository) ListRuns(ctx context.Context, limit int) ([]*models.ReconciliationRun, error) {
+	query := `
+	SELECT ` + reconciliationRunColumns + `
+	FROM reconciliation_runs
+	ORDER BY started_at DESC, id DESC
+	LIMIT $1
+	`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.ListRuns", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list reconciliation runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*models.ReconciliationRun, 0)
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reconciliation runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (r *ReconciliationRepository) ListDiscrepancies(ctx context.Context, runID int) ([]*models.BalanceDiscrepancy, error) {
+	query := `
+	SELECT d.id, d.run_id, d.account_id, a.account_number, d.stored_balance, d.ledger_balance, d.difference,
+		d.transaction_count, d.last_transaction_at, d.frozen
+	FROM balance_discrepancies d
+	JOIN accounts a ON a.id = d.account_id
+	WHERE d.run_id = $1
+	ORDER BY d.account_id
+	`
+	ctx, span := startSpan(ctx, "ReconciliationRepository.ListDiscrepancies", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, runID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	discrepancies := make([]*models.BalanceDiscrepancy, 0)
+	for rows.Next() {
+		d := &models.BalanceDiscrepancy{}
+		err := rows.Scan(&d.ID, &d.RunID, &d.AccountID, &d.AccountNumber, &d.StoredBalance, &d.LedgerBalance, &d.Difference,
+			&d.TransactionCount, &d.LastTransactionAt, &d.Frozen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating discrepancies: %w", err)
+	}
+	return discrepancies, nil
+}
+
+// scanReconciliationRun reads a row of reconciliationRunColumns.
+// sql.ErrNoRows is returned unwrapped.
+func scanReconciliationRun(row rowScanner) (*models.ReconciliationRun, error) {
+	run := &models.ReconciliationRun{}
+	err := row.Scan(&run.ID, &run.Trigger, &run.StartedAt, &run.FinishedAt, &run.AccountsChecked, &run.Discrepancies, &run.AccountsFrozen)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -19,50 +20,61 @@ func NewSessionRepository(db *db.DB) *SessionRepository {
 
 }
 
-func (r *SessionRepository) Create(sessionID string, accountID int, expiresAt time.Time) (*models.Session, error) {
+func (r *SessionRepository) Create(ctx context.Context, sessionID string, accountID int, expiresAt time.Time) (*models.Session, error) {
 
 	query := `
 	INSERT INTO sessions (id,account_id,expires_at)
 	VALUES ($1,$2,$3)
 	RETURNING id, account_id, expires_at, created_at
 	`
+	ctx, span := startSpan(ctx, "SessionRepository.Create", query)
+	defer span.End()
 
 	session := &models.Session{}
 
-	err := r.db.QueryRow(query, sessionID, accountID, expiresAt).Scan(&session.ID, session.AccountID, session.CreatedAt, session.ExpiresAt)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, sessionID, accountID, expiresAt).Scan(&session.ID, &session.AccountID, &session.ExpiresAt, &session.CreatedAt)
 
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to create a session: %w", err)
 	}
 	return session, nil
 }
 
-func (r *SessionRepository) GetByID(sessionID string) (*models.Session, error) {
+func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (*models.Session, error) {
 	query := `
 	SELECT id,account_id,expires_at,created_at
 	FROM sessions
 	WHERE id = $1
 	`
+	ctx, span := startSpan(ctx, "SessionRepository.GetByID", query)
+	defer span.End()
+
 	session := &models.Session{}
-	err := r.db.QueryRow(query, sessionID).Scan(&session.ID, session.AccountID, session.CreatedAt, session.ExpiresAt)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, sessionID).Scan(&session.ID, &session.AccountID, &session.ExpiresAt, &session.CreatedAt)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("session not found")
+		return nil, fmt.Errorf("session not found: %w", ErrNotFound)
 	}
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	return session, nil
 }
 
-func (r *SessionRepository) GetByAccountID(accountID string) ([]*models.Session, error) {
+func (r *SessionRepository) GetByAccountID(ctx context.Context, accountID string) ([]*models.Session, error) {
 	sessions := make([]*models.Session, 0)
 	query := `
 	SELECT id, account_id, expires_at, created_at
 	FROM sessions
 	WHERE account_id = $1
 	`
-	rows, err := r.db.Query(query, accountID)
+	ctx, span := startSpan(ctx, "SessionRepository.GetByAccountID", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID)
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get sessions: %w", err)
 	}
 
@@ -70,7 +82,7 @@ func (r *SessionRepository) GetByAccountID(accountID string) ([]*models.Session,
 	for rows.Next() {
 		session := &models.Session{}
 
-		err := rows.Scan(session.AccountID, session.ID, session.CreatedAt, session.ExpiresAt)
+		err := rows.Scan(&session.ID, &session.AccountID, &session.ExpiresAt, &session.CreatedAt)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %w", err)
@@ -85,12 +97,16 @@ func (r *SessionRepository) GetByAccountID(accountID string) ([]*models.Session,
 	return sessions, nil
 }
 
-func (r *SessionRepository) Delete(sessionID string) error {
+func (r *SessionRepository) Delete(ctx context.Context, sessionID string) error {
 	query := `
 	DELETE FROM sessions WHERE id = $1
 	`
-	result, err := r.db.Exec(query, sessionID)
+	ctx, span := startSpan(ctx, "SessionRepository.Delete", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, sessionID)
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
 
@@ -100,19 +116,22 @@ func (r *SessionRepository) Delete(sessionID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("session not found")
+		return fmt.Errorf("session not found: %w", ErrNotFound)
 	}
 	return nil
 }
 
-func (r *SessionRepository) DeleteAccountByID(accountID int) error {
+func (r *SessionRepository) DeleteAccountByID(ctx context.Context, accountID int) error {
 
 	query := `
 	DELETE FROM sessions WHERE account_id = $1
 	`
+	ctx, span := startSpan(ctx, "SessionRepository.DeleteAccountByID", query)
+	defer span.End()
 
-	rows, err := r.db.Exec(query, accountID)
+	rows, err := r.db.Conn(ctx).ExecContext(ctx, query, accountID)
 	if err != nil {
+		span.RecordError(err)
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
 
@@ -122,17 +141,21 @@ func (r *SessionRepository) DeleteAccountByID(accountID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("session not found")
+		return fmt.Errorf("session not found: %w", ErrNotFound)
 	}
 	return nil
 }
 
-func (r *SessionRepository) DeleteExpired() (int, error) {
+func (r *SessionRepository) DeleteExpired(ctx context.Context) (int, error) {
 	query := `
 	DELETE FROM sessions WHERE expires_at < $1
 	`
-	result, err := r.db.Exec(query, time.Now())
+	ctx, span := startSpan(ctx, "SessionRepository.DeleteExpired", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, time.Now())
 	if err != nil {
+		span.RecordError(err)
 		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
 	}
 
@@ -145,14 +168,14 @@ func (r *SessionRepository) DeleteExpired() (int, error) {
 
 }
 
-func (r *SessionRepository) IsValid(sessionID string) (bool, error) {
-	session, err := r.GetByID(sessionID)
+func (r *SessionRepository) IsValid(ctx context.Context, sessionID string) (bool, error) {
+	session, err := r.GetByID(ctx, sessionID)
 	if err != nil {
 		return false, nil
 	}
 
 	if session.IsExpired() {
-		r.Delete(sessionID)
+		r.Delete(ctx, sessionID)
 		return false, nil
 	}
 
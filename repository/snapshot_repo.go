@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type SnapshotRepository struct {
+	db *db.DB
+}
+
+func NewSnapshotRepository(db *db.DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db}
+}
+
+func (r *SnapshotRepository) Save(ctx context.Context, snapshot *models.BalanceSnapshot) error {
+	query := `
+	INSERT INTO balance_snapshots (account_id, snapshot_date, balance)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (account_id, snapshot_date)
+	DO UPDATE SET balance = EXCLUDED.balance, created_at = CURRENT_TIMESTAMP
+	`
+	ctx, span := startSpan(ctx, "SnapshotRepository.Save", query)
+	defer span.End()
+
+	_, err := r.db.Conn(ctx).ExecContext(ctx, query, snapshot.AccountID, snapshot.SnapshotDate.Format(dateLayout), snapshot.Balance)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to save balance snapshot: %w", err)
+	}
+	return nil
+}
+
+func (r *SnapshotRepository) LastDate(ctx context.Context, accountID int) (*time.Time, error) {
+	query := `SELECT MAX(snapshot_date) FROM balance_snapshots WHERE account_id = $1`
+	ctx, span := startSpan(ctx, "SnapshotRepository.LastDate", query)
+	defer span.End()
+
+	var last sql.NullTime
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID).Scan(&last); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get last snapshot date: %w", err)
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
+
+func (r *SnapshotRepository) GetLatest(ctx context.Context, accountID int, day time.Time) (*models.BalanceSnapshot, error) {
+	query := `
+	SELECT account_id, snapshot_date, balance, created_at
+	FROM balance_snapshots
+	WHERE account_id = $1 AND snapshot_date <= $2
+	ORDER BY snapshot_date DESC
+	LIMIT 1
+	`
+	ctx, span := startSpan(ctx, "SnapshotRepository.GetLatest", query)
+	defer span.End()
+
+	snapshot := &models.BalanceSnapshot{}
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, day.Format(dateLayout)).Scan(
+		&snapshot.AccountID, &snapshot.SnapshotDate, &snapshot.Balance, &snapshot.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("balance snapshot not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get balance snapshot: %w", err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+// TxRunner runs a function inside a single atomic unit of work. Stores called
+// with the ctx handed to fn take part in the same transaction.
+type TxRunner interface {
+	WithTransaction(ctx context.Context, fn db.TxFunc) error
+}
+
+// AccountStore persists bank accounts
+type AccountStore interface {
+	Create(ctx context.Context, email, passwordHash, firstName, lastName string) (*models.Account, error)
+	GetByID(ctx context.Context, id int) (*models.Account, error)
+	GeyByEmail(ctx context.Context, email string) (*models.Account, error)
+	GetByAccountNumber(ctx context.Context, accountNumber string) (*models.Account, error)
+	// GetAccountNumbers maps each of the given account IDs that exists to
+	// its account number
+	GetAccountNumbers(ctx context.Context, ids []int) (map[int]string, error)
+	SetProduct(ctx context.Context, id int, product string) error
+	SetOverdraftLimit(ctx context.Context, id int, limit float64) error
+	SetStatus(ctx context.Context, id int, status string) error
+	ListIDsByProduct(ctx context.Context, product string) ([]int, error)
+	// ListOpenIDs returns the IDs of every account that isn't closed
+	ListOpenIDs(ctx context.Context) ([]int, error)
+	Update(ctx context.Context, id int, firstName, lastName string) error
+	UpdateBalance(ctx context.Context, accountID int, newBalance float64) error
+	// GetBalanceForUpdate locks the account until the surrounding transaction ends
+	GetBalanceForUpdate(ctx context.Context, accountID int) (float64, error)
+	// GetFundsForUpdate locks the account like GetBalanceForUpdate and
+	// returns its balance and overdraft limit
+	GetFundsForUpdate(ctx context.Context, accountID int) (balance, overdraftLimit float64, err error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	// Close marks the account closed; the row and its history are kept
+	Close(ctx context.Context, id int) error
+	// Anonymize replaces a closed account's email, names and password hash.
+	// It fails with ErrNotFound unless the account is closed.
+	Anonymize(ctx context.Context, id int) error
+	List(ctx context.Context, page, limit int) ([]*models.Account, int, error)
+}
+
+// TransactionStore persists the ledger of money movements
+type TransactionStore interface {
+	Create(ctx context.Context, fromAccountID, toAccountID *int, amount float64, transactionType, description string) (*models.Transaction, error)
+	// Insert records a completed transaction from the given fields, including
+	// its links to a related transaction and to a pot
+	Insert(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error)
+	GetByID(ctx context.Context, id int) (*models.Transaction, error)
+	// ListByAccount pages through an account's history by keyset; after is
+	// the cursor of the last row already returned, or nil
+	ListByAccount(ctx context.Context, accountID int, filter models.TransactionFilter, after *models.TransactionCursor, limit int) ([]*models.Transaction, error)
+	GetRecent(ctx context.Context, accountID, limit int) ([]*models.Transaction, error)
+	// GetByDateRange streams the account's transactions in [startDate, endDate)
+	// to fn, oldest first
+	GetByDateRange(ctx context.Context, accountID int, startDate, endDate time.Time, fn func(*models.Transaction) error) error
+	GetBalanceAt(ctx context.Context, accountID int, at time.Time) (float64, error)
+	GetTotalBalance(ctx context.Context, accountID int) (float64, error)
+	// LastTransferAt is nil when fromAccountID has never paid toAccountID
+	LastTransferAt(ctx context.Context, fromAccountID, toAccountID int) (*time.Time, error)
+	// SumSentSince totals the account's completed withdrawals and transfers
+	// out at or after since
+	SumSentSince(ctx context.Context, accountID int, since time.Time) (float64, error)
+}
+
+// PayeeStore persists each account's saved transfer recipients
+type PayeeStore interface {
+	Create(ctx context.Context, accountID, payeeAccountID int, nickname, verifiedName string, lastPaidAt *time.Time) (*models.Payee, error)
+	GetByID(ctx context.Context, id int) (*models.Payee, error)
+	ListByAccount(ctx context.Context, accountID int) ([]*models.Payee, error)
+	Delete(ctx context.Context, id int) error
+	MarkPaid(ctx context.Context, accountID, payeeAccountID int, at time.Time) error
+	// ClearVerifiedNames blanks the holder name saved in every address book
+	// entry for payeeAccountID
+	ClearVerifiedNames(ctx context.Context, payeeAccountID int) error
+}
+
+// PotStore persists savings pots. Callers lock the owning account's row
+// before changing a pot's balance.
+type PotStore interface {
+	Create(ctx context.Context, pot *models.Pot) (*models.Pot, error)
+	GetByID(ctx context.Context, id int) (*models.Pot, error)
+	// ListByAccount returns the account's open pots
+	ListByAccount(ctx context.Context, accountID int) ([]*models.Pot, error)
+	Update(ctx context.Context, pot *models.Pot) error
+	UpdateBalance(ctx context.Context, id int, balance float64) error
+	// GetRoundUp returns the open pot taking round-ups, or ErrNotFound
+	GetRoundUp(ctx context.Context, accountID int) (*models.Pot, error)
+	ClearRoundUp(ctx context.Context, accountID int) error
+	Close(ctx context.Context, id int) error
+	TotalByAccount(ctx context.Context, accountID int) (float64, error)
+}
+
+// PaymentRequestStore persists requests for money between accounts
+type PaymentRequestStore interface {
+	Create(ctx context.Context, request *models.PaymentRequest) (*models.PaymentRequest, error)
+	GetByID(ctx context.Context, id int) (*models.PaymentRequest, error)
+	// ListByAccount returns the requests the account made (incoming false)
+	// or was asked to pay (incoming true), newest first. An empty status
+	// matches every status.
+	ListByAccount(ctx context.Context, accountID int, incoming bool, status string) ([]*models.PaymentRequest, error)
+	// Resolve moves a pending request to status. It fails with ErrNotFound
+	// if the request is no longer pending.
+	Resolve(ctx context.Context, id int, status string, transactionID *int, at time.Time) error
+	// ListExpired returns pending requests whose expiry is at or before now
+	ListExpired(ctx context.Context, now time.Time) ([]*models.PaymentRequest, error)
+}
+
+// NotificationStore persists notification preferences and the delivery
+// queue
+type NotificationStore interface {
+	// GetPreferences fails with ErrNotFound for an account that has never
+	// saved any
+	GetPreferences(ctx context.Context, accountID int) (*models.NotificationPreferences, error)
+	SavePreferences(ctx context.Context, prefs *models.NotificationPreferences) error
+	Enqueue(ctx context.Context, deliveries []*models.NotificationDelivery) error
+	// ClaimDue takes up to limit pending deliveries due at now, counts an
+	// attempt on each and hides them from other workers until now+lease
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*models.NotificationDelivery, error)
+	MarkSent(ctx context.Context, id int, at time.Time) error
+	// MarkFailed records a failed attempt. A nil retryAt gives up on the
+	// delivery.
+	MarkFailed(ctx context.Context, id int, lastError string, retryAt *time.Time) error
+	ListDeliveries(ctx context.Context, accountID, limit int) ([]*models.NotificationDelivery, error)
+	// DeleteByAccount removes the account's preferences and deliveries,
+	// which hold its phone number, push token and addresses
+	DeleteByAccount(ctx context.Context, accountID int) error
+}
+
+// AlertStore persists balance and spending alert rules
+type AlertStore interface {
+	// Create fails with ErrDuplicate if the account already has the same rule
+	Create(ctx context.Context, rule *models.AlertRule) (*models.AlertRule, error)
+	GetByID(ctx context.Context, id int) (*models.AlertRule, error)
+	ListByAccount(ctx context.Context, accountID int) ([]*models.AlertRule, error)
+	Delete(ctx context.Context, id int) error
+	// SetTriggered moves a rule into or out of the triggered state, stamping
+	// last_triggered_at when it triggers. It reports false if the rule was
+	// already in that state, so concurrent callers fire a crossing only once.
+	SetTriggered(ctx context.Context, id int, triggered bool, at time.Time) (bool, error)
+	// RecordTriggered stamps last_triggered_at without changing the state
+	RecordTriggered(ctx context.Context, id int, at time.Time) error
+}
+
+// VerificationStore persists email verification tokens, keyed by their hash
+type VerificationStore interface {
+	Create(ctx context.Context, verification *models.EmailVerification) (*models.EmailVerification, error)
+	// GetByTokenHash fails with ErrNotFound for a token never issued
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.EmailVerification, error)
+	// MarkUsed reports false if the token was already used, so each token
+	// verifies an account at most once
+	MarkUsed(ctx context.Context, id int, at time.Time) (bool, error)
+	// CountSince counts the tokens issued to the account at or after since
+	CountSince(ctx context.Context, accountID int, since time.Time) (int, error)
+}
+
+// KYCStore persists KYC profiles and the records of uploaded documents
+type KYCStore interface {
+	// GetProfile fails with ErrNotFound for an account that has never
+	// started KYC
+	GetProfile(ctx context.Context, accountID int) (*models.KYCProfile, error)
+	// SaveProfile creates or replaces the account's profile
+	SaveProfile(ctx context.Context, profile *models.KYCProfile) error
+	// ListByStatus returns up to limit profiles in status, oldest submission
+	// first
+	ListByStatus(ctx context.Context, status string, limit int) ([]*models.KYCProfile, error)
+	AddDocument(ctx context.Context, document *models.KYCDocument) (*models.KYCDocument, error)
+	GetDocument(ctx context.Context, id int) (*models.KYCDocument, error)
+	// ListDocuments returns the account's documents, oldest first
+	ListDocuments(ctx context.Context, accountID int) ([]*models.KYCDocument, error)
+	// DeleteByAccount removes the account's profile and document records
+	// and returns the storage keys of the documents' files
+	DeleteByAccount(ctx context.Context, accountID int) ([]string, error)
+}
+
+// ClosureStore persists account closures and their retention periods
+type ClosureStore interface {
+	Create(ctx context.Context, closure *models.AccountClosure) (*models.AccountClosure, error)
+	// GetByAccount fails with ErrNotFound for an account that was never
+	// closed by its holder
+	GetByAccount(ctx context.Context, accountID int) (*models.AccountClosure, error)
+	// ListDue returns up to limit closures not yet anonymized whose
+	// retention ended at or before now, oldest first
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*models.AccountClosure, error)
+	MarkAnonymized(ctx context.Context, accountID int, at time.Time) error
+}
+
+// ReconciliationStore compares stored balances with the ledger and keeps
+// the results
+type ReconciliationStore interface {
+	// CheckBalances compares every account's balance with the sum of its
+	// completed transactions in one snapshot. It returns how many accounts
+	// were checked and those that don't match, by account ID.
+	CheckBalances(ctx context.Context) (int, []*models.BalanceDiscrepancy, error)
+	// CreateRun records a run with its discrepancies, setting their IDs
+	CreateRun(ctx context.Context, run *models.ReconciliationRun, discrepancies []*models.BalanceDiscrepancy) (*models.ReconciliationRun, error)
+	GetRun(ctx context.Context, id int) (*models.ReconciliationRun, error)
+	// GetLatestRun fails with ErrNotFound if there has been no run, or none
+	// with trigger when it is not empty
+	GetLatestRun(ctx context.Context, trigger string) (*models.ReconciliationRun, error)
+	// ListRuns returns up to limit runs, newest first
+	ListRuns(ctx context.Context, limit int) ([]*models.ReconciliationRun, error)
+	ListDiscrepancies(ctx context.Context, runID int) ([]*models.BalanceDiscrepancy, error)
+}
+
+// SnapshotStore persists end-of-day balances
+type SnapshotStore interface {
+	// Save records the snapshot, replacing any for the same account and day
+	Save(ctx context.Context, snapshot *models.BalanceSnapshot) error
+	// LastDate is nil when the account has no snapshots
+	LastDate(ctx context.Context, accountID int) (*time.Time, error)
+	// GetLatest returns the account's newest snapshot dated on or before
+	// day, or ErrNotFound
+	GetLatest(ctx context.Context, accountID int, day time.Time) (*models.BalanceSnapshot, error)
+}
+
+// CategoryStore persists category rules and the category each account
+// filed its transactions under
+type CategoryStore interface {
+	CreateRule(ctx context.Context, rule *models.CategoryRule) (*models.CategoryRule, error)
+	// ListRules returns the account's rules oldest first
+	ListRules(ctx context.Context, accountID int) ([]*models.CategoryRule, error)
+	// DeleteRule fails with ErrNotFound unless the account has the rule
+	DeleteRule(ctx context.Context, accountID, ruleID int) error
+	// ListUncategorized returns up to limit of the account's completed
+	// transactions it has no category for, oldest first. Pot moves are
+	// never categorized.
+	ListUncategorized(ctx context.Context, accountID, limit int) ([]*models.Transaction, error)
+	// Assign records the category, replacing any the account already had
+	// for the transaction. A category set by the holder is only replaced by
+	// another one set by the holder.
+	Assign(ctx context.Context, category *models.TransactionCategory) error
+	GetAssignment(ctx context.Context, accountID, transactionID int) (*models.TransactionCategory, error)
+	DeleteAssignment(ctx context.Context, accountID, transactionID int) error
+	// ClearAssigned removes the account's categories not set by the holder,
+	// so they are worked out again from the current rules
+	ClearAssigned(ctx context.Context, accountID int) error
+}
+
+// InsightStore aggregates an account's completed transactions in
+// [from, to), leaving out pot moves
+type InsightStore interface {
+	// MonthlyFlows returns the months with any money in or out, oldest first
+	MonthlyFlows(ctx context.Context, accountID int, from, to time.Time) ([]*models.MonthlyFlow, error)
+	// TopCounterparties returns up to limit accounts the account exchanged
+	// transfers with, most money first
+	TopCounterparties(ctx context.Context, accountID int, from, to time.Time, limit int) ([]*models.CounterpartyTotal, error)
+	// CategoryTotals sums the transactions the account has categorized, by
+	// category
+	CategoryTotals(ctx context.Context, accountID int, from, to time.Time) ([]*models.CategoryTotal, error)
+}
+
+// BudgetStore persists monthly spending budgets per category
+type BudgetStore interface {
+	// Save creates the account's budget for the category or changes its limit
+	Save(ctx context.Context, budget *models.Budget) (*models.Budget, error)
+	ListByAccount(ctx context.Context, accountID int) ([]*models.Budget, error)
+	// Delete fails with ErrNotFound when the account has no such budget
+	Delete(ctx context.Context, accountID int, category string) error
+}
+
+// AnnotationStore persists the notes, tags and attachments each account put
+// on its transactions
+type AnnotationStore interface {
+	// Save creates or replaces the account's note and tags on the transaction
+	Save(ctx context.Context, annotation *models.TransactionAnnotation) (*models.TransactionAnnotation, error)
+	// Get fails with ErrNotFound when the account has not annotated the
+	// transaction
+	Get(ctx context.Context, accountID, transactionID int) (*models.TransactionAnnotation, error)
+	Delete(ctx context.Context, accountID, transactionID int) error
+	// ListByTransactions returns the account's annotations on any of the
+	// transactions
+	ListByTransactions(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAnnotation, error)
+	AddAttachment(ctx context.Context, attachment *models.TransactionAttachment) (*models.TransactionAttachment, error)
+	GetAttachment(ctx context.Context, id int) (*models.TransactionAttachment, error)
+	// ListAttachments returns the account's attachments on any of the
+	// transactions, oldest first
+	ListAttachments(ctx context.Context, accountID int, transactionIDs []int) ([]*models.TransactionAttachment, error)
+	// DeleteAttachment fails with ErrNotFound when there is no such attachment
+	DeleteAttachment(ctx context.Context, id int) error
+	// DeleteByAccount removes the account's annotations and attachment
+	// records and returns the storage keys of the attachments' files
+	DeleteByAccount(ctx context.Context, accountID int) ([]string, error)
+}
+
+// BatchStore persists batch transfer uploads and their per-row outcomes
+type BatchStore interface {
+	Create(ctx context.Context, accountID int, mode string, totalAmount float64, items []*models.TransferBatchItem) (*models.TransferBatch, error)
+	GetByID(ctx context.Context, id int) (*models.TransferBatch, error)
+	UpdateItem(ctx context.Context, item *models.TransferBatchItem) error
+	UpdateStatus(ctx context.Context, id int, status string, succeeded, failed int, completedAt *time.Time) error
+}
+
+// ProductStore persists account products and their interest rates
+type ProductStore interface {
+	List(ctx context.Context) ([]*models.Product, error)
+	GetByCode(ctx context.Context, code string) (*models.Product, error)
+	UpdateRates(ctx context.Context, code, annualRate, overdraftRate string) error
+}
+
+// InterestStore persists daily interest accruals
+type InterestStore interface {
+	CreateAccrual(ctx context.Context, accrual *models.InterestAccrual) (*models.InterestAccrual, error)
+	LastAccrualDate(ctx context.Context, accountID int) (*time.Time, error)
+	ListAccruals(ctx context.Context, accountID int, from, to time.Time) ([]*models.InterestAccrual, error)
+	ListUncapitalized(ctx context.Context, accountID int, before time.Time) ([]*models.InterestAccrual, error)
+	AccountsWithUncapitalized(ctx context.Context, before time.Time) ([]int, error)
+	MarkCapitalized(ctx context.Context, ids []int, transactionID *int, at time.Time) error
+}
+
+// FeeStore persists the fee schedule and the monthly maintenance charges
+type FeeStore interface {
+	ListRules(ctx context.Context) ([]*models.FeeRule, error)
+	// FindRule returns the active rule for the transaction type on product,
+	// falling back to the rule for all products
+	FindRule(ctx context.Context, transactionType, product string) (*models.FeeRule, error)
+	CreateRule(ctx context.Context, rule *models.FeeRule) (*models.FeeRule, error)
+	DeactivateRule(ctx context.Context, id int) error
+	// RecordMaintenance fails with ErrDuplicate if the account was already
+	// charged for period
+	RecordMaintenance(ctx context.Context, accountID int, period time.Time, amount float64, transactionID *int) error
+	ListMaintenanceCharged(ctx context.Context, period time.Time) ([]int, error)
+}
+
+// SessionStore persists login sessions
+type SessionStore interface {
+	Create(ctx context.Context, sessionID string, accountID int, expiresAt time.Time) (*models.Session, error)
+	GetByID(ctx context.Context, sessionID string) (*models.Session, error)
+	GetByAccountID(ctx context.Context, accountID string) ([]*models.Session, error)
+	Delete(ctx context.Context, sessionID string) error
+	DeleteAccountByID(ctx context.Context, accountID int) error
+	DeleteExpired(ctx context.Context) (int, error)
+	IsValid(ctx context.Context, sessionID string) (bool, error)
+}
+
+var (
+	_ TxRunner            = (*db.DB)(nil)
+	_ AccountStore        = (*AccountRepository)(nil)
+	_ TransactionStore    = (*TransactionRepositoty)(nil)
+	_ SessionStore        = (*SessionRepository)(nil)
+	_ BatchStore          = (*BatchRepository)(nil)
+	_ PayeeStore          = (*PayeeRepository)(nil)
+	_ PotStore            = (*PotRepository)(nil)
+	_ PaymentRequestStore = (*PaymentRequestRepository)(nil)
+	_ NotificationStore   = (*NotificationRepository)(nil)
+	_ AlertStore          = (*AlertRepository)(nil)
+	_ VerificationStore   = (*VerificationRepository)(nil)
+	_ KYCStore            = (*KYCRepository)(nil)
+	_ ClosureStore        = (*ClosureRepository)(nil)
+	_ ReconciliationStore = (*ReconciliationRepository)(nil)
+	_ SnapshotStore       = (*SnapshotRepository)(nil)
+	_ CategoryStore       = (*CategoryRepository)(nil)
+	_ InsightStore        = (*InsightRepository)(nil)
+	_ BudgetStore         = (*BudgetRepository)(nil)
+	_ AnnotationStore     = (*AnnotationRepository)(nil)
+	_ ProductStore        = (*ProductRepository)(nil)
+	_ InterestStore       = (*InterestRepository)(nil)
+	_ FeeStore            = (*FeeRepository)(nil)
+)
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/tracing"
+)
+
+// startSpan opens a child span for a repository call, tagged with the SQL it runs
+func startSpan(ctx context.Context, name, query string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, name)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.statement", strings.Join(strings.Fields(query), " "))
+	return ctx, span
+}
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/wizzyszn/go_bank/db"
@@ -17,105 +19,179 @@ func NewTransactionRepository(db *db.DB) *TransactionRepositoty {
 	return &TransactionRepositoty{db: db}
 }
 
-func (t *TransactionRepositoty) Create(tx *sql.Tx, fromAccountID, toAccountID *int, amount float64, transactionType, description string) (*models.Transaction, error) {
+func (t *TransactionRepositoty) Create(ctx context.Context, fromAccountID, toAccountID *int, amount float64, transactionType, description string) (*models.Transaction, error) {
+	return t.Insert(ctx, &models.Transaction{
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        amount,
+		Type:          transactionType,
+		Description:   description,
+	})
+}
+
+// Insert records a completed transaction with any links it carries, such as
+// the transaction a fee was charged on or the pot money moved to
+func (t *TransactionRepositoty) Insert(ctx context.Context, transaction *models.Transaction) (*models.Transaction, error) {
 	query := `
-	INSERT INTO transactions(from_account_id,to_account_id,amount,type,description,status)
-	VALUES ($1,$2,$3,$4,$5,$6)
-	RETURNING id,from_account_id,to_account_id,amount,type,description,status
+	INSERT INTO transactions(from_account_id,to_account_id,amount,type,description,status,related_transaction_id,pot_id)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	RETURNING id,from_account_id,to_account_id,amount,type,description,status,related_transaction_id,pot_id,created_at
 	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.Insert", query)
+	defer span.End()
+
 	transactions := &models.Transaction{}
-	var err error
 
-	if tx != nil {
-		err = tx.QueryRow(query, fromAccountID, toAccountID, amount, transactionType, description, models.TransactionStatusCompleted).Scan(&transactions.FromAccountID, &transactions.ToAccountID, &transactions.Amount, &transactions.Type, &transactions.Description, &transactions.Status, &transactions.Status)
-	} else {
-		err = t.db.QueryRow(query, fromAccountID, toAccountID, amount, transactionType, description, models.TransactionStatusCompleted).Scan(&transactions.FromAccountID, &transactions.ToAccountID, &transactions.Amount, &transactions.Type, &transactions.Description, &transactions.Status, &transactions.Status)
-	}
+	err := t.db.Conn(ctx).QueryRowContext(ctx, query,
+		transaction.FromAccountID, transaction.ToAccountID, transaction.Amount, transaction.Type, transaction.Description,
+		models.TransactionStatusCompleted, transaction.RelatedTransactionID, transaction.PotID,
+	).Scan(&transactions.ID, &transactions.FromAccountID, &transactions.ToAccountID, &transactions.Amount, &transactions.Type, &transactions.Description, &transactions.Status, &transactions.RelatedTransactionID, &transactions.PotID, &transactions.CreatedAt)
 
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to create transaction: %w", err)
 	}
 	return transactions, nil
 }
 
-func (r *TransactionRepositoty) GetByID(id int) (*models.Transaction, error) {
+func (r *TransactionRepositoty) GetByID(ctx context.Context, id int) (*models.Transaction, error) {
 	query := `
-	SELECT id,from_account_id,to_account_id,amount,type,description,status,created_at
+	SELECT id,from_account_id,to_account_id,amount,type,description,status,related_transaction_id,pot_id,created_at
 	FROM transactions
 	WHERE id = $1
 	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.GetByID", query)
+	defer span.End()
+
 	transaction := &models.Transaction{}
-	err := r.db.QueryRow(query, id).Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.CreatedAt)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, id).Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.RelatedTransactionID, &transaction.PotID, &transaction.CreatedAt)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("transactions not found")
+		return nil, fmt.Errorf("transactions not found: %w", ErrNotFound)
 	}
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
 
 	return transaction, nil
 }
 
-func (r *TransactionRepositoty) GetByAccountID(accountID, page, limit int) ([]*models.Transaction, int, error) {
-	offset := (page - 1) * limit
-
-	var totalCount int
+// ListByAccount returns up to limit transactions of an account matching
+// filter, starting after the given cursor (nil for the first page). Rows are
+// ordered by (created_at, id) so paging with a cursor never skips or repeats
+// a row, and both indexes on (account, created_at, id) can serve the scan.
+func (r *TransactionRepositoty) ListByAccount(ctx context.Context, accountID int, filter models.TransactionFilter, after *models.TransactionCursor, limit int) ([]*models.Transaction, error) {
+	args := []any{accountID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
-	countQuery := `
-	SELECT COUNT(*)
-	FROM transactions
-	WHERE from_account_id = $1 OR to_account_id = $1
-	`
+	conditions := []string{"(from_account_id = $1 OR to_account_id = $1)"}
 
-	err := r.db.QueryRow(countQuery, accountID).Scan(&totalCount)
+	switch filter.Direction {
+	case models.TransactionDirectionIncoming:
+		conditions = append(conditions, "to_account_id = $1")
+	case models.TransactionDirectionOutgoing:
+		conditions = append(conditions, "from_account_id = $1")
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = "+arg(filter.Type))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount >= "+arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount <= "+arg(*filter.MaxAmount))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < "+arg(*filter.To))
+	}
+	if filter.CounterpartyID != nil {
+		counterparty := arg(*filter.CounterpartyID)
+		conditions = append(conditions, fmt.Sprintf(
+			"((from_account_id = $1 AND to_account_id = %[1]s) OR (to_account_id = $1 AND from_account_id = %[1]s))", counterparty))
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, "description ILIKE "+arg("%"+escapeLike(filter.Search)+"%"))
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM transaction_annotations n WHERE n.account_id = $1 AND n.transaction_id = transactions.id AND n.tags @> ARRAY[%s::TEXT])", arg(filter.Tag)))
+	}
 
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+	order := "DESC"
+	comparison := "<"
+	if filter.Ascending {
+		order = "ASC"
+		comparison = ">"
+	}
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) %s (%s, %s)", comparison, arg(after.CreatedAt), arg(after.ID)))
 	}
 
-	query := `
-	SELECT id,from_account_id,to_account_id,amount,type,description,status,created_at
+	query := fmt.Sprintf(`
+	SELECT id,from_account_id,to_account_id,amount,type,description,status,related_transaction_id,pot_id,created_at
 	FROM transactions
-	WHERE from_account_id = $1 OR to_account_id = $1
-	ORDER BY created_at DESC
-	LIMIT $2
-	OFFSET $3
-	`
-	rows, err := r.db.Query(query, accountID, limit, offset)
+	WHERE %s
+	ORDER BY created_at %s, id %s
+	LIMIT %s
+	`, strings.Join(conditions, " AND "), order, order, arg(limit))
+
+	ctx, span := startSpan(ctx, "TransactionRepositoty.ListByAccount", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get transactions: %w", err)
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
 	}
 	defer rows.Close()
 
-	transactions := make([]*models.Transaction, 0)
+	transactions := make([]*models.Transaction, 0, limit)
 
 	for rows.Next() {
 		transaction := &models.Transaction{}
-		err := rows.Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.CreatedAt)
+		err := rows.Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.RelatedTransactionID, &transaction.PotID, &transaction.CreatedAt)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan transaction: %w", err)
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
 		transactions = append(transactions, transaction)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating transactions: %w", err)
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
 	}
 
-	return transactions, totalCount, nil
+	return transactions, nil
+}
+
+// escapeLike makes % and _ in user input match literally in a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
-func (r *TransactionRepositoty) GetRecent(accountID, limit int) ([]*models.Transaction, error) {
+func (r *TransactionRepositoty) GetRecent(ctx context.Context, accountID, limit int) ([]*models.Transaction, error) {
 	query := `
-	SELECT id, from_account_id, to_account_id, amount, type, description, status, created_at
-	FROM accounts
-	WHERE from_account_id = $1 OR to_account_id = $2
+	SELECT id, from_account_id, to_account_id, amount, type, description, status, related_transaction_id, pot_id, created_at
+	FROM transactions
+	WHERE from_account_id = $1 OR to_account_id = $1
 	ORDER BY created_at DESC
 	LIMIT $2
 	`
-	rows, err := r.db.Query(query, accountID, limit)
+	ctx, span := startSpan(ctx, "TransactionRepositoty.GetRecent", query)
+	defer span.End()
+
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, limit)
 
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("failed to get recent transactions: %w", err)
 	}
 	defer rows.Close()
@@ -125,7 +201,7 @@ func (r *TransactionRepositoty) GetRecent(accountID, limit int) ([]*models.Trans
 	for rows.Next() {
 		transaction := &models.Transaction{}
 
-		err := rows.Scan(&transaction.ID, transaction.FromAccountID, transaction.ToAccountID, transaction.Amount, transaction.Type, transaction.Status, transaction.CreatedAt)
+		err := rows.Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.RelatedTransactionID, &transaction.PotID, &transaction.CreatedAt)
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
@@ -138,39 +214,115 @@ func (r *TransactionRepositoty) GetRecent(accountID, limit int) ([]*models.Trans
 	return transactions, nil
 }
 
-func (r *TransactionRepositoty) GetByDateRange(accountID int, startDate, endDate time.Time) ([]*models.Transaction, error) {
+// GetByDateRange calls fn for each transaction of the account created in
+// [startDate, endDate), oldest first. Rows are streamed from the database so
+// long ranges never sit in memory; an error from fn stops the iteration.
+func (r *TransactionRepositoty) GetByDateRange(ctx context.Context, accountID int, startDate, endDate time.Time, fn func(*models.Transaction) error) error {
 	query := `
-	SELECT id, from_account_id, to_account_id, amount, type, description, status, created_at
-	FROM accounts
+	SELECT id, from_account_id, to_account_id, amount, type, description, status, related_transaction_id, pot_id, created_at
+	FROM transactions
 	WHERE (from_account_id = $1 OR to_account_id = $1)
 	AND created_at >= $2
-	AND created_at <= $3	
-	ORDER BY created_at DESC
+	AND created_at < $3
+	ORDER BY created_at ASC, id ASC
 	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.GetByDateRange", query)
+	defer span.End()
 
-	rows, err := r.db.Query(query, accountID, startDate, endDate)
+	rows, err := r.db.Conn(ctx).QueryContext(ctx, query, accountID, startDate, endDate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transaction by date range: %w", err)
+		span.RecordError(err)
+		return fmt.Errorf("failed to get transaction by date range: %w", err)
 	}
-	transactions := make([]*models.Transaction, 0)
 	defer rows.Close()
+
 	for rows.Next() {
 		transaction := &models.Transaction{}
 
-		err := rows.Scan(&transaction.ID, transaction.FromAccountID, transaction.ToAccountID, transaction.Amount, transaction.Type, transaction.Status, transaction.CreatedAt)
+		err := rows.Scan(&transaction.ID, &transaction.FromAccountID, &transaction.ToAccountID, &transaction.Amount, &transaction.Type, &transaction.Description, &transaction.Status, &transaction.RelatedTransactionID, &transaction.PotID, &transaction.CreatedAt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if err := fn(transaction); err != nil {
+			return err
 		}
-		transactions = append(transactions, transaction)
 	}
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("err iterating transactions: %w", err)
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("err iterating transactions: %w", err)
 	}
-	return transactions, nil
+	return nil
+}
+
+// GetBalanceAt returns the account balance implied by the completed
+// transactions created before at
+func (r *TransactionRepositoty) GetBalanceAt(ctx context.Context, accountID int, at time.Time) (float64, error) {
+	var balance float64
+
+	query := `
+	SELECT
+		COALESCE(SUM(CASE WHEN to_account_id = $1 THEN amount ELSE -amount END), 0)
+	FROM transactions
+	WHERE (from_account_id = $1 OR to_account_id = $1)
+	AND status = $2
+	AND created_at < $3
+	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.GetBalanceAt", query)
+	defer span.End()
+
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, models.TransactionStatusCompleted, at).Scan(&balance)
+	if err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to get balance at %s: %w", at.Format(time.RFC3339), err)
+	}
+
+	return balance, nil
+}
+
+// LastTransferAt returns when fromAccountID last completed a transfer to
+// toAccountID, or nil if it never has
+func (r *TransactionRepositoty) LastTransferAt(ctx context.Context, fromAccountID, toAccountID int) (*time.Time, error) {
+	query := `
+	SELECT MAX(created_at)
+	FROM transactions
+	WHERE from_account_id = $1 AND to_account_id = $2 AND status = $3
+	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.LastTransferAt", query)
+	defer span.End()
+
+	var last sql.NullTime
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, fromAccountID, toAccountID, models.TransactionStatusCompleted).Scan(&last)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get last transfer: %w", err)
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
+
+func (r *TransactionRepositoty) SumSentSince(ctx context.Context, accountID int, since time.Time) (float64, error) {
+	query := `
+	SELECT COALESCE(SUM(amount), 0)
+	FROM transactions
+	WHERE from_account_id = $1 AND type IN ($2, $3) AND status = $4 AND created_at >= $5
+	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.SumSentSince", query)
+	defer span.End()
+
+	var total float64
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, models.TransactionTypeWithdraw, models.TransactionTypeTransfer,
+		models.TransactionStatusCompleted, since).Scan(&total)
+	if err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to sum money sent: %w", err)
+	}
+	return total, nil
 }
 
 // GetTotalBalance
-func (r *TransactionRepositoty) GetTotalBalance(accountID int) (float64, error) {
+func (r *TransactionRepositoty) GetTotalBalance(ctx context.Context, accountID int) (float64, error) {
 	var totalBalance float64
 
 	query := `
@@ -181,9 +333,12 @@ func (r *TransactionRepositoty) GetTotalBalance(accountID int) (float64, error)
 	WHERE (from_account_id = $1 OR to_account_id = $1)
 	AND status = $2
 	`
+	ctx, span := startSpan(ctx, "TransactionRepositoty.GetTotalBalance", query)
+	defer span.End()
 
-	err := r.db.QueryRow(query, accountID, models.TransactionStatusCompleted).Scan(&totalBalance)
+	err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, models.TransactionStatusCompleted).Scan(&totalBalance)
 	if err != nil {
+		span.RecordError(err)
 		return 0, fmt.Errorf("failed to get total balance: %w", err)
 	}
 
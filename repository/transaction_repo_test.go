@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -58,17 +59,18 @@ func TestGetTotalBalance(t *testing.T) {
 	database, teardown := setupTestDB(t)
 	defer teardown()
 
+	ctx := context.Background()
 	accountRepo := NewAccountRepository(database)
 	transactionRepo := NewTransactionRepository(database)
 
 	// Create test accounts
 	// Email must be unique, so use random or timestamp
 	timestamp := time.Now().UnixNano()
-	acc1, err := accountRepo.Create(fmt.Sprintf("test1_%d@example.com", timestamp), "hash", "John", "Doe")
+	acc1, err := accountRepo.Create(ctx, fmt.Sprintf("test1_%d@example.com", timestamp), "hash", "John", "Doe")
 	if err != nil {
 		t.Fatalf("failed to create account 1: %v", err)
 	}
-	acc2, err := accountRepo.Create(fmt.Sprintf("test2_%d@example.com", timestamp), "hash", "Jane", "Doe")
+	acc2, err := accountRepo.Create(ctx, fmt.Sprintf("test2_%d@example.com", timestamp), "hash", "Jane", "Doe")
 	if err != nil {
 		t.Fatalf("failed to create account 2: %v", err)
 	}
@@ -89,7 +91,7 @@ func TestGetTotalBalance(t *testing.T) {
 	}()
 
 	// 1. Initial Balance should be 0
-	bal, err := transactionRepo.GetTotalBalance(acc1.ID)
+	bal, err := transactionRepo.GetTotalBalance(ctx, acc1.ID)
 	if err != nil {
 		t.Fatalf("GetTotalBalance failed: %v", err)
 	}
@@ -118,12 +120,12 @@ func TestGetTotalBalance(t *testing.T) {
 
 	// Acc1 Deposit 1000
 	amount1000 := 1000.00
-	_, err = transactionRepo.Create(nil, nil, &acc1.ID, amount1000, models.TransactionTypeDeposit, "Deposit")
+	_, err = transactionRepo.Create(ctx, nil, &acc1.ID, amount1000, models.TransactionTypeDeposit, "Deposit")
 	if err != nil {
 		t.Fatalf("failed to create deposit: %v", err)
 	}
 
-	bal, err = transactionRepo.GetTotalBalance(acc1.ID)
+	bal, err = transactionRepo.GetTotalBalance(ctx, acc1.ID)
 	if err != nil {
 		t.Fatalf("GetTotalBalance failed: %v", err)
 	}
@@ -133,13 +135,13 @@ func TestGetTotalBalance(t *testing.T) {
 
 	// 3. Transfer 200 from Acc1 to Acc2
 	amount200 := 200.00
-	_, err = transactionRepo.Create(nil, &acc1.ID, &acc2.ID, amount200, models.TransactionTypeTransfer, "Transfer to Acc2")
+	_, err = transactionRepo.Create(ctx, &acc1.ID, &acc2.ID, amount200, models.TransactionTypeTransfer, "Transfer to Acc2")
 	if err != nil {
 		t.Fatalf("failed to create transfer: %v", err)
 	}
 
 	// Acc1 Balance should be 800
-	bal, err = transactionRepo.GetTotalBalance(acc1.ID)
+	bal, err = transactionRepo.GetTotalBalance(ctx, acc1.ID)
 	if err != nil {
 		t.Fatalf("GetTotalBalance failed: %v", err)
 	}
@@ -148,7 +150,7 @@ func TestGetTotalBalance(t *testing.T) {
 	}
 
 	// Acc2 Balance should be 200
-	bal2, err := transactionRepo.GetTotalBalance(acc2.ID)
+	bal2, err := transactionRepo.GetTotalBalance(ctx, acc2.ID)
 	if err != nil {
 		t.Fatalf("GetTotalBalance failed for acc2: %v", err)
 	}
@@ -159,13 +161,13 @@ func TestGetTotalBalance(t *testing.T) {
 	// 4. Withdraw 100 from Acc1
 	amount100 := 100.00
 	// Withdraw: From Acc1, To nil?
-	_, err = transactionRepo.Create(nil, &acc1.ID, nil, amount100, models.TransactionTypeWithdraw, "Withdrawal")
+	_, err = transactionRepo.Create(ctx, &acc1.ID, nil, amount100, models.TransactionTypeWithdraw, "Withdrawal")
 	if err != nil {
 		t.Fatalf("failed to create withdrawal: %v", err)
 	}
 
 	// Acc1 Balance should be 700
-	bal, err = transactionRepo.GetTotalBalance(acc1.ID)
+	bal, err = transactionRepo.GetTotalBalance(ctx, acc1.ID)
 	if err != nil {
 		t.Fatalf("GetTotalBalance failed: %v", err)
 	}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/db"
+	"github.com/wizzyszn/go_bank/models"
+)
+
+type VerificationRepository struct {
+	db *db.DB
+}
+
+func NewVerificationRepository(db *db.DB) *VerificationRepository {
+	return &VerificationRepository{db: db}
+}
+
+const verificationColumns = `id, account_id, token_hash, expires_at, used_at, created_at`
+
+func (r *VerificationRepository) Create(ctx context.Context, verification *models.EmailVerification) (*models.EmailVerification, error) {
+	query := `
+	INSERT INTO email_verifications (account_id, token_hash, expires_at)
+	VALUES ($1, $2, $3)
+	RETURNING ` + verificationColumns
+	ctx, span := startSpan(ctx, "VerificationRepository.Create", query)
+	defer span.End()
+
+	created, err := scanVerification(r.db.Conn(ctx).QueryRowContext(ctx, query, verification.AccountID, verification.TokenHash, verification.ExpiresAt))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("failed to create email verification: %w", ErrDuplicate)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create email verification: %w", err)
+	}
+	return created, nil
+}
+
+func (r *VerificationRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.EmailVerification, error) {
+	query := `SELECT ` + verificationColumns + ` FROM email_verifications WHERE token_hash = $1`
+	ctx, span := startSpan(ctx, "VerificationRepository.GetByTokenHash", query)
+	defer span.End()
+
+	verification, err := scanVerification(r.db.Conn(ctx).QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("email verification not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to get email verification: %w", err)
+	}
+	return verification, nil
+}
+
+func (r *VerificationRepository) MarkUsed(ctx context.Context, id int, at time.Time) (bool, error) {
+	query := `UPDATE email_verifications SET used_at = $2 WHERE id = $1 AND used_at IS NULL`
+	ctx, span := startSpan(ctx, "VerificationRepository.MarkUsed", query)
+	defer span.End()
+
+	result, err := r.db.Conn(ctx).ExecContext(ctx, query, id, at)
+	if err != nil {
+		span.RecordError(err)
+		return false, fmt.Errorf("failed to mark email verification used: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *VerificationRepository) CountSince(ctx context.Context, accountID int, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM email_verifications WHERE account_id = $1 AND created_at >= $2`
+	ctx, span := startSpan(ctx, "VerificationRepository.CountSince", query)
+	defer span.End()
+
+	var count int
+	if err := r.db.Conn(ctx).QueryRowContext(ctx, query, accountID, since).Scan(&count); err != nil {
+		span.RecordError(err)
+		return 0, fmt.Errorf("failed to count email verifications: %w", err)
+	}
+	return count, nil
+}
+
+// scanVerification reads a row of verificationColumns. sql.ErrNoRows is
+// returned unwrapped.
+func scanVerification(row rowScanner) (*models.EmailVerification, error) {
+	verification := &models.EmailVerification{}
+	err := row.Scan(&verification.ID, &verification.AccountID, &verification.TokenHash, &verification.ExpiresAt, &verification.UsedAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return verification, nil
+}
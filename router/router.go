@@ -0,0 +1,142 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/middleware"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// probeMethods are tried against the mux to work out which methods a path
+// supports when a request does not match any route
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Router dispatches requests using Go 1.22 "METHOD /path/{param}" patterns.
+// Every route runs behind the router's base middleware; groups add their own
+// stacks on top. Requests matching a path but not a method get a JSON 405
+// with an Allow header, and OPTIONS requests are answered automatically.
+type Router struct {
+	mux              *http.ServeMux
+	base             []middleware.Middleware
+	notFound         http.HandlerFunc
+	methodNotAllowed http.HandlerFunc
+	options          http.HandlerFunc
+}
+
+func New(base ...middleware.Middleware) *Router {
+	return &Router{
+		mux:              http.NewServeMux(),
+		base:             base,
+		notFound:         middleware.Chain(notFound, base...),
+		methodNotAllowed: middleware.Chain(methodNotAllowed, base...),
+		options:          middleware.Chain(options, base...),
+	}
+}
+
+// Group returns a set of routes sharing the given middleware, applied after
+// the router's base middleware
+func (r *Router) Group(middlewares ...middleware.Middleware) *Group {
+	return &Group{router: r, middlewares: middlewares}
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if _, pattern := r.mux.Handler(req); pattern != "" {
+		r.mux.ServeHTTP(w, req)
+		return
+	}
+
+	allowed := r.allowedMethods(req)
+	if len(allowed) == 0 {
+		r.notFound(w, req)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	if req.Method == http.MethodOptions {
+		r.options(w, req)
+		return
+	}
+	r.methodNotAllowed(w, req)
+}
+
+func (r *Router) handle(method, path string, handler http.HandlerFunc) {
+	r.mux.HandleFunc(method+" "+path, middleware.Chain(handler, r.base...))
+}
+
+func (r *Router) allowedMethods(req *http.Request) []string {
+	allowed := make([]string, 0, len(probeMethods)+1)
+	for _, method := range probeMethods {
+		probe := req.Clone(req.Context())
+		probe.Method = method
+		if _, pattern := r.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) > 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+// Group registers routes behind a shared middleware stack
+type Group struct {
+	router      *Router
+	middlewares []middleware.Middleware
+}
+
+// With returns a new group that runs this group's middleware followed by the
+// given middleware
+func (g *Group) With(middlewares ...middleware.Middleware) *Group {
+	combined := make([]middleware.Middleware, 0, len(g.middlewares)+len(middlewares))
+	combined = append(combined, g.middlewares...)
+	combined = append(combined, middlewares...)
+	return &Group{router: g.router, middlewares: combined}
+}
+
+// Handle registers handler for method and path. Path may contain wildcards
+// such as /api/transactions/{id}, read with r.PathValue("id").
+func (g *Group) Handle(method, path string, handler http.HandlerFunc) {
+	g.router.handle(method, path, middleware.Chain(handler, g.middlewares...))
+}
+
+func (g *Group) Get(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodGet, path, handler)
+}
+
+func (g *Group) Post(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPost, path, handler)
+}
+
+func (g *Group) Put(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPut, path, handler)
+}
+
+func (g *Group) Patch(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPatch, path, handler)
+}
+
+func (g *Group) Delete(path string, handler http.HandlerFunc) {
+	g.Handle(http.MethodDelete, path, handler)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	utils.WriteNotFound(w, "route not found")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	utils.WriteError(w, http.StatusMethodNotAllowed, r.Method+" method not allowed")
+}
+
+// options answers requests that reach the router without a CORS middleware
+// having already replied to the preflight
+func options(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/middleware"
+)
+
+func header(name, value string) middleware.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add(name, value)
+			next(w, r)
+		}
+	}
+}
+
+func newTestRouter() *Router {
+	r := New(header("X-Stack", "base"))
+	api := r.Group(header("X-Stack", "api"))
+
+	api.Get("/api/account", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get account"))
+	})
+	api.Patch("/api/account", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("patch account"))
+	})
+	api.Get("/api/transactions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("transaction " + r.PathValue("id")))
+	})
+	api.With(header("X-Stack", "admin")).Get("/api/admin/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("accounts"))
+	})
+	return r
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		{"method dispatch GET", http.MethodGet, "/api/account", http.StatusOK, "get account", ""},
+		{"method dispatch PATCH", http.MethodPatch, "/api/account", http.StatusOK, "patch account", ""},
+		{"path parameter", http.MethodGet, "/api/transactions/42", http.StatusOK, "transaction 42", ""},
+		{"method not allowed", http.MethodDelete, "/api/account", http.StatusMethodNotAllowed, "", "GET, HEAD, PATCH, OPTIONS"},
+		{"method not allowed with parameter", http.MethodPost, "/api/transactions/42", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{"options", http.MethodOptions, "/api/account", http.StatusNoContent, "", "GET, HEAD, PATCH, OPTIONS"},
+		{"unknown route", http.MethodGet, "/api/nope", http.StatusNotFound, "", ""},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/account", []string{"base", "api"}},
+		{"/api/admin/accounts", []string{"base", "api", "admin"}},
+		{"/api/nope", []string{"base"}},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			got := w.Header().Values("X-Stack")
+			if len(got) != len(tt.want) {
+				t.Fatalf("stack = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("stack = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
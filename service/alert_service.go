@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxAlertRules caps the alert rules one account can have
+const maxAlertRules = 20
+
+// AlertService manages balance and spending alert rules. The rules are
+// evaluated by TransactionService after each balance change.
+type AlertService struct {
+	accountRepo repository.AccountStore
+	alertRepo   repository.AlertStore
+}
+
+func NewAlertService(accountRepo repository.AccountStore, alertRepo repository.AlertStore) *AlertService {
+	return &AlertService{
+		accountRepo: accountRepo,
+		alertRepo:   alertRepo,
+	}
+}
+
+func (s *AlertService) List(ctx context.Context, accountID int) ([]*models.AlertRule, error) {
+	rules, err := s.alertRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		return nil, Internal("failed to list alert rules", err)
+	}
+	return rules, nil
+}
+
+// Create adds a rule. A low_balance rule made while the balance is already
+// below its threshold starts triggered, so it first fires on the next drop
+// after the balance recovers.
+func (s *AlertService) Create(ctx context.Context, accountID int, req *models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	ctx, span := tracing.Start(ctx, "AlertService.Create")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if req.Type != models.AlertLowBalance && req.Type != models.AlertLargeTransaction {
+		return nil, &utils.ValidationError{Field: "type", Message: "type must be low_balance or large_transaction"}
+	}
+	if err := utils.ValidateAlertThreshold(req.Threshold); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.alertRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to create alert rule", err)
+	}
+	if len(existing) >= maxAlertRules {
+		return nil, Conflict("too_many_alerts", "an account can have at most 20 alert rules")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+
+	rule, err := s.alertRepo.Create(ctx, &models.AlertRule{
+		AccountID: accountID,
+		Type:      req.Type,
+		Threshold: req.Threshold,
+		Triggered: req.Type == models.AlertLowBalance && account.Balance < req.Threshold,
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, Conflict("alert_exists", "you already have this alert")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to create alert rule", err)
+	}
+	return rule, nil
+}
+
+func (s *AlertService) Delete(ctx context.Context, accountID, ruleID int) error {
+	ctx, span := tracing.Start(ctx, "AlertService.Delete")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	rule, err := s.alertRepo.GetByID(ctx, ruleID)
+	if err != nil {
+		return notFoundOrInternal(err, "alert_not_found", "alert rule not found")
+	}
+	// Someone else's rule is reported as missing so IDs can't be probed
+	if rule.AccountID != accountID {
+		return NotFound("alert_not_found", "alert rule not found")
+	}
+
+	if err := s.alertRepo.Delete(ctx, ruleID); err != nil {
+		return notFoundOrInternal(err, "alert_not_found", "alert rule not found")
+	}
+	return nil
+}
+
+// evaluateAlerts checks accountID's rules against a committed balance
+// change: balance is the balance after it and spent the amount that left the
+// account (0 for money coming in). Like notifications, alerts are best
+// effort and never fail the change.
+func evaluateAlerts(ctx context.Context, database repository.TxRunner, accountRepo repository.AccountStore, alertRepo repository.AlertStore, notifier notifications.Notifier, accountID int, balance, spent float64, transactionID int) {
+	rules, err := alertRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		log.Printf("failed to load alert rules for account %d: %v", accountID, err)
+		return
+	}
+
+	now := time.Now()
+	var lowBalance []*models.AlertRule
+	for _, rule := range rules {
+		switch rule.Type {
+		case models.AlertLowBalance:
+			lowBalance = append(lowBalance, rule)
+
+		case models.AlertLargeTransaction:
+			if spent <= rule.Threshold {
+				continue
+			}
+			if err := alertRepo.RecordTriggered(ctx, rule.ID, now); err != nil {
+				log.Printf("failed to update alert rule %d: %v", rule.ID, err)
+			}
+			sendNotification(ctx, notifier, accountID, notifications.EventLargeTransaction, map[string]any{
+				"threshold":      rule.Threshold,
+				"amount":         spent,
+				"balance":        balance,
+				"transaction_id": transactionID,
+			})
+		}
+	}
+	if len(lowBalance) > 0 {
+		evaluateLowBalanceAlerts(ctx, database, accountRepo, alertRepo, notifier, accountID, lowBalance, now)
+	}
+}
+
+// evaluateLowBalanceAlerts moves each rule's triggered state to match the
+// account's balance. Other changes may have committed since the one being
+// evaluated, so the balance is read again under the account lock: concurrent
+// evaluations take turns and each sees the latest balance.
+func evaluateLowBalanceAlerts(ctx context.Context, database repository.TxRunner, accountRepo repository.AccountStore, alertRepo repository.AlertStore, notifier notifications.Notifier, accountID int, rules []*models.AlertRule, now time.Time) {
+	var balance float64
+	var fired []*models.AlertRule
+	err := database.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		balance, err = accountRepo.GetBalanceForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		fired = nil
+		for _, rule := range rules {
+			below := balance < rule.Threshold
+			// Only the caller that flips the state alerts, so a crossing
+			// fires once however many changes see it
+			changed, err := alertRepo.SetTriggered(ctx, rule.ID, below, now)
+			if err != nil {
+				return err
+			}
+			if changed && below {
+				fired = append(fired, rule)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to evaluate low balance alerts for account %d: %v", accountID, err)
+		return
+	}
+
+	for _, rule := range fired {
+		sendNotification(ctx, notifier, accountID, notifications.EventLowBalance, map[string]any{
+			"threshold": rule.Threshold,
+			"balance":   balance,
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestAlertService(t *testing.T) (*AlertService, *TransactionService, *notifications.MemoryNotifier) {
+	t.Helper()
+	svc, store := newTestTransactionService(t)
+	notifier := &notifications.MemoryNotifier{}
+	svc.notifier = notifier
+	return NewAlertService(store.Accounts(), store.Alerts()), svc, notifier
+}
+
+func countEvents(notifier *notifications.MemoryNotifier, accountID int, eventType string) int {
+	count := 0
+	for _, got := range eventTypes(notifier, accountID) {
+		if got == eventType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAlertRuleValidation(t *testing.T) {
+	alerts, svc, _ := newTestAlertService(t)
+	ctx := context.Background()
+	accounts := svc.accountRepo
+	owner, err := accounts.Create(ctx, "owner@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	other, err := accounts.Create(ctx, "other@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  models.CreateAlertRuleRequest
+	}{
+		{"unknown type", models.CreateAlertRuleRequest{Type: "high_balance", Threshold: 10}},
+		{"zero threshold", models.CreateAlertRuleRequest{Type: models.AlertLowBalance}},
+		{"too many decimals", models.CreateAlertRuleRequest{Type: models.AlertLargeTransaction, Threshold: 10.001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := alerts.Create(ctx, owner.ID, &tt.req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	rule, err := alerts.Create(ctx, owner.ID, &models.CreateAlertRuleRequest{Type: models.AlertLowBalance, Threshold: 50})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	// The balance is already below 50, so the rule waits for it to recover
+	if !rule.Triggered {
+		t.Errorf("expected a rule created below its threshold to start triggered")
+	}
+	if _, err := alerts.Create(ctx, owner.ID, &models.CreateAlertRuleRequest{Type: models.AlertLowBalance, Threshold: 50}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a duplicate rule to conflict, got %v", err)
+	}
+
+	if err := alerts.Delete(ctx, other.ID, rule.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected someone else's rule to be not found, got %v", err)
+	}
+	if err := alerts.Delete(ctx, owner.ID, rule.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	rules, err := alerts.List(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("expected no rules left, got %+v", rules)
+	}
+}
+
+func TestLowBalanceAlertFiresOncePerCrossing(t *testing.T) {
+	alerts, svc, notifier := newTestAlertService(t)
+	ctx := context.Background()
+	accounts := svc.accountRepo
+	account, err := accounts.Create(ctx, "low@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	friend, err := accounts.Create(ctx, "friend@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 100}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if _, err := alerts.Create(ctx, account.ID, &models.CreateAlertRuleRequest{Type: models.AlertLowBalance, Threshold: 50}); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		fired  int
+	}{
+		{"above the threshold", func() error {
+			_, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 30})
+			return err
+		}, 0},
+		{"crossing below", func() error {
+			_, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 30})
+			return err
+		}, 1},
+		{"still below", func() error {
+			_, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 10})
+			return err
+		}, 1},
+		{"recovering", func() error {
+			_, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 50})
+			return err
+		}, 1},
+		{"crossing below again by transfer", func() error {
+			_, err := svc.Transfer(ctx, account.ID, &models.TransferRequest{ToAccountID: friend.ID, Amount: 40})
+			return err
+		}, 2},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := countEvents(notifier, account.ID, notifications.EventLowBalance); got != step.fired {
+			t.Errorf("%s: expected %d low balance alerts so far, got %d", step.name, step.fired, got)
+		}
+	}
+
+	var last notifications.Event
+	for _, event := range notifier.Events() {
+		if event.Type == notifications.EventLowBalance {
+			last = event
+		}
+	}
+	if last.Data["balance"] != 40.0 || last.Data["threshold"] != 50.0 {
+		t.Errorf("expected the alert to report 40 against 50, got %v", last.Data)
+	}
+}
+
+func TestLargeTransactionAlert(t *testing.T) {
+	alerts, svc, notifier := newTestAlertService(t)
+	ctx := context.Background()
+	accounts := svc.accountRepo
+	account, err := accounts.Create(ctx, "large@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	friend, err := accounts.Create(ctx, "friend@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	for _, id := range []int{account.ID, friend.ID} {
+		if _, err := alerts.Create(ctx, id, &models.CreateAlertRuleRequest{Type: models.AlertLargeTransaction, Threshold: 100}); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+
+	// Money coming in is not spending
+	if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 500}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 100}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if got := countEvents(notifier, account.ID, notifications.EventLargeTransaction); got != 0 {
+		t.Fatalf("expected no alerts up to the threshold, got %d", got)
+	}
+
+	transfer, err := svc.Transfer(ctx, account.ID, &models.TransferRequest{ToAccountID: friend.ID, Amount: 150})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 120}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if got := countEvents(notifier, account.ID, notifications.EventLargeTransaction); got != 2 {
+		t.Errorf("expected an alert for each large payment, got %d", got)
+	}
+	if got := countEvents(notifier, friend.ID, notifications.EventLargeTransaction); got != 0 {
+		t.Errorf("expected the recipient not to be alerted, got %d", got)
+	}
+
+	for _, event := range notifier.Events() {
+		if event.Type == notifications.EventLargeTransaction {
+			if event.Data["transaction_id"] != transfer.ID || event.Data["amount"] != 150.0 || event.Data["balance"] != 250.0 {
+				t.Errorf("expected the first alert to describe the transfer, got %v", event.Data)
+			}
+			break
+		}
+	}
+
+	rules, err := alerts.List(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if rules[0].LastTriggeredAt == nil {
+		t.Errorf("expected the rule to record when it last fired")
+	}
+}
+
+// An evaluation can run after later changes have committed; the rule must
+// follow the balance the account has now, not the one it was handed
+func TestLowBalanceAlertIgnoresStaleBalance(t *testing.T) {
+	alerts, svc, notifier := newTestAlertService(t)
+	ctx := context.Background()
+	account, err := svc.accountRepo.Create(ctx, "stale@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	deposit, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 100})
+	if err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if _, err := alerts.Create(ctx, account.ID, &models.CreateAlertRuleRequest{Type: models.AlertLowBalance, Threshold: 50}); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 70}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+
+	// The deposit's evaluation arriving late still believes the balance is 100
+	evaluateAlerts(ctx, svc.db, svc.accountRepo, svc.alertRepo, svc.notifier, account.ID, 100, 0, deposit.ID)
+
+	rules, err := alerts.List(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !rules[0].Triggered {
+		t.Errorf("expected the rule to stay triggered while the balance is 30")
+	}
+	if got := countEvents(notifier, account.ID, notifications.EventLowBalance); got != 1 {
+		t.Errorf("expected one low balance alert, got %d", got)
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// MaxAttachmentBytes bounds the size of one attached file
+	MaxAttachmentBytes = 10 << 20
+	maxAttachments     = 10
+	maxNoteLength      = 1000
+	maxTags            = 10
+)
+
+// attachmentContentTypes are the file formats accepted for receipts, as
+// sniffed from the file's contents rather than trusted from the upload
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// AnnotationService lets holders annotate their transactions for expense
+// reports with a note, tags and attached receipts. Annotations belong to
+// the account that made them: the other side of a transfer never sees them.
+type AnnotationService struct {
+	db              repository.TxRunner
+	transactionRepo repository.TransactionStore
+	annotationRepo  repository.AnnotationStore
+	storage         storage.Storage
+}
+
+func NewAnnotationService(
+	database repository.TxRunner,
+	transactionRepo repository.TransactionStore,
+	annotationRepo repository.AnnotationStore,
+	storage storage.Storage,
+) *AnnotationService {
+	return &AnnotationService{
+		db:              database,
+		transactionRepo: transactionRepo,
+		annotationRepo:  annotationRepo,
+		storage:         storage,
+	}
+}
+
+// Update replaces the account's note and tags on a transaction. Tags are
+// lowercased and de-duplicated. An empty note and no tags remove the
+// annotation, and nil is returned.
+func (s *AnnotationService) Update(ctx context.Context, accountID, transactionID int, req *models.UpdateAnnotationRequest) (*models.TransactionAnnotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationService.Update")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+	span.SetAttribute("transaction.id", transactionID)
+
+	note := strings.TrimSpace(req.Note)
+	if len(note) > maxNoteLength {
+		return nil, &utils.ValidationError{Field: "note", Message: "note cannot exceed 1000 characters"}
+	}
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = utils.NormalizeTag(tag)
+		if err := utils.ValidateTag(tag, "tags"); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return nil, &utils.ValidationError{Field: "tags", Message: "a transaction can have at most 10 tags"}
+	}
+	slices.Sort(tags)
+
+	if err := participantTransaction(ctx, s.transactionRepo, accountID, transactionID); err != nil {
+		return nil, err
+	}
+
+	if note == "" && len(tags) == 0 {
+		if err := s.annotationRepo.Delete(ctx, accountID, transactionID); err != nil {
+			return nil, wrapInternal("failed to update annotation", err)
+		}
+		return nil, nil
+	}
+	annotation, err := s.annotationRepo.Save(ctx, &models.TransactionAnnotation{
+		AccountID:     accountID,
+		TransactionID: transactionID,
+		Note:          note,
+		Tags:          tags,
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to update annotation", err)
+	}
+	return annotation, nil
+}
+
+// UploadAttachment attaches a receipt to one of the account's transactions.
+// The file is checked to be a JPEG, PNG or PDF of at most MaxAttachmentBytes.
+func (s *AnnotationService) UploadAttachment(ctx context.Context, accountID, transactionID int, fileName string, file io.Reader) (*models.TransactionAttachment, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationService.UploadAttachment")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+	span.SetAttribute("transaction.id", transactionID)
+
+	fileName = filepath.Base(utils.SanitizeString(fileName))
+	if fileName == "." || fileName == string(filepath.Separator) || len(fileName) > 255 {
+		return nil, &utils.ValidationError{Field: "file", Message: "file must have a name of at most 255 characters"}
+	}
+	if err := participantTransaction(ctx, s.transactionRepo, accountID, transactionID); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxAttachmentBytes+1))
+	if err != nil {
+		return nil, wrapInternal("failed to upload attachment", err)
+	}
+	if len(data) == 0 {
+		return nil, &utils.ValidationError{Field: "file", Message: "file is empty"}
+	}
+	if len(data) > MaxAttachmentBytes {
+		return nil, Validation("file_too_large", fmt.Sprintf("attachments can be at most %d MB", MaxAttachmentBytes>>20))
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !attachmentContentTypes[contentType] {
+		return nil, Validation("unsupported_file_type", "attachments must be JPEG, PNG or PDF files")
+	}
+
+	// The file is stored first so the database never points at a missing
+	// one; it is removed again if the attachment is not recorded
+	key := fmt.Sprintf("attachments/%d/%s", accountID, rand.Text())
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return nil, wrapInternal("failed to upload attachment", err)
+	}
+
+	sum := sha256.Sum256(data)
+	var attachment *models.TransactionAttachment
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.annotationRepo.ListAttachments(ctx, accountID, []int{transactionID})
+		if err != nil {
+			return err
+		}
+		if len(existing) >= maxAttachments {
+			return Conflict("attachment_limit", fmt.Sprintf("a transaction can have at most %d attachments", maxAttachments))
+		}
+		attachment, err = s.annotationRepo.AddAttachment(ctx, &models.TransactionAttachment{
+			AccountID:     accountID,
+			TransactionID: transactionID,
+			FileName:      fileName,
+			ContentType:   contentType,
+			Size:          int64(len(data)),
+			SHA256:        hex.EncodeToString(sum[:]),
+			StorageKey:    key,
+		})
+		return err
+	})
+	if err != nil {
+		if deleteErr := s.storage.Delete(ctx, key); deleteErr != nil {
+			log.Printf("failed to remove unrecorded attachment %s: %v", key, deleteErr)
+		}
+		return nil, wrapInternal("failed to upload attachment", err)
+	}
+	return attachment, nil
+}
+
+// OpenAttachment returns one of the account's attachments on a transaction
+// and its contents; the caller closes the reader
+func (s *AnnotationService) OpenAttachment(ctx context.Context, accountID, transactionID, attachmentID int) (*models.TransactionAttachment, io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationService.OpenAttachment")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	attachment, err := s.attachment(ctx, accountID, transactionID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := s.storage.Open(ctx, attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, NotFound("attachment_not_found", "attachment file is missing")
+	}
+	if err != nil {
+		return nil, nil, wrapInternal("failed to open attachment", err)
+	}
+	return attachment, file, nil
+}
+
+// DeleteAttachment removes an attachment and its file
+func (s *AnnotationService) DeleteAttachment(ctx context.Context, accountID, transactionID, attachmentID int) error {
+	ctx, span := tracing.Start(ctx, "AnnotationService.DeleteAttachment")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	attachment, err := s.attachment(ctx, accountID, transactionID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := s.annotationRepo.DeleteAttachment(ctx, attachment.ID); err != nil {
+		return notFoundOrInternal(err, "attachment_not_found", "attachment not found")
+	}
+	// The record is gone, so a file left behind is unreachable; it is
+	// logged for cleanup rather than failing the request
+	if err := s.storage.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("failed to delete attachment %s: %v", attachment.StorageKey, err)
+	}
+	return nil
+}
+
+// attachment looks up one of the account's attachments on a transaction.
+// Someone else's attachment is reported as missing so IDs can't be probed.
+func (s *AnnotationService) attachment(ctx context.Context, accountID, transactionID, attachmentID int) (*models.TransactionAttachment, error) {
+	attachment, err := s.annotationRepo.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "attachment_not_found", "attachment not found")
+	}
+	if attachment.AccountID != accountID || attachment.TransactionID != transactionID {
+		return nil, NotFound("attachment_not_found", "attachment not found")
+	}
+	return attachment, nil
+}
+
+// participantTransaction checks that the transaction involves the account.
+// Someone else's transaction is reported as missing so IDs can't be probed.
+func participantTransaction(ctx context.Context, transactionRepo repository.TransactionStore, accountID, transactionID int) error {
+	transaction, err := transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return notFoundOrInternal(err, "transaction_not_found", "transaction not found")
+	}
+	if !equalAccount(transaction.FromAccountID, accountID) && !equalAccount(transaction.ToAccountID, accountID) {
+		return NotFound("transaction_not_found", "transaction not found")
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestAnnotationService(t *testing.T) (*AnnotationService, *TransactionService, *memory.Store) {
+	t.Helper()
+	svc, store := newTestTransactionService(t)
+	return NewAnnotationService(store, store.Transactions(), store.Annotations(), &storage.MemoryStorage{}), svc, store
+}
+
+func TestAnnotationUpdate(t *testing.T) {
+	annotations, svc, store := newTestAnnotationService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 500)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+	carol := createFundedAccount(t, store, svc, "carol@example.com", 0)
+
+	transfer, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: 40, Description: "Team lunch"})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  models.UpdateAnnotationRequest
+	}{
+		{"note too long", models.UpdateAnnotationRequest{Note: strings.Repeat("a", maxNoteLength+1)}},
+		{"invalid tag", models.UpdateAnnotationRequest{Tags: []string{"client lunch"}}},
+		{"empty tag", models.UpdateAnnotationRequest{Tags: []string{" "}}},
+		{"too many tags", models.UpdateAnnotationRequest{Tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ",")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := annotations.Update(ctx, alice.ID, transfer.ID, &tt.req); !errors.As(err, new(*utils.ValidationError)) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	// Tags are normalized, de-duplicated and sorted
+	annotation, err := annotations.Update(ctx, alice.ID, transfer.ID, &models.UpdateAnnotationRequest{
+		Note: "  Client lunch with Acme ",
+		Tags: []string{"Expenses", "client-acme", "expenses"},
+	})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if annotation.Note != "Client lunch with Acme" || !slices.Equal(annotation.Tags, []string{"client-acme", "expenses"}) {
+		t.Errorf("unexpected annotation %+v", annotation)
+	}
+	if _, err := annotations.Update(ctx, carol.ID, transfer.ID, &models.UpdateAnnotationRequest{Note: "mine"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an outsider's transaction to be not found, got %v", err)
+	}
+
+	// Only the annotating party sees the annotation
+	got, err := svc.GetTransaction(ctx, alice.ID, transfer.ID)
+	if err != nil {
+		t.Fatalf("get transaction failed: %v", err)
+	}
+	if got.Note != "Client lunch with Acme" || len(got.Tags) != 2 {
+		t.Errorf("expected the annotation on the transaction, got note %q tags %v", got.Note, got.Tags)
+	}
+	got, err = svc.GetTransaction(ctx, bob.ID, transfer.ID)
+	if err != nil {
+		t.Fatalf("get transaction failed: %v", err)
+	}
+	if got.Note != "" || len(got.Tags) != 0 {
+		t.Errorf("expected the other party not to see the annotation, got note %q tags %v", got.Note, got.Tags)
+	}
+
+	// The tag filter only matches the account's own tags
+	page, err := svc.ListTransactions(ctx, alice.ID, models.TransactionFilter{Tag: "expenses"}, "", 20)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != transfer.ID || page.Data[0].Note == "" {
+		t.Errorf("expected only the tagged transfer, got %+v", page.Data)
+	}
+	page, err = svc.ListTransactions(ctx, bob.ID, models.TransactionFilter{Tag: "expenses"}, "", 20)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(page.Data) != 0 {
+		t.Errorf("expected no tagged transactions for the other party, got %d", len(page.Data))
+	}
+
+	// An empty note and no tags remove the annotation
+	annotation, err = annotations.Update(ctx, alice.ID, transfer.ID, &models.UpdateAnnotationRequest{})
+	if err != nil || annotation != nil {
+		t.Fatalf("expected the annotation to be removed, got %+v, %v", annotation, err)
+	}
+	if _, err := store.Annotations().Get(ctx, alice.ID, transfer.ID); err == nil {
+		t.Error("expected the annotation to be deleted")
+	}
+}
+
+func TestAnnotationAttachments(t *testing.T) {
+	annotations, svc, store := newTestAnnotationService(t)
+	files := annotations.storage.(*storage.MemoryStorage)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 500)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+
+	transfer, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: 40, Description: "Taxi"})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		code string
+	}{
+		{"empty file", nil, "validation_error"},
+		{"text file", []byte("just some text"), "unsupported_file_type"},
+		{"too large", append(testPDF, make([]byte, MaxAttachmentBytes)...), "file_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := annotations.UploadAttachment(ctx, alice.ID, transfer.ID, "receipt", bytes.NewReader(tt.data))
+			if tt.code == "validation_error" && !errors.As(err, new(*utils.ValidationError)) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+			if tt.code != "validation_error" && errorCode(err) != tt.code {
+				t.Errorf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+	if len(files.Keys()) != 0 {
+		t.Fatalf("expected refused uploads not to be stored, got %v", files.Keys())
+	}
+
+	attachment, err := annotations.UploadAttachment(ctx, alice.ID, transfer.ID, "../receipt.pdf", bytes.NewReader(testPDF))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if attachment.FileName != "receipt.pdf" || attachment.ContentType != "application/pdf" || attachment.Size != int64(len(testPDF)) || len(attachment.SHA256) != 64 {
+		t.Errorf("unexpected attachment %+v", attachment)
+	}
+
+	got, file, err := annotations.OpenAttachment(ctx, alice.ID, transfer.ID, attachment.ID)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if got.ID != attachment.ID || !bytes.Equal(data, testPDF) {
+		t.Errorf("expected the uploaded file back, got %q", data)
+	}
+	if _, _, err := annotations.OpenAttachment(ctx, bob.ID, transfer.ID, attachment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the other party's view of the attachment to be not found, got %v", err)
+	}
+
+	response, err := svc.GetTransaction(ctx, alice.ID, transfer.ID)
+	if err != nil {
+		t.Fatalf("get transaction failed: %v", err)
+	}
+	if len(response.Attachments) != 1 || response.Attachments[0].ID != attachment.ID {
+		t.Errorf("expected the attachment on the transaction, got %+v", response.Attachments)
+	}
+
+	for i := 1; i < maxAttachments; i++ {
+		if _, err := annotations.UploadAttachment(ctx, alice.ID, transfer.ID, "receipt.png", bytes.NewReader(testPNG)); err != nil {
+			t.Fatalf("upload %d failed: %v", i, err)
+		}
+	}
+	if _, err := annotations.UploadAttachment(ctx, alice.ID, transfer.ID, "receipt.png", bytes.NewReader(testPNG)); errorCode(err) != "attachment_limit" {
+		t.Errorf("expected the attachment limit to apply, got %v", err)
+	}
+	// The file of a refused upload is removed again
+	if len(files.Keys()) != maxAttachments {
+		t.Errorf("expected %d stored files, got %d", maxAttachments, len(files.Keys()))
+	}
+
+	if err := annotations.DeleteAttachment(ctx, bob.ID, transfer.ID, attachment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the other party not to delete the attachment, got %v", err)
+	}
+	if err := annotations.DeleteAttachment(ctx, alice.ID, transfer.ID, attachment.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if len(files.Keys()) != maxAttachments-1 {
+		t.Errorf("expected the file to be deleted, got %d stored files", len(files.Keys()))
+	}
+	if _, _, err := annotations.OpenAttachment(ctx, alice.ID, transfer.ID, attachment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a deleted attachment to be not found, got %v", err)
+	}
+}
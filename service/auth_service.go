@@ -1,33 +1,54 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/wizzyszn/go_bank/db"
 	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
 	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
 	"github.com/wizzyszn/go_bank/utils"
 )
 
 type AuthService struct {
-	db              *db.DB
-	accountRepo     *repository.AccountRepository
-	sessionRepo     *repository.SessionRepository
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	sessionRepo     repository.SessionStore
 	sessionDuration time.Duration
+	iban            utils.IBANFormat
+	notifier        notifications.Notifier
+	verification    *VerificationService
 }
 
-func NewAuthService(database *db.DB, accountRepo *repository.AccountRepository, sessionRepo *repository.SessionRepository, sessionDuration time.Duration) *AuthService {
+func NewAuthService(database repository.TxRunner, accountRepo repository.AccountStore, sessionRepo repository.SessionStore, sessionDuration time.Duration, iban utils.IBANFormat, notifier notifications.Notifier, verification *VerificationService) *AuthService {
 
 	return &AuthService{
 		db:              database,
 		accountRepo:     accountRepo,
 		sessionRepo:     sessionRepo,
 		sessionDuration: sessionDuration,
+		iban:            iban,
+		notifier:        notifier,
+		verification:    verification,
 	}
 }
 
-func (s *AuthService) Register(req *models.CreateAccountRequest) (*models.AccountResponse, error) {
+// AccountResponse converts an account for the client, adding its IBAN when
+// IBANs are configured
+func (s *AuthService) AccountResponse(account *models.Account) *models.AccountResponse {
+	response := account.ToResponse()
+	response.IBAN = s.iban.Format(account.AccountNumber)
+	return response
+}
+
+// Register opens an account pending verification and emails the holder a
+// link to verify their address
+func (s *AuthService) Register(ctx context.Context, req *models.CreateAccountRequest) (*models.AccountResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Register")
+	defer span.End()
 
 	//Validate
 	if err := utils.ValidateEmail(req.Email); err != nil {
@@ -48,33 +69,51 @@ func (s *AuthService) Register(req *models.CreateAccountRequest) (*models.Accoun
 	req.FirstName = utils.SanitizeString(req.FirstName)
 	req.LastName = utils.SanitizeString(req.LastName)
 
-	exists, err := s.accountRepo.EmailExists(req.Email)
+	exists, err := s.accountRepo.EmailExists(ctx, req.Email)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to check email: %w", err)
+		return nil, Internal("failed to check email", err)
 	}
 
 	if exists {
-		return nil, fmt.Errorf("Email already in use")
+		return nil, Conflict("email_taken", "Email already in use")
 	}
 
 	passwordHash, err := utils.HashPassword(req.Password)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, Internal("failed to hash password", err)
 	}
 
-	account, err := s.accountRepo.Create(req.Email, passwordHash, req.FirstName, req.LastName)
-
+	var account *models.Account
+	var token string
+	var expiresAt time.Time
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		account, err = s.accountRepo.Create(ctx, req.Email, passwordHash, req.FirstName, req.LastName)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return Conflict("email_taken", "Email already in use")
+		}
+		if err != nil {
+			return err
+		}
+		token, expiresAt, err = s.verification.start(ctx, account.ID)
+		account.Status = models.AccountStatusPendingVerification
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create account: %w", err)
+		return nil, wrapInternal("failed to create account", err)
 	}
 
-	return account.ToResponse(), nil
+	s.verification.sendFirst(ctx, account, token, expiresAt)
+	return s.AccountResponse(account), nil
 
 }
 
-func (s *AuthService) Login(req models.LoginAccountRequest) (*models.LoginResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req models.LoginAccountRequest) (*models.LoginResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
+
 	if err := utils.ValidateEmail(req.Email); err != nil {
 		return nil, err
 	}
@@ -83,93 +122,127 @@ func (s *AuthService) Login(req models.LoginAccountRequest) (*models.LoginRespon
 		return nil, err
 	}
 
-	account, err := s.accountRepo.GeyByEmail(req.Email)
+	account, err := s.accountRepo.GeyByEmail(ctx, req.Email)
 
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, Unauthorized("invalid_credentials", "Invalid email or password")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("Invalid email or password")
+		return nil, Internal("failed to look up account", err)
 	}
 
-	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("account is %s", account.Status)
+	if !canSignIn(account.Status) {
+		return nil, accountInactive(account.Status)
 	}
 
 	if err := utils.CheckPassword(req.Password, account.PasswordHash); err != nil {
-		return nil, fmt.Errorf("Invalid Email or Password")
+		return nil, Unauthorized("invalid_credentials", "Invalid email or password")
 	}
 
 	sessionID, err := utils.GenerateSessionID()
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate session: %w", err)
+		return nil, Internal("failed to generate session", err)
 	}
 
-	session, err := s.sessionRepo.Create(sessionID, account.ID, time.Now().Add(s.sessionDuration))
+	session, err := s.sessionRepo.Create(ctx, sessionID, account.ID, time.Now().Add(s.sessionDuration))
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, Internal("failed to create session", err)
 	}
 
+	sendNotification(ctx, s.notifier, account.ID, notifications.EventLogin, map[string]any{
+		"at": time.Now().UTC().Format("2 Jan 2006 15:04 MST"),
+	})
+
 	return &models.LoginResponse{
-		Account:   account.ToResponse(),
+		Account:   s.AccountResponse(account),
 		SessionID: session.ID,
 		ExpiresAt: session.ExpiresAt,
 	}, nil
 
 }
 
-func (s *AuthService) Logout(sessionID string) error {
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
 	if err := utils.ValidateRequired(sessionID, "session_id"); err != nil {
 		return err
 	}
 
-	if err := s.sessionRepo.Delete(sessionID); err != nil {
-		return fmt.Errorf("failed to logout: %w", err)
+	if err := s.sessionRepo.Delete(ctx, sessionID); err != nil {
+		return notFoundOrInternal(err, "session_not_found", "session not found")
 	}
 	return nil
 }
 
-func (s *AuthService) LogoutAll(accountID int) error {
-	if err := s.sessionRepo.DeleteAccountByID(accountID); err != nil {
-		return fmt.Errorf("failed to logout all sessions: %w", err)
+func (s *AuthService) LogoutAll(ctx context.Context, accountID int) error {
+	if err := s.sessionRepo.DeleteAccountByID(ctx, accountID); err != nil {
+		return wrapInternal("failed to logout all sessions", err)
 	}
 	return nil
 }
 
-func (s *AuthService) ValidateSession(sessionID string) (*models.Account, error) {
-	session, err := s.sessionRepo.GetByID(sessionID)
+func (s *AuthService) ValidateSession(ctx context.Context, sessionID string) (*models.Account, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ValidateSession")
+	defer span.End()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
 
 	if err != nil {
-		return nil, fmt.Errorf("invalid session")
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, Unauthorized("invalid_session", "invalid session")
+		}
+		return nil, Internal("failed to load session", err)
 	}
 
 	if session.IsExpired() {
-		s.sessionRepo.Delete(sessionID)
-		return nil, fmt.Errorf("session expired")
+		s.sessionRepo.Delete(ctx, sessionID)
+		return nil, Unauthorized("session_expired", "session expired")
 	}
 
-	account, err := s.accountRepo.GetByID(session.AccountID)
+	account, err := s.accountRepo.GetByID(ctx, session.AccountID)
 	if err != nil {
-		return nil, fmt.Errorf("account not found")
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
-	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("account is %s", account.Status)
+	if !canSignIn(account.Status) {
+		return nil, accountInactive(account.Status)
 	}
 
 	return account, nil
 }
 
-func (s *AuthService) GetAccount(accountID int) (*models.AccountResponse, error) {
-	account, err := s.accountRepo.GetByID(accountID)
+// canSignIn reports whether an account in status may sign in. Accounts
+// pending verification can, so they can ask for another verification email,
+// but they are refused anything that moves money.
+func canSignIn(status string) bool {
+	return status == models.AccountStatusActice || status == models.AccountStatusPendingVerification
+}
+
+func (s *AuthService) GetAccount(ctx context.Context, accountID int) (*models.AccountResponse, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("account not found")
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
-	return account.ToResponse(), nil
+	return s.AccountResponse(account), nil
 }
 
-func (s *AuthService) UpdateAccount(accountID int, req *models.UpdateAccountRequest) (*models.AccountResponse, error) {
-	account, err := s.accountRepo.GetByID(accountID)
+// GetByAccountNumber finds an account by its number or IBAN, for admin lookups
+func (s *AuthService) GetByAccountNumber(ctx context.Context, accountNumber string) (*models.Account, error) {
+	number, err := utils.ParseAccountNumber(accountNumber, s.iban)
 	if err != nil {
-		return nil, fmt.Errorf("account not found")
+		return nil, err
+	}
+	account, err := s.accountRepo.GetByAccountNumber(ctx, number)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	return account, nil
+}
+
+func (s *AuthService) UpdateAccount(ctx context.Context, accountID int, req *models.UpdateAccountRequest) (*models.AccountResponse, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
 
 	firstName := account.FirstName
@@ -189,24 +262,126 @@ func (s *AuthService) UpdateAccount(accountID int, req *models.UpdateAccountRequ
 		lastName = utils.SanitizeString(req.LastName)
 	}
 
-	if err := s.accountRepo.Update(accountID, firstName, lastName); err != nil {
-		return nil, fmt.Errorf("failed to update account: %w", err)
+	if err := s.accountRepo.Update(ctx, accountID, firstName, lastName); err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "failed to update account")
+	}
+
+	updated, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to fetch updated account", err)
+	}
+
+	return s.AccountResponse(updated), nil
+}
+
+// SetOverdraftLimit approves, changes or removes (limit 0) an account's
+// overdraft. The limit cannot go below what the account already owes.
+func (s *AuthService) SetOverdraftLimit(ctx context.Context, accountID int, limit float64) (*models.AccountResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.SetOverdraftLimit")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateOverdraftLimit(limit); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID)
+		if err != nil {
+			return notFoundOrInternal(err, "account_not_found", "account not found")
+		}
+		if balance < -limit {
+			return Conflict("overdraft_in_use", fmt.Sprintf("account is overdrawn by %.2f; the limit cannot be lower", -balance))
+		}
+		return s.accountRepo.SetOverdraftLimit(ctx, accountID, limit)
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to set overdraft limit", err)
 	}
 
-	updated, err := s.accountRepo.GetByID(accountID)
+	return s.GetAccount(ctx, accountID)
+}
+
+// SetStatus suspends or reactivates an account and tells its holder.
+// Closed accounts stay closed, and accounts pending verification are only
+// activated by verifying their email.
+func (s *AuthService) SetStatus(ctx context.Context, accountID int, status string) (*models.AccountResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.SetStatus")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if status != models.AccountStatusActice && status != models.AccountStatusSuspended {
+		return nil, &utils.ValidationError{Field: "status", Message: "status must be active or suspended"}
+	}
+
+	changed := false
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID); err != nil {
+			return notFoundOrInternal(err, "account_not_found", "account not found")
+		}
+		account, err := s.accountRepo.GetByID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Status == models.AccountStatusClosed {
+			return Conflict("account_closed", "account is closed")
+		}
+		if account.Status == models.AccountStatusPendingVerification {
+			return Conflict("email_unverified", "account has not verified its email address")
+		}
+		if account.Status == status {
+			return nil
+		}
+		changed = true
+		return s.accountRepo.SetStatus(ctx, accountID, status)
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to set account status", err)
+	}
+
+	if changed {
+		sendNotification(ctx, s.notifier, accountID, notifications.EventAccountStatusChanged, map[string]any{
+			"status": status,
+		})
+	}
+	return s.GetAccount(ctx, accountID)
+}
+
+// ListAccounts returns a page of open accounts, newest first
+func (s *AuthService) ListAccounts(ctx context.Context, page, limit int) (*models.PaginatedResponse, error) {
+	if err := utils.ValidatePagination(page, limit); err != nil {
+		return nil, err
+	}
+
+	accounts, totalCount, err := s.accountRepo.List(ctx, page, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch updated account: %w", err)
+		return nil, wrapInternal("failed to list accounts", err)
 	}
 
-	return updated.ToResponse(), nil
+	responses := make([]*models.AccountResponse, len(accounts))
+	for i, account := range accounts {
+		responses[i] = s.AccountResponse(account)
+	}
+
+	totalPages := totalCount / limit
+	if totalCount%limit != 0 {
+		totalPages++
+	}
+	return &models.PaginatedResponse{
+		Data:       responses,
+		Page:       page,
+		Limit:      limit,
+		TotalCount: totalCount,
+		TotalPages: totalPages,
+	}, nil
 }
 
 // CleanupExpiredSessions removes all expired sessions
 // Intended to be called on a schedule (e.g. every hour via a goroutine in main.go)
-func (s *AuthService) CleanupExpiredSessions() (int, error) {
-	count, err := s.sessionRepo.DeleteExpired()
+func (s *AuthService) CleanupExpiredSessions(ctx context.Context) (int, error) {
+	count, err := s.sessionRepo.DeleteExpired(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup sessions: %w", err)
+		return 0, wrapInternal("failed to cleanup sessions", err)
 	}
 	return count, nil
 }
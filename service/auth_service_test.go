@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const testPassword = "Str0ng!Password"
+
+func newTestAuthService(t *testing.T) (*AuthService, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	return NewAuthService(store, store.Accounts(), store.Sessions(), time.Hour, utils.IBANFormat{}, &notifications.MemoryNotifier{}, newTestVerificationService(store)), store
+}
+
+// registerTestAccount registers an account and verifies its email, leaving
+// it active
+func registerTestAccount(t *testing.T, svc *AuthService, email string) *models.AccountResponse {
+	t.Helper()
+	ctx := context.Background()
+	account, err := svc.Register(ctx, &models.CreateAccountRequest{
+		Email:     email,
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Password:  testPassword,
+	})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if err := svc.verification.Verify(ctx, verificationToken(t, svc.verification, email)); err != nil {
+		t.Fatalf("failed to verify email: %v", err)
+	}
+	verified, err := svc.GetAccount(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	return verified
+}
+
+func TestRegister(t *testing.T) {
+	svc, _ := newTestAuthService(t)
+	ctx := context.Background()
+
+	account, err := svc.Register(ctx, &models.CreateAccountRequest{
+		Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Password: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if account.Status != models.AccountStatusPendingVerification {
+		t.Errorf("expected account pending verification, got %s", account.Status)
+	}
+
+	_, err = svc.Register(ctx, &models.CreateAccountRequest{
+		Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Password: testPassword,
+	})
+	if err == nil {
+		t.Error("expected duplicate email to be rejected")
+	}
+
+	_, err = svc.Register(ctx, &models.CreateAccountRequest{
+		Email: "weak@example.com", FirstName: "Jane", LastName: "Doe", Password: "weak",
+	})
+	if err == nil {
+		t.Error("expected weak password to be rejected")
+	}
+}
+
+func TestLoginAndValidateSession(t *testing.T) {
+	svc, _ := newTestAuthService(t)
+	ctx := context.Background()
+	registerTestAccount(t, svc, "jane@example.com")
+
+	if _, err := svc.Login(ctx, models.LoginAccountRequest{Email: "jane@example.com", Password: "Wr0ng!Password"}); err == nil {
+		t.Error("expected wrong password to be rejected")
+	}
+
+	res, err := svc.Login(ctx, models.LoginAccountRequest{Email: "jane@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	account, err := svc.ValidateSession(ctx, res.SessionID)
+	if err != nil {
+		t.Fatalf("session should be valid: %v", err)
+	}
+	if account.Email != "jane@example.com" {
+		t.Errorf("session resolved to wrong account: %s", account.Email)
+	}
+
+	if err := svc.Logout(ctx, res.SessionID); err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+	if _, err := svc.ValidateSession(ctx, res.SessionID); err == nil {
+		t.Error("session should be invalid after logout")
+	}
+}
+
+func TestExpiredSessionsAreRejected(t *testing.T) {
+	svc, store := newTestAuthService(t)
+	ctx := context.Background()
+	account := registerTestAccount(t, svc, "jane@example.com")
+
+	if _, err := store.Sessions().Create(ctx, "expired", account.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := svc.ValidateSession(ctx, "expired"); err == nil {
+		t.Error("expired session should be rejected")
+	}
+
+	store.Sessions().Create(ctx, "stale", account.ID, time.Now().Add(-time.Minute))
+	count, err := svc.CleanupExpiredSessions(ctx)
+	if err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 expired session removed, got %d", count)
+	}
+}
+
+func TestRegisterAssignsAccountNumber(t *testing.T) {
+	store := memory.NewStore()
+	svc := NewAuthService(store, store.Accounts(), store.Sessions(), time.Hour, utils.IBANFormat{CountryCode: "GB", BankCode: "GOBK"}, &notifications.MemoryNotifier{}, newTestVerificationService(store))
+
+	first := registerTestAccount(t, svc, "first@example.com")
+	second := registerTestAccount(t, svc, "second@example.com")
+
+	if err := utils.ValidateAccountNumber(first.AccountNumber, svc.iban); err != nil {
+		t.Fatalf("account number %q is invalid: %v", first.AccountNumber, err)
+	}
+	if first.AccountNumber == second.AccountNumber {
+		t.Errorf("accounts share number %q", first.AccountNumber)
+	}
+	if number, err := utils.ParseAccountNumber(first.IBAN, svc.iban); err != nil || number != first.AccountNumber {
+		t.Errorf("IBAN %q does not wrap account number %q (err %v)", first.IBAN, first.AccountNumber, err)
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// MaxBatchRows bounds the number of transfers in one upload
+const MaxBatchRows = 1000
+
+// RowErrors is returned when rows of a batch upload are invalid. Nothing is
+// stored and no money moves.
+type RowErrors struct {
+	Rows []models.BatchRowError
+}
+
+func (e *RowErrors) Error() string {
+	return fmt.Sprintf("batch has %d invalid rows", len(e.Rows))
+}
+
+func (e *RowErrors) Unwrap() error {
+	return ErrValidation
+}
+
+// BatchService runs uploaded batches of transfers in the background. Clients
+// poll Get with the batch ID for progress and per-row results.
+type BatchService struct {
+	db           repository.TxRunner
+	accountRepo  repository.AccountStore
+	batchRepo    repository.BatchStore
+	transactions *TransactionService
+
+	wg sync.WaitGroup
+}
+
+func NewBatchService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	batchRepo repository.BatchStore,
+	transactionService *TransactionService,
+) *BatchService {
+	return &BatchService{
+		db:           database,
+		accountRepo:  accountRepo,
+		batchRepo:    batchRepo,
+		transactions: transactionService,
+	}
+}
+
+// Submit validates every row and checks the sender can cover the total
+// before storing the batch and starting it. The returned batch is pending.
+func (s *BatchService) Submit(ctx context.Context, accountID int, mode string, rows []models.TransferRequest) (*models.TransferBatch, error) {
+	ctx, span := tracing.Start(ctx, "BatchService.Submit")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+	span.SetAttribute("batch.rows", len(rows))
+
+	if mode == "" {
+		mode = models.BatchModeAtomic
+	}
+	if mode != models.BatchModeAtomic && mode != models.BatchModeBestEffort {
+		return nil, &utils.ValidationError{Field: "mode", Message: "mode must be atomic or best_effort"}
+	}
+	if len(rows) == 0 {
+		return nil, Validation("empty_batch", "batch has no transfers")
+	}
+	if len(rows) > MaxBatchRows {
+		return nil, Validation("batch_too_large", fmt.Sprintf("batch cannot exceed %d transfers", MaxBatchRows))
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if account.Status != models.AccountStatusActice {
+		return nil, accountInactive(account.Status)
+	}
+
+	items, totalCents, err := s.validateRows(ctx, accountID, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every transfer pays its own fee, which has to fit as well
+	total := float64(totalCents) / 100
+	debit := total
+	for _, item := range items {
+		fee, _, err := feeFor(ctx, s.transactions.feeRepo, account.Product, models.TransactionTypeTransfer, item.Amount)
+		if err != nil {
+			return nil, wrapInternal("failed to create batch", err)
+		}
+		debit = sumAmounts(debit, fee)
+	}
+	if available := account.AvailableBalance(); available < debit {
+		return nil, InsufficientFunds(available, debit)
+	}
+
+	var batch *models.TransferBatch
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		batch, err = s.batchRepo.Create(ctx, accountID, mode, total, items)
+		return err
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to create batch", err)
+	}
+	if err := s.itemAccountNumbers(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	// The batch outlives the request that submitted it
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.process(context.WithoutCancel(ctx), batch)
+	}()
+
+	return batch, nil
+}
+
+// Get returns a batch owned by accountID
+func (s *BatchService) Get(ctx context.Context, accountID, batchID int) (*models.TransferBatch, error) {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "batch_not_found", "batch not found")
+	}
+	if batch.AccountID != accountID {
+		return nil, NotFound("batch_not_found", "batch not found")
+	}
+	if err := s.itemAccountNumbers(ctx, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// itemAccountNumbers sets the recipients' account numbers on the items
+func (s *BatchService) itemAccountNumbers(ctx context.Context, batch *models.TransferBatch) error {
+	numbers, err := s.accountRepo.GetAccountNumbers(ctx, batchAccountIDs(batch))
+	if err != nil {
+		return wrapInternal("failed to get account numbers", err)
+	}
+	for _, item := range batch.Items {
+		item.ToAccountNumber = numbers[item.ToAccountID]
+	}
+	return nil
+}
+
+// Wait blocks until every batch that has been started has finished
+func (s *BatchService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *BatchService) validateRows(ctx context.Context, accountID int, rows []models.TransferRequest) ([]*models.TransferBatchItem, int64, error) {
+	var rowErrors []models.BatchRowError
+	reject := func(row int, err error) {
+		rowError := models.BatchRowError{Row: row, Message: err.Error()}
+		var validationErr *utils.ValidationError
+		var domainErr *Error
+		switch {
+		case errors.As(err, &validationErr):
+			rowError.Field, rowError.Message = validationErr.Field, validationErr.Message
+		case errors.As(err, &domainErr):
+			rowError.Message = domainErr.Message
+		}
+		rowErrors = append(rowErrors, rowError)
+	}
+
+	recipients := make(map[int]error)
+	items := make([]*models.TransferBatchItem, 0, len(rows))
+	var totalCents int64
+
+	for i, req := range rows {
+		row := i + 1
+
+		if err := utils.ValidateAmount(req.Amount); err != nil {
+			reject(row, err)
+			continue
+		}
+		if req.ToAccountNumber == "" {
+			reject(row, &utils.ValidationError{Field: "to_account_number", Message: "to_account_number is required"})
+			continue
+		}
+		recipient, err := findByAccountNumber(ctx, s.accountRepo, s.transactions.iban, req.ToAccountNumber, "to_account_number")
+		if errors.Is(err, ErrInternal) {
+			return nil, 0, err
+		}
+		if err != nil {
+			reject(row, err)
+			continue
+		}
+		req.ToAccountID = recipient.ID
+		if req.ToAccountID == accountID {
+			reject(row, Validation("same_account", "cannot transfer to your own account"))
+			continue
+		}
+
+		recipientErr, seen := recipients[req.ToAccountID]
+		if !seen {
+			recipientErr = s.checkRecipient(ctx, req.ToAccountID)
+			recipients[req.ToAccountID] = recipientErr
+		}
+		if recipientErr != nil {
+			var domainErr *Error
+			if !errors.As(recipientErr, &domainErr) || errors.Is(recipientErr, ErrInternal) {
+				return nil, 0, recipientErr
+			}
+			reject(row, recipientErr)
+			continue
+		}
+
+		totalCents += int64(math.Round(req.Amount * 100))
+		items = append(items, &models.TransferBatchItem{
+			Row:         row,
+			ToAccountID: req.ToAccountID,
+			Amount:      req.Amount,
+			Description: utils.SanitizeString(req.Description),
+		})
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, 0, &RowErrors{Rows: rowErrors}
+	}
+	return items, totalCents, nil
+}
+
+func (s *BatchService) checkRecipient(ctx context.Context, accountID int) error {
+	recipient, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return notFoundOrInternal(err, "recipient_not_found", "recipient account not found")
+	}
+	if recipient.Status != models.AccountStatusActice {
+		return Forbidden("recipient_inactive", fmt.Sprintf("recipient account is %s", recipient.Status))
+	}
+	return nil
+}
+
+func (s *BatchService) process(ctx context.Context, batch *models.TransferBatch) {
+	ctx, span := tracing.Start(ctx, "BatchService.process")
+	defer span.End()
+	span.SetAttribute("batch.id", batch.ID)
+	span.SetAttribute("batch.mode", batch.Mode)
+
+	if err := s.batchRepo.UpdateStatus(ctx, batch.ID, models.BatchStatusProcessing, 0, 0, nil); err != nil {
+		span.RecordError(err)
+		log.Printf("batch %d: failed to mark processing: %v", batch.ID, err)
+	}
+
+	var succeeded int
+	if batch.Mode == models.BatchModeAtomic {
+		succeeded = s.runAtomic(ctx, batch)
+	} else {
+		succeeded = s.runBestEffort(ctx, batch)
+	}
+	failed := len(batch.Items) - succeeded
+
+	status := models.BatchStatusPartiallyCompleted
+	switch {
+	case failed == 0:
+		status = models.BatchStatusCompleted
+	case succeeded == 0:
+		status = models.BatchStatusFailed
+	}
+
+	completedAt := time.Now()
+	if err := s.batchRepo.UpdateStatus(ctx, batch.ID, status, succeeded, failed, &completedAt); err != nil {
+		span.RecordError(err)
+		log.Printf("batch %d: failed to record status %s: %v", batch.ID, status, err)
+	}
+}
+
+// runAtomic executes every row in one database transaction. If any row
+// fails the whole batch rolls back and every row is reported as failed.
+func (s *BatchService) runAtomic(ctx context.Context, batch *models.TransferBatch) int {
+	var failedItem *models.TransferBatchItem
+
+	txCtx, runAfterCommit := withAfterCommit(ctx)
+	err := s.db.WithTransaction(txCtx, func(ctx context.Context) error {
+		// Lock every account up front, in ID order like Transfer does, so two
+		// batches touching the same accounts cannot deadlock
+		for _, id := range batchAccountIDs(batch) {
+			if _, err := s.accountRepo.GetBalanceForUpdate(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range batch.Items {
+			if err := s.transfer(ctx, batch.AccountID, item); err != nil {
+				failedItem = item
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		runAfterCommit()
+		return len(batch.Items)
+	}
+
+	code, message := errorDetails(err)
+	for _, item := range batch.Items {
+		item.Status = models.BatchItemStatusFailed
+		item.TransactionID = nil
+		item.ErrorCode, item.ErrorMessage = "batch_rolled_back", "not executed because another row failed"
+		if item == failedItem || failedItem == nil {
+			item.ErrorCode, item.ErrorMessage = code, message
+		}
+		s.saveItem(ctx, item)
+	}
+	return 0
+}
+
+// runBestEffort executes each row in its own transaction
+func (s *BatchService) runBestEffort(ctx context.Context, batch *models.TransferBatch) int {
+	succeeded := 0
+	for _, item := range batch.Items {
+		if err := s.transfer(ctx, batch.AccountID, item); err != nil {
+			item.Status = models.BatchItemStatusFailed
+			item.ErrorCode, item.ErrorMessage = errorDetails(err)
+			s.saveItem(ctx, item)
+			continue
+		}
+		succeeded++
+	}
+	return succeeded
+}
+
+// transfer moves the money for one row and records the item as completed.
+// Inside runAtomic both writes join the batch's transaction. Notifications
+// and alerts wait until the transaction holding the row commits.
+func (s *BatchService) transfer(ctx context.Context, fromAccountID int, item *models.TransferBatchItem) error {
+	txCtx, runAfterCommit := withAfterCommit(ctx)
+	err := s.db.WithTransaction(txCtx, func(ctx context.Context) error {
+		transaction, err := s.transactions.Transfer(ctx, fromAccountID, &models.TransferRequest{
+			ToAccountID: item.ToAccountID,
+			Amount:      item.Amount,
+			Description: item.Description,
+		})
+		if err != nil {
+			return err
+		}
+
+		item.Status = models.BatchItemStatusCompleted
+		item.TransactionID = &transaction.ID
+		return s.batchRepo.UpdateItem(ctx, item)
+	})
+	if err != nil {
+		return err
+	}
+	runAfterCommit()
+	return nil
+}
+
+func (s *BatchService) saveItem(ctx context.Context, item *models.TransferBatchItem) {
+	if err := s.batchRepo.UpdateItem(ctx, item); err != nil {
+		log.Printf("batch %d: failed to record row %d: %v", item.BatchID, item.Row, err)
+	}
+}
+
+// batchAccountIDs returns the sender and all recipients, sorted and unique
+func batchAccountIDs(batch *models.TransferBatch) []int {
+	seen := map[int]bool{batch.AccountID: true}
+	ids := []int{batch.AccountID}
+	for _, item := range batch.Items {
+		if !seen[item.ToAccountID] {
+			seen[item.ToAccountID] = true
+			ids = append(ids, item.ToAccountID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// errorDetails gives the client-safe code and message for a failed row
+func errorDetails(err error) (code, message string) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) && !errors.Is(err, ErrInternal) {
+		return domainErr.Code, domainErr.Message
+	}
+	var validationErr *utils.ValidationError
+	if errors.As(err, &validationErr) {
+		return "validation_failed", validationErr.Error()
+	}
+	return "internal_error", "transfer failed"
+}
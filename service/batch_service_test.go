@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+)
+
+func newTestBatchService(t *testing.T) (*BatchService, *TransactionService, *memory.Store) {
+	t.Helper()
+	svc, store := newTestTransactionService(t)
+	return NewBatchService(store, store.Accounts(), store.Batches(), svc), svc, store
+}
+
+func TestBatchSubmitAtomic(t *testing.T) {
+	batches, svc, store := newTestBatchService(t)
+	ctx := context.Background()
+	payer := createFundedAccount(t, store, svc, "payroll@example.com", 100)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+	carol := createFundedAccount(t, store, svc, "carol@example.com", 0)
+
+	batch, err := batches.Submit(ctx, payer.ID, models.BatchModeAtomic, []models.TransferRequest{
+		{ToAccountNumber: bob.AccountNumber, Amount: 30, Description: "March salary"},
+		{ToAccountNumber: carol.AccountNumber, Amount: 45.5, Description: "March salary"},
+	})
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if batch.Status != models.BatchStatusPending || batch.TotalAmount != 75.5 {
+		t.Errorf("unexpected batch: %+v", batch)
+	}
+	batches.Wait()
+
+	done, err := batches.Get(ctx, payer.ID, batch.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if done.Status != models.BatchStatusCompleted || done.SucceededCount != 2 || done.CompletedAt == nil {
+		t.Errorf("unexpected batch after processing: %+v", done)
+	}
+	for _, item := range done.Items {
+		if item.Status != models.BatchItemStatusCompleted || item.TransactionID == nil {
+			t.Errorf("unexpected item: %+v", item)
+		}
+	}
+	notifier := svc.notifier.(*notifications.MemoryNotifier)
+	for _, recipient := range []*models.Account{bob, carol} {
+		if got := countEvents(notifier, recipient.ID, notifications.EventTransferReceived); got != 1 {
+			t.Errorf("expected account %d to be told of its transfer once the batch committed, got %d", recipient.ID, got)
+		}
+	}
+	if got := balanceOf(t, svc, payer.ID); got != 24.5 {
+		t.Errorf("expected payer balance 24.50, got %.2f", got)
+	}
+
+	if _, err := batches.Get(ctx, bob.ID, batch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other accounts should not see the batch, got %v", err)
+	}
+}
+
+func TestBatchSubmitRejectsInvalidRows(t *testing.T) {
+	batches, svc, store := newTestBatchService(t)
+	ctx := context.Background()
+	payer := createFundedAccount(t, store, svc, "payroll@example.com", 100)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+
+	_, err := batches.Submit(ctx, payer.ID, models.BatchModeBestEffort, []models.TransferRequest{
+		{ToAccountNumber: bob.AccountNumber, Amount: 10},
+		{ToAccountNumber: bob.AccountNumber, Amount: -1},
+		{ToAccountNumber: payer.AccountNumber, Amount: 5},
+		{ToAccountNumber: "0000000000", Amount: 5},
+	})
+
+	var rowErrors *RowErrors
+	if !errors.As(err, &rowErrors) {
+		t.Fatalf("expected row errors, got %v", err)
+	}
+	var rows []int
+	for _, rowErr := range rowErrors.Rows {
+		rows = append(rows, rowErr.Row)
+	}
+	if len(rows) != 3 || rows[0] != 2 || rows[1] != 3 || rows[2] != 4 {
+		t.Errorf("expected rows 2, 3 and 4 rejected, got %+v", rowErrors.Rows)
+	}
+	if got := balanceOf(t, svc, payer.ID); got != 100 {
+		t.Errorf("no money should move, balance is %.2f", got)
+	}
+}
+
+func TestBatchSubmitChecksTotalDebit(t *testing.T) {
+	batches, svc, store := newTestBatchService(t)
+	payer := createFundedAccount(t, store, svc, "payroll@example.com", 50)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+
+	_, err := batches.Submit(context.Background(), payer.ID, models.BatchModeBestEffort, []models.TransferRequest{
+		{ToAccountNumber: bob.AccountNumber, Amount: 30},
+		{ToAccountNumber: bob.AccountNumber, Amount: 30},
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+}
+
+func TestBatchSubmitCountsFees(t *testing.T) {
+	batches, svc, store := newTestBatchService(t)
+	fees := NewFeeService(store, store.Accounts(), store.Products(), store.Fees(), store.Transactions())
+	payer := createFundedAccount(t, store, svc, "payroll@example.com", 60)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: models.TransactionTypeTransfer, Kind: models.FeeKindFlat, FlatAmount: "1"})
+
+	// 59 in transfers fits the balance, but not with 2.00 in fees
+	_, err := batches.Submit(context.Background(), payer.ID, models.BatchModeBestEffort, []models.TransferRequest{
+		{ToAccountNumber: bob.AccountNumber, Amount: 30},
+		{ToAccountNumber: bob.AccountNumber, Amount: 29},
+	})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	if got := balanceOf(t, svc, payer.ID); got != 60 {
+		t.Errorf("no money should move, balance is %.2f", got)
+	}
+}
+
+func TestBatchProcessingWhenARowFails(t *testing.T) {
+	tests := []struct {
+		mode          string
+		wantStatus    string
+		wantSucceeded int
+		wantBalance   float64
+		// A rolled back row must not tell its recipient about the money
+		wantReceived int
+	}{
+		{models.BatchModeAtomic, models.BatchStatusFailed, 0, 100, 0},
+		{models.BatchModeBestEffort, models.BatchStatusPartiallyCompleted, 1, 90, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			batches, svc, store := newTestBatchService(t)
+			ctx := context.Background()
+			payer := createFundedAccount(t, store, svc, "payroll@example.com", 100)
+			bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+			carol := createFundedAccount(t, store, svc, "carol@example.com", 0)
+
+			batch, err := store.Batches().Create(ctx, payer.ID, tt.mode, 30, []*models.TransferBatchItem{
+				{Row: 1, ToAccountID: bob.ID, Amount: 10},
+				{Row: 2, ToAccountID: carol.ID, Amount: 20},
+			})
+			if err != nil {
+				t.Fatalf("failed to create batch: %v", err)
+			}
+			// The recipient closes between validation and execution
+			if err := store.Accounts().Close(ctx, carol.ID); err != nil {
+				t.Fatalf("failed to close account: %v", err)
+			}
+
+			batches.process(ctx, batch)
+
+			done, err := batches.Get(ctx, payer.ID, batch.ID)
+			if err != nil {
+				t.Fatalf("get failed: %v", err)
+			}
+			if done.Status != tt.wantStatus || done.SucceededCount != tt.wantSucceeded {
+				t.Errorf("status = %s with %d succeeded, want %s with %d", done.Status, done.SucceededCount, tt.wantStatus, tt.wantSucceeded)
+			}
+			if failed := done.Items[1]; failed.Status != models.BatchItemStatusFailed || failed.ErrorCode != "recipient_inactive" {
+				t.Errorf("unexpected failed item: %+v", failed)
+			}
+			if got := balanceOf(t, svc, payer.ID); got != tt.wantBalance {
+				t.Errorf("expected payer balance %.2f, got %.2f", tt.wantBalance, got)
+			}
+			notifier := svc.notifier.(*notifications.MemoryNotifier)
+			if got := countEvents(notifier, bob.ID, notifications.EventTransferReceived); got != tt.wantReceived {
+				t.Errorf("expected %d transfer received notifications, got %d", tt.wantReceived, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// maxCategoryRules caps the category rules one account can have
+	maxCategoryRules = 50
+	// maxRulePatternLength matches category_rules.pattern
+	maxRulePatternLength = 100
+	// categorizeBatchSize is how many transactions Categorize loads at a time
+	categorizeBatchSize = 500
+)
+
+// CategoryService files an account's transactions under spending
+// categories. A category the holder set wins; otherwise the first matching
+// rule, then a default from the transaction type. Categories are worked out
+// lazily, by Categorize, before they are reported.
+type CategoryService struct {
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	transactionRepo repository.TransactionStore
+	categoryRepo    repository.CategoryStore
+	iban            utils.IBANFormat
+}
+
+func NewCategoryService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	transactionRepo repository.TransactionStore,
+	categoryRepo repository.CategoryStore,
+	iban utils.IBANFormat,
+) *CategoryService {
+	return &CategoryService{
+		db:              database,
+		accountRepo:     accountRepo,
+		transactionRepo: transactionRepo,
+		categoryRepo:    categoryRepo,
+		iban:            iban,
+	}
+}
+
+func (s *CategoryService) ListRules(ctx context.Context, accountID int) ([]*models.CategoryRule, error) {
+	rules, err := s.categoryRepo.ListRules(ctx, accountID)
+	if err != nil {
+		return nil, Internal("failed to list category rules", err)
+	}
+	return rules, nil
+}
+
+// CreateRule adds a rule. Transactions already filed by rule or by default
+// are worked out again, so the new rule applies to past transactions too.
+func (s *CategoryService) CreateRule(ctx context.Context, accountID int, req *models.CreateCategoryRuleRequest) (*models.CategoryRule, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.CreateRule")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateCategory(req.Category); err != nil {
+		return nil, err
+	}
+	pattern := strings.TrimSpace(req.Pattern)
+	if len(pattern) > maxRulePatternLength {
+		return nil, &utils.ValidationError{Field: "pattern", Message: "pattern must be at most 100 characters"}
+	}
+	if pattern == "" && req.CounterpartyAccountNumber == "" {
+		return nil, &utils.ValidationError{Message: "a rule needs a pattern, a counterparty_account_number, or both"}
+	}
+
+	rule := &models.CategoryRule{AccountID: accountID, Category: req.Category, Pattern: pattern}
+	if req.CounterpartyAccountNumber != "" {
+		number, err := utils.ParseAccountNumber(req.CounterpartyAccountNumber, s.iban)
+		if err != nil {
+			return nil, err
+		}
+		counterparty, err := s.accountRepo.GetByAccountNumber(ctx, number)
+		if err != nil {
+			return nil, notFoundOrInternal(err, "account_not_found", "counterparty account not found")
+		}
+		if counterparty.ID == accountID {
+			return nil, &utils.ValidationError{Field: "counterparty_account_number", Message: "counterparty cannot be your own account"}
+		}
+		rule.CounterpartyAccountID = &counterparty.ID
+	}
+
+	existing, err := s.categoryRepo.ListRules(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to create category rule", err)
+	}
+	if len(existing) >= maxCategoryRules {
+		return nil, Conflict("too_many_category_rules", "an account can have at most 50 category rules")
+	}
+
+	var created *models.CategoryRule
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.categoryRepo.CreateRule(ctx, rule); err != nil {
+			return err
+		}
+		return s.categoryRepo.ClearAssigned(ctx, accountID)
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to create category rule", err)
+	}
+	created.CounterpartyAccountNumber = req.CounterpartyAccountNumber
+	return created, nil
+}
+
+// DeleteRule removes a rule and works out again what it had filed
+func (s *CategoryService) DeleteRule(ctx context.Context, accountID, ruleID int) error {
+	ctx, span := tracing.Start(ctx, "CategoryService.DeleteRule")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.categoryRepo.DeleteRule(ctx, accountID, ruleID); err != nil {
+			return err
+		}
+		return s.categoryRepo.ClearAssigned(ctx, accountID)
+	})
+	if err != nil {
+		return notFoundOrInternal(err, "category_rule_not_found", "category rule not found")
+	}
+	return nil
+}
+
+// SetCategory files one of the account's transactions under category,
+// overriding rules and defaults. Each side of a transfer sets its own.
+func (s *CategoryService) SetCategory(ctx context.Context, accountID, transactionID int, category string) (*models.TransactionCategory, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.SetCategory")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+	span.SetAttribute("transaction.id", transactionID)
+
+	if err := utils.ValidateCategory(category); err != nil {
+		return nil, err
+	}
+	if err := s.ownTransaction(ctx, accountID, transactionID); err != nil {
+		return nil, err
+	}
+
+	assigned := &models.TransactionCategory{
+		AccountID:     accountID,
+		TransactionID: transactionID,
+		Category:      category,
+		Source:        models.CategorySourceUser,
+	}
+	if err := s.categoryRepo.Assign(ctx, assigned); err != nil {
+		return nil, wrapInternal("failed to set category", err)
+	}
+	return assigned, nil
+}
+
+// ClearCategory drops the holder's category for a transaction, so it is
+// filed by rule or default again
+func (s *CategoryService) ClearCategory(ctx context.Context, accountID, transactionID int) error {
+	ctx, span := tracing.Start(ctx, "CategoryService.ClearCategory")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+	span.SetAttribute("transaction.id", transactionID)
+
+	if err := s.ownTransaction(ctx, accountID, transactionID); err != nil {
+		return err
+	}
+	if err := s.categoryRepo.DeleteAssignment(ctx, accountID, transactionID); err != nil {
+		return wrapInternal("failed to clear category", err)
+	}
+	return nil
+}
+
+// ownTransaction checks that the transaction involves the account and can
+// be categorized. Someone else's transaction is reported as missing so IDs
+// can't be probed.
+func (s *CategoryService) ownTransaction(ctx context.Context, accountID, transactionID int) error {
+	transaction, err := s.transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return notFoundOrInternal(err, "transaction_not_found", "transaction not found")
+	}
+	if !equalAccount(transaction.FromAccountID, accountID) && !equalAccount(transaction.ToAccountID, accountID) {
+		return NotFound("transaction_not_found", "transaction not found")
+	}
+	if transaction.PotID != nil {
+		return Conflict("pot_move_uncategorized", "moves to and from pots are not categorized")
+	}
+	return nil
+}
+
+// Categorize files every completed transaction of the account that has no
+// category yet
+func (s *CategoryService) Categorize(ctx context.Context, accountID int) error {
+	ctx, span := tracing.Start(ctx, "CategoryService.Categorize")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	rules, err := s.categoryRepo.ListRules(ctx, accountID)
+	if err != nil {
+		return wrapInternal("failed to categorize transactions", err)
+	}
+
+	categorized := 0
+	for {
+		batch, err := s.categoryRepo.ListUncategorized(ctx, accountID, categorizeBatchSize)
+		if err != nil {
+			return wrapInternal("failed to categorize transactions", err)
+		}
+		for _, transaction := range batch {
+			if err := s.categoryRepo.Assign(ctx, categorize(transaction, accountID, rules)); err != nil {
+				return wrapInternal("failed to categorize transactions", err)
+			}
+		}
+		categorized += len(batch)
+		if len(batch) < categorizeBatchSize {
+			break
+		}
+	}
+	span.SetAttribute("transactions.categorized", categorized)
+	return nil
+}
+
+// categorize picks the category for one transaction of the account: the
+// first rule that matches, or the default for its type
+func categorize(transaction *models.Transaction, accountID int, rules []*models.CategoryRule) *models.TransactionCategory {
+	counterparty := transaction.ToAccountID
+	if equalAccount(transaction.ToAccountID, accountID) {
+		counterparty = transaction.FromAccountID
+	}
+	description := strings.ToLower(transaction.Description)
+
+	for _, rule := range rules {
+		if rule.Pattern != "" && !strings.Contains(description, strings.ToLower(rule.Pattern)) {
+			continue
+		}
+		if rule.CounterpartyAccountID != nil && (counterparty == nil || *counterparty != *rule.CounterpartyAccountID) {
+			continue
+		}
+		ruleID := rule.ID
+		return &models.TransactionCategory{
+			AccountID:     accountID,
+			TransactionID: transaction.ID,
+			Category:      rule.Category,
+			Source:        models.CategorySourceRule,
+			RuleID:        &ruleID,
+		}
+	}
+
+	return &models.TransactionCategory{
+		AccountID:     accountID,
+		TransactionID: transaction.ID,
+		Category:      defaultCategory(transaction, accountID),
+		Source:        models.CategorySourceDefault,
+	}
+}
+
+// defaultCategory files a transaction by its type
+func defaultCategory(transaction *models.Transaction, accountID int) string {
+	switch transaction.Type {
+	case models.TransactionTypeDeposit:
+		return models.CategoryIncome
+	case models.TransactionTypeWithdraw:
+		return models.CategoryCash
+	case models.TransactionTypeFee:
+		return models.CategoryFees
+	case models.TransactionTypeInterest:
+		// Interest paid to the account is income; overdraft interest is a fee
+		if equalAccount(transaction.ToAccountID, accountID) {
+			return models.CategoryIncome
+		}
+		return models.CategoryFees
+	case models.TransactionTypeTransfer:
+		return models.CategoryTransfers
+	}
+	return models.CategoryGeneral
+}
+
+func equalAccount(id *int, accountID int) bool {
+	return id != nil && *id == accountID
+}
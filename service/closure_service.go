@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxAnonymizeBatch bounds the accounts anonymized by one retention run
+const maxAnonymizeBatch = 100
+
+// ClosureService closes accounts at their holder's request and anonymizes
+// them once their retention period is over. Closing empties the pots, pays
+// out what is left, resolves pending payment requests and signs the holder
+// out everywhere, all in one database transaction.
+type ClosureService struct {
+	db                 repository.TxRunner
+	accountRepo        repository.AccountStore
+	transactionRepo    repository.TransactionStore
+	potRepo            repository.PotStore
+	paymentRequestRepo repository.PaymentRequestStore
+	payeeRepo          repository.PayeeStore
+	sessionRepo        repository.SessionStore
+	kycRepo            repository.KYCStore
+	annotationRepo     repository.AnnotationStore
+	notificationRepo   repository.NotificationStore
+	closureRepo        repository.ClosureStore
+	interest           *InterestService
+	storage            storage.Storage
+	iban               utils.IBANFormat
+	notifier           notifications.Notifier
+	// retention is how long a closed account's personal data is kept
+	retention time.Duration
+}
+
+func NewClosureService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	transactionRepo repository.TransactionStore,
+	potRepo repository.PotStore,
+	paymentRequestRepo repository.PaymentRequestStore,
+	payeeRepo repository.PayeeStore,
+	sessionRepo repository.SessionStore,
+	kycRepo repository.KYCStore,
+	annotationRepo repository.AnnotationStore,
+	notificationRepo repository.NotificationStore,
+	closureRepo repository.ClosureStore,
+	interest *InterestService,
+	storage storage.Storage,
+	iban utils.IBANFormat,
+	notifier notifications.Notifier,
+	retention time.Duration,
+) *ClosureService {
+	return &ClosureService{
+		db:                 database,
+		accountRepo:        accountRepo,
+		transactionRepo:    transactionRepo,
+		potRepo:            potRepo,
+		paymentRequestRepo: paymentRequestRepo,
+		payeeRepo:          payeeRepo,
+		sessionRepo:        sessionRepo,
+		kycRepo:            kycRepo,
+		annotationRepo:     annotationRepo,
+		notificationRepo:   notificationRepo,
+		closureRepo:        closureRepo,
+		interest:           interest,
+		storage:            storage,
+		iban:               iban,
+		notifier:           notifier,
+		retention:          retention,
+	}
+}
+
+// Close closes the holder's account. The password is checked again.
+// Outstanding interest is posted and pots are emptied into the balance
+// first; an overdrawn account can't close, and a positive balance needs a
+// payout account to go to.
+func (s *ClosureService) Close(ctx context.Context, accountID int, req *models.CloseAccountRequest) (*models.AccountClosureResponse, error) {
+	ctx, span := tracing.Start(ctx, "ClosureService.Close")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateRequired(req.Password, "password"); err != nil {
+		return nil, err
+	}
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if !canSignIn(account.Status) {
+		return nil, accountInactive(account.Status)
+	}
+	withHash, err := s.accountRepo.GeyByEmail(ctx, account.Email)
+	if err != nil {
+		return nil, wrapInternal("failed to close account", err)
+	}
+	if err := utils.CheckPassword(req.Password, withHash.PasswordHash); err != nil {
+		return nil, Unauthorized("invalid_credentials", "password is incorrect")
+	}
+
+	var payout *models.Account
+	if strings.TrimSpace(req.PayoutAccountNumber) != "" {
+		if payout, err = s.payoutAccount(ctx, accountID, req.PayoutAccountNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	var closure *models.AccountClosure
+	var payoutTransaction *models.Transaction
+	var resolved []*models.PaymentRequest
+	potsClosed := 0
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, err := s.lock(ctx, accountID, payout)
+		if err != nil {
+			return err
+		}
+		current, err := s.accountRepo.GetByID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if !canSignIn(current.Status) {
+			return accountInactive(current.Status)
+		}
+
+		// Nothing capitalizes interest on a closed account, so what has
+		// accrued is paid or charged now
+		if err := s.interest.settleAccount(ctx, accountID, now); err != nil {
+			return err
+		}
+		if balance, err = s.accountRepo.GetBalanceForUpdate(ctx, accountID); err != nil {
+			return err
+		}
+
+		pots, err := s.potRepo.ListByAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		for _, pot := range pots {
+			if pot.Balance > 0 {
+				if _, err := moveFromPot(ctx, s.accountRepo, s.potRepo, s.transactionRepo, accountID, balance, pot, pot.Balance); err != nil {
+					return err
+				}
+				balance = sumAmounts(balance, pot.Balance)
+			}
+			if err := s.potRepo.Close(ctx, pot.ID); err != nil {
+				return err
+			}
+		}
+		potsClosed = len(pots)
+
+		switch {
+		case balance < 0:
+			return Conflict("account_overdrawn", fmt.Sprintf("repay the overdrawn balance of %.2f before closing", -balance))
+		case balance > 0 && payout == nil:
+			return Validation("payout_account_required", fmt.Sprintf("give a payout_account_number for the remaining balance of %.2f", balance))
+		case balance > 0:
+			if payoutTransaction, err = s.payOut(ctx, accountID, balance, payout); err != nil {
+				return err
+			}
+		}
+
+		if resolved, err = s.resolvePaymentRequests(ctx, accountID, now); err != nil {
+			return err
+		}
+		// ErrNotFound only means no sessions were left to revoke
+		if err := s.sessionRepo.DeleteAccountByID(ctx, accountID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err := s.accountRepo.Close(ctx, accountID); err != nil {
+			return err
+		}
+
+		closed := &models.AccountClosure{AccountID: accountID, ClosedAt: now, RetainUntil: now.Add(s.retention)}
+		if payoutTransaction != nil {
+			closed.PayoutAccountID = &payout.ID
+			closed.PayoutTransactionID = &payoutTransaction.ID
+		}
+		closure, err = s.closureRepo.Create(ctx, closed)
+		return err
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to close account", err)
+	}
+
+	response := &models.AccountClosureResponse{
+		ClosedAt:                 closure.ClosedAt,
+		RetainUntil:              closure.RetainUntil,
+		PotsClosed:               potsClosed,
+		PaymentRequestsCancelled: len(resolved),
+	}
+	paidOut := 0.0
+	if payoutTransaction != nil {
+		paidOut = payoutTransaction.Amount
+		response.Payout = payoutTransaction.ToResponse()
+		setAccountNumbers(map[int]string{account.ID: account.AccountNumber, payout.ID: payout.AccountNumber}, response.Payout)
+		sendNotification(ctx, s.notifier, payout.ID, notifications.EventTransferReceived, map[string]any{
+			"transaction_id": payoutTransaction.ID,
+			"amount":         payoutTransaction.Amount,
+			"from":           utils.MaskName(account.FirstName, account.LastName),
+		})
+	}
+	for _, request := range resolved {
+		if request.Status == models.PaymentRequestCancelled {
+			notifyPaymentRequest(ctx, s.notifier, request.PayerAccountID, notifications.EventPaymentRequestCancelled, request)
+		} else {
+			notifyPaymentRequest(ctx, s.notifier, request.RequesterAccountID, notifications.EventPaymentRequestDeclined, request)
+		}
+	}
+	sendNotification(ctx, s.notifier, accountID, notifications.EventAccountClosed, map[string]any{
+		"amount": paidOut,
+		"until":  closure.RetainUntil.UTC().Format("2 Jan 2006"),
+	})
+	return response, nil
+}
+
+// AnonymizeDue anonymizes closed accounts whose retention period ended at or
+// before now. It returns how many accounts were anonymized.
+func (s *ClosureService) AnonymizeDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "ClosureService.AnonymizeDue")
+	defer span.End()
+
+	closures, err := s.closureRepo.ListDue(ctx, now, maxAnonymizeBatch)
+	if err != nil {
+		return 0, wrapInternal("failed to list closed accounts", err)
+	}
+
+	anonymized := 0
+	for _, closure := range closures {
+		err := s.anonymize(ctx, closure.AccountID, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			// Erased on request since it was listed
+			continue
+		}
+		if err != nil {
+			return anonymized, wrapInternal("failed to anonymize account", err)
+		}
+		anonymized++
+	}
+	span.SetAttribute("accounts.anonymized", anonymized)
+	return anonymized, nil
+}
+
+// Erase anonymizes a closed account now, on its holder's erasure request,
+// without waiting for the retention period to end
+func (s *ClosureService) Erase(ctx context.Context, accountID int) (*models.AccountClosure, error) {
+	ctx, span := tracing.Start(ctx, "ClosureService.Erase")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	closure, err := s.closureRepo.GetByAccount(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, Conflict("account_not_closed", "only closed accounts can be erased")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to erase account", err)
+	}
+	if closure.AnonymizedAt != nil {
+		return nil, Conflict("already_erased", "account has already been erased")
+	}
+
+	now := time.Now()
+	if err := s.anonymize(ctx, accountID, now); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Anonymized by the retention job since it was read
+			return nil, Conflict("already_erased", "account has already been erased")
+		}
+		return nil, wrapInternal("failed to erase account", err)
+	}
+	closure.AnonymizedAt = &now
+	return closure, nil
+}
+
+// anonymize pseudonymizes a closed account in one transaction: its email,
+// names and password, the name other holders saved it under as a payee, its
+// notification settings and history, its KYC details and documents, and
+// its notes, tags and attachments on transactions.
+// Transactions and the closure record are kept so the ledger stays whole.
+func (s *ClosureService) anonymize(ctx context.Context, accountID int, now time.Time) error {
+	var keys []string
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		// Marking first makes a concurrent run fail here rather than
+		// anonymize the account twice
+		if err := s.closureRepo.MarkAnonymized(ctx, accountID, now); err != nil {
+			return err
+		}
+		if err := s.accountRepo.Anonymize(ctx, accountID); err != nil {
+			return err
+		}
+		if err := s.payeeRepo.ClearVerifiedNames(ctx, accountID); err != nil {
+			return err
+		}
+		if err := s.notificationRepo.DeleteByAccount(ctx, accountID); err != nil {
+			return err
+		}
+		documentKeys, err := s.kycRepo.DeleteByAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		attachmentKeys, err := s.annotationRepo.DeleteByAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		keys = append(documentKeys, attachmentKeys...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The records are gone, so a file left behind here is unreachable; it
+	// is logged for cleanup rather than failing the run
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete file %s of anonymized account %d: %v", key, accountID, err)
+		}
+	}
+	return nil
+}
+
+// payoutAccount resolves the account a closing balance is paid to
+func (s *ClosureService) payoutAccount(ctx context.Context, accountID int, accountNumber string) (*models.Account, error) {
+	number, err := utils.ParseAccountNumber(accountNumber, s.iban)
+	if err != nil {
+		return nil, err
+	}
+	payout, err := s.accountRepo.GetByAccountNumber(ctx, number)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "payout_account_not_found", "payout account not found")
+	}
+	if payout.ID == accountID {
+		return nil, Validation("same_account", "cannot pay out to the account being closed")
+	}
+	if payout.Status != models.AccountStatusActice {
+		return nil, Forbidden("payout_account_inactive", fmt.Sprintf("payout account is %s", payout.Status))
+	}
+	return payout, nil
+}
+
+// lock takes the row locks for a closure, in account ID order when there is
+// a payout account, and returns the closing account's balance
+func (s *ClosureService) lock(ctx context.Context, accountID int, payout *models.Account) (float64, error) {
+	if payout == nil {
+		return s.accountRepo.GetBalanceForUpdate(ctx, accountID)
+	}
+	first, second := accountID, payout.ID
+	if first > second {
+		first, second = second, first
+	}
+	firstBalance, err := s.accountRepo.GetBalanceForUpdate(ctx, first)
+	if err != nil {
+		return 0, err
+	}
+	secondBalance, err := s.accountRepo.GetBalanceForUpdate(ctx, second)
+	if err != nil {
+		return 0, err
+	}
+	if first == accountID {
+		return firstBalance, nil
+	}
+	return secondBalance, nil
+}
+
+// payOut transfers the closing balance to the payout account, which the
+// caller has locked and checked is active. The account's KYC limits still
+// apply.
+func (s *ClosureService) payOut(ctx context.Context, accountID int, amount float64, payout *models.Account) (*models.Transaction, error) {
+	if err := checkKYCLimits(ctx, s.kycRepo, s.transactionRepo, accountID, amount); err != nil {
+		return nil, err
+	}
+	current, err := s.accountRepo.GetByID(ctx, payout.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != models.AccountStatusActice {
+		return nil, Forbidden("payout_account_inactive", fmt.Sprintf("payout account is %s", current.Status))
+	}
+	if err := s.accountRepo.UpdateBalance(ctx, accountID, 0); err != nil {
+		return nil, err
+	}
+	if err := s.accountRepo.UpdateBalance(ctx, payout.ID, sumAmounts(current.Balance, amount)); err != nil {
+		return nil, err
+	}
+	return s.transactionRepo.Create(ctx, &accountID, &payout.ID, amount, models.TransactionTypeTransfer, "Account closure payout")
+}
+
+// resolvePaymentRequests cancels the pending requests the account made and
+// declines those it was asked to pay
+func (s *ClosureService) resolvePaymentRequests(ctx context.Context, accountID int, now time.Time) ([]*models.PaymentRequest, error) {
+	resolved := make([]*models.PaymentRequest, 0)
+	for _, incoming := range []bool{false, true} {
+		status := models.PaymentRequestCancelled
+		if incoming {
+			status = models.PaymentRequestDeclined
+		}
+		requests, err := s.paymentRequestRepo.ListByAccount(ctx, accountID, incoming, models.PaymentRequestPending)
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range requests {
+			err := s.paymentRequestRepo.Resolve(ctx, request.ID, status, nil, now)
+			if errors.Is(err, repository.ErrNotFound) {
+				// Resolved since it was listed
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			request.Status = status
+			request.ResolvedAt = &now
+			resolved = append(resolved, request)
+		}
+	}
+	return resolved, nil
+}
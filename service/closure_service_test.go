@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const testRetention = 30 * 24 * time.Hour
+
+type closureFixture struct {
+	closures     *ClosureService
+	auth         *AuthService
+	transactions *TransactionService
+	interest     *InterestService
+	store        *memory.Store
+	storage      *storage.MemoryStorage
+	notifier     *notifications.MemoryNotifier
+}
+
+func newTestClosureService(t *testing.T) *closureFixture {
+	t.Helper()
+	auth, store := newTestAuthService(t)
+	notifier := &notifications.MemoryNotifier{}
+	documents := &storage.MemoryStorage{}
+	transactions := NewTransactionService(store, store.Accounts(), store.Transactions(), store.Payees(), store.Fees(), store.Pots(), store.Alerts(), store.KYC(), store.Annotations(), utils.IBANFormat{}, notifier)
+	interest := NewInterestService(store, store.Accounts(), store.Products(), store.Interest(), store.Transactions())
+	closures := NewClosureService(store, store.Accounts(), store.Transactions(), store.Pots(), store.PaymentRequests(), store.Payees(), store.Sessions(),
+		store.KYC(), store.Annotations(), store.Notifications(), store.Closures(), interest, documents, utils.IBANFormat{}, notifier, testRetention)
+	return &closureFixture{closures, auth, transactions, interest, store, documents, notifier}
+}
+
+func TestCloseAccount(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	friend := registerTestAccount(t, f.auth, "friend@example.com")
+	if _, err := f.transactions.Deposit(ctx, holder.ID, &models.DepositRequest{Amount: 100}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	pots := NewPotService(f.store, f.store.Accounts(), f.store.Pots(), f.store.Transactions())
+	pot, err := pots.Create(ctx, holder.ID, &models.CreatePotRequest{Name: "Holiday", RoundUp: true})
+	if err != nil {
+		t.Fatalf("failed to create pot: %v", err)
+	}
+	if _, err := pots.Deposit(ctx, holder.ID, pot.ID, &models.PotMoveRequest{Amount: 30}); err != nil {
+		t.Fatalf("pot deposit failed: %v", err)
+	}
+	requests := NewPaymentRequestService(f.store, f.store.Accounts(), f.store.Payees(), f.store.PaymentRequests(), f.transactions, f.notifier)
+	outgoing, err := requests.Create(ctx, holder.ID, &models.CreatePaymentRequestRequest{FromEmail: "friend@example.com", Amount: 5})
+	if err != nil {
+		t.Fatalf("failed to create payment request: %v", err)
+	}
+	incoming, err := requests.Create(ctx, friend.ID, &models.CreatePaymentRequestRequest{FromEmail: "holder@example.com", Amount: 7})
+	if err != nil {
+		t.Fatalf("failed to create payment request: %v", err)
+	}
+	unknown, err := utils.GenerateAccountNumber()
+	if err != nil {
+		t.Fatalf("failed to generate account number: %v", err)
+	}
+	login, err := f.auth.Login(ctx, models.LoginAccountRequest{Email: "holder@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  models.CloseAccountRequest
+		code string
+	}{
+		{"wrong password", models.CloseAccountRequest{Password: "wrong", PayoutAccountNumber: friend.AccountNumber}, "invalid_credentials"},
+		{"balance left", models.CloseAccountRequest{Password: testPassword}, "payout_account_required"},
+		{"paying out to itself", models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: holder.AccountNumber}, "same_account"},
+		{"unknown payout account", models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: unknown}, "payout_account_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.closures.Close(ctx, holder.ID, &tt.req); errorCode(err) != tt.code {
+				t.Errorf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+	// The refused attempts left everything as it was
+	if got := balanceOf(t, f.transactions, holder.ID); got != 70 {
+		t.Fatalf("expected balance 70 after refused closures, got %.2f", got)
+	}
+	if open, _ := f.store.Pots().ListByAccount(ctx, holder.ID); len(open) != 1 || open[0].Balance != 30 {
+		t.Fatalf("expected the pot to stay open with 30, got %+v", open)
+	}
+
+	closure, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: friend.AccountNumber})
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if closure.PotsClosed != 1 || closure.PaymentRequestsCancelled != 2 {
+		t.Errorf("expected one pot closed and two requests resolved, got %+v", closure)
+	}
+	if closure.Payout == nil || closure.Payout.Amount != 100 {
+		t.Errorf("expected the pot and balance to be paid out together, got %+v", closure.Payout)
+	}
+	if got := closure.RetainUntil.Sub(closure.ClosedAt); got != testRetention {
+		t.Errorf("expected data to be retained for %s, got %s", testRetention, got)
+	}
+	if got := balanceOf(t, f.transactions, friend.ID); got != 100 {
+		t.Errorf("expected the payout account to receive 100, got %.2f", got)
+	}
+
+	account, err := f.store.Accounts().GetByID(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if account.Status != models.AccountStatusClosed || account.Balance != 0 {
+		t.Errorf("expected a closed, empty account, got %s with %.2f", account.Status, account.Balance)
+	}
+	for id, want := range map[int]string{outgoing.ID: models.PaymentRequestCancelled, incoming.ID: models.PaymentRequestDeclined} {
+		request, err := f.store.PaymentRequests().GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to get payment request: %v", err)
+		}
+		if request.Status != want {
+			t.Errorf("expected request %d to be %s, got %s", id, want, request.Status)
+		}
+	}
+
+	if _, err := f.auth.ValidateSession(ctx, login.SessionID); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected the session to be revoked, got %v", err)
+	}
+	if _, err := f.auth.Login(ctx, models.LoginAccountRequest{Email: "holder@example.com", Password: testPassword}); errorCode(err) != "account_inactive" {
+		t.Errorf("expected a closed account not to sign in, got %v", err)
+	}
+	if _, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected closing twice to be refused, got %v", err)
+	}
+
+	if countEvents(f.notifier, holder.ID, notifications.EventAccountClosed) != 1 {
+		t.Errorf("expected the holder to be told the account closed, got %v", eventTypes(f.notifier, holder.ID))
+	}
+	for _, want := range []string{notifications.EventTransferReceived, notifications.EventPaymentRequestCancelled, notifications.EventPaymentRequestDeclined} {
+		if countEvents(f.notifier, friend.ID, want) != 1 {
+			t.Errorf("expected the friend to get %s, got %v", want, eventTypes(f.notifier, friend.ID))
+		}
+	}
+}
+
+func TestCloseEmptyAccountNeedsNoPayout(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+
+	closure, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword})
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if closure.Payout != nil {
+		t.Errorf("expected no payout from an empty account, got %+v", closure.Payout)
+	}
+	stored, err := f.store.Closures().GetByAccount(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get closure: %v", err)
+	}
+	if stored.PayoutAccountID != nil || stored.AnonymizedAt != nil {
+		t.Errorf("expected a closure without payout, not yet anonymized, got %+v", stored)
+	}
+}
+
+func TestCloseSettlesInterest(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	friend := registerTestAccount(t, f.auth, "friend@example.com")
+	if _, err := f.transactions.Deposit(ctx, holder.ID, &models.DepositRequest{Amount: 400}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if err := f.interest.ChangeProduct(ctx, holder.ID, models.ProductSavings); err != nil {
+		t.Fatalf("ChangeProduct: %v", err)
+	}
+	// Today's 0.0273972603, not due to be capitalized until next month
+	today := startOfDay(time.Now())
+	if _, err := f.interest.AccrueThrough(ctx, today); err != nil {
+		t.Fatalf("AccrueThrough: %v", err)
+	}
+
+	closure, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: friend.AccountNumber})
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if closure.Payout == nil || closure.Payout.Amount != 400.03 {
+		t.Errorf("expected the payout to include the interest, got %+v", closure.Payout)
+	}
+	if paid, err := f.interest.Capitalize(ctx, today.AddDate(0, 0, 1)); err != nil || paid != 0 {
+		t.Errorf("Capitalize after closing = %d, %v; want 0, nil", paid, err)
+	}
+	account, err := f.store.Accounts().GetByID(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if account.Balance != 0 {
+		t.Errorf("expected the closed account to stay empty, got %.2f", account.Balance)
+	}
+}
+
+func TestCloseOverdrawnAccount(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	friend := registerTestAccount(t, f.auth, "friend@example.com")
+	if err := f.store.Accounts().SetOverdraftLimit(ctx, holder.ID, 50); err != nil {
+		t.Fatalf("failed to set overdraft: %v", err)
+	}
+	if _, err := f.transactions.WithDraw(ctx, holder.ID, &models.WitdrawRequest{Amount: 20}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+
+	_, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: friend.AccountNumber})
+	if !errors.Is(err, ErrConflict) || errorCode(err) != "account_overdrawn" {
+		t.Errorf("expected an overdrawn account not to close, got %v", err)
+	}
+	account, err := f.store.Accounts().GetByID(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if account.Status != models.AccountStatusActice {
+		t.Errorf("expected the account to stay active, got %s", account.Status)
+	}
+}
+
+func TestAnonymizeClosedAccounts(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	kyc := NewKYCService(f.store, f.store.Accounts(), f.store.KYC(), f.storage, f.notifier)
+	submitTestKYC(t, kyc, holder.ID)
+	if len(f.storage.Keys()) != 1 {
+		t.Fatalf("expected one stored document, got %d", len(f.storage.Keys()))
+	}
+	if _, err := f.transactions.Deposit(ctx, holder.ID, &models.DepositRequest{Amount: 10}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if _, err := f.transactions.WithDraw(ctx, holder.ID, &models.WitdrawRequest{Amount: 10}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+
+	closure, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword})
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Nothing is due until the retention period is over
+	if count, err := f.closures.AnonymizeDue(ctx, closure.RetainUntil.Add(-time.Minute)); err != nil || count != 0 {
+		t.Fatalf("expected nothing anonymized within retention, got %d, %v", count, err)
+	}
+	if count, err := f.closures.AnonymizeDue(ctx, closure.RetainUntil); err != nil || count != 1 {
+		t.Fatalf("expected one account anonymized, got %d, %v", count, err)
+	}
+	if count, err := f.closures.AnonymizeDue(ctx, closure.RetainUntil.Add(time.Hour)); err != nil || count != 0 {
+		t.Errorf("expected an account to be anonymized once, got %d, %v", count, err)
+	}
+
+	account, err := f.store.Accounts().GetByID(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if account.Email == "holder@example.com" || account.FirstName != "" || account.LastName != "" {
+		t.Errorf("expected personal data to be removed, got %+v", account)
+	}
+	if _, err := f.store.KYC().GetProfile(ctx, holder.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected the KYC profile to be deleted, got %v", err)
+	}
+	if len(f.storage.Keys()) != 0 {
+		t.Errorf("expected KYC documents to be deleted, got %v", f.storage.Keys())
+	}
+	history, err := f.store.Transactions().GetRecent(ctx, holder.ID, 10)
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected the transaction history to be kept, got %d transactions", len(history))
+	}
+
+	// The address is free for a new account
+	if _, err := f.auth.Register(ctx, &models.CreateAccountRequest{
+		Email: "holder@example.com", FirstName: "Jane", LastName: "Doe", Password: testPassword,
+	}); err != nil {
+		t.Errorf("expected the email to be reusable, got %v", err)
+	}
+	if _, err := f.auth.Login(ctx, models.LoginAccountRequest{Email: "holder@example.com", Password: "wrong"}); errorCode(err) != "invalid_credentials" {
+		t.Errorf("expected a sign in to reach the new account, got %v", err)
+	}
+}
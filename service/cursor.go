@@ -0,0 +1,31 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/wizzyszn/go_bank/models"
+)
+
+// Cursors are opaque to clients: base64url-encoded JSON of the last row's
+// position, so the encoding can change without breaking the API contract
+
+func encodeCursor(cursor models.TransactionCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (*models.TransactionCursor, error) {
+	invalid := Validation("invalid_cursor", "cursor is invalid")
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor models.TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID < 1 || cursor.CreatedAt.IsZero() {
+		return nil, invalid
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+)
+
+// Error kinds. Use errors.Is(err, service.ErrNotFound) etc. to classify an
+// error returned by any service method.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrForbidden         = errors.New("forbidden")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrTooManyRequests   = errors.New("too many requests")
+	ErrValidation        = errors.New("validation failed")
+	ErrInternal          = errors.New("internal error")
+)
+
+// Error is a domain error. Code and Message are safe to show to clients;
+// Cause holds the underlying error (SQL errors etc.) and is never exposed.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Cause)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Kind, e.Cause}
+	}
+	return []error{e.Kind}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func InsufficientFunds(have, need float64) *Error {
+	return &Error{
+		Kind:    ErrInsufficientFunds,
+		Code:    "insufficient_funds",
+		Message: fmt.Sprintf("insufficient funds: have %.2f, need %.2f", have, need),
+	}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func TooManyRequests(code, message string) *Error {
+	return &Error{Kind: ErrTooManyRequests, Code: code, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+// Internal wraps an unexpected failure. The message shown to clients is
+// generic; the cause is kept for logs.
+func Internal(message string, cause error) *Error {
+	return &Error{Kind: ErrInternal, Code: "internal_error", Message: message, Cause: cause}
+}
+
+// accountInactive is returned when an account that must be active is not
+func accountInactive(status string) *Error {
+	if status == models.AccountStatusPendingVerification {
+		return Forbidden("email_unverified", "verify your email address first")
+	}
+	return Forbidden("account_inactive", fmt.Sprintf("account is %s", status))
+}
+
+// notFoundOrInternal turns a store lookup failure into NotFound when the row
+// is missing, and into an internal error for anything else
+func notFoundOrInternal(err error, code, message string) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound(code, message)
+	}
+	return wrapInternal(message, err)
+}
+
+// wrapInternal passes domain errors through untouched and hides anything else
+// (SQL errors, driver errors) behind an internal error
+func wrapInternal(message string, err error) error {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return Internal(message, err)
+}
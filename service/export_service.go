@@ -0,0 +1,206 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxExportEvents bounds the notification history put in an export
+const maxExportEvents = 10000
+
+// ExportService gathers the personal data held about an account holder
+// and packages it for download
+type ExportService struct {
+	accountRepo      repository.AccountStore
+	sessionRepo      repository.SessionStore
+	transactionRepo  repository.TransactionStore
+	kycRepo          repository.KYCStore
+	annotationRepo   repository.AnnotationStore
+	notificationRepo repository.NotificationStore
+	storage          storage.Storage
+	iban             utils.IBANFormat
+}
+
+func NewExportService(
+	accountRepo repository.AccountStore,
+	sessionRepo repository.SessionStore,
+	transactionRepo repository.TransactionStore,
+	kycRepo repository.KYCStore,
+	annotationRepo repository.AnnotationStore,
+	notificationRepo repository.NotificationStore,
+	storage storage.Storage,
+	iban utils.IBANFormat,
+) *ExportService {
+	return &ExportService{
+		accountRepo:      accountRepo,
+		sessionRepo:      sessionRepo,
+		transactionRepo:  transactionRepo,
+		kycRepo:          kycRepo,
+		annotationRepo:   annotationRepo,
+		notificationRepo: notificationRepo,
+		storage:          storage,
+		iban:             iban,
+	}
+}
+
+// Export collects the account's profile, KYC details, notification
+// preferences, sessions, full transaction history and notification history
+func (s *ExportService) Export(ctx context.Context, accountID int) (*models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "ExportService.Export")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	export := &models.DataExport{
+		GeneratedAt: time.Now().UTC(),
+		Account:     account.ToResponse(),
+	}
+	export.Account.IBAN = s.iban.Format(account.AccountNumber)
+
+	profile, err := s.kycRepo.GetProfile(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		profile, err = models.NewKYCProfile(accountID), nil
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to export kyc profile", err)
+	}
+	documents, err := s.kycRepo.ListDocuments(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to export kyc documents", err)
+	}
+	export.KYC = profile.ToResponse(documents)
+
+	prefs, err := s.notificationRepo.GetPreferences(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		prefs, err = models.DefaultNotificationPreferences(accountID), nil
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to export notification preferences", err)
+	}
+	export.NotificationPreferences = prefs
+
+	sessions, err := s.sessionRepo.GetByAccountID(ctx, strconv.Itoa(accountID))
+	if err != nil {
+		return nil, wrapInternal("failed to export sessions", err)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	export.Sessions = make([]*models.ExportedSession, 0, len(sessions))
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, &models.ExportedSession{CreatedAt: session.CreatedAt, ExpiresAt: session.ExpiresAt})
+	}
+
+	export.Transactions = make([]*models.TransactionResponse, 0)
+	err = s.transactionRepo.GetByDateRange(ctx, accountID, time.Time{}, export.GeneratedAt.Add(time.Second), func(transaction *models.Transaction) error {
+		export.Transactions = append(export.Transactions, transaction.ToResponse())
+		return nil
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to export transactions", err)
+	}
+	if err := accountNumbers(ctx, s.accountRepo, export.Transactions...); err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(export.Transactions))
+	byID := make(map[int]*models.TransactionResponse, len(export.Transactions))
+	for i, transaction := range export.Transactions {
+		ids[i] = transaction.ID
+		byID[transaction.ID] = transaction
+	}
+	annotations, err := s.annotationRepo.ListByTransactions(ctx, accountID, ids)
+	if err != nil {
+		return nil, wrapInternal("failed to export annotations", err)
+	}
+	for _, annotation := range annotations {
+		byID[annotation.TransactionID].Annotate(annotation)
+	}
+	if export.Attachments, err = s.annotationRepo.ListAttachments(ctx, accountID, ids); err != nil {
+		return nil, wrapInternal("failed to export attachments", err)
+	}
+
+	if export.Events, err = s.notificationRepo.ListDeliveries(ctx, accountID, maxExportEvents); err != nil {
+		return nil, wrapInternal("failed to export notifications", err)
+	}
+	return export, nil
+}
+
+// WriteArchive writes an export as a ZIP archive: one JSON file per part of
+// the export, the uploaded KYC documents under documents/ and the files
+// attached to transactions under attachments/
+func (s *ExportService) WriteArchive(ctx context.Context, w io.Writer, export *models.DataExport) error {
+	ctx, span := tracing.Start(ctx, "ExportService.WriteArchive")
+	defer span.End()
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"account.json", export.Account},
+		{"kyc.json", export.KYC},
+		{"notification_preferences.json", export.NotificationPreferences},
+		{"sessions.json", export.Sessions},
+		{"transactions.json", export.Transactions},
+		{"attachments.json", export.Attachments},
+		{"events.json", export.Events},
+	}
+	for _, file := range files {
+		out, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	for _, document := range export.KYC.Documents {
+		name := fmt.Sprintf("documents/%d-%s", document.ID, path.Base(document.FileName))
+		if err := s.writeFile(ctx, archive, name, document.StorageKey, export.GeneratedAt); err != nil {
+			return fmt.Errorf("failed to write kyc document %d: %w", document.ID, err)
+		}
+	}
+	for _, attachment := range export.Attachments {
+		name := fmt.Sprintf("attachments/%d-%s", attachment.ID, path.Base(attachment.FileName))
+		if err := s.writeFile(ctx, archive, name, attachment.StorageKey, export.GeneratedAt); err != nil {
+			return fmt.Errorf("failed to write attachment %d: %w", attachment.ID, err)
+		}
+	}
+	return archive.Close()
+}
+
+// writeFile copies a stored file into the archive. Files are named by their
+// ID so two uploads with the same file name don't collide.
+func (s *ExportService) writeFile(ctx context.Context, archive *zip.Writer, name, key string, modified time.Time) error {
+	file, err := s.storage.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Uploads are JPEG, PNG or PDF and already compressed
+	out, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, file)
+	return err
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func TestDataExport(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	renderer, err := notifications.NewRenderer()
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+	// Deliveries are the account's event history, so notify through the queue
+	f.auth.notifier = notifications.NewDispatcher(f.store.Accounts(), f.store.Notifications(), renderer)
+	exports := NewExportService(f.store.Accounts(), f.store.Sessions(), f.store.Transactions(), f.store.KYC(), f.store.Annotations(), f.store.Notifications(), f.storage, utils.IBANFormat{})
+
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	other := registerTestAccount(t, f.auth, "other@example.com")
+	login, err := f.auth.Login(ctx, models.LoginAccountRequest{Email: "holder@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if _, err := f.transactions.Deposit(ctx, holder.ID, &models.DepositRequest{Amount: 50}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	transfer, err := f.transactions.Transfer(ctx, holder.ID, &models.TransferRequest{ToAccountID: other.ID, Amount: 20})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := f.transactions.Deposit(ctx, other.ID, &models.DepositRequest{Amount: 5}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	kyc := NewKYCService(f.store, f.store.Accounts(), f.store.KYC(), f.storage, f.notifier)
+	submitTestKYC(t, kyc, holder.ID)
+	annotations := NewAnnotationService(f.store, f.store.Transactions(), f.store.Annotations(), f.storage)
+	if _, err := annotations.Update(ctx, holder.ID, transfer.ID, &models.UpdateAnnotationRequest{Note: "Rent share", Tags: []string{"home"}}); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	if _, err := annotations.UploadAttachment(ctx, holder.ID, transfer.ID, "receipt.pdf", bytes.NewReader(testPDF)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	export, err := exports.Export(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if export.Account.Email != "holder@example.com" || export.KYC.Status != models.KYCSubmitted || len(export.KYC.Documents) != 1 {
+		t.Errorf("expected the profile and KYC submission, got %+v and %+v", export.Account, export.KYC)
+	}
+	if len(export.Transactions) != 2 {
+		t.Errorf("expected the holder's two transactions only, got %d", len(export.Transactions))
+	}
+	if len(export.Attachments) != 1 {
+		t.Errorf("expected the holder's attachment, got %d", len(export.Attachments))
+	}
+	if len(export.Sessions) != 1 {
+		t.Errorf("expected one session, got %d", len(export.Sessions))
+	}
+	if len(export.Events) == 0 || export.Events[0].Channel != models.NotificationChannelEmail {
+		t.Errorf("expected the notification history, got %+v", export.Events)
+	}
+	if !export.NotificationPreferences.EmailEnabled {
+		t.Errorf("expected the default preferences, got %+v", export.NotificationPreferences)
+	}
+
+	encoded, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("failed to encode export: %v", err)
+	}
+	if bytes.Contains(encoded, []byte(login.SessionID)) {
+		t.Errorf("expected session IDs to be left out of the export")
+	}
+
+	var buf bytes.Buffer
+	if err := exports.WriteArchive(ctx, &buf, export); err != nil {
+		t.Fatalf("write archive failed: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		files[file.Name] = content
+	}
+	for _, name := range []string{"account.json", "kyc.json", "notification_preferences.json", "sessions.json", "transactions.json", "attachments.json", "events.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected %s in the archive", name)
+		}
+	}
+	var transactions []*models.TransactionResponse
+	if err := json.Unmarshal(files["transactions.json"], &transactions); err != nil || len(transactions) != 2 {
+		t.Errorf("expected transactions.json to hold both transactions, got %d, %v", len(transactions), err)
+	}
+	annotated := false
+	for _, transaction := range transactions {
+		annotated = annotated || transaction.Note == "Rent share"
+	}
+	if !annotated {
+		t.Errorf("expected transactions.json to include the holder's notes")
+	}
+	document := export.KYC.Documents[0]
+	if got := files[fmt.Sprintf("documents/%d-passport.png", document.ID)]; !bytes.Equal(got, testPNG) {
+		t.Errorf("expected the uploaded document in the archive, got %d bytes", len(got))
+	}
+	attachment := export.Attachments[0]
+	if got := files[fmt.Sprintf("attachments/%d-receipt.pdf", attachment.ID)]; !bytes.Equal(got, testPDF) {
+		t.Errorf("expected the attached receipt in the archive, got %d bytes", len(got))
+	}
+}
+
+func TestEraseClosedAccount(t *testing.T) {
+	f := newTestClosureService(t)
+	ctx := context.Background()
+	holder := registerTestAccount(t, f.auth, "holder@example.com")
+	friend := registerTestAccount(t, f.auth, "friend@example.com")
+	payees := NewPayeeService(f.store.Accounts(), f.store.Payees(), f.store.Transactions(), utils.IBANFormat{})
+	if _, err := payees.Create(ctx, friend.ID, &models.CreatePayeeRequest{Nickname: "Jane", Email: "holder@example.com"}); err != nil {
+		t.Fatalf("failed to save payee: %v", err)
+	}
+	deposit, err := f.transactions.Deposit(ctx, holder.ID, &models.DepositRequest{Amount: 40})
+	if err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	annotations := NewAnnotationService(f.store, f.store.Transactions(), f.store.Annotations(), f.storage)
+	if _, err := annotations.Update(ctx, holder.ID, deposit.ID, &models.UpdateAnnotationRequest{Note: "Birthday money"}); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	if _, err := annotations.UploadAttachment(ctx, holder.ID, deposit.ID, "card.png", bytes.NewReader(testPNG)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	if _, err := f.closures.Erase(ctx, holder.ID); errorCode(err) != "account_not_closed" {
+		t.Fatalf("expected an open account not to be erased, got %v", err)
+	}
+	closure, err := f.closures.Close(ctx, holder.ID, &models.CloseAccountRequest{Password: testPassword, PayoutAccountNumber: friend.AccountNumber})
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	erased, err := f.closures.Erase(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("erase failed: %v", err)
+	}
+	if erased.AnonymizedAt == nil {
+		t.Errorf("expected the closure to record the erasure")
+	}
+	if _, err := f.closures.Erase(ctx, holder.ID); errorCode(err) != "already_erased" {
+		t.Errorf("expected erasing twice to conflict, got %v", err)
+	}
+	// The retention job has nothing left to do
+	if count, err := f.closures.AnonymizeDue(ctx, closure.RetainUntil); err != nil || count != 0 {
+		t.Errorf("expected an erased account not to be anonymized again, got %d, %v", count, err)
+	}
+
+	account, err := f.store.Accounts().GetByID(ctx, holder.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if account.Email == "holder@example.com" || account.FirstName != "" || account.LastName != "" {
+		t.Errorf("expected personal fields to be pseudonymized, got %+v", account)
+	}
+	saved, err := f.store.Payees().ListByAccount(ctx, friend.ID)
+	if err != nil {
+		t.Fatalf("failed to list payees: %v", err)
+	}
+	if len(saved) != 1 || saved[0].VerifiedName != "" {
+		t.Errorf("expected the holder's name to be cleared from other address books, got %+v", saved)
+	}
+	if _, err := f.store.Annotations().Get(ctx, holder.ID, deposit.ID); err == nil {
+		t.Errorf("expected the holder's notes to be erased")
+	}
+	if keys := f.storage.Keys(); len(keys) != 0 {
+		t.Errorf("expected the holder's files to be erased, got %v", keys)
+	}
+
+	// The payout and deposit still balance the ledger
+	transactions, err := f.store.Transactions().GetRecent(ctx, holder.ID, 10)
+	if err != nil {
+		t.Fatalf("failed to get transactions: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Errorf("expected the financial records to be kept, got %d", len(transactions))
+	}
+	if got := balanceOf(t, f.transactions, friend.ID); got != 40 {
+		t.Errorf("expected the payout to stand, got %.2f", got)
+	}
+}
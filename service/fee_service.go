@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// FeeService manages the fee schedule, quotes fees before an operation is
+// confirmed and charges the monthly maintenance fee. Withdrawal and transfer
+// fees are charged by TransactionService with the operation itself.
+type FeeService struct {
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	productRepo     repository.ProductStore
+	feeRepo         repository.FeeStore
+	transactionRepo repository.TransactionStore
+}
+
+func NewFeeService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	productRepo repository.ProductStore,
+	feeRepo repository.FeeStore,
+	transactionRepo repository.TransactionStore,
+) *FeeService {
+	return &FeeService{
+		db:              database,
+		accountRepo:     accountRepo,
+		productRepo:     productRepo,
+		feeRepo:         feeRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (s *FeeService) ListRules(ctx context.Context) ([]*models.FeeRule, error) {
+	rules, err := s.feeRepo.ListRules(ctx)
+	if err != nil {
+		return nil, wrapInternal("failed to list fee rules", err)
+	}
+	return rules, nil
+}
+
+// CreateRule adds a rule to the schedule. To change a rule, deactivate it
+// and create its replacement.
+func (s *FeeService) CreateRule(ctx context.Context, req *models.CreateFeeRuleRequest) (*models.FeeRule, error) {
+	ctx, span := tracing.Start(ctx, "FeeService.CreateRule")
+	defer span.End()
+
+	if err := utils.ValidateFeeRule(req); err != nil {
+		return nil, err
+	}
+	if req.Product != nil {
+		if _, err := s.productRepo.GetByCode(ctx, *req.Product); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, &utils.ValidationError{Field: "product", Message: "unknown product"}
+			}
+			return nil, wrapInternal("failed to create fee rule", err)
+		}
+	}
+
+	rule := &models.FeeRule{
+		TransactionType: req.TransactionType,
+		Product:         req.Product,
+		Kind:            req.Kind,
+		FlatAmount:      normalizeDecimal(req.FlatAmount, 2),
+		Percentage:      normalizeDecimal(req.Percentage, rateScale),
+		MinFee:          normalizeOptional(req.MinFee),
+		MaxFee:          normalizeOptional(req.MaxFee),
+	}
+	switch req.Kind {
+	case models.FeeKindFlat:
+		rule.Percentage = normalizeDecimal("", rateScale)
+	case models.FeeKindPercentage:
+		rule.FlatAmount = normalizeDecimal("", 2)
+	case models.FeeKindTiered:
+		rule.FlatAmount = normalizeDecimal("", 2)
+		rule.Percentage = normalizeDecimal("", rateScale)
+		rule.Tiers = make([]models.FeeTier, len(req.Tiers))
+		for i, tier := range req.Tiers {
+			rule.Tiers[i] = models.FeeTier{
+				UpTo:       normalizeOptional(tier.UpTo),
+				FlatAmount: normalizeDecimal(tier.FlatAmount, 2),
+				Percentage: normalizeDecimal(tier.Percentage, rateScale),
+			}
+		}
+	}
+
+	created, err := s.feeRepo.CreateRule(ctx, rule)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, Conflict("fee_rule_exists", "an active fee rule already exists for this transaction type and product")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to create fee rule", err)
+	}
+	return created, nil
+}
+
+func (s *FeeService) DeactivateRule(ctx context.Context, id int) error {
+	if err := s.feeRepo.DeactivateRule(ctx, id); err != nil {
+		return notFoundOrInternal(err, "fee_rule_not_found", "fee rule not found")
+	}
+	return nil
+}
+
+// Quote tells the account holder what a withdrawal or transfer of amount
+// would cost, using the same rule the operation will be charged with
+func (s *FeeService) Quote(ctx context.Context, accountID int, transactionType string, amount float64) (*models.FeeQuote, error) {
+	ctx, span := tracing.Start(ctx, "FeeService.Quote")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	switch transactionType {
+	case models.TransactionTypeWithdraw, models.TransactionTypeTransfer:
+	default:
+		return nil, &utils.ValidationError{Field: "type", Message: "type must be withdraw or transfer"}
+	}
+	if err := utils.ValidateAmount(amount); err != nil {
+		return nil, err
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+
+	fee, rule, err := feeFor(ctx, s.feeRepo, account.Product, transactionType, amount)
+	if err != nil {
+		return nil, wrapInternal("failed to quote fee", err)
+	}
+	quote := &models.FeeQuote{
+		TransactionType: transactionType,
+		Amount:          amount,
+		Fee:             fee,
+		Total:           sumAmounts(amount, fee),
+	}
+	if rule != nil {
+		quote.RuleID = &rule.ID
+	}
+	return quote, nil
+}
+
+// RunMonthly charges the maintenance fee for the month before now. It is
+// idempotent, so it can run as often as convenient.
+func (s *FeeService) RunMonthly(ctx context.Context, now time.Time) (int, error) {
+	month := startOfDay(now)
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return s.ChargeMaintenance(ctx, month.AddDate(0, -1, 0))
+}
+
+// ChargeMaintenance charges the month starting at period to every account
+// opened before the month ended, at most once per account. It returns how
+// many accounts were charged.
+func (s *FeeService) ChargeMaintenance(ctx context.Context, period time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "FeeService.ChargeMaintenance")
+	defer span.End()
+
+	period = startOfDay(period)
+	products, err := s.productRepo.List(ctx)
+	if err != nil {
+		return 0, wrapInternal("failed to list products", err)
+	}
+	chargedIDs, err := s.feeRepo.ListMaintenanceCharged(ctx, period)
+	if err != nil {
+		return 0, wrapInternal("failed to list maintenance charges", err)
+	}
+	charged := make(map[int]bool, len(chargedIDs))
+	for _, id := range chargedIDs {
+		charged[id] = true
+	}
+
+	total := 0
+	var errs []error
+	for _, product := range products {
+		rule, err := s.feeRepo.FindRule(ctx, models.FeeTypeMaintenance, product.Code)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s: %w", product.Code, err))
+			continue
+		}
+
+		accountIDs, err := s.accountRepo.ListIDsByProduct(ctx, product.Code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s: %w", product.Code, err))
+			continue
+		}
+		for _, accountID := range accountIDs {
+			if charged[accountID] {
+				continue
+			}
+			ok, err := s.chargeMaintenance(ctx, accountID, rule, period)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("account %d: %w", accountID, err))
+				continue
+			}
+			if ok {
+				total++
+			}
+		}
+	}
+
+	span.SetAttribute("fees.maintenance_charged", total)
+	if len(errs) > 0 {
+		return total, wrapInternal("maintenance fee run failed", errors.Join(errs...))
+	}
+	return total, nil
+}
+
+// chargeMaintenance charges one account's monthly fee, priced on its current
+// balance so tiers can waive the fee above a threshold. The fee never takes
+// the account past its overdraft limit.
+func (s *FeeService) chargeMaintenance(ctx context.Context, accountID int, rule *models.FeeRule, period time.Time) (bool, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	if !account.CreatedAt.Before(period.AddDate(0, 1, 0)) {
+		return false, nil
+	}
+
+	paid := false
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, overdraftLimit, err := s.accountRepo.GetFundsForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		fee, err := computeFee(rule, utils.DecimalFromAmount(balance))
+		if err != nil {
+			return err
+		}
+		amount := min(amountFromDecimal(fee), max(models.AvailableBalance(balance, overdraftLimit), 0))
+
+		var transactionID *int
+		if amount > 0 {
+			description := "Monthly maintenance fee for " + period.Format("January 2006")
+			transaction, err := s.transactionRepo.Create(ctx, &accountID, nil, amount, models.TransactionTypeFee, description)
+			if err != nil {
+				return err
+			}
+			if err := s.accountRepo.UpdateBalance(ctx, accountID, sumAmounts(balance, -amount)); err != nil {
+				return err
+			}
+			transactionID = &transaction.ID
+			paid = true
+		}
+		return s.feeRepo.RecordMaintenance(ctx, accountID, period, amount, transactionID)
+	})
+	// Another instance of the job charged the account first
+	if errors.Is(err, repository.ErrDuplicate) {
+		return false, nil
+	}
+	return paid, err
+}
+
+// feeFor prices an operation of amount on an account of product. The fee is
+// zero and the rule nil when the schedule has no rule for it.
+func feeFor(ctx context.Context, feeRepo repository.FeeStore, product, transactionType string, amount float64) (float64, *models.FeeRule, error) {
+	rule, err := feeRepo.FindRule(ctx, transactionType, product)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	fee, err := computeFee(rule, utils.DecimalFromAmount(amount))
+	if err != nil {
+		return 0, nil, err
+	}
+	return amountFromDecimal(fee), rule, nil
+}
+
+// computeFee applies rule to amount and rounds the result to the cent. A
+// tiered rule prices the whole amount with the first tier it fits in; the
+// min and max caps apply to every kind.
+func computeFee(rule *models.FeeRule, amount *big.Rat) (*big.Rat, error) {
+	var fee *big.Rat
+	var err error
+	switch rule.Kind {
+	case models.FeeKindFlat:
+		fee, err = flatPlusPercentage(rule.FlatAmount, "", amount)
+	case models.FeeKindPercentage:
+		fee, err = flatPlusPercentage("", rule.Percentage, amount)
+	case models.FeeKindTiered:
+		tier, tierErr := tierFor(rule.Tiers, amount)
+		if tierErr != nil {
+			return nil, tierErr
+		}
+		fee, err = flatPlusPercentage(tier.FlatAmount, tier.Percentage, amount)
+	default:
+		return nil, fmt.Errorf("fee rule %d has unknown kind %q", rule.ID, rule.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.MinFee != nil {
+		minFee, err := utils.ParseDecimal(*rule.MinFee)
+		if err != nil {
+			return nil, err
+		}
+		if fee.Cmp(minFee) < 0 {
+			fee = minFee
+		}
+	}
+	if rule.MaxFee != nil {
+		maxFee, err := utils.ParseDecimal(*rule.MaxFee)
+		if err != nil {
+			return nil, err
+		}
+		if fee.Cmp(maxFee) > 0 {
+			fee = maxFee
+		}
+	}
+	return utils.ParseDecimal(utils.FormatDecimal(fee, 2))
+}
+
+func tierFor(tiers []models.FeeTier, amount *big.Rat) (*models.FeeTier, error) {
+	for i := range tiers {
+		if tiers[i].UpTo == nil {
+			return &tiers[i], nil
+		}
+		upTo, err := utils.ParseDecimal(*tiers[i].UpTo)
+		if err != nil {
+			return nil, err
+		}
+		if amount.Cmp(upTo) <= 0 {
+			return &tiers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no fee tier covers %s", utils.FormatDecimal(amount, 2))
+}
+
+// flatPlusPercentage is flat + amount * percentage%. Empty strings count as
+// zero.
+func flatPlusPercentage(flat, percentage string, amount *big.Rat) (*big.Rat, error) {
+	fee := new(big.Rat)
+	if flat != "" {
+		f, err := utils.ParseDecimal(flat)
+		if err != nil {
+			return nil, err
+		}
+		fee.Add(fee, f)
+	}
+	if percentage != "" {
+		p, err := utils.ParseDecimal(percentage)
+		if err != nil {
+			return nil, err
+		}
+		share := new(big.Rat).Mul(amount, p)
+		fee.Add(fee, share.Quo(share, big.NewRat(100, 1)))
+	}
+	return fee, nil
+}
+
+// sumAmounts adds money amounts exactly, so totals compared against a
+// balance are not thrown off by float rounding
+func sumAmounts(amounts ...float64) float64 {
+	total := new(big.Rat)
+	for _, amount := range amounts {
+		total.Add(total, utils.DecimalFromAmount(amount))
+	}
+	return amountFromDecimal(total)
+}
+
+func amountFromDecimal(r *big.Rat) float64 {
+	amount, _ := strconv.ParseFloat(utils.FormatDecimal(r, 2), 64)
+	return amount
+}
+
+// normalizeDecimal formats a validated decimal string to scale places; an
+// empty string is zero
+func normalizeDecimal(value string, scale int) string {
+	if value == "" {
+		value = "0"
+	}
+	r, err := utils.ParseDecimal(value)
+	if err != nil {
+		return value
+	}
+	return utils.FormatDecimal(r, scale)
+}
+
+func normalizeOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	normalized := normalizeDecimal(*value, 2)
+	return &normalized
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestFeeService(t *testing.T) (*FeeService, *TransactionService, *memory.Store) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	fees := NewFeeService(store, store.Accounts(), store.Products(), store.Fees(), store.Transactions())
+	return fees, transactions, store
+}
+
+func decimalPtr(s string) *string {
+	return &s
+}
+
+func createFeeRule(t *testing.T, svc *FeeService, req *models.CreateFeeRuleRequest) *models.FeeRule {
+	t.Helper()
+	rule, err := svc.CreateRule(context.Background(), req)
+	if err != nil {
+		t.Fatalf("failed to create fee rule: %v", err)
+	}
+	return rule
+}
+
+func TestComputeFee(t *testing.T) {
+	tiers := []models.FeeTier{
+		{UpTo: decimalPtr("100.00"), FlatAmount: "0.50", Percentage: "0"},
+		{UpTo: decimalPtr("1000.00"), FlatAmount: "1.00", Percentage: "0.5"},
+		{FlatAmount: "0", Percentage: "0.25"},
+	}
+	tests := []struct {
+		name   string
+		rule   models.FeeRule
+		amount float64
+		want   string
+	}{
+		{"flat", models.FeeRule{Kind: models.FeeKindFlat, FlatAmount: "2.50"}, 40, "2.50"},
+		{"percentage", models.FeeRule{Kind: models.FeeKindPercentage, Percentage: "1.5"}, 200, "3.00"},
+		{"percentage rounds half up", models.FeeRule{Kind: models.FeeKindPercentage, Percentage: "1"}, 10.50, "0.11"},
+		{"min cap", models.FeeRule{Kind: models.FeeKindPercentage, Percentage: "1", MinFee: decimalPtr("1.00")}, 20, "1.00"},
+		{"max cap", models.FeeRule{Kind: models.FeeKindPercentage, Percentage: "1", MaxFee: decimalPtr("5.00")}, 10000, "5.00"},
+		{"first tier", models.FeeRule{Kind: models.FeeKindTiered, Tiers: tiers}, 100, "0.50"},
+		{"middle tier", models.FeeRule{Kind: models.FeeKindTiered, Tiers: tiers}, 100.01, "1.50"},
+		{"open tier", models.FeeRule{Kind: models.FeeKindTiered, Tiers: tiers}, 4000, "10.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := computeFee(&tt.rule, utils.DecimalFromAmount(tt.amount))
+			if err != nil {
+				t.Fatalf("computeFee: %v", err)
+			}
+			if got := utils.FormatDecimal(fee, 2); got != tt.want {
+				t.Errorf("fee on %v = %s, want %s", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateFeeRuleValidation(t *testing.T) {
+	fees, _, _ := newTestFeeService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  models.CreateFeeRuleRequest
+	}{
+		{"unknown type", models.CreateFeeRuleRequest{TransactionType: "deposit", Kind: models.FeeKindFlat, FlatAmount: "1"}},
+		{"unknown kind", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: "free"}},
+		{"missing flat amount", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat}},
+		{"too many decimals", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat, FlatAmount: "1.005"}},
+		{"percentage over 100", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindPercentage, Percentage: "101"}},
+		{"min above max", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat, FlatAmount: "1", MinFee: decimalPtr("5"), MaxFee: decimalPtr("2")}},
+		{"closed last tier", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindTiered, Tiers: []models.FeeTier{
+			{UpTo: decimalPtr("100"), FlatAmount: "1"},
+		}}},
+		{"tiers out of order", models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindTiered, Tiers: []models.FeeTier{
+			{UpTo: decimalPtr("100"), FlatAmount: "1"},
+			{UpTo: decimalPtr("50"), FlatAmount: "2"},
+			{FlatAmount: "3"},
+		}}},
+		{"unknown product", models.CreateFeeRuleRequest{TransactionType: "withdraw", Product: decimalPtr("gold"), Kind: models.FeeKindFlat, FlatAmount: "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fees.CreateRule(ctx, &tt.req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat, FlatAmount: "1"})
+	_, err := fees.CreateRule(ctx, &models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat, FlatAmount: "2"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected conflict for a second active rule, got %v", err)
+	}
+}
+
+func TestWithdrawChargesLinkedFee(t *testing.T) {
+	fees, svc, store := newTestFeeService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "fee@example.com", 100)
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: "withdraw", Kind: models.FeeKindFlat, FlatAmount: "1.50"})
+
+	quote, err := fees.Quote(ctx, account.ID, models.TransactionTypeWithdraw, 40)
+	if err != nil {
+		t.Fatalf("quote failed: %v", err)
+	}
+	if quote.Fee != 1.5 || quote.Total != 41.5 {
+		t.Errorf("expected fee 1.50 and total 41.50, got %.2f and %.2f", quote.Fee, quote.Total)
+	}
+
+	response, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 40})
+	if err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if response.Fee == nil || response.Fee.Amount != quote.Fee {
+		t.Fatalf("expected the quoted fee on the response, got %+v", response.Fee)
+	}
+	if response.Fee.Type != models.TransactionTypeFee || response.Fee.RelatedTransactionID == nil || *response.Fee.RelatedTransactionID != response.ID {
+		t.Errorf("expected a fee transaction linked to %d, got %+v", response.ID, response.Fee)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 58.5 {
+		t.Errorf("expected balance 58.50, got %.2f", got)
+	}
+
+	// The fee counts towards the funds needed, and nothing is charged when
+	// the operation fails
+	_, err = svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 58})
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 58.5 {
+		t.Errorf("expected balance to stay 58.50, got %.2f", got)
+	}
+}
+
+func TestTransferFeeUsesProductRule(t *testing.T) {
+	fees, svc, store := newTestFeeService(t)
+	ctx := context.Background()
+	sender := createFundedAccount(t, store, svc, "sender@example.com", 500)
+	recipient := createFundedAccount(t, store, svc, "recipient@example.com", 0)
+
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: "transfer", Kind: models.FeeKindPercentage, Percentage: "1", MinFee: decimalPtr("0.25")})
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: "transfer", Product: decimalPtr(models.ProductSavings), Kind: models.FeeKindFlat, FlatAmount: "3"})
+
+	response, err := svc.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: recipient.ID, Amount: 200})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if response.Fee == nil || response.Fee.Amount != 2 {
+		t.Fatalf("expected the checking fee of 2.00, got %+v", response.Fee)
+	}
+	if got := balanceOf(t, svc, sender.ID); got != 298 {
+		t.Errorf("expected sender balance 298.00, got %.2f", got)
+	}
+	if got := balanceOf(t, svc, recipient.ID); got != 200 {
+		t.Errorf("expected recipient balance 200.00, got %.2f", got)
+	}
+
+	if err := store.Accounts().SetProduct(ctx, sender.ID, models.ProductSavings); err != nil {
+		t.Fatalf("failed to change product: %v", err)
+	}
+	quote, err := fees.Quote(ctx, sender.ID, models.TransactionTypeTransfer, 200)
+	if err != nil {
+		t.Fatalf("quote failed: %v", err)
+	}
+	if quote.Fee != 3 {
+		t.Errorf("expected the savings fee of 3.00, got %.2f", quote.Fee)
+	}
+}
+
+func TestChargeMaintenanceIsIdempotent(t *testing.T) {
+	fees, svc, store := newTestFeeService(t)
+	ctx := context.Background()
+	low := createFundedAccount(t, store, svc, "low@example.com", 3)
+	high := createFundedAccount(t, store, svc, "high@example.com", 2000)
+	empty := createFundedAccount(t, store, svc, "empty@example.com", 0)
+
+	// Balances of 1000 or more are waived
+	createFeeRule(t, fees, &models.CreateFeeRuleRequest{TransactionType: models.FeeTypeMaintenance, Kind: models.FeeKindTiered, Tiers: []models.FeeTier{
+		{UpTo: decimalPtr("999.99"), FlatAmount: "5"},
+		{FlatAmount: "0"},
+	}})
+
+	now := time.Now().UTC()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	charged, err := fees.ChargeMaintenance(ctx, period)
+	if err != nil {
+		t.Fatalf("maintenance run failed: %v", err)
+	}
+	if charged != 1 {
+		t.Errorf("expected 1 account charged, got %d", charged)
+	}
+	if got := balanceOf(t, svc, low.ID); got != 0 {
+		t.Errorf("expected the fee to stop at a zero balance, got %.2f", got)
+	}
+	if got := balanceOf(t, svc, high.ID); got != 2000 {
+		t.Errorf("expected the fee to be waived, got balance %.2f", got)
+	}
+	if got := balanceOf(t, svc, empty.ID); got != 0 {
+		t.Errorf("expected empty account to stay at 0, got %.2f", got)
+	}
+
+	if _, err := svc.Deposit(ctx, low.ID, &models.DepositRequest{Amount: 50}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	charged, err = fees.ChargeMaintenance(ctx, period)
+	if err != nil {
+		t.Fatalf("second maintenance run failed: %v", err)
+	}
+	if charged != 0 {
+		t.Errorf("expected no charges on the second run, got %d", charged)
+	}
+	if got := balanceOf(t, svc, low.ID); got != 50 {
+		t.Errorf("expected balance 50.00 after the second run, got %.2f", got)
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// monthLayout is the YYYY-MM form insight months are given in
+	monthLayout = "2006-01"
+	// defaultInsightMonths is how many months insights cover when no range
+	// is given, counting the current one
+	defaultInsightMonths = 6
+	// maxInsightMonths caps the months one insights request can cover
+	maxInsightMonths = 24
+	// topCounterparties is how many counterparties insights list
+	topCounterparties = 5
+)
+
+// InsightService reports where an account's money went: monthly totals,
+// the accounts it dealt with most, per-category totals and budgets. The
+// sums are computed by the store; transactions are categorized first.
+type InsightService struct {
+	categoryService *CategoryService
+	insightRepo     repository.InsightStore
+	budgetRepo      repository.BudgetStore
+}
+
+func NewInsightService(
+	categoryService *CategoryService,
+	insightRepo repository.InsightStore,
+	budgetRepo repository.BudgetStore,
+) *InsightService {
+	return &InsightService{
+		categoryService: categoryService,
+		insightRepo:     insightRepo,
+		budgetRepo:      budgetRepo,
+	}
+}
+
+// Insights covers the whole months from through to (YYYY-MM, UTC). Either
+// may be empty: to defaults to the current month and from to five months
+// before to.
+func (s *InsightService) Insights(ctx context.Context, accountID int, from, to string) (*models.Insights, error) {
+	ctx, span := tracing.Start(ctx, "InsightService.Insights")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	start, end, err := insightRange(from, to, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.categoryService.Categorize(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	flows, err := s.insightRepo.MonthlyFlows(ctx, accountID, start, end)
+	if err != nil {
+		return nil, wrapInternal("failed to load insights", err)
+	}
+	counterparties, err := s.insightRepo.TopCounterparties(ctx, accountID, start, end, topCounterparties)
+	if err != nil {
+		return nil, wrapInternal("failed to load insights", err)
+	}
+	categories, err := s.insightRepo.CategoryTotals(ctx, accountID, start, end)
+	if err != nil {
+		return nil, wrapInternal("failed to load insights", err)
+	}
+
+	// Months without any money moving are reported as zero
+	byMonth := make(map[string]*models.MonthlyFlow, len(flows))
+	for _, flow := range flows {
+		byMonth[flow.Month] = flow
+	}
+	months := make([]*models.MonthlyFlow, 0)
+	for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+		key := month.Format(monthLayout)
+		if flow, ok := byMonth[key]; ok {
+			months = append(months, flow)
+		} else {
+			months = append(months, &models.MonthlyFlow{Month: key})
+		}
+	}
+	for _, counterparty := range counterparties {
+		counterparty.Name = utils.MaskName(counterparty.FirstName, counterparty.LastName)
+	}
+
+	return &models.Insights{
+		From:              start.Format(monthLayout),
+		To:                end.AddDate(0, -1, 0).Format(monthLayout),
+		Months:            months,
+		TopCounterparties: counterparties,
+		Categories:        categories,
+	}, nil
+}
+
+// insightRange turns the from and to months into the instants [start, end)
+func insightRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	last := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if to != "" {
+		parsed, err := time.Parse(monthLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, &utils.ValidationError{Field: "to", Message: "to must be a month in YYYY-MM format"}
+		}
+		last = parsed
+	}
+	first := last.AddDate(0, -(defaultInsightMonths - 1), 0)
+	if from != "" {
+		parsed, err := time.Parse(monthLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, &utils.ValidationError{Field: "from", Message: "from must be a month in YYYY-MM format"}
+		}
+		first = parsed
+	}
+
+	if first.After(last) {
+		return time.Time{}, time.Time{}, &utils.ValidationError{Field: "from", Message: "from must not be after to"}
+	}
+	if first.AddDate(0, maxInsightMonths, 0).Before(last.AddDate(0, 1, 0)) {
+		return time.Time{}, time.Time{}, &utils.ValidationError{Field: "from", Message: "insights can cover at most 24 months"}
+	}
+	return first, last.AddDate(0, 1, 0), nil
+}
+
+// ListBudgets returns the account's budgets with what was spent in each
+// category so far this month (UTC)
+func (s *InsightService) ListBudgets(ctx context.Context, accountID int) ([]*models.BudgetProgress, error) {
+	ctx, span := tracing.Start(ctx, "InsightService.ListBudgets")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	budgets, err := s.budgetRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to list budgets", err)
+	}
+	progress := make([]*models.BudgetProgress, 0, len(budgets))
+	if len(budgets) == 0 {
+		return progress, nil
+	}
+
+	if err := s.categoryService.Categorize(ctx, accountID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.insightRepo.CategoryTotals(ctx, accountID, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, wrapInternal("failed to list budgets", err)
+	}
+	spent := make(map[string]float64, len(totals))
+	for _, total := range totals {
+		spent[total.Category] = total.Outflow
+	}
+
+	for _, budget := range budgets {
+		progress = append(progress, budgetProgress(budget, start.Format(monthLayout), spent[budget.Category]))
+	}
+	return progress, nil
+}
+
+func budgetProgress(budget *models.Budget, month string, spent float64) *models.BudgetProgress {
+	return &models.BudgetProgress{
+		Budget:      budget,
+		Month:       month,
+		Spent:       spent,
+		Remaining:   sumAmounts(budget.MonthlyLimit, -spent),
+		PercentUsed: math.Round(spent/budget.MonthlyLimit*10000) / 100,
+		Exceeded:    spent > budget.MonthlyLimit,
+	}
+}
+
+// SetBudget creates the account's budget for a category or changes its
+// monthly limit
+func (s *InsightService) SetBudget(ctx context.Context, accountID int, category string, req *models.SetBudgetRequest) (*models.Budget, error) {
+	ctx, span := tracing.Start(ctx, "InsightService.SetBudget")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateCategory(category); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateMonthlyLimit(req.MonthlyLimit); err != nil {
+		return nil, err
+	}
+
+	budget, err := s.budgetRepo.Save(ctx, &models.Budget{
+		AccountID:    accountID,
+		Category:     category,
+		MonthlyLimit: req.MonthlyLimit,
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to save budget", err)
+	}
+	return budget, nil
+}
+
+func (s *InsightService) DeleteBudget(ctx context.Context, accountID int, category string) error {
+	ctx, span := tracing.Start(ctx, "InsightService.DeleteBudget")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	err := s.budgetRepo.Delete(ctx, accountID, category)
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("budget_not_found", "budget not found")
+	}
+	if err != nil {
+		return wrapInternal("failed to delete budget", err)
+	}
+	return nil
+}
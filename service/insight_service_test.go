@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestInsightService(t *testing.T) (*InsightService, *CategoryService, *TransactionService, *memory.Store) {
+	t.Helper()
+	svc, store := newTestTransactionService(t)
+	categories := NewCategoryService(store, store.Accounts(), store.Transactions(), store.Categories(), utils.IBANFormat{})
+	return NewInsightService(categories, store.Insights(), store.Budgets()), categories, svc, store
+}
+
+func categoryTotal(t *testing.T, insights *models.Insights, category string) *models.CategoryTotal {
+	t.Helper()
+	for _, total := range insights.Categories {
+		if total.Category == category {
+			return total
+		}
+	}
+	return &models.CategoryTotal{Category: category}
+}
+
+func TestCategorizeRulesAndOverrides(t *testing.T) {
+	insights, categories, svc, store := newTestInsightService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 500)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+	landlord := createFundedAccount(t, store, svc, "landlord@example.com", 0)
+
+	groceries, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: 40, Description: "TESCO Metro"})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: landlord.ID, Amount: 300, Description: "October"}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, alice.ID, &models.WitdrawRequest{Amount: 20, Description: "ATM"}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+
+	// Categories already worked out are redone when a rule is added
+	if _, err := insights.Insights(ctx, alice.ID, "", ""); err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if _, err := categories.CreateRule(ctx, alice.ID, &models.CreateCategoryRuleRequest{Category: models.CategoryGroceries, Pattern: "tesco"}); err != nil {
+		t.Fatalf("create rule failed: %v", err)
+	}
+	rent, err := categories.CreateRule(ctx, alice.ID, &models.CreateCategoryRuleRequest{Category: models.CategoryBills, CounterpartyAccountNumber: landlord.AccountNumber})
+	if err != nil {
+		t.Fatalf("create rule failed: %v", err)
+	}
+	if rent.CounterpartyAccountNumber != landlord.AccountNumber {
+		t.Errorf("expected the rule to show the counterparty's account number, got %q", rent.CounterpartyAccountNumber)
+	}
+
+	got, err := insights.Insights(ctx, alice.ID, "", "")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	want := map[string]float64{models.CategoryGroceries: 40, models.CategoryBills: 300, models.CategoryCash: 20}
+	for category, outflow := range want {
+		if total := categoryTotal(t, got, category); total.Outflow != outflow || total.Count != 1 {
+			t.Errorf("expected %s outflow %.2f over 1 transaction, got %.2f over %d", category, outflow, total.Outflow, total.Count)
+		}
+	}
+	if total := categoryTotal(t, got, models.CategoryIncome); total.Inflow != 500 {
+		t.Errorf("expected the seed deposit as 500 income, got %.2f", total.Inflow)
+	}
+
+	// The other side of a transfer files it separately
+	bobInsights, err := insights.Insights(ctx, bob.ID, "", "")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if total := categoryTotal(t, bobInsights, models.CategoryTransfers); total.Inflow != 40 {
+		t.Errorf("expected bob's side to default to transfers, got %+v", total)
+	}
+
+	// The holder's category wins over rules, including ones added later
+	if _, err := categories.SetCategory(ctx, alice.ID, groceries.ID, models.CategoryDining); err != nil {
+		t.Fatalf("set category failed: %v", err)
+	}
+	if _, err := categories.CreateRule(ctx, alice.ID, &models.CreateCategoryRuleRequest{Category: models.CategoryShopping, Pattern: "metro"}); err != nil {
+		t.Fatalf("create rule failed: %v", err)
+	}
+	got, err = insights.Insights(ctx, alice.ID, "", "")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if total := categoryTotal(t, got, models.CategoryDining); total.Outflow != 40 {
+		t.Errorf("expected the override to file 40 under dining, got %.2f", total.Outflow)
+	}
+
+	if err := categories.ClearCategory(ctx, alice.ID, groceries.ID); err != nil {
+		t.Fatalf("clear category failed: %v", err)
+	}
+	if err := categories.DeleteRule(ctx, alice.ID, rent.ID); err != nil {
+		t.Fatalf("delete rule failed: %v", err)
+	}
+	got, err = insights.Insights(ctx, alice.ID, "", "")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if total := categoryTotal(t, got, models.CategoryGroceries); total.Outflow != 40 {
+		t.Errorf("expected the oldest matching rule to apply once the override is cleared, got %.2f", total.Outflow)
+	}
+	if total := categoryTotal(t, got, models.CategoryTransfers); total.Outflow != 300 {
+		t.Errorf("expected rent to fall back to transfers once its rule is deleted, got %.2f", total.Outflow)
+	}
+
+	// Someone else's transaction is reported as missing
+	carol := createFundedAccount(t, store, svc, "carol@example.com", 0)
+	if _, err := categories.SetCategory(ctx, carol.ID, groceries.ID, models.CategoryDining); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected someone else's transaction to be not found, got %v", err)
+	}
+	if err := categories.DeleteRule(ctx, carol.ID, rent.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected someone else's rule to be not found, got %v", err)
+	}
+}
+
+func TestCategoryValidation(t *testing.T) {
+	_, categories, svc, store := newTestInsightService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 100)
+
+	tests := []struct {
+		name string
+		req  models.CreateCategoryRuleRequest
+	}{
+		{"unknown category", models.CreateCategoryRuleRequest{Category: "yachts", Pattern: "marina"}},
+		{"nothing to match", models.CreateCategoryRuleRequest{Category: models.CategoryBills, Pattern: "   "}},
+		{"own account", models.CreateCategoryRuleRequest{Category: models.CategoryBills, CounterpartyAccountNumber: alice.AccountNumber}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := categories.CreateRule(ctx, alice.ID, &tt.req)
+			if !errors.As(err, new(*utils.ValidationError)) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	unknown, err := utils.GenerateAccountNumber()
+	if err != nil {
+		t.Fatalf("failed to generate account number: %v", err)
+	}
+	if _, err := categories.CreateRule(ctx, alice.ID, &models.CreateCategoryRuleRequest{Category: models.CategoryBills, CounterpartyAccountNumber: unknown}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown counterparty to be not found, got %v", err)
+	}
+
+	pots := NewPotService(store, store.Accounts(), store.Pots(), store.Transactions())
+	pot, err := pots.Create(ctx, alice.ID, &models.CreatePotRequest{Name: "Holiday"})
+	if err != nil {
+		t.Fatalf("create pot failed: %v", err)
+	}
+	move, err := pots.Deposit(ctx, alice.ID, pot.ID, &models.PotMoveRequest{Amount: 10})
+	if err != nil {
+		t.Fatalf("pot deposit failed: %v", err)
+	}
+	if _, err := categories.SetCategory(ctx, alice.ID, move.Transaction.ID, models.CategoryTravel); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a pot move to refuse a category, got %v", err)
+	}
+}
+
+func TestInsightsMonthlyFlows(t *testing.T) {
+	insights, _, svc, store := newTestInsightService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 500)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 100)
+	carol := createFundedAccount(t, store, svc, "carol@example.com", 0)
+
+	for _, req := range []models.TransferRequest{
+		{ToAccountID: bob.ID, Amount: 40},
+		{ToAccountID: carol.ID, Amount: 120},
+		{ToAccountID: bob.ID, Amount: 15.5},
+	} {
+		if _, err := svc.Transfer(ctx, alice.ID, &req); err != nil {
+			t.Fatalf("transfer failed: %v", err)
+		}
+	}
+	if _, err := svc.Transfer(ctx, bob.ID, &models.TransferRequest{ToAccountID: alice.ID, Amount: 60}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	pots := NewPotService(store, store.Accounts(), store.Pots(), store.Transactions())
+	pot, err := pots.Create(ctx, alice.ID, &models.CreatePotRequest{Name: "Holiday"})
+	if err != nil {
+		t.Fatalf("create pot failed: %v", err)
+	}
+	if _, err := pots.Deposit(ctx, alice.ID, pot.ID, &models.PotMoveRequest{Amount: 50}); err != nil {
+		t.Fatalf("pot deposit failed: %v", err)
+	}
+
+	got, err := insights.Insights(ctx, alice.ID, "", "")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if len(got.Months) != 6 {
+		t.Fatalf("expected 6 months by default, got %d", len(got.Months))
+	}
+	month := time.Now().UTC().Format("2006-01")
+	if got.To != month || got.Months[5].Month != month || got.Months[0].Month != got.From {
+		t.Errorf("expected the range to end this month, got %s to %s", got.From, got.To)
+	}
+	for _, flow := range got.Months[:5] {
+		if flow.Inflow != 0 || flow.Outflow != 0 {
+			t.Errorf("expected %s to be empty, got %+v", flow.Month, flow)
+		}
+	}
+	// Pot moves stay within the account and are left out
+	current := got.Months[5]
+	if current.Inflow != 560 || current.Outflow != 175.5 || current.Net != 384.5 {
+		t.Errorf("expected 560 in, 175.50 out and 384.50 net, got %+v", current)
+	}
+
+	if len(got.TopCounterparties) != 2 {
+		t.Fatalf("expected 2 counterparties, got %d", len(got.TopCounterparties))
+	}
+	top := got.TopCounterparties[0]
+	if top.AccountNumber != carol.AccountNumber || top.Outflow != 120 || top.Count != 1 {
+		t.Errorf("expected carol first with 120 out, got %+v", top)
+	}
+	second := got.TopCounterparties[1]
+	if second.AccountNumber != bob.AccountNumber || second.Inflow != 60 || second.Outflow != 55.5 || second.Count != 3 {
+		t.Errorf("expected bob with 60 in and 55.50 out over 3 transfers, got %+v", second)
+	}
+	if top.Name != "T*** U***" {
+		t.Errorf("expected a masked name, got %q", top.Name)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+	}{
+		{"bad from", "2024-13", ""},
+		{"bad to", "", "March"},
+		{"from after to", "2025-06", "2025-05"},
+		{"too long", "2023-01", "2025-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := insights.Insights(ctx, alice.ID, tt.from, tt.to)
+			if !errors.As(err, new(*utils.ValidationError)) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	past, err := insights.Insights(ctx, alice.ID, "2023-01", "2024-12")
+	if err != nil {
+		t.Fatalf("insights failed: %v", err)
+	}
+	if len(past.Months) != 24 || len(past.TopCounterparties) != 0 || len(past.Categories) != 0 {
+		t.Errorf("expected 24 empty months, got %d months, %d counterparties, %d categories", len(past.Months), len(past.TopCounterparties), len(past.Categories))
+	}
+}
+
+func TestBudgetProgress(t *testing.T) {
+	insights, categories, svc, store := newTestInsightService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 500)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+
+	if _, err := categories.CreateRule(ctx, alice.ID, &models.CreateCategoryRuleRequest{Category: models.CategoryDining, Pattern: "lunch"}); err != nil {
+		t.Fatalf("create rule failed: %v", err)
+	}
+	for _, amount := range []float64{12.5, 30, 20} {
+		if _, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: amount, Description: "Team lunch"}); err != nil {
+			t.Fatalf("transfer failed: %v", err)
+		}
+	}
+
+	for _, req := range []struct {
+		category string
+		limit    float64
+	}{
+		{"yachts", 100},
+		{models.CategoryDining, 0},
+		{models.CategoryDining, 10.001},
+	} {
+		_, err := insights.SetBudget(ctx, alice.ID, req.category, &models.SetBudgetRequest{MonthlyLimit: req.limit})
+		if !errors.As(err, new(*utils.ValidationError)) {
+			t.Errorf("expected validation error for %s %.3f, got %v", req.category, req.limit, err)
+		}
+	}
+
+	if _, err := insights.SetBudget(ctx, alice.ID, models.CategoryDining, &models.SetBudgetRequest{MonthlyLimit: 100}); err != nil {
+		t.Fatalf("set budget failed: %v", err)
+	}
+	if _, err := insights.SetBudget(ctx, alice.ID, models.CategoryGroceries, &models.SetBudgetRequest{MonthlyLimit: 200}); err != nil {
+		t.Fatalf("set budget failed: %v", err)
+	}
+	budgets, err := insights.ListBudgets(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("list budgets failed: %v", err)
+	}
+	if len(budgets) != 2 {
+		t.Fatalf("expected 2 budgets, got %d", len(budgets))
+	}
+	dining := budgets[0]
+	if dining.Category != models.CategoryDining || dining.Spent != 62.5 || dining.Remaining != 37.5 || dining.PercentUsed != 62.5 || dining.Exceeded {
+		t.Errorf("expected 62.50 of 100 spent on dining, got %+v", dining)
+	}
+	if groceries := budgets[1]; groceries.Spent != 0 || groceries.Remaining != 200 {
+		t.Errorf("expected nothing spent on groceries, got %+v", groceries)
+	}
+
+	// Saving again changes the limit of the same budget
+	updated, err := insights.SetBudget(ctx, alice.ID, models.CategoryDining, &models.SetBudgetRequest{MonthlyLimit: 50})
+	if err != nil {
+		t.Fatalf("set budget failed: %v", err)
+	}
+	if updated.ID != dining.ID {
+		t.Errorf("expected the existing budget to be updated")
+	}
+	budgets, err = insights.ListBudgets(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("list budgets failed: %v", err)
+	}
+	if dining := budgets[0]; !dining.Exceeded || dining.Remaining != -12.5 || dining.PercentUsed != 125 {
+		t.Errorf("expected the dining budget to be exceeded by 12.50, got %+v", dining)
+	}
+
+	if err := insights.DeleteBudget(ctx, alice.ID, models.CategoryDining); err != nil {
+		t.Fatalf("delete budget failed: %v", err)
+	}
+	if err := insights.DeleteBudget(ctx, alice.ID, models.CategoryDining); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a deleted budget to be not found, got %v", err)
+	}
+}
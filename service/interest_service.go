@@ -0,0 +1,468 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// daysPerYear is the Actual/365 Fixed day count: every day earns
+	// 1/365 of the annual rate, leap years included
+	daysPerYear = 365
+	// accrualScale is the number of decimal places kept on a day's interest
+	accrualScale = 10
+	// rateScale matches annual_rate NUMERIC(9, 6)
+	rateScale = 6
+)
+
+// InterestService accrues interest daily on end-of-day balances and pays it
+// out monthly as an interest transaction
+type InterestService struct {
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	productRepo     repository.ProductStore
+	interestRepo    repository.InterestStore
+	transactionRepo repository.TransactionStore
+}
+
+func NewInterestService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	productRepo repository.ProductStore,
+	interestRepo repository.InterestStore,
+	transactionRepo repository.TransactionStore,
+) *InterestService {
+	return &InterestService{
+		db:              database,
+		accountRepo:     accountRepo,
+		productRepo:     productRepo,
+		interestRepo:    interestRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (s *InterestService) ListProducts(ctx context.Context) ([]*models.Product, error) {
+	products, err := s.productRepo.List(ctx)
+	if err != nil {
+		return nil, wrapInternal("failed to list products", err)
+	}
+	return products, nil
+}
+
+// UpdateProduct changes the rates future accruals use; days already
+// accrued keep the rate recorded with them
+func (s *InterestService) UpdateProduct(ctx context.Context, code string, req *models.UpdateProductRequest) (*models.Product, error) {
+	ctx, span := tracing.Start(ctx, "InterestService.UpdateProduct")
+	defer span.End()
+
+	if req.AnnualRate == "" && req.OverdraftRate == "" {
+		return nil, &utils.ValidationError{Field: "annual_rate", Message: "annual_rate or overdraft_rate is required"}
+	}
+	if req.AnnualRate != "" {
+		if err := utils.ValidateInterestRate(req.AnnualRate); err != nil {
+			return nil, err
+		}
+	}
+	if req.OverdraftRate != "" {
+		if err := utils.ValidateInterestRate(req.OverdraftRate); err != nil {
+			return nil, &utils.ValidationError{Field: "overdraft_rate", Message: "overdraft_rate must be between 0 and 100 with at most 6 decimal places"}
+		}
+	}
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		product, err := s.productRepo.GetByCode(ctx, code)
+		if err != nil {
+			return err
+		}
+		annualRate, overdraftRate := product.AnnualRate, product.OverdraftRate
+		if req.AnnualRate != "" {
+			annualRate = normalizeDecimal(req.AnnualRate, rateScale)
+		}
+		if req.OverdraftRate != "" {
+			overdraftRate = normalizeDecimal(req.OverdraftRate, rateScale)
+		}
+		return s.productRepo.UpdateRates(ctx, code, annualRate, overdraftRate)
+	})
+	if err != nil {
+		return nil, notFoundOrInternal(err, "product_not_found", "product not found")
+	}
+	product, err := s.productRepo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, wrapInternal("failed to fetch updated product", err)
+	}
+	return product, nil
+}
+
+// ChangeProduct moves an account onto another product. Interest already
+// accrued stays and is paid out with the next capitalization.
+func (s *InterestService) ChangeProduct(ctx context.Context, accountID int, code string) error {
+	ctx, span := tracing.Start(ctx, "InterestService.ChangeProduct")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateRequired(code, "product"); err != nil {
+		return err
+	}
+	if _, err := s.productRepo.GetByCode(ctx, code); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return &utils.ValidationError{Field: "product", Message: "unknown product"}
+		}
+		return wrapInternal("failed to change product", err)
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if account.Status != models.AccountStatusActice {
+		return accountInactive(account.Status)
+	}
+
+	if err := s.accountRepo.SetProduct(ctx, accountID, code); err != nil {
+		return notFoundOrInternal(err, "account_not_found", "failed to change product")
+	}
+	return nil
+}
+
+// ListAccruals returns the account's daily accruals dated in [from, to)
+func (s *InterestService) ListAccruals(ctx context.Context, accountID int, from, to time.Time) ([]*models.InterestAccrual, error) {
+	if !from.Before(to) {
+		return nil, &utils.ValidationError{Field: "from", Message: "from must be before to"}
+	}
+	accruals, err := s.interestRepo.ListAccruals(ctx, accountID, from, to)
+	if err != nil {
+		return nil, wrapInternal("failed to list accruals", err)
+	}
+	return accruals, nil
+}
+
+// RunDaily accrues interest for every day up to yesterday and pays out
+// everything accrued before the current month. Both steps are idempotent,
+// so running it more than once a day, or after downtime, is safe.
+func (s *InterestService) RunDaily(ctx context.Context, now time.Time) (accrued, capitalized int, err error) {
+	today := startOfDay(now)
+	accrued, accrueErr := s.AccrueThrough(ctx, today.AddDate(0, 0, -1))
+	capitalized, capitalizeErr := s.Capitalize(ctx, time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC))
+	return accrued, capitalized, errors.Join(accrueErr, capitalizeErr)
+}
+
+// AccrueThrough records interest for each interest-bearing account for every
+// day it has not accrued yet, up to and including through. It returns how
+// many daily accruals were recorded.
+func (s *InterestService) AccrueThrough(ctx context.Context, through time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "InterestService.AccrueThrough")
+	defer span.End()
+
+	through = startOfDay(through)
+	products, err := s.productRepo.List(ctx)
+	if err != nil {
+		return 0, wrapInternal("failed to list products", err)
+	}
+
+	total := 0
+	var errs []error
+	for _, product := range products {
+		rate, err := utils.ParseDecimal(product.AnnualRate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s: %w", product.Code, err))
+			continue
+		}
+		overdraftRate, err := utils.ParseDecimal(product.OverdraftRate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s: %w", product.Code, err))
+			continue
+		}
+		if rate.Sign() == 0 && overdraftRate.Sign() == 0 {
+			continue
+		}
+
+		accountIDs, err := s.accountRepo.ListIDsByProduct(ctx, product.Code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %s: %w", product.Code, err))
+			continue
+		}
+		for _, accountID := range accountIDs {
+			count, err := s.accrueAccount(ctx, accountID, product, through)
+			total += count
+			if err != nil {
+				errs = append(errs, fmt.Errorf("account %d: %w", accountID, err))
+			}
+		}
+	}
+
+	span.SetAttribute("interest.accrued", total)
+	if len(errs) > 0 {
+		return total, wrapInternal("interest accrual failed", errors.Join(errs...))
+	}
+	return total, nil
+}
+
+// accrueAccount accrues one account's outstanding days. Accounts that
+// neither earn interest nor can go overdrawn are skipped.
+func (s *InterestService) accrueAccount(ctx context.Context, accountID int, product *models.Product, through time.Time) (int, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if decimalEqual(product.AnnualRate, "0") && (decimalEqual(product.OverdraftRate, "0") || account.OverdraftLimit == 0) {
+		return 0, nil
+	}
+	start := startOfDay(account.CreatedAt)
+	last, err := s.interestRepo.LastAccrualDate(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if last != nil {
+		start = startOfDay(*last).AddDate(0, 0, 1)
+	}
+
+	count := 0
+	for day := start; !day.After(through); day = day.AddDate(0, 0, 1) {
+		accrual, err := s.computeAccrual(ctx, accountID, day, product.AnnualRate, product.OverdraftRate)
+		if err != nil {
+			return count, err
+		}
+		_, err = s.interestRepo.CreateAccrual(ctx, accrual)
+		// Another instance of the job got there first
+		if errors.Is(err, repository.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// computeAccrual works out one day's interest from the ledger. It only reads
+// completed transactions, so the same day always gives the same answer. An
+// overdrawn balance is charged overdraftRate and accrues a negative amount.
+func (s *InterestService) computeAccrual(ctx context.Context, accountID int, day time.Time, annualRate, overdraftRate string) (*models.InterestAccrual, error) {
+	balance, err := s.transactionRepo.GetBalanceAt(ctx, accountID, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	exact := utils.DecimalFromAmount(balance)
+	rate := annualRate
+	amount, err := dailyInterest(exact, annualRate)
+	if exact.Sign() < 0 {
+		rate = overdraftRate
+		amount, err = dailyInterest(new(big.Rat).Neg(exact), overdraftRate)
+		if err == nil {
+			amount.Neg(amount)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.InterestAccrual{
+		AccountID:   accountID,
+		AccrualDate: day,
+		Balance:     utils.FormatDecimal(exact, 2),
+		AnnualRate:  rate,
+		Amount:      utils.FormatDecimal(amount, accrualScale),
+	}, nil
+}
+
+// dailyInterest is balance * rate% / 365. A balance at or below zero earns
+// nothing.
+func dailyInterest(balance *big.Rat, annualRate string) (*big.Rat, error) {
+	rate, err := utils.ParseDecimal(annualRate)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() <= 0 {
+		return new(big.Rat), nil
+	}
+	amount := new(big.Rat).Mul(balance, rate)
+	return amount.Quo(amount, big.NewRat(100*daysPerYear, 1)), nil
+}
+
+// Capitalize pays out every accrual dated before before, one interest
+// transaction per account. It returns how many accounts were paid.
+func (s *InterestService) Capitalize(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "InterestService.Capitalize")
+	defer span.End()
+
+	accountIDs, err := s.interestRepo.AccountsWithUncapitalized(ctx, startOfDay(before))
+	if err != nil {
+		return 0, wrapInternal("failed to list accounts to capitalize", err)
+	}
+
+	paid := 0
+	var errs []error
+	for _, accountID := range accountIDs {
+		ok, err := s.capitalizeAccount(ctx, accountID, startOfDay(before))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", accountID, err))
+			continue
+		}
+		if ok {
+			paid++
+		}
+	}
+
+	span.SetAttribute("interest.capitalized_accounts", paid)
+	if len(errs) > 0 {
+		return paid, wrapInternal("interest capitalization failed", errors.Join(errs...))
+	}
+	return paid, nil
+}
+
+// capitalizeAccount posts the account's outstanding accruals: interest
+// earned as one interest transaction in, overdraft interest as one out. Each
+// exact total is rounded to the cent once, here, so no interest is lost to
+// rounding each day. Overdraft interest never takes the account past its
+// limit.
+func (s *InterestService) capitalizeAccount(ctx context.Context, accountID int, before time.Time) (bool, error) {
+	paid := false
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		// Locking the account first serializes concurrent runs of the job
+		balance, overdraftLimit, err := s.accountRepo.GetFundsForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		// A closed account was settled when it closed, and a suspended one
+		// waits until it is reactivated
+		account, err := s.accountRepo.GetByID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Status != models.AccountStatusActice {
+			return nil
+		}
+		paid, err = s.postAccruals(ctx, accountID, balance, overdraftLimit, before)
+		return err
+	})
+	return paid, err
+}
+
+// settleAccount posts every outstanding accrual, up to and including today's,
+// of an account that is closing. It runs in the caller's transaction, which
+// has locked the account.
+func (s *InterestService) settleAccount(ctx context.Context, accountID int, now time.Time) error {
+	balance, overdraftLimit, err := s.accountRepo.GetFundsForUpdate(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	_, err = s.postAccruals(ctx, accountID, balance, overdraftLimit, startOfDay(now).AddDate(0, 0, 1))
+	return err
+}
+
+// postAccruals does the work of capitalizeAccount once the account is
+// locked and reports whether anything was posted
+func (s *InterestService) postAccruals(ctx context.Context, accountID int, balance, overdraftLimit float64, before time.Time) (bool, error) {
+	accruals, err := s.interestRepo.ListUncapitalized(ctx, accountID, before)
+	if err != nil || len(accruals) == 0 {
+		return false, err
+	}
+
+	earned, charged := new(big.Rat), new(big.Rat)
+	var earnedIDs, chargedIDs []int
+	for _, accrual := range accruals {
+		amount, err := utils.ParseDecimal(accrual.Amount)
+		if err != nil {
+			return false, err
+		}
+		if amount.Sign() < 0 {
+			charged.Sub(charged, amount)
+			chargedIDs = append(chargedIDs, accrual.ID)
+		} else {
+			earned.Add(earned, amount)
+			earnedIDs = append(earnedIDs, accrual.ID)
+		}
+	}
+	last := accruals[len(accruals)-1].AccrualDate.Format("2 January 2006")
+
+	paid := false
+	if len(earnedIDs) > 0 {
+		var transactionID *int
+		if amount := amountFromDecimal(earned); amount > 0 {
+			transaction, err := s.transactionRepo.Create(ctx, nil, &accountID, amount, models.TransactionTypeInterest, "Interest to "+last)
+			if err != nil {
+				return false, err
+			}
+			balance = sumAmounts(balance, amount)
+			transactionID = &transaction.ID
+			paid = true
+		}
+		if err := s.interestRepo.MarkCapitalized(ctx, earnedIDs, transactionID, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	if len(chargedIDs) > 0 {
+		var transactionID *int
+		amount := min(amountFromDecimal(charged), max(models.AvailableBalance(balance, overdraftLimit), 0))
+		if amount > 0 {
+			transaction, err := s.transactionRepo.Create(ctx, &accountID, nil, amount, models.TransactionTypeInterest, "Overdraft interest to "+last)
+			if err != nil {
+				return false, err
+			}
+			balance = sumAmounts(balance, -amount)
+			transactionID = &transaction.ID
+			paid = true
+		}
+		if err := s.interestRepo.MarkCapitalized(ctx, chargedIDs, transactionID, time.Now()); err != nil {
+			return false, err
+		}
+	}
+
+	if !paid {
+		return false, nil
+	}
+	return true, s.accountRepo.UpdateBalance(ctx, accountID, balance)
+}
+
+// Recompute re-derives each accrual dated in [from, to) from the ledger and
+// the rate stored with it, for audit. A mismatch means the ledger changed
+// after the day was accrued or the accrual was tampered with.
+func (s *InterestService) Recompute(ctx context.Context, accountID int, from, to time.Time) ([]*models.InterestAudit, error) {
+	ctx, span := tracing.Start(ctx, "InterestService.Recompute")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	accruals, err := s.ListAccruals(ctx, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	audits := make([]*models.InterestAudit, 0, len(accruals))
+	for _, accrual := range accruals {
+		recomputed, err := s.computeAccrual(ctx, accountID, startOfDay(accrual.AccrualDate), accrual.AnnualRate, accrual.AnnualRate)
+		if err != nil {
+			return nil, wrapInternal("failed to recompute interest", err)
+		}
+		audits = append(audits, &models.InterestAudit{
+			Date:              accrual.AccrualDate.Format(time.DateOnly),
+			AnnualRate:        accrual.AnnualRate,
+			Balance:           accrual.Balance,
+			Recorded:          accrual.Amount,
+			Recomputed:        recomputed.Amount,
+			RecomputedBalance: recomputed.Balance,
+			Matches:           decimalEqual(accrual.Amount, recomputed.Amount) && decimalEqual(accrual.Balance, recomputed.Balance),
+		})
+	}
+	return audits, nil
+}
+
+func decimalEqual(a, b string) bool {
+	x, errA := utils.ParseDecimal(a)
+	y, errB := utils.ParseDecimal(b)
+	return errA == nil && errB == nil && x.Cmp(y) == 0
+}
+
+// startOfDay truncates t to midnight UTC; accrual days are UTC days
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
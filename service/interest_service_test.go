@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestInterestService(t *testing.T) (*InterestService, *TransactionService, *memory.Store) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	interest := NewInterestService(store, store.Accounts(), store.Products(), store.Interest(), store.Transactions())
+	return interest, transactions, store
+}
+
+func TestDailyInterest(t *testing.T) {
+	tests := []struct {
+		balance float64
+		rate    string
+		want    string
+	}{
+		{1000, "2.500000", "0.0684931507"},
+		{365, "1", "0.0100000000"},
+		{0.01, "2.5", "0.0000006849"},
+		{0, "2.5", "0.0000000000"},
+		{-50, "2.5", "0.0000000000"},
+	}
+
+	for _, tt := range tests {
+		amount, err := dailyInterest(utils.DecimalFromAmount(tt.balance), tt.rate)
+		if err != nil {
+			t.Fatalf("dailyInterest(%v, %s): %v", tt.balance, tt.rate, err)
+		}
+		if got := utils.FormatDecimal(amount, accrualScale); got != tt.want {
+			t.Errorf("dailyInterest(%v, %s) = %s, want %s", tt.balance, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestAccrueAndCapitalizeInterest(t *testing.T) {
+	ctx := context.Background()
+	interest, transactions, store := newTestInterestService(t)
+
+	saver := createFundedAccount(t, store, transactions, "saver@example.com", 1000)
+	spender := createFundedAccount(t, store, transactions, "spender@example.com", 1000)
+	if err := interest.ChangeProduct(ctx, saver.ID, models.ProductSavings); err != nil {
+		t.Fatalf("ChangeProduct: %v", err)
+	}
+
+	today := startOfDay(time.Now())
+	through := today.AddDate(0, 0, 30)
+
+	accrued, err := interest.AccrueThrough(ctx, through)
+	if err != nil {
+		t.Fatalf("AccrueThrough: %v", err)
+	}
+	if accrued != 31 {
+		t.Fatalf("accrued %d days, want 31", accrued)
+	}
+	if again, err := interest.AccrueThrough(ctx, through); err != nil || again != 0 {
+		t.Fatalf("second AccrueThrough = %d, %v; want 0, nil", again, err)
+	}
+	if accruals, _ := interest.ListAccruals(ctx, spender.ID, today, through.AddDate(0, 0, 1)); len(accruals) != 0 {
+		t.Errorf("checking account accrued %d days of interest", len(accruals))
+	}
+
+	// 31 days at 0.0684931507 is 2.1232876717, paid as 2.12
+	paid, err := interest.Capitalize(ctx, through.AddDate(0, 0, 1))
+	if err != nil || paid != 1 {
+		t.Fatalf("Capitalize = %d, %v; want 1, nil", paid, err)
+	}
+	account, _ := store.Accounts().GetByID(ctx, saver.ID)
+	if account.Balance != 1002.12 {
+		t.Errorf("balance after capitalization = %.2f, want 1002.12", account.Balance)
+	}
+	if paid, _ := interest.Capitalize(ctx, through.AddDate(0, 0, 1)); paid != 0 {
+		t.Errorf("accruals were capitalized twice")
+	}
+
+	page, err := transactions.ListTransactions(ctx, saver.ID, models.TransactionFilter{Type: models.TransactionTypeInterest}, "", 10)
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Amount != 2.12 {
+		t.Fatalf("interest transactions = %+v, want one of 2.12", page.Data)
+	}
+
+	accruals, _ := interest.ListAccruals(ctx, saver.ID, today, through.AddDate(0, 0, 1))
+	for _, accrual := range accruals {
+		if accrual.TransactionID == nil || *accrual.TransactionID != page.Data[0].ID {
+			t.Fatalf("accrual for %s not linked to the interest transaction", accrual.AccrualDate.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestRecomputeInterestIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	interest, transactions, store := newTestInterestService(t)
+
+	saver := createFundedAccount(t, store, transactions, "saver@example.com", 500)
+	if err := interest.ChangeProduct(ctx, saver.ID, models.ProductSavings); err != nil {
+		t.Fatalf("ChangeProduct: %v", err)
+	}
+	today := startOfDay(time.Now())
+	if _, err := interest.AccrueThrough(ctx, today.AddDate(0, 0, 2)); err != nil {
+		t.Fatalf("AccrueThrough: %v", err)
+	}
+
+	// A later rate change must not alter the audit of days already accrued
+	if _, err := interest.UpdateProduct(ctx, models.ProductSavings, &models.UpdateProductRequest{AnnualRate: "5"}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	audits, err := interest.Recompute(ctx, saver.ID, today, today.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("Recompute: %v", err)
+	}
+	if len(audits) != 3 {
+		t.Fatalf("got %d audits, want 3", len(audits))
+	}
+	for _, audit := range audits {
+		if !audit.Matches || audit.AnnualRate != "2.500000" {
+			t.Errorf("audit for %s = %+v, want a match at 2.5%%", audit.Date, audit)
+		}
+	}
+
+	// Money booked after the days were accrued changes their ledger balance
+	if _, err := transactions.Deposit(ctx, saver.ID, &models.DepositRequest{Amount: 100}); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	audits, _ = interest.Recompute(ctx, saver.ID, today, today.AddDate(0, 0, 3))
+	for _, audit := range audits {
+		if audit.Matches || audit.RecomputedBalance != "600.00" {
+			t.Errorf("audit for %s = %+v, want a mismatch against 600.00", audit.Date, audit)
+		}
+	}
+}
+
+func TestUpdateProductRateValidation(t *testing.T) {
+	ctx := context.Background()
+	interest, _, _ := newTestInterestService(t)
+
+	product, err := interest.UpdateProduct(ctx, models.ProductSavings, &models.UpdateProductRequest{AnnualRate: "3.25"})
+	if err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if product.AnnualRate != "3.250000" {
+		t.Errorf("AnnualRate = %q, want 3.250000", product.AnnualRate)
+	}
+
+	for _, rate := range []string{"", "-1", "101", "2.1234567", "abc"} {
+		var validationErr *utils.ValidationError
+		if _, err := interest.UpdateProduct(ctx, models.ProductSavings, &models.UpdateProductRequest{AnnualRate: rate}); !errors.As(err, &validationErr) {
+			t.Errorf("UpdateProduct(%q) err = %v, want validation error", rate, err)
+		}
+	}
+	if _, err := interest.UpdateProduct(ctx, "gold", &models.UpdateProductRequest{AnnualRate: "1"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown product err = %v, want not found", err)
+	}
+}
+
+func TestOverdraftInterest(t *testing.T) {
+	ctx := context.Background()
+	interest, transactions, store := newTestInterestService(t)
+	auth := NewAuthService(store, store.Accounts(), store.Sessions(), time.Hour, utils.IBANFormat{}, &notifications.MemoryNotifier{}, newTestVerificationService(store))
+
+	borrower := createFundedAccount(t, store, transactions, "borrower@example.com", 0)
+	if _, err := auth.SetOverdraftLimit(ctx, borrower.ID, 1000); err != nil {
+		t.Fatalf("SetOverdraftLimit: %v", err)
+	}
+	if _, err := transactions.WithDraw(ctx, borrower.ID, &models.WitdrawRequest{Amount: 365}); err != nil {
+		t.Fatalf("WithDraw: %v", err)
+	}
+	if _, err := interest.UpdateProduct(ctx, models.ProductChecking, &models.UpdateProductRequest{OverdraftRate: "10"}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+
+	// -365 at 10% is -0.10 a day
+	today := startOfDay(time.Now())
+	through := today.AddDate(0, 0, 9)
+	if accrued, err := interest.AccrueThrough(ctx, through); err != nil || accrued != 10 {
+		t.Fatalf("AccrueThrough = %d, %v; want 10, nil", accrued, err)
+	}
+	accruals, _ := interest.ListAccruals(ctx, borrower.ID, today, through.AddDate(0, 0, 1))
+	if len(accruals) != 10 || accruals[0].Amount != "-0.1000000000" || accruals[0].AnnualRate != "10.000000" {
+		t.Fatalf("accruals = %+v, want ten of -0.10 at 10%%", accruals)
+	}
+
+	audits, err := interest.Recompute(ctx, borrower.ID, today, through.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Recompute: %v", err)
+	}
+	if len(audits) != 10 || !audits[0].Matches {
+		t.Errorf("audits = %v, want ten matching days", audits)
+	}
+
+	if paid, err := interest.Capitalize(ctx, through.AddDate(0, 0, 1)); err != nil || paid != 1 {
+		t.Fatalf("Capitalize = %d, %v; want 1, nil", paid, err)
+	}
+	account, _ := store.Accounts().GetByID(ctx, borrower.ID)
+	if account.Balance != -366 {
+		t.Errorf("balance after overdraft interest = %.2f, want -366.00", account.Balance)
+	}
+}
+
+func TestCapitalizeSkipsInactiveAccounts(t *testing.T) {
+	ctx := context.Background()
+	interest, transactions, store := newTestInterestService(t)
+
+	accounts := make([]*models.Account, 0, 2)
+	for _, email := range []string{"closed@example.com", "suspended@example.com"} {
+		account := createFundedAccount(t, store, transactions, email, 1000)
+		if err := interest.ChangeProduct(ctx, account.ID, models.ProductSavings); err != nil {
+			t.Fatalf("ChangeProduct: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+	today := startOfDay(time.Now())
+	if _, err := interest.AccrueThrough(ctx, today); err != nil {
+		t.Fatalf("AccrueThrough: %v", err)
+	}
+	if err := store.Accounts().Close(ctx, accounts[0].ID); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := store.Accounts().SetStatus(ctx, accounts[1].ID, models.AccountStatusSuspended); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+
+	if paid, err := interest.Capitalize(ctx, today.AddDate(0, 0, 1)); err != nil || paid != 0 {
+		t.Fatalf("Capitalize = %d, %v; want 0, nil", paid, err)
+	}
+	for _, account := range accounts {
+		stored, _ := store.Accounts().GetByID(ctx, account.ID)
+		if stored.Balance != 1000 {
+			t.Errorf("%s balance = %.2f, want 1000.00", stored.Email, stored.Balance)
+		}
+	}
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// MaxKYCDocumentBytes bounds the size of one uploaded document
+	MaxKYCDocumentBytes = 10 << 20
+	maxKYCDocuments     = 10
+	maxKYCReviewList    = 100
+	minKYCAge           = 18
+)
+
+// kycContentTypes are the document formats accepted, as sniffed from the
+// file's contents rather than trusted from the upload
+var kycContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// KYCService runs identity checks. Holders fill in their details, upload
+// documents and submit; a reviewer then approves the profile at a limits
+// tier or rejects it with a reason. Details and documents are locked while
+// a profile is submitted or approved.
+type KYCService struct {
+	db          repository.TxRunner
+	accountRepo repository.AccountStore
+	kycRepo     repository.KYCStore
+	storage     storage.Storage
+	notifier    notifications.Notifier
+}
+
+func NewKYCService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	kycRepo repository.KYCStore,
+	storage storage.Storage,
+	notifier notifications.Notifier,
+) *KYCService {
+	return &KYCService{
+		db:          database,
+		accountRepo: accountRepo,
+		kycRepo:     kycRepo,
+		storage:     storage,
+		notifier:    notifier,
+	}
+}
+
+// Get returns the holder's profile. An account that has not started KYC
+// gets an unverified profile.
+func (s *KYCService) Get(ctx context.Context, accountID int) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.Get")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	profile, err := s.profile(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to get kyc profile", err)
+	}
+	return s.response(ctx, profile)
+}
+
+// UpdateDetails sets the holder's personal details
+func (s *KYCService) UpdateDetails(ctx context.Context, accountID int, req *models.UpdateKYCDetailsRequest) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.UpdateDetails")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	details, err := kycDetails(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var profile *models.KYCProfile
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		profile, err = s.editableProfile(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		profile.DateOfBirth = details.DateOfBirth
+		profile.Nationality = details.Nationality
+		profile.AddressLine1 = details.AddressLine1
+		profile.AddressLine2 = details.AddressLine2
+		profile.City = details.City
+		profile.PostalCode = details.PostalCode
+		profile.Country = details.Country
+		return s.kycRepo.SaveProfile(ctx, profile)
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to update kyc details", err)
+	}
+	return s.response(ctx, profile)
+}
+
+// UploadDocument stores a document for the holder's next submission. The
+// file is checked to be a JPEG, PNG or PDF of at most MaxKYCDocumentBytes.
+func (s *KYCService) UploadDocument(ctx context.Context, accountID int, docType, fileName string, file io.Reader) (*models.KYCDocument, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.UploadDocument")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if !validKYCDocumentType(docType) {
+		return nil, &utils.ValidationError{Field: "type", Message: "type must be one of passport, driving_licence, national_id, proof_of_address"}
+	}
+	fileName = filepath.Base(utils.SanitizeString(fileName))
+	if fileName == "." || fileName == string(filepath.Separator) || len(fileName) > 255 {
+		return nil, &utils.ValidationError{Field: "file", Message: "file must have a name of at most 255 characters"}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxKYCDocumentBytes+1))
+	if err != nil {
+		return nil, wrapInternal("failed to upload document", err)
+	}
+	if len(data) == 0 {
+		return nil, &utils.ValidationError{Field: "file", Message: "file is empty"}
+	}
+	if len(data) > MaxKYCDocumentBytes {
+		return nil, Validation("file_too_large", fmt.Sprintf("documents can be at most %d MB", MaxKYCDocumentBytes>>20))
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !kycContentTypes[contentType] {
+		return nil, Validation("unsupported_file_type", "documents must be JPEG, PNG or PDF files")
+	}
+
+	// The file is stored first so the database never points at a missing
+	// one; it is removed again if the document is not recorded
+	key := fmt.Sprintf("kyc/%d/%s", accountID, rand.Text())
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return nil, wrapInternal("failed to upload document", err)
+	}
+
+	sum := sha256.Sum256(data)
+	var document *models.KYCDocument
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.editableProfile(ctx, accountID); err != nil {
+			return err
+		}
+		documents, err := s.kycRepo.ListDocuments(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if len(documents) >= maxKYCDocuments {
+			return Conflict("document_limit", fmt.Sprintf("an account can upload at most %d documents", maxKYCDocuments))
+		}
+		document, err = s.kycRepo.AddDocument(ctx, &models.KYCDocument{
+			AccountID:   accountID,
+			Type:        docType,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			SHA256:      hex.EncodeToString(sum[:]),
+			StorageKey:  key,
+		})
+		return err
+	})
+	if err != nil {
+		if deleteErr := s.storage.Delete(ctx, key); deleteErr != nil {
+			log.Printf("failed to remove unrecorded document %s: %v", key, deleteErr)
+		}
+		return nil, wrapInternal("failed to upload document", err)
+	}
+	return document, nil
+}
+
+// Submit sends the holder's details and documents for review. It needs the
+// personal details and at least one identity document.
+func (s *KYCService) Submit(ctx context.Context, accountID int) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.Submit")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	var profile *models.KYCProfile
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		profile, err = s.editableProfile(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if profile.DateOfBirth == nil {
+			return Validation("kyc_details_missing", "add your personal details before submitting")
+		}
+		documents, err := s.kycRepo.ListDocuments(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if !hasIdentityDocument(documents) {
+			return Validation("identity_document_missing", "upload a passport, driving licence or national ID before submitting")
+		}
+
+		now := time.Now()
+		profile.Status = models.KYCSubmitted
+		profile.RejectionReason = ""
+		profile.SubmittedAt = &now
+		profile.ReviewedAt = nil
+		profile.ReviewedBy = nil
+		return s.kycRepo.SaveProfile(ctx, profile)
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to submit kyc", err)
+	}
+	return s.response(ctx, profile)
+}
+
+// ListForReview returns profiles in a status, oldest submission first, with
+// their account details. The status defaults to submitted.
+func (s *KYCService) ListForReview(ctx context.Context, status string) ([]*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.ListForReview")
+	defer span.End()
+
+	if status == "" {
+		status = models.KYCSubmitted
+	}
+	switch status {
+	case models.KYCSubmitted, models.KYCApproved, models.KYCRejected:
+	default:
+		return nil, &utils.ValidationError{Field: "status", Message: "status must be one of submitted, approved, rejected"}
+	}
+
+	profiles, err := s.kycRepo.ListByStatus(ctx, status, maxKYCReviewList)
+	if err != nil {
+		return nil, wrapInternal("failed to list kyc profiles", err)
+	}
+	responses := make([]*models.KYCResponse, len(profiles))
+	for i, profile := range profiles {
+		if responses[i], err = s.reviewResponse(ctx, profile); err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// GetForReview returns an account's profile with its account details
+func (s *KYCService) GetForReview(ctx context.Context, accountID int) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.GetForReview")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	profile, err := s.profile(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to get kyc profile", err)
+	}
+	return s.reviewResponse(ctx, profile)
+}
+
+// OpenDocument returns one of an account's documents and its contents; the
+// caller closes the reader
+func (s *KYCService) OpenDocument(ctx context.Context, accountID, documentID int) (*models.KYCDocument, io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.OpenDocument")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	document, err := s.kycRepo.GetDocument(ctx, documentID)
+	if err != nil {
+		return nil, nil, notFoundOrInternal(err, "document_not_found", "document not found")
+	}
+	if document.AccountID != accountID {
+		return nil, nil, NotFound("document_not_found", "document not found")
+	}
+	file, err := s.storage.Open(ctx, document.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, NotFound("document_not_found", "document file is missing")
+	}
+	if err != nil {
+		return nil, nil, wrapInternal("failed to open document", err)
+	}
+	return document, file, nil
+}
+
+// Approve approves a submitted profile at level, raising the account's
+// limits to that tier
+func (s *KYCService) Approve(ctx context.Context, reviewerID, accountID, level int) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.Approve")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if level < 1 || level > models.MaxKYCLevel {
+		return nil, &utils.ValidationError{Field: "level", Message: fmt.Sprintf("level must be between 1 and %d", models.MaxKYCLevel)}
+	}
+
+	profile, err := s.review(ctx, reviewerID, accountID, func(profile *models.KYCProfile) {
+		profile.Status = models.KYCApproved
+		profile.Level = level
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to approve kyc", err)
+	}
+
+	sendNotification(ctx, s.notifier, accountID, notifications.EventKYCApproved, map[string]any{
+		"level": level,
+	})
+	return s.reviewResponse(ctx, profile)
+}
+
+// Reject returns a submitted profile to the holder with the reason, so they
+// can correct it and submit again
+func (s *KYCService) Reject(ctx context.Context, reviewerID, accountID int, reason string) (*models.KYCResponse, error) {
+	ctx, span := tracing.Start(ctx, "KYCService.Reject")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	reason = utils.SanitizeString(reason)
+	if err := utils.ValidateRequired(reason, "reason"); err != nil {
+		return nil, err
+	}
+	if len(reason) > 500 {
+		return nil, &utils.ValidationError{Field: "reason", Message: "reason must be at most 500 characters"}
+	}
+
+	profile, err := s.review(ctx, reviewerID, accountID, func(profile *models.KYCProfile) {
+		profile.Status = models.KYCRejected
+		profile.Level = 0
+		profile.RejectionReason = reason
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to reject kyc", err)
+	}
+
+	sendNotification(ctx, s.notifier, accountID, notifications.EventKYCRejected, map[string]any{
+		"reason": reason,
+	})
+	return s.reviewResponse(ctx, profile)
+}
+
+// review applies a reviewer's decision to a submitted profile
+func (s *KYCService) review(ctx context.Context, reviewerID, accountID int, decide func(*models.KYCProfile)) (*models.KYCProfile, error) {
+	if reviewerID == accountID {
+		return nil, Forbidden("self_review", "you cannot review your own identity check")
+	}
+
+	var profile *models.KYCProfile
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID); err != nil {
+			return notFoundOrInternal(err, "account_not_found", "account not found")
+		}
+		var err error
+		profile, err = s.profile(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if profile.Status != models.KYCSubmitted {
+			return Conflict("kyc_not_submitted", fmt.Sprintf("kyc is %s, not awaiting review", profile.Status))
+		}
+
+		now := time.Now()
+		decide(profile)
+		profile.ReviewedAt = &now
+		profile.ReviewedBy = &reviewerID
+		return s.kycRepo.SaveProfile(ctx, profile)
+	})
+	return profile, err
+}
+
+// editableProfile locks the account and returns its profile, refusing one
+// that is awaiting review or already approved
+func (s *KYCService) editableProfile(ctx context.Context, accountID int) (*models.KYCProfile, error) {
+	if _, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID); err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	profile, err := s.profile(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Status == models.KYCSubmitted || profile.Status == models.KYCApproved {
+		return nil, Conflict("kyc_locked", fmt.Sprintf("kyc is %s and can no longer be changed", profile.Status))
+	}
+	return profile, nil
+}
+
+// profile returns the account's profile, or a new unverified one
+func (s *KYCService) profile(ctx context.Context, accountID int) (*models.KYCProfile, error) {
+	profile, err := s.kycRepo.GetProfile(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.NewKYCProfile(accountID), nil
+	}
+	return profile, err
+}
+
+func (s *KYCService) response(ctx context.Context, profile *models.KYCProfile) (*models.KYCResponse, error) {
+	documents, err := s.kycRepo.ListDocuments(ctx, profile.AccountID)
+	if err != nil {
+		return nil, wrapInternal("failed to list kyc documents", err)
+	}
+	return profile.ToResponse(documents), nil
+}
+
+func (s *KYCService) reviewResponse(ctx context.Context, profile *models.KYCProfile) (*models.KYCResponse, error) {
+	account, err := s.accountRepo.GetByID(ctx, profile.AccountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	response, err := s.response(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+	response.AccountNumber = account.AccountNumber
+	response.FirstName = account.FirstName
+	response.LastName = account.LastName
+	return response, nil
+}
+
+// kycDetails validates and normalises personal details. The holder must be
+// at least minKYCAge on now.
+func kycDetails(req *models.UpdateKYCDetailsRequest, now time.Time) (*models.KYCProfile, error) {
+	dateOfBirth, err := time.Parse(time.DateOnly, strings.TrimSpace(req.DateOfBirth))
+	if err != nil {
+		return nil, &utils.ValidationError{Field: "date_of_birth", Message: "date_of_birth must be a date in YYYY-MM-DD format"}
+	}
+	if dateOfBirth.AddDate(minKYCAge, 0, 0).After(now) {
+		return nil, &utils.ValidationError{Field: "date_of_birth", Message: fmt.Sprintf("you must be at least %d", minKYCAge)}
+	}
+	if dateOfBirth.Year() < 1900 {
+		return nil, &utils.ValidationError{Field: "date_of_birth", Message: "date_of_birth is not a valid date of birth"}
+	}
+
+	details := &models.KYCProfile{
+		DateOfBirth:  &dateOfBirth,
+		Nationality:  strings.ToUpper(strings.TrimSpace(req.Nationality)),
+		AddressLine1: utils.SanitizeString(req.AddressLine1),
+		AddressLine2: utils.SanitizeString(req.AddressLine2),
+		City:         utils.SanitizeString(req.City),
+		PostalCode:   strings.ToUpper(utils.SanitizeString(req.PostalCode)),
+		Country:      strings.ToUpper(strings.TrimSpace(req.Country)),
+	}
+	if err := utils.ValidateCountryCode(details.Nationality, "nationality"); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateCountryCode(details.Country, "country"); err != nil {
+		return nil, err
+	}
+
+	fields := []struct {
+		name     string
+		value    string
+		required bool
+		max      int
+	}{
+		{"address_line1", details.AddressLine1, true, 100},
+		{"address_line2", details.AddressLine2, false, 100},
+		{"city", details.City, true, 100},
+		{"postal_code", details.PostalCode, true, 20},
+	}
+	for _, field := range fields {
+		if field.required {
+			if err := utils.ValidateRequired(field.value, field.name); err != nil {
+				return nil, err
+			}
+		}
+		if len(field.value) > field.max {
+			return nil, &utils.ValidationError{Field: field.name, Message: fmt.Sprintf("%s must be at most %d characters", field.name, field.max)}
+		}
+	}
+	return details, nil
+}
+
+func validKYCDocumentType(docType string) bool {
+	switch docType {
+	case models.KYCDocumentPassport, models.KYCDocumentDrivingLicence, models.KYCDocumentNationalID, models.KYCDocumentProofOfAddress:
+		return true
+	}
+	return false
+}
+
+func hasIdentityDocument(documents []*models.KYCDocument) bool {
+	for _, document := range documents {
+		if document.Type != models.KYCDocumentProofOfAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKYCLimits refuses to send amount from an account when it would break
+// the limits of the account's KYC level: the amount on its own, or the total
+// sent over the last 24 hours with it. Call it with the account locked so
+// concurrent payments can't both pass.
+func checkKYCLimits(ctx context.Context, kycRepo repository.KYCStore, transactionRepo repository.TransactionStore, accountID int, amount float64) error {
+	level := 0
+	profile, err := kycRepo.GetProfile(ctx, accountID)
+	if err == nil {
+		level = profile.Level
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	limits := models.KYCLimitsFor(level)
+
+	if amount > limits.PerTransaction {
+		return Forbidden("kyc_limit_exceeded", fmt.Sprintf("payments above %.2f need a higher verification level", limits.PerTransaction))
+	}
+	sent, err := transactionRepo.SumSentSince(ctx, accountID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if sumAmounts(sent, amount) > limits.Daily {
+		return Forbidden("kyc_limit_exceeded", fmt.Sprintf("this would take you over your daily limit of %.2f; verify your identity to raise it", limits.Daily))
+	}
+	return nil
+}
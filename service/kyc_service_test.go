@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/storage"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+var (
+	testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+	testPDF = []byte("%PDF-1.4\n%test document\n")
+)
+
+func newTestKYCService(t *testing.T) (*KYCService, *memory.Store, *notifications.MemoryNotifier) {
+	t.Helper()
+	store := memory.NewStore()
+	notifier := &notifications.MemoryNotifier{}
+	return NewKYCService(store, store.Accounts(), store.KYC(), &storage.MemoryStorage{}, notifier), store, notifier
+}
+
+func testKYCDetails() *models.UpdateKYCDetailsRequest {
+	return &models.UpdateKYCDetailsRequest{
+		DateOfBirth:  "1990-04-12",
+		Nationality:  "gb",
+		AddressLine1: "1  High Street",
+		City:         "London",
+		PostalCode:   "sw1a 1aa",
+		Country:      "GB",
+	}
+}
+
+// submitTestKYC fills in details, uploads a passport and submits
+func submitTestKYC(t *testing.T, svc *KYCService, accountID int) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := svc.UpdateDetails(ctx, accountID, testKYCDetails()); err != nil {
+		t.Fatalf("update details failed: %v", err)
+	}
+	if _, err := svc.UploadDocument(ctx, accountID, models.KYCDocumentPassport, "passport.png", bytes.NewReader(testPNG)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if _, err := svc.Submit(ctx, accountID); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+}
+
+func TestKYCWorkflow(t *testing.T) {
+	svc, store, notifier := newTestKYCService(t)
+	ctx := context.Background()
+	account, err := store.Accounts().Create(ctx, "holder@example.com", "hash", "Jane", "Doe")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	reviewer, err := store.Accounts().Create(ctx, "reviewer@example.com", "hash", "Rita", "Review")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+
+	profile, err := svc.Get(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if profile.Status != models.KYCUnverified || profile.Level != 0 || profile.Limits.PerTransaction != 500 {
+		t.Errorf("expected a new account to be unverified on level 0, got %+v", profile)
+	}
+
+	if _, err := svc.Submit(ctx, account.ID); errorCode(err) != "kyc_details_missing" {
+		t.Errorf("expected a submission without details to be refused, got %v", err)
+	}
+	profile, err = svc.UpdateDetails(ctx, account.ID, testKYCDetails())
+	if err != nil {
+		t.Fatalf("update details failed: %v", err)
+	}
+	if profile.DateOfBirth != "1990-04-12" || profile.Nationality != "GB" || profile.AddressLine1 != "1 High Street" || profile.PostalCode != "SW1A 1AA" {
+		t.Errorf("expected the details to be normalised, got %+v", profile)
+	}
+
+	if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentProofOfAddress, "bill.pdf", bytes.NewReader(testPDF)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if _, err := svc.Submit(ctx, account.ID); errorCode(err) != "identity_document_missing" {
+		t.Errorf("expected a submission without an identity document to be refused, got %v", err)
+	}
+	if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentPassport, "passport.png", bytes.NewReader(testPNG)); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	profile, err = svc.Submit(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if profile.Status != models.KYCSubmitted || profile.SubmittedAt == nil || len(profile.Documents) != 2 {
+		t.Errorf("expected a submitted profile with both documents, got %+v", profile)
+	}
+
+	// Nothing can change while the profile is being reviewed
+	if _, err := svc.UpdateDetails(ctx, account.ID, testKYCDetails()); errorCode(err) != "kyc_locked" {
+		t.Errorf("expected details to be locked once submitted, got %v", err)
+	}
+	if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentNationalID, "id.png", bytes.NewReader(testPNG)); errorCode(err) != "kyc_locked" {
+		t.Errorf("expected documents to be locked once submitted, got %v", err)
+	}
+	if _, err := svc.Submit(ctx, account.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a second submission to conflict, got %v", err)
+	}
+
+	queue, err := svc.ListForReview(ctx, "")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(queue) != 1 || queue[0].AccountNumber != account.AccountNumber || queue[0].FirstName != "Jane" {
+		t.Errorf("expected the profile in the review queue with its account, got %+v", queue)
+	}
+
+	if _, err := svc.Reject(ctx, reviewer.ID, account.ID, " "); !errors.As(err, new(*utils.ValidationError)) {
+		t.Errorf("expected a rejection without a reason to be refused, got %v", err)
+	}
+	profile, err = svc.Reject(ctx, reviewer.ID, account.ID, "Passport photo is blurred")
+	if err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if profile.Status != models.KYCRejected || profile.RejectionReason != "Passport photo is blurred" {
+		t.Errorf("expected a rejected profile with the reason, got %+v", profile)
+	}
+
+	// A rejected profile can be corrected and submitted again
+	if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentPassport, "passport-2.png", bytes.NewReader(testPNG)); err != nil {
+		t.Fatalf("upload after rejection failed: %v", err)
+	}
+	profile, err = svc.Submit(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("resubmit failed: %v", err)
+	}
+	if profile.RejectionReason != "" || profile.ReviewedAt != nil {
+		t.Errorf("expected a resubmission to clear the last review, got %+v", profile)
+	}
+
+	if _, err := svc.Approve(ctx, account.ID, account.ID, 1); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected a holder not to approve themselves, got %v", err)
+	}
+	if _, err := svc.Approve(ctx, reviewer.ID, account.ID, 3); !errors.As(err, new(*utils.ValidationError)) {
+		t.Errorf("expected an unknown level to be refused, got %v", err)
+	}
+	profile, err = svc.Approve(ctx, reviewer.ID, account.ID, 1)
+	if err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if profile.Status != models.KYCApproved || profile.Level != 1 || profile.Limits != models.KYCLimitsFor(1) {
+		t.Errorf("expected an approved profile on level 1, got %+v", profile)
+	}
+	if _, err := svc.Reject(ctx, reviewer.ID, account.ID, "Changed my mind"); errorCode(err) != "kyc_not_submitted" {
+		t.Errorf("expected an approved profile not to be reviewed again, got %v", err)
+	}
+	if _, err := svc.UpdateDetails(ctx, account.ID, testKYCDetails()); errorCode(err) != "kyc_locked" {
+		t.Errorf("expected an approved profile to be locked, got %v", err)
+	}
+
+	stored, err := store.KYC().GetProfile(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("failed to get profile: %v", err)
+	}
+	if stored.ReviewedBy == nil || *stored.ReviewedBy != reviewer.ID {
+		t.Errorf("expected the reviewer to be recorded, got %v", stored.ReviewedBy)
+	}
+
+	want := []string{notifications.EventKYCRejected, notifications.EventKYCApproved}
+	got := eventTypes(notifier, account.ID)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestKYCDetailsValidation(t *testing.T) {
+	svc, store, _ := newTestKYCService(t)
+	ctx := context.Background()
+	account, err := store.Accounts().Create(ctx, "holder@example.com", "hash", "Jane", "Doe")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*models.UpdateKYCDetailsRequest)
+	}{
+		{"bad date", func(r *models.UpdateKYCDetailsRequest) { r.DateOfBirth = "12/04/1990" }},
+		{"under 18", func(r *models.UpdateKYCDetailsRequest) {
+			r.DateOfBirth = time.Now().AddDate(-17, 0, 0).Format(time.DateOnly)
+		}},
+		{"bad nationality", func(r *models.UpdateKYCDetailsRequest) { r.Nationality = "GBR" }},
+		{"missing country", func(r *models.UpdateKYCDetailsRequest) { r.Country = "" }},
+		{"missing address", func(r *models.UpdateKYCDetailsRequest) { r.AddressLine1 = "  " }},
+		{"missing city", func(r *models.UpdateKYCDetailsRequest) { r.City = "" }},
+		{"long postal code", func(r *models.UpdateKYCDetailsRequest) { r.PostalCode = strings.Repeat("1", 21) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testKYCDetails()
+			tt.change(req)
+			_, err := svc.UpdateDetails(ctx, account.ID, req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKYCDocumentUpload(t *testing.T) {
+	svc, store, _ := newTestKYCService(t)
+	files := svc.storage.(*storage.MemoryStorage)
+	ctx := context.Background()
+	account, err := store.Accounts().Create(ctx, "holder@example.com", "hash", "Jane", "Doe")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		docType string
+		data    []byte
+		code    string
+	}{
+		{"unknown type", "selfie", testPNG, "validation_error"},
+		{"empty file", models.KYCDocumentPassport, nil, "validation_error"},
+		{"text file", models.KYCDocumentPassport, []byte("just some text"), "unsupported_file_type"},
+		{"too large", models.KYCDocumentPassport, append(testPDF, make([]byte, MaxKYCDocumentBytes)...), "file_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.UploadDocument(ctx, account.ID, tt.docType, "scan", bytes.NewReader(tt.data))
+			var validationErr *utils.ValidationError
+			if tt.code == "validation_error" && !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+			if tt.code != "validation_error" && errorCode(err) != tt.code {
+				t.Errorf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+	if len(files.Keys()) != 0 {
+		t.Fatalf("expected refused uploads not to be stored, got %v", files.Keys())
+	}
+
+	// The name is reduced to its last element and the type is sniffed
+	document, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentDrivingLicence, "../../licence.txt", bytes.NewReader(testPDF))
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if document.FileName != "licence.txt" || document.ContentType != "application/pdf" || document.Size != int64(len(testPDF)) || len(document.SHA256) != 64 {
+		t.Errorf("unexpected document %+v", document)
+	}
+
+	got, file, err := svc.OpenDocument(ctx, account.ID, document.ID)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if got.ID != document.ID || !bytes.Equal(data, testPDF) {
+		t.Errorf("expected the uploaded file back, got %q", data)
+	}
+	if _, _, err := svc.OpenDocument(ctx, account.ID+1, document.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another account's document to be not found, got %v", err)
+	}
+
+	for i := 1; i < maxKYCDocuments; i++ {
+		if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentPassport, "passport.png", bytes.NewReader(testPNG)); err != nil {
+			t.Fatalf("upload %d failed: %v", i, err)
+		}
+	}
+	if _, err := svc.UploadDocument(ctx, account.ID, models.KYCDocumentPassport, "passport.png", bytes.NewReader(testPNG)); errorCode(err) != "document_limit" {
+		t.Errorf("expected the document limit to apply, got %v", err)
+	}
+	// The file of a refused upload is removed again
+	if len(files.Keys()) != maxKYCDocuments {
+		t.Errorf("expected %d stored files, got %d", maxKYCDocuments, len(files.Keys()))
+	}
+}
+
+func TestKYCLimits(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	kyc := NewKYCService(store, store.Accounts(), store.KYC(), &storage.MemoryStorage{}, &notifications.MemoryNotifier{})
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "holder@example.com", 5000)
+	friend := createFundedAccount(t, store, svc, "friend@example.com", 0)
+	reviewer := createFundedAccount(t, store, svc, "reviewer@example.com", 0)
+
+	// Level 0 allows 500 a payment and 1000 a day
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 500.01}); errorCode(err) != "kyc_limit_exceeded" {
+		t.Errorf("expected a withdrawal over the payment limit to be refused, got %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 500}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if _, err := svc.Transfer(ctx, account.ID, &models.TransferRequest{ToAccountID: friend.ID, Amount: 400}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if _, err := svc.Transfer(ctx, account.ID, &models.TransferRequest{ToAccountID: friend.ID, Amount: 100.01}); !errors.Is(err, ErrForbidden) || errorCode(err) != "kyc_limit_exceeded" {
+		t.Errorf("expected a transfer over the daily limit to be refused, got %v", err)
+	}
+	// Money coming in is not limited
+	if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 5000}); err != nil {
+		t.Errorf("expected deposits not to be limited, got %v", err)
+	}
+
+	submitTestKYC(t, kyc, account.ID)
+	if _, err := kyc.Approve(ctx, reviewer.ID, account.ID, 1); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 2000}); err != nil {
+		t.Errorf("expected level 1 to raise the limits, got %v", err)
+	}
+
+	updated, err := store.Accounts().GetByID(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if updated.Balance != 7100 {
+		t.Errorf("expected refused payments to leave the balance alone, got %.2f", updated.Balance)
+	}
+}
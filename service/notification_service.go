@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// recentNotificationsLimit caps the delivery history shown to a holder
+const recentNotificationsLimit = 50
+
+type NotificationService struct {
+	notificationRepo repository.NotificationStore
+}
+
+func NewNotificationService(notificationRepo repository.NotificationStore) *NotificationService {
+	return &NotificationService{notificationRepo: notificationRepo}
+}
+
+// GetPreferences returns the account's saved preferences, or the defaults
+// if it has never saved any
+func (s *NotificationService) GetPreferences(ctx context.Context, accountID int) (*models.NotificationPreferences, error) {
+	prefs, err := s.notificationRepo.GetPreferences(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultNotificationPreferences(accountID), nil
+	}
+	if err != nil {
+		return nil, Internal("failed to get notification preferences", err)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences changes the fields given. SMS and push can only be
+// turned on once there is a phone number or push token to send to.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, accountID int, req *models.UpdateNotificationPreferencesRequest) (*models.NotificationPreferences, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.UpdatePreferences")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	prefs, err := s.GetPreferences(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Phone != nil {
+		phone := strings.TrimSpace(*req.Phone)
+		if phone != "" {
+			if err := utils.ValidatePhone(phone); err != nil {
+				return nil, err
+			}
+		}
+		prefs.Phone = phone
+	}
+	if req.PushToken != nil {
+		token := strings.TrimSpace(*req.PushToken)
+		if len(token) > 255 {
+			return nil, &utils.ValidationError{Field: "push_token", Message: "push_token must be at most 255 characters"}
+		}
+		prefs.PushToken = token
+	}
+	if req.MutedEvents != nil {
+		for _, eventType := range req.MutedEvents {
+			if !slices.Contains(notifications.EventTypes, eventType) {
+				return nil, &utils.ValidationError{Field: "muted_events", Message: "unknown event type " + eventType}
+			}
+			if notifications.IsSecurityEvent(eventType) {
+				return nil, &utils.ValidationError{Field: "muted_events", Message: eventType + " is a security event and cannot be muted"}
+			}
+		}
+		slices.Sort(req.MutedEvents)
+		prefs.MutedEvents = slices.Compact(req.MutedEvents)
+	}
+	if req.EmailEnabled != nil {
+		prefs.EmailEnabled = *req.EmailEnabled
+	}
+	if req.SMSEnabled != nil {
+		prefs.SMSEnabled = *req.SMSEnabled
+	}
+	if req.PushEnabled != nil {
+		prefs.PushEnabled = *req.PushEnabled
+	}
+
+	if prefs.SMSEnabled && prefs.Phone == "" {
+		return nil, &utils.ValidationError{Field: "phone", Message: "a phone number is required for SMS notifications"}
+	}
+	if prefs.PushEnabled && prefs.PushToken == "" {
+		return nil, &utils.ValidationError{Field: "push_token", Message: "a push token is required for push notifications"}
+	}
+
+	if err := s.notificationRepo.SavePreferences(ctx, prefs); err != nil {
+		return nil, Internal("failed to save notification preferences", err)
+	}
+	return prefs, nil
+}
+
+// ListRecent returns the account's most recent notifications, newest first
+func (s *NotificationService) ListRecent(ctx context.Context, accountID int) ([]*models.NotificationDelivery, error) {
+	deliveries, err := s.notificationRepo.ListDeliveries(ctx, accountID, recentNotificationsLimit)
+	if err != nil {
+		return nil, Internal("failed to list notifications", err)
+	}
+	return deliveries, nil
+}
+
+// sendNotification publishes an event. Delivery is best effort: the change
+// it reports has already been made, so a failure is only logged.
+func sendNotification(ctx context.Context, notifier notifications.Notifier, accountID int, eventType string, data map[string]any) {
+	event := notifications.Event{AccountID: accountID, Type: eventType, Data: data}
+	if err := notifier.Notify(ctx, event); err != nil {
+		log.Printf("failed to send %s to account %d: %v", eventType, accountID, err)
+	}
+}
+
+type afterCommitKey struct{}
+
+// afterCommitQueue holds the best-effort follow-up work of changes made
+// inside a transaction started with withAfterCommit
+type afterCommitQueue struct {
+	work []func(ctx context.Context)
+}
+
+// withAfterCommit returns the context to start a transaction with and a
+// function to call once it has committed. Notifications and alerts for
+// changes that join the transaction are queued and run by that function,
+// with ctx: run inside the transaction, a failing statement would abort
+// it, and a rolled back change must not be announced. Nested calls share
+// the outermost queue, and their run function does nothing.
+func withAfterCommit(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(afterCommitKey{}).(*afterCommitQueue); ok {
+		return ctx, func() {}
+	}
+	queue := &afterCommitQueue{}
+	return context.WithValue(ctx, afterCommitKey{}, queue), func() {
+		for _, work := range queue.work {
+			work(ctx)
+		}
+	}
+}
+
+// afterCommit runs work now, once the caller's own transaction is done, or
+// queues it when ctx comes from withAfterCommit
+func afterCommit(ctx context.Context, work func(ctx context.Context)) {
+	if queue, ok := ctx.Value(afterCommitKey{}).(*afterCommitQueue); ok {
+		queue.work = append(queue.work, work)
+		return
+	}
+	work(ctx)
+}
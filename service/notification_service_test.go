@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	_, store := newTestTransactionService(t)
+	svc := NewNotificationService(store.Notifications())
+	ctx := context.Background()
+	account, err := store.Accounts().Create(ctx, "prefs@example.com", "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+
+	yes := true
+	badPhone := "07700 900123"
+	tests := []struct {
+		name string
+		req  models.UpdateNotificationPreferencesRequest
+	}{
+		{"sms without a phone", models.UpdateNotificationPreferencesRequest{SMSEnabled: &yes}},
+		{"push without a token", models.UpdateNotificationPreferencesRequest{PushEnabled: &yes}},
+		{"phone not international", models.UpdateNotificationPreferencesRequest{Phone: &badPhone}},
+		{"unknown event", models.UpdateNotificationPreferencesRequest{MutedEvents: []string{"account.birthday"}}},
+		{"muting a security event", models.UpdateNotificationPreferencesRequest{MutedEvents: []string{notifications.EventLogin}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.UpdatePreferences(ctx, account.ID, &tt.req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	prefs, err := svc.GetPreferences(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if !prefs.EmailEnabled || prefs.SMSEnabled || prefs.PushEnabled {
+		t.Errorf("expected the email-only defaults, got %+v", prefs)
+	}
+
+	phone := "+447700900123"
+	prefs, err = svc.UpdatePreferences(ctx, account.ID, &models.UpdateNotificationPreferencesRequest{
+		SMSEnabled:  &yes,
+		Phone:       &phone,
+		MutedEvents: []string{notifications.EventDeposit, notifications.EventDeposit},
+	})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if !prefs.EmailEnabled || !prefs.SMSEnabled || prefs.Phone != phone || len(prefs.MutedEvents) != 1 {
+		t.Errorf("expected email and SMS with deposits muted, got %+v", prefs)
+	}
+
+	// Clearing the phone while SMS is on is refused
+	empty := ""
+	_, err = svc.UpdatePreferences(ctx, account.ID, &models.UpdateNotificationPreferencesRequest{Phone: &empty})
+	var validationErr *utils.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected clearing the phone to be refused while SMS is on, got %v", err)
+	}
+}
+
+func TestAccountActivityNotifies(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	notifier := &notifications.MemoryNotifier{}
+	svc.notifier = notifier
+	auth := NewAuthService(store, store.Accounts(), store.Sessions(), time.Hour, utils.IBANFormat{}, notifier, newTestVerificationService(store))
+	ctx := context.Background()
+
+	alice := registerTestAccount(t, auth, "alice@example.com")
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+
+	if _, err := auth.Login(ctx, models.LoginAccountRequest{Email: "alice@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if _, err := svc.Deposit(ctx, alice.ID, &models.DepositRequest{Amount: 100}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, alice.ID, &models.WitdrawRequest{Amount: 30}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if _, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: 20}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	// A failed withdrawal tells nobody
+	if _, err := svc.WithDraw(ctx, alice.ID, &models.WitdrawRequest{Amount: 1000}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+	if _, err := auth.SetStatus(ctx, alice.ID, models.AccountStatusSuspended); err != nil {
+		t.Fatalf("suspend failed: %v", err)
+	}
+
+	want := []string{
+		notifications.EventLogin,
+		notifications.EventDeposit,
+		notifications.EventWithdrawal,
+		notifications.EventTransferSent,
+		notifications.EventAccountStatusChanged,
+	}
+	got := eventTypes(notifier, alice.ID)
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected events %v, got %v", want, got)
+		}
+	}
+	if got := eventTypes(notifier, bob.ID); len(got) != 1 || got[0] != notifications.EventTransferReceived {
+		t.Errorf("expected bob to hear about the transfer, got %v", got)
+	}
+
+	events := notifier.Events()
+	withdrawal := events[2]
+	if withdrawal.Data["balance"] != 70.0 || withdrawal.Data["amount"] != 30.0 {
+		t.Errorf("expected the withdrawal to report 30 out and 70 left, got %v", withdrawal.Data)
+	}
+	if sent := events[3]; sent.Data["to"] != "T*** U***" || sent.Data["balance"] != 50.0 {
+		t.Errorf("expected the transfer to name the masked recipient and 50 left, got %v", sent.Data)
+	}
+}
+
+func TestSetAccountStatus(t *testing.T) {
+	svc, _ := newTestAuthService(t)
+	notifier := svc.notifier.(*notifications.MemoryNotifier)
+	ctx := context.Background()
+	account := registerTestAccount(t, svc, "status@example.com")
+
+	_, err := svc.SetStatus(ctx, account.ID, models.AccountStatusClosed)
+	var validationErr *utils.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("expected closing through SetStatus to be refused, got %v", err)
+	}
+
+	suspended, err := svc.SetStatus(ctx, account.ID, models.AccountStatusSuspended)
+	if err != nil {
+		t.Fatalf("suspend failed: %v", err)
+	}
+	if suspended.Status != models.AccountStatusSuspended {
+		t.Errorf("expected a suspended account, got %s", suspended.Status)
+	}
+	if _, err := svc.Login(ctx, models.LoginAccountRequest{Email: "status@example.com", Password: testPassword}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected a suspended account not to log in, got %v", err)
+	}
+
+	// Setting the same status again changes nothing and tells no one
+	if _, err := svc.SetStatus(ctx, account.ID, models.AccountStatusSuspended); err != nil {
+		t.Fatalf("suspend failed: %v", err)
+	}
+	if _, err := svc.SetStatus(ctx, account.ID, models.AccountStatusActice); err != nil {
+		t.Fatalf("reactivate failed: %v", err)
+	}
+
+	got := eventTypes(notifier, account.ID)
+	if len(got) != 2 {
+		t.Fatalf("expected two status change events, got %v", got)
+	}
+	for i, status := range []string{models.AccountStatusSuspended, models.AccountStatusActice} {
+		if event := notifier.Events()[i]; event.Type != notifications.EventAccountStatusChanged || event.Data["status"] != status {
+			t.Errorf("expected a change to %s, got %+v", status, event)
+		}
+	}
+
+	if _, err := svc.SetStatus(ctx, 999, models.AccountStatusSuspended); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown account to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// PayeeService manages saved transfer recipients and confirmation-of-payee
+// lookups
+type PayeeService struct {
+	accountRepo     repository.AccountStore
+	payeeRepo       repository.PayeeStore
+	transactionRepo repository.TransactionStore
+	iban            utils.IBANFormat
+}
+
+func NewPayeeService(
+	accountRepo repository.AccountStore,
+	payeeRepo repository.PayeeStore,
+	transactionRepo repository.TransactionStore,
+	iban utils.IBANFormat,
+) *PayeeService {
+	return &PayeeService{
+		accountRepo:     accountRepo,
+		payeeRepo:       payeeRepo,
+		transactionRepo: transactionRepo,
+		iban:            iban,
+	}
+}
+
+func (s *PayeeService) Create(ctx context.Context, accountID int, req *models.CreatePayeeRequest) (*models.PayeeResponse, error) {
+	ctx, span := tracing.Start(ctx, "PayeeService.Create")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	nickname := utils.SanitizeString(req.Nickname)
+	if err := utils.ValidateRequired(nickname, "nickname"); err != nil {
+		return nil, err
+	}
+	if len(nickname) > 50 {
+		return nil, &utils.ValidationError{Field: "nickname", Message: "nickname must be at most 50 characters"}
+	}
+
+	recipient, err := s.findRecipient(ctx, req.AccountNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if recipient.ID == accountID {
+		return nil, Validation("same_account", "cannot add your own account as a payee")
+	}
+	if recipient.Status != models.AccountStatusActice {
+		return nil, Forbidden("recipient_inactive", "recipient account is "+recipient.Status)
+	}
+
+	lastPaid, err := s.transactionRepo.LastTransferAt(ctx, accountID, recipient.ID)
+	if err != nil {
+		return nil, wrapInternal("failed to create payee", err)
+	}
+
+	payee, err := s.payeeRepo.Create(ctx, accountID, recipient.ID, nickname, fullName(recipient), lastPaid)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, Conflict("payee_exists", "this account is already in your payees")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to create payee", err)
+	}
+
+	return payeeResponse(payee), nil
+}
+
+func (s *PayeeService) List(ctx context.Context, accountID int) ([]*models.PayeeResponse, error) {
+	ctx, span := tracing.Start(ctx, "PayeeService.List")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	payees, err := s.payeeRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to list payees", err)
+	}
+
+	responses := make([]*models.PayeeResponse, len(payees))
+	for i, payee := range payees {
+		responses[i] = payeeResponse(payee)
+	}
+	return responses, nil
+}
+
+func (s *PayeeService) Delete(ctx context.Context, accountID, payeeID int) error {
+	ctx, span := tracing.Start(ctx, "PayeeService.Delete")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	payee, err := s.payeeRepo.GetByID(ctx, payeeID)
+	if err != nil {
+		return notFoundOrInternal(err, "payee_not_found", "payee not found")
+	}
+	// Someone else's payee is reported as missing so IDs can't be probed
+	if payee.AccountID != accountID {
+		return NotFound("payee_not_found", "payee not found")
+	}
+
+	if err := s.payeeRepo.Delete(ctx, payeeID); err != nil {
+		return notFoundOrInternal(err, "payee_not_found", "payee not found")
+	}
+	return nil
+}
+
+// Lookup confirms who owns an account before money is sent. When req.Name
+// is given it is compared with the holder's name; the holder's name itself
+// is only ever returned masked.
+func (s *PayeeService) Lookup(ctx context.Context, accountID int, req *models.PayeeLookupRequest) (*models.PayeeLookupResponse, error) {
+	ctx, span := tracing.Start(ctx, "PayeeService.Lookup")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	recipient, err := s.findRecipient(ctx, req.AccountNumber, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if recipient.Status != models.AccountStatusActice {
+		return nil, Forbidden("recipient_inactive", "recipient account is "+recipient.Status)
+	}
+
+	lastPaid, err := s.transactionRepo.LastTransferAt(ctx, accountID, recipient.ID)
+	if err != nil {
+		return nil, wrapInternal("payee lookup failed", err)
+	}
+
+	response := &models.PayeeLookupResponse{
+		AccountNumber:  recipient.AccountNumber,
+		MaskedName:     utils.MaskName(recipient.FirstName, recipient.LastName),
+		FirstTimePayee: lastPaid == nil,
+	}
+	if strings.TrimSpace(req.Name) != "" {
+		response.NameMatch = matchName(recipient, req.Name)
+	}
+	return response, nil
+}
+
+// findRecipient resolves a recipient given by exactly one of account
+// number or email
+func (s *PayeeService) findRecipient(ctx context.Context, accountNumber, email string) (*models.Account, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if accountNumber != "" && email != "" {
+		return nil, Validation("ambiguous_recipient", "give only one of account_number or email")
+	}
+	if accountNumber != "" {
+		return findByAccountNumber(ctx, s.accountRepo, s.iban, accountNumber, "account_number")
+	}
+	if err := utils.ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	account, err := s.accountRepo.GeyByEmail(ctx, email)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "recipient_not_found", "recipient account not found")
+	}
+	return account, nil
+}
+
+// matchName compares the name a payer expects with the account holder's.
+// A close match has the same surname and first initial, which catches
+// "J Smith" or "Jon Smith" for "Jonathan Smith".
+func matchName(account *models.Account, expected string) string {
+	expected = utils.NormalizeName(expected)
+	if expected == utils.NormalizeName(fullName(account)) {
+		return models.NameMatchExact
+	}
+
+	parts := strings.Fields(expected)
+	first := utils.NormalizeName(account.FirstName)
+	last := utils.NormalizeName(account.LastName)
+	if len(parts) >= 2 && first != "" &&
+		strings.Join(parts[1:], " ") == last &&
+		[]rune(parts[0])[0] == []rune(first)[0] {
+		return models.NameMatchClose
+	}
+	return models.NameMatchNone
+}
+
+func fullName(account *models.Account) string {
+	return strings.TrimSpace(account.FirstName + " " + account.LastName)
+}
+
+func payeeResponse(payee *models.Payee) *models.PayeeResponse {
+	return &models.PayeeResponse{
+		ID:             payee.ID,
+		Nickname:       payee.Nickname,
+		AccountNumber:  payee.PayeeAccountNumber,
+		MaskedName:     utils.MaskName(payee.VerifiedName, ""),
+		FirstTimePayee: payee.LastPaidAt == nil,
+		LastPaidAt:     payee.LastPaidAt,
+		CreatedAt:      payee.CreatedAt,
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestPayeeService(t *testing.T) (*PayeeService, *TransactionService, *memory.Store) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	return NewPayeeService(store.Accounts(), store.Payees(), store.Transactions(), utils.IBANFormat{}), transactions, store
+}
+
+func createNamedAccount(t *testing.T, store *memory.Store, email, firstName, lastName string) *models.Account {
+	t.Helper()
+	account, err := store.Accounts().Create(context.Background(), email, "hash", firstName, lastName)
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	return account
+}
+
+func TestPayeeCreateAndList(t *testing.T) {
+	ctx := context.Background()
+	payees, _, store := newTestPayeeService(t)
+	owner := createNamedAccount(t, store, "owner@example.com", "Olive", "Owner")
+	jane := createNamedAccount(t, store, "jane@example.com", "Jane", "Doe")
+	bob := createNamedAccount(t, store, "bob@example.com", "Bob", "Brown")
+
+	created, err := payees.Create(ctx, owner.ID, &models.CreatePayeeRequest{Nickname: "Sis", AccountNumber: jane.AccountNumber})
+	if err != nil {
+		t.Fatalf("Create by account number: %v", err)
+	}
+	if created.MaskedName != "J*** D***" || !created.FirstTimePayee {
+		t.Errorf("unexpected payee: %+v", created)
+	}
+	if _, err := payees.Create(ctx, owner.ID, &models.CreatePayeeRequest{Nickname: "Bob", Email: " BOB@example.com "}); err != nil {
+		t.Fatalf("Create by email: %v", err)
+	}
+
+	_, err = payees.Create(ctx, owner.ID, &models.CreatePayeeRequest{Nickname: "Again", AccountNumber: jane.AccountNumber})
+	if svcErr := (*Error)(nil); !errors.As(err, &svcErr) || svcErr.Code != "payee_exists" {
+		t.Errorf("duplicate payee err = %v, want payee_exists", err)
+	}
+	_, err = payees.Create(ctx, owner.ID, &models.CreatePayeeRequest{Nickname: "Me", AccountNumber: owner.AccountNumber})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("self payee err = %v, want validation error", err)
+	}
+
+	list, err := payees.List(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].AccountNumber != bob.AccountNumber || list[1].AccountNumber != jane.AccountNumber {
+		t.Errorf("payees not listed by nickname: %+v", list)
+	}
+
+	if err := payees.Delete(ctx, bob.ID, created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting another account's payee err = %v, want not found", err)
+	}
+	if err := payees.Delete(ctx, owner.ID, created.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+}
+
+func TestPayeeLookupNameMatch(t *testing.T) {
+	ctx := context.Background()
+	payees, _, store := newTestPayeeService(t)
+	owner := createNamedAccount(t, store, "owner@example.com", "Olive", "Owner")
+	target := createNamedAccount(t, store, "jon@example.com", "Jonathan", "Smith")
+
+	tests := []struct {
+		expected string
+		want     string
+	}{
+		{"jonathan  SMITH", models.NameMatchExact},
+		{"J Smith", models.NameMatchClose},
+		{"Jon Smith", models.NameMatchClose},
+		{"Jane Smith", models.NameMatchClose},
+		{"Jonathan Smyth", models.NameMatchNone},
+		{"Smith", models.NameMatchNone},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			result, err := payees.Lookup(ctx, owner.ID, &models.PayeeLookupRequest{AccountNumber: target.AccountNumber, Name: tt.expected})
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+			if result.NameMatch != tt.want {
+				t.Errorf("NameMatch = %q, want %q", result.NameMatch, tt.want)
+			}
+			if result.MaskedName != "J*** S***" {
+				t.Errorf("MaskedName = %q, want masked name", result.MaskedName)
+			}
+		})
+	}
+
+	if _, err := payees.Lookup(ctx, owner.ID, &models.PayeeLookupRequest{AccountNumber: target.AccountNumber, Email: "jon@example.com"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("lookup with both account and email err = %v, want validation error", err)
+	}
+	if _, err := payees.Lookup(ctx, owner.ID, &models.PayeeLookupRequest{Email: "nobody@example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("lookup of unknown email err = %v, want not found", err)
+	}
+}
+
+func TestTransferToPayeeOrEmail(t *testing.T) {
+	ctx := context.Background()
+	payees, transactions, store := newTestPayeeService(t)
+	sender := createFundedAccount(t, store, transactions, "sender@example.com", 100)
+	recipient := createNamedAccount(t, store, "recipient@example.com", "Rita", "Reed")
+	stranger := createNamedAccount(t, store, "stranger@example.com", "Sam", "Stone")
+
+	payee, err := payees.Create(ctx, sender.ID, &models.CreatePayeeRequest{Nickname: "Rita", AccountNumber: recipient.AccountNumber})
+	if err != nil {
+		t.Fatalf("Create payee: %v", err)
+	}
+
+	first, err := transactions.Transfer(ctx, sender.ID, &models.TransferRequest{PayeeID: payee.ID, Amount: 10})
+	if err != nil {
+		t.Fatalf("Transfer by payee: %v", err)
+	}
+	if *first.ToAccountID != recipient.ID || !first.FirstTimePayee {
+		t.Errorf("first transfer = %+v, want first-time transfer to recipient", first)
+	}
+
+	second, err := transactions.Transfer(ctx, sender.ID, &models.TransferRequest{ToEmail: "recipient@example.com", Amount: 5})
+	if err != nil {
+		t.Fatalf("Transfer by email: %v", err)
+	}
+	if *second.ToAccountID != recipient.ID || second.FirstTimePayee {
+		t.Errorf("second transfer = %+v, want repeat transfer to recipient", second)
+	}
+
+	list, err := payees.List(ctx, sender.ID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if list[0].LastPaidAt == nil || list[0].FirstTimePayee {
+		t.Errorf("payee was not marked paid: %+v", list[0])
+	}
+
+	// A payee ID belonging to someone else must not resolve
+	_, err = transactions.Transfer(ctx, stranger.ID, &models.TransferRequest{PayeeID: payee.ID, Amount: 1})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("transfer with another account's payee err = %v, want not found", err)
+	}
+	_, err = transactions.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: recipient.ID, ToEmail: "recipient@example.com", Amount: 1})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("transfer with two recipients err = %v, want validation error", err)
+	}
+}
+
+func TestTransferByAccountNumber(t *testing.T) {
+	ctx := context.Background()
+	payees, transactions, store := newTestPayeeService(t)
+	sender := createFundedAccount(t, store, transactions, "sender@example.com", 50)
+	recipient := createNamedAccount(t, store, "recipient@example.com", "Rita", "Reed")
+
+	transactions.iban = utils.IBANFormat{CountryCode: "GB", BankCode: "GOBK"}
+	iban := transactions.iban.Format(recipient.AccountNumber)
+	for _, number := range []string{recipient.AccountNumber, iban} {
+		resp, err := transactions.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountNumber: number, Amount: 5})
+		if err != nil {
+			t.Fatalf("Transfer to %q: %v", number, err)
+		}
+		if *resp.ToAccountID != recipient.ID {
+			t.Errorf("transfer to %q reached account %d, want %d", number, *resp.ToAccountID, recipient.ID)
+		}
+	}
+
+	_, err := transactions.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountNumber: "1234567890", Amount: 5})
+	var validationErr *utils.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "to_account_number" {
+		t.Errorf("bad check digit err = %v, want to_account_number validation error", err)
+	}
+	// An IBAN of another bank never resolves to a local account, even when
+	// the account number inside it is one of ours
+	foreign := utils.IBANFormat{CountryCode: "DE", BankCode: "GOBK"}.Format(recipient.AccountNumber)
+	if _, err := transactions.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountNumber: foreign, Amount: 5}); !errors.As(err, &validationErr) {
+		t.Errorf("foreign IBAN err = %v, want validation error", err)
+	}
+
+	payee, err := payees.Create(ctx, sender.ID, &models.CreatePayeeRequest{Nickname: "Rita", AccountNumber: recipient.AccountNumber})
+	if err != nil {
+		t.Fatalf("Create payee by account number: %v", err)
+	}
+	if payee.AccountNumber != recipient.AccountNumber || payee.FirstTimePayee {
+		t.Errorf("unexpected payee: %+v", payee)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	defaultPaymentRequestExpiry = 7 * 24 * time.Hour
+	maxPaymentRequestExpiry     = 30 * 24 * time.Hour
+)
+
+// PaymentRequestService lets one account holder ask another for money.
+// The payer pays with an ordinary transfer or declines; the requester can
+// cancel while the request is pending. Each change notifies the other side.
+type PaymentRequestService struct {
+	db                 repository.TxRunner
+	accountRepo        repository.AccountStore
+	payeeRepo          repository.PayeeStore
+	requestRepo        repository.PaymentRequestStore
+	transactionService *TransactionService
+	notifier           notifications.Notifier
+}
+
+func NewPaymentRequestService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	payeeRepo repository.PayeeStore,
+	requestRepo repository.PaymentRequestStore,
+	transactionService *TransactionService,
+	notifier notifications.Notifier,
+) *PaymentRequestService {
+	return &PaymentRequestService{
+		db:                 database,
+		accountRepo:        accountRepo,
+		payeeRepo:          payeeRepo,
+		requestRepo:        requestRepo,
+		transactionService: transactionService,
+		notifier:           notifier,
+	}
+}
+
+func (s *PaymentRequestService) Create(ctx context.Context, accountID int, req *models.CreatePaymentRequestRequest) (*models.PaymentRequestResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.Create")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if err := utils.ValidateAmount(req.Amount); err != nil {
+		return nil, err
+	}
+	memo := utils.SanitizeString(req.Memo)
+	if len(memo) > 140 {
+		return nil, &utils.ValidationError{Field: "memo", Message: "memo must be at most 140 characters"}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(defaultPaymentRequestExpiry)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+		if !expiresAt.After(now) {
+			return nil, &utils.ValidationError{Field: "expires_at", Message: "expires_at must be in the future"}
+		}
+		if expiresAt.After(now.Add(maxPaymentRequestExpiry)) {
+			return nil, &utils.ValidationError{Field: "expires_at", Message: "expires_at can be at most 30 days away"}
+		}
+	}
+
+	requester, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if requester.Status != models.AccountStatusActice {
+		return nil, accountInactive(requester.Status)
+	}
+
+	payer, err := s.resolvePayer(ctx, accountID, req)
+	if err != nil {
+		return nil, err
+	}
+	if payer.ID == accountID {
+		return nil, Validation("same_account", "cannot request money from your own account")
+	}
+	if payer.Status != models.AccountStatusActice {
+		return nil, Forbidden("payer_inactive", "payer account is "+payer.Status)
+	}
+
+	request, err := s.requestRepo.Create(ctx, &models.PaymentRequest{
+		RequesterAccountID: accountID,
+		PayerAccountID:     payer.ID,
+		Amount:             req.Amount,
+		Memo:               memo,
+		ExpiresAt:          expiresAt,
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to create payment request", err)
+	}
+
+	notifyPaymentRequest(ctx, s.notifier, request.PayerAccountID, notifications.EventPaymentRequested, request)
+	return paymentRequestResponse(request, accountID), nil
+}
+
+// List returns the caller's requests. direction is incoming (requests to
+// pay) or outgoing (requests made); status optionally narrows the list.
+func (s *PaymentRequestService) List(ctx context.Context, accountID int, direction, status string) ([]*models.PaymentRequestResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.List")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	switch direction {
+	case models.TransactionDirectionIncoming, models.TransactionDirectionOutgoing:
+	default:
+		return nil, &utils.ValidationError{Field: "direction", Message: "direction must be incoming or outgoing"}
+	}
+	switch status {
+	case "", models.PaymentRequestPending, models.PaymentRequestPaid, models.PaymentRequestDeclined,
+		models.PaymentRequestCancelled, models.PaymentRequestExpired:
+	default:
+		return nil, &utils.ValidationError{Field: "status", Message: "status must be one of pending, paid, declined, cancelled, expired"}
+	}
+
+	requests, err := s.requestRepo.ListByAccount(ctx, accountID, direction == models.TransactionDirectionIncoming, status)
+	if err != nil {
+		return nil, wrapInternal("failed to list payment requests", err)
+	}
+
+	responses := make([]*models.PaymentRequestResponse, len(requests))
+	for i, request := range requests {
+		responses[i] = paymentRequestResponse(request, accountID)
+	}
+	return responses, nil
+}
+
+func (s *PaymentRequestService) Get(ctx context.Context, accountID, requestID int) (*models.PaymentRequestResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.Get")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	request, err := s.visibleRequest(ctx, accountID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return paymentRequestResponse(request, accountID), nil
+}
+
+// Pay transfers the requested amount from the payer to the requester. The
+// transfer and the status change commit together, so a request is never
+// paid twice.
+func (s *PaymentRequestService) Pay(ctx context.Context, accountID, requestID int) (*models.PaymentRequestPayResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.Pay")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	request, err := s.pendingRequest(ctx, requestID, func(r *models.PaymentRequest) bool { return r.PayerAccountID == accountID })
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Payment request"
+	if request.Memo != "" {
+		description += ": " + request.Memo
+	}
+
+	var transaction *models.TransactionResponse
+	txCtx, runAfterCommit := withAfterCommit(ctx)
+	err = s.db.WithTransaction(txCtx, func(ctx context.Context) error {
+		var err error
+		transaction, err = s.transactionService.Transfer(ctx, accountID, &models.TransferRequest{
+			ToAccountID: request.RequesterAccountID,
+			Amount:      request.Amount,
+			Description: description,
+		})
+		if err != nil {
+			return err
+		}
+		err = s.requestRepo.Resolve(ctx, requestID, models.PaymentRequestPaid, &transaction.ID, transaction.CreatedAt)
+		if errors.Is(err, repository.ErrNotFound) {
+			// Declined, cancelled or paid since it was loaded
+			return Conflict("payment_request_resolved", "payment request is no longer pending")
+		}
+		return err
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to pay payment request", err)
+	}
+	runAfterCommit()
+
+	request, err = s.requestRepo.GetByID(ctx, requestID)
+	if err != nil {
+		return nil, wrapInternal("failed to pay payment request", err)
+	}
+	notifyPaymentRequest(ctx, s.notifier, request.RequesterAccountID, notifications.EventPaymentRequestPaid, request)
+	return &models.PaymentRequestPayResponse{
+		Request:     paymentRequestResponse(request, accountID),
+		Transaction: transaction,
+	}, nil
+}
+
+// Decline is the payer turning a request down
+func (s *PaymentRequestService) Decline(ctx context.Context, accountID, requestID int) (*models.PaymentRequestResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.Decline")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	request, err := s.resolve(ctx, requestID, models.PaymentRequestDeclined, func(r *models.PaymentRequest) bool { return r.PayerAccountID == accountID })
+	if err != nil {
+		return nil, err
+	}
+	notifyPaymentRequest(ctx, s.notifier, request.RequesterAccountID, notifications.EventPaymentRequestDeclined, request)
+	return paymentRequestResponse(request, accountID), nil
+}
+
+// Cancel is the requester withdrawing a request
+func (s *PaymentRequestService) Cancel(ctx context.Context, accountID, requestID int) (*models.PaymentRequestResponse, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.Cancel")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	request, err := s.resolve(ctx, requestID, models.PaymentRequestCancelled, func(r *models.PaymentRequest) bool { return r.RequesterAccountID == accountID })
+	if err != nil {
+		return nil, err
+	}
+	notifyPaymentRequest(ctx, s.notifier, request.PayerAccountID, notifications.EventPaymentRequestCancelled, request)
+	return paymentRequestResponse(request, accountID), nil
+}
+
+// ExpireDue marks pending requests past their expiry as expired and tells
+// both sides. It returns how many requests expired.
+func (s *PaymentRequestService) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "PaymentRequestService.ExpireDue")
+	defer span.End()
+
+	requests, err := s.requestRepo.ListExpired(ctx, now)
+	if err != nil {
+		return 0, wrapInternal("failed to list expired payment requests", err)
+	}
+
+	expired := 0
+	for _, request := range requests {
+		err := s.requestRepo.Resolve(ctx, request.ID, models.PaymentRequestExpired, nil, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			// Paid, declined or cancelled since it was listed
+			continue
+		}
+		if err != nil {
+			return expired, wrapInternal("failed to expire payment request", err)
+		}
+		expired++
+		request.Status = models.PaymentRequestExpired
+		notifyPaymentRequest(ctx, s.notifier, request.RequesterAccountID, notifications.EventPaymentRequestExpired, request)
+		notifyPaymentRequest(ctx, s.notifier, request.PayerAccountID, notifications.EventPaymentRequestExpired, request)
+	}
+	span.SetAttribute("payment_requests.expired", expired)
+	return expired, nil
+}
+
+// resolve moves a pending request that allowed accepts to status
+func (s *PaymentRequestService) resolve(ctx context.Context, requestID int, status string, allowed func(*models.PaymentRequest) bool) (*models.PaymentRequest, error) {
+	if _, err := s.pendingRequest(ctx, requestID, allowed); err != nil {
+		return nil, err
+	}
+
+	err := s.requestRepo.Resolve(ctx, requestID, status, nil, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, Conflict("payment_request_resolved", "payment request is no longer pending")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to update payment request", err)
+	}
+
+	request, err := s.requestRepo.GetByID(ctx, requestID)
+	if err != nil {
+		return nil, wrapInternal("failed to update payment request", err)
+	}
+	return request, nil
+}
+
+// pendingRequest loads a request the caller may act on and checks it can
+// still be acted on. A request past its expiry is treated as expired even
+// before the expiry job has marked it.
+func (s *PaymentRequestService) pendingRequest(ctx context.Context, requestID int, allowed func(*models.PaymentRequest) bool) (*models.PaymentRequest, error) {
+	request, err := s.requestRepo.GetByID(ctx, requestID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "payment_request_not_found", "payment request not found")
+	}
+	if !allowed(request) {
+		return nil, NotFound("payment_request_not_found", "payment request not found")
+	}
+	if request.Status != models.PaymentRequestPending {
+		return nil, Conflict("payment_request_resolved", "payment request is already "+request.Status)
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		return nil, Conflict("payment_request_expired", "payment request has expired")
+	}
+	return request, nil
+}
+
+// visibleRequest loads a request the caller is a party to. Other requests
+// are reported as missing so IDs can't be probed.
+func (s *PaymentRequestService) visibleRequest(ctx context.Context, accountID, requestID int) (*models.PaymentRequest, error) {
+	request, err := s.requestRepo.GetByID(ctx, requestID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "payment_request_not_found", "payment request not found")
+	}
+	if request.RequesterAccountID != accountID && request.PayerAccountID != accountID {
+		return nil, NotFound("payment_request_not_found", "payment request not found")
+	}
+	return request, nil
+}
+
+func (s *PaymentRequestService) resolvePayer(ctx context.Context, accountID int, req *models.CreatePaymentRequestRequest) (*models.Account, error) {
+	given := 0
+	for _, set := range []bool{req.FromAccountNumber != "", req.FromEmail != "", req.PayeeID != 0} {
+		if set {
+			given++
+		}
+	}
+	if given == 0 {
+		return nil, &utils.ValidationError{Field: "from_account_number", Message: "one of from_account_number, from_email or payee_id is required"}
+	}
+	if given > 1 {
+		return nil, Validation("ambiguous_payer", "give only one of from_account_number, from_email or payee_id")
+	}
+
+	switch {
+	case req.FromAccountNumber != "":
+		return findByAccountNumber(ctx, s.accountRepo, s.transactionService.iban, req.FromAccountNumber, "from_account_number")
+
+	case req.FromEmail != "":
+		if err := utils.ValidateEmail(req.FromEmail); err != nil {
+			return nil, err
+		}
+		payer, err := s.accountRepo.GeyByEmail(ctx, strings.ToLower(strings.TrimSpace(req.FromEmail)))
+		if err != nil {
+			return nil, notFoundOrInternal(err, "payer_not_found", "payer account not found")
+		}
+		return payer, nil
+
+	default:
+		payee, err := s.payeeRepo.GetByID(ctx, req.PayeeID)
+		if err != nil {
+			return nil, notFoundOrInternal(err, "payee_not_found", "payee not found")
+		}
+		if payee.AccountID != accountID {
+			return nil, NotFound("payee_not_found", "payee not found")
+		}
+		payer, err := s.accountRepo.GetByID(ctx, payee.PayeeAccountID)
+		if err != nil {
+			return nil, notFoundOrInternal(err, "payer_not_found", "payer account not found")
+		}
+		return payer, nil
+	}
+}
+
+// notifyPaymentRequest tells accountID about a change to request
+func notifyPaymentRequest(ctx context.Context, notifier notifications.Notifier, accountID int, eventType string, request *models.PaymentRequest) {
+	sendNotification(ctx, notifier, accountID, eventType, map[string]any{
+		"payment_request_id": request.ID,
+		"amount":             request.Amount,
+		"memo":               request.Memo,
+		"status":             request.Status,
+		"requester":          utils.MaskName(request.RequesterName, ""),
+		"payer":              utils.MaskName(request.PayerName, ""),
+	})
+}
+
+func paymentRequestResponse(request *models.PaymentRequest, accountID int) *models.PaymentRequestResponse {
+	direction := models.TransactionDirectionOutgoing
+	if request.PayerAccountID == accountID {
+		direction = models.TransactionDirectionIncoming
+	}
+	return &models.PaymentRequestResponse{
+		ID:        request.ID,
+		Direction: direction,
+		Requester: &models.PaymentRequestParty{
+			AccountNumber: request.RequesterAccountNumber,
+			MaskedName:    utils.MaskName(request.RequesterName, ""),
+		},
+		Payer: &models.PaymentRequestParty{
+			AccountNumber: request.PayerAccountNumber,
+			MaskedName:    utils.MaskName(request.PayerName, ""),
+		},
+		Amount:        request.Amount,
+		Memo:          request.Memo,
+		Status:        request.Status,
+		TransactionID: request.TransactionID,
+		ExpiresAt:     request.ExpiresAt,
+		CreatedAt:     request.CreatedAt,
+		ResolvedAt:    request.ResolvedAt,
+	}
+}
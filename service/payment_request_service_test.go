@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestPaymentRequestService(t *testing.T) (*PaymentRequestService, *TransactionService, *memory.Store, *notifications.MemoryNotifier) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	notifier := &notifications.MemoryNotifier{}
+	requests := NewPaymentRequestService(store, store.Accounts(), store.Payees(), store.PaymentRequests(), transactions, notifier)
+	return requests, transactions, store, notifier
+}
+
+func eventTypes(notifier *notifications.MemoryNotifier, accountID int) []string {
+	types := make([]string, 0)
+	for _, event := range notifier.Events() {
+		if event.AccountID == accountID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestPaymentRequestValidation(t *testing.T) {
+	requests, svc, store, _ := newTestPaymentRequestService(t)
+	ctx := context.Background()
+	requester := createFundedAccount(t, store, svc, "requester@example.com", 0)
+	createFundedAccount(t, store, svc, "payer@example.com", 0)
+	past := time.Now().Add(-time.Hour)
+	far := time.Now().Add(60 * 24 * time.Hour)
+
+	tests := []struct {
+		name string
+		req  models.CreatePaymentRequestRequest
+	}{
+		{"no payer", models.CreatePaymentRequestRequest{Amount: 10}},
+		{"zero amount", models.CreatePaymentRequestRequest{FromEmail: "payer@example.com"}},
+		{"expiry in the past", models.CreatePaymentRequestRequest{FromEmail: "payer@example.com", Amount: 10, ExpiresAt: &past}},
+		{"expiry too far", models.CreatePaymentRequestRequest{FromEmail: "payer@example.com", Amount: 10, ExpiresAt: &far}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := requests.Create(ctx, requester.ID, &tt.req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	_, err := requests.Create(ctx, requester.ID, &models.CreatePaymentRequestRequest{FromEmail: "requester@example.com", Amount: 10})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a request to yourself to be rejected, got %v", err)
+	}
+}
+
+func TestPayPaymentRequest(t *testing.T) {
+	requests, svc, store, notifier := newTestPaymentRequestService(t)
+	ctx := context.Background()
+	requester := createFundedAccount(t, store, svc, "requester@example.com", 0)
+	payer := createFundedAccount(t, store, svc, "payer@example.com", 100)
+	outsider := createFundedAccount(t, store, svc, "outsider@example.com", 0)
+
+	request, err := requests.Create(ctx, requester.ID, &models.CreatePaymentRequestRequest{FromAccountNumber: payer.AccountNumber, Amount: 25, Memo: "Dinner"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if request.Direction != models.TransactionDirectionOutgoing || request.Status != models.PaymentRequestPending {
+		t.Errorf("expected a pending outgoing request, got %+v", request)
+	}
+
+	incoming, err := requests.List(ctx, payer.ID, models.TransactionDirectionIncoming, models.PaymentRequestPending)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(incoming) != 1 || incoming[0].ID != request.ID {
+		t.Fatalf("expected the payer to see the request, got %+v", incoming)
+	}
+
+	if _, err := requests.Pay(ctx, outsider.ID, request.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected someone else's request to be not found, got %v", err)
+	}
+	if _, err := requests.Pay(ctx, requester.ID, request.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the requester not to be able to pay, got %v", err)
+	}
+
+	paid, err := requests.Pay(ctx, payer.ID, request.ID)
+	if err != nil {
+		t.Fatalf("pay failed: %v", err)
+	}
+	if paid.Request.Status != models.PaymentRequestPaid || paid.Request.TransactionID == nil || *paid.Request.TransactionID != paid.Transaction.ID {
+		t.Errorf("expected the request to be paid by transaction %d, got %+v", paid.Transaction.ID, paid.Request)
+	}
+	if got := balanceOf(t, svc, payer.ID); got != 75 {
+		t.Errorf("expected payer balance 75, got %.2f", got)
+	}
+	if got := balanceOf(t, svc, requester.ID); got != 25 {
+		t.Errorf("expected requester balance 25, got %.2f", got)
+	}
+
+	if _, err := requests.Pay(ctx, payer.ID, request.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected paying twice to conflict, got %v", err)
+	}
+	if _, err := requests.Cancel(ctx, requester.ID, request.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected cancelling a paid request to conflict, got %v", err)
+	}
+
+	if got := eventTypes(notifier, payer.ID); len(got) != 1 || got[0] != notifications.EventPaymentRequested {
+		t.Errorf("expected the payer to be told about the request, got %v", got)
+	}
+	if got := eventTypes(notifier, requester.ID); len(got) != 1 || got[0] != notifications.EventPaymentRequestPaid {
+		t.Errorf("expected the requester to be told it was paid, got %v", got)
+	}
+}
+
+func TestPayPaymentRequestWithoutFunds(t *testing.T) {
+	requests, svc, store, _ := newTestPaymentRequestService(t)
+	ctx := context.Background()
+	requester := createFundedAccount(t, store, svc, "requester@example.com", 0)
+	payer := createFundedAccount(t, store, svc, "payer@example.com", 10)
+
+	request, err := requests.Create(ctx, requester.ID, &models.CreatePaymentRequestRequest{FromEmail: "payer@example.com", Amount: 25})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := requests.Pay(ctx, payer.ID, request.ID); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+
+	// The failed payment leaves the request pending
+	got, err := requests.Get(ctx, payer.ID, request.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Status != models.PaymentRequestPending {
+		t.Errorf("expected the request to stay pending, got %s", got.Status)
+	}
+}
+
+func TestDeclineCancelAndExpirePaymentRequests(t *testing.T) {
+	requests, svc, store, notifier := newTestPaymentRequestService(t)
+	ctx := context.Background()
+	requester := createFundedAccount(t, store, svc, "requester@example.com", 0)
+	payer := createFundedAccount(t, store, svc, "payer@example.com", 100)
+
+	create := func() *models.PaymentRequestResponse {
+		t.Helper()
+		request, err := requests.Create(ctx, requester.ID, &models.CreatePaymentRequestRequest{FromEmail: "payer@example.com", Amount: 5})
+		if err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+		return request
+	}
+
+	declined := create()
+	if _, err := requests.Decline(ctx, requester.ID, declined.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected only the payer to be able to decline, got %v", err)
+	}
+	response, err := requests.Decline(ctx, payer.ID, declined.ID)
+	if err != nil {
+		t.Fatalf("decline failed: %v", err)
+	}
+	if response.Status != models.PaymentRequestDeclined || response.ResolvedAt == nil {
+		t.Errorf("expected a declined request, got %+v", response)
+	}
+
+	cancelled := create()
+	if _, err := requests.Cancel(ctx, payer.ID, cancelled.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected only the requester to be able to cancel, got %v", err)
+	}
+	if _, err := requests.Cancel(ctx, requester.ID, cancelled.ID); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+
+	expiring := create()
+	later := time.Now().Add(8 * 24 * time.Hour)
+	expired, err := requests.ExpireDue(ctx, later)
+	if err != nil {
+		t.Fatalf("expire failed: %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("expected 1 request to expire, got %d", expired)
+	}
+	if _, err := requests.Pay(ctx, payer.ID, expiring.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected an expired request to be unpayable, got %v", err)
+	}
+
+	want := []string{
+		notifications.EventPaymentRequestDeclined,
+		notifications.EventPaymentRequestExpired,
+	}
+	got := eventTypes(notifier, requester.ID)
+	if len(got) != len(want) {
+		t.Fatalf("expected requester events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected requester events %v, got %v", want, got)
+		}
+	}
+	if got := eventTypes(notifier, payer.ID); len(got) != 5 || got[2] != notifications.EventPaymentRequestCancelled || got[4] != notifications.EventPaymentRequestExpired {
+		t.Errorf("expected the payer to hear about 3 requests, the cancellation and the expiry, got %v", got)
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// PotService manages savings pots and moves money between an account's
+// balance and its pots. Every move is a pot transaction on the ledger.
+type PotService struct {
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	potRepo         repository.PotStore
+	transactionRepo repository.TransactionStore
+}
+
+func NewPotService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	potRepo repository.PotStore,
+	transactionRepo repository.TransactionStore,
+) *PotService {
+	return &PotService{
+		db:              database,
+		accountRepo:     accountRepo,
+		potRepo:         potRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (s *PotService) List(ctx context.Context, accountID int) ([]*models.PotResponse, error) {
+	ctx, span := tracing.Start(ctx, "PotService.List")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	pots, err := s.potRepo.ListByAccount(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to list pots", err)
+	}
+
+	responses := make([]*models.PotResponse, len(pots))
+	for i, pot := range pots {
+		responses[i] = pot.ToResponse()
+	}
+	return responses, nil
+}
+
+// Create opens a pot. A pot created with round-ups on takes them over from
+// any other pot.
+func (s *PotService) Create(ctx context.Context, accountID int, req *models.CreatePotRequest) (*models.PotResponse, error) {
+	ctx, span := tracing.Start(ctx, "PotService.Create")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	name, err := potName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if req.TargetAmount != nil {
+		if err := utils.ValidateTargetAmount(*req.TargetAmount); err != nil {
+			return nil, err
+		}
+	}
+	targetDate, err := potTargetDate(req.TargetDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.activeAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	var pot *models.Pot
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if req.RoundUp {
+			if err := s.potRepo.ClearRoundUp(ctx, accountID); err != nil {
+				return err
+			}
+		}
+		pot, err = s.potRepo.Create(ctx, &models.Pot{
+			AccountID:    accountID,
+			Name:         name,
+			TargetAmount: req.TargetAmount,
+			TargetDate:   targetDate,
+			RoundUp:      req.RoundUp,
+		})
+		return err
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, Conflict("pot_exists", "you already have a pot with this name")
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to create pot", err)
+	}
+	return pot.ToResponse(), nil
+}
+
+func (s *PotService) Update(ctx context.Context, accountID, potID int, req *models.UpdatePotRequest) (*models.PotResponse, error) {
+	ctx, span := tracing.Start(ctx, "PotService.Update")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	pot, err := s.ownedPot(ctx, accountID, potID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		if pot.Name, err = potName(*req.Name); err != nil {
+			return nil, err
+		}
+	}
+	if req.TargetAmount != nil {
+		pot.TargetAmount = nil
+		if *req.TargetAmount != 0 {
+			if err := utils.ValidateTargetAmount(*req.TargetAmount); err != nil {
+				return nil, err
+			}
+			pot.TargetAmount = req.TargetAmount
+		}
+	}
+	if req.TargetDate != nil {
+		if pot.TargetDate, err = potTargetDate(*req.TargetDate); err != nil {
+			return nil, err
+		}
+	}
+	if req.RoundUp != nil {
+		pot.RoundUp = *req.RoundUp
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if req.RoundUp != nil && *req.RoundUp {
+			if err := s.potRepo.ClearRoundUp(ctx, accountID); err != nil {
+				return err
+			}
+		}
+		return s.potRepo.Update(ctx, pot)
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, Conflict("pot_exists", "you already have a pot with this name")
+	}
+	if err != nil {
+		return nil, notFoundOrInternal(err, "pot_not_found", "pot not found")
+	}
+	return pot.ToResponse(), nil
+}
+
+// Close moves whatever is left in the pot back to the account balance and
+// closes it
+func (s *PotService) Close(ctx context.Context, accountID, potID int) error {
+	ctx, span := tracing.Start(ctx, "PotService.Close")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	if _, err := s.ownedPot(ctx, accountID, potID); err != nil {
+		return err
+	}
+	if _, err := s.activeAccount(ctx, accountID); err != nil {
+		return err
+	}
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		pot, err := s.potRepo.GetByID(ctx, potID)
+		if err != nil {
+			return err
+		}
+		if pot.Balance > 0 {
+			if _, err := moveFromPot(ctx, s.accountRepo, s.potRepo, s.transactionRepo, accountID, balance, pot, pot.Balance); err != nil {
+				return err
+			}
+		}
+		return s.potRepo.Close(ctx, potID)
+	})
+	if err != nil {
+		return notFoundOrInternal(err, "pot_not_found", "pot not found")
+	}
+	return nil
+}
+
+// Deposit moves money from the account balance into a pot. Only money the
+// account holds can be saved; the overdraft can't be moved into a pot.
+func (s *PotService) Deposit(ctx context.Context, accountID, potID int, req *models.PotMoveRequest) (*models.PotMoveResponse, error) {
+	ctx, span := tracing.Start(ctx, "PotService.Deposit")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	return s.move(ctx, accountID, potID, req, func(ctx context.Context, balance float64, pot *models.Pot) (*models.Transaction, error) {
+		if balance < req.Amount {
+			return nil, InsufficientFunds(balance, req.Amount)
+		}
+		return moveToPot(ctx, s.accountRepo, s.potRepo, s.transactionRepo, accountID, balance, pot, req.Amount, nil)
+	})
+}
+
+// Withdraw moves money from a pot back to the account balance
+func (s *PotService) Withdraw(ctx context.Context, accountID, potID int, req *models.PotMoveRequest) (*models.PotMoveResponse, error) {
+	ctx, span := tracing.Start(ctx, "PotService.Withdraw")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	return s.move(ctx, accountID, potID, req, func(ctx context.Context, balance float64, pot *models.Pot) (*models.Transaction, error) {
+		if pot.Balance < req.Amount {
+			return nil, InsufficientFunds(pot.Balance, req.Amount)
+		}
+		return moveFromPot(ctx, s.accountRepo, s.potRepo, s.transactionRepo, accountID, balance, pot, req.Amount)
+	})
+}
+
+// move runs fn with the account row locked, passing the account balance
+// and the pot as they stand under the lock
+func (s *PotService) move(
+	ctx context.Context,
+	accountID, potID int,
+	req *models.PotMoveRequest,
+	fn func(ctx context.Context, balance float64, pot *models.Pot) (*models.Transaction, error),
+) (*models.PotMoveResponse, error) {
+	if err := utils.ValidateAmount(req.Amount); err != nil {
+		return nil, err
+	}
+	if _, err := s.ownedPot(ctx, accountID, potID); err != nil {
+		return nil, err
+	}
+	account, err := s.activeAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pot *models.Pot
+	var transaction *models.Transaction
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if pot, err = s.potRepo.GetByID(ctx, potID); err != nil {
+			return err
+		}
+		if pot.ClosedAt != nil {
+			return NotFound("pot_not_found", "pot not found")
+		}
+		if transaction, err = fn(ctx, balance, pot); err != nil {
+			return err
+		}
+		pot, err = s.potRepo.GetByID(ctx, potID)
+		return err
+	})
+	if err != nil {
+		return nil, notFoundOrInternal(err, "pot_not_found", "pot not found")
+	}
+	response := &models.PotMoveResponse{Pot: pot.ToResponse(), Transaction: transaction.ToResponse()}
+	setAccountNumbers(map[int]string{accountID: account.AccountNumber}, response.Transaction)
+	return response, nil
+}
+
+// ownedPot loads an open pot belonging to accountID. Other accounts' pots
+// are reported as missing so IDs can't be probed.
+func (s *PotService) ownedPot(ctx context.Context, accountID, potID int) (*models.Pot, error) {
+	pot, err := s.potRepo.GetByID(ctx, potID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "pot_not_found", "pot not found")
+	}
+	if pot.AccountID != accountID || pot.ClosedAt != nil {
+		return nil, NotFound("pot_not_found", "pot not found")
+	}
+	return pot, nil
+}
+
+func (s *PotService) activeAccount(ctx context.Context, accountID int) (*models.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if account.Status != models.AccountStatusActice {
+		return nil, accountInactive(account.Status)
+	}
+	return account, nil
+}
+
+// moveToPot takes amount from the account balance, which the caller has
+// locked and read as balance, and puts it in pot. relatedTransactionID links
+// a round-up to the withdrawal it came from.
+func moveToPot(
+	ctx context.Context,
+	accountRepo repository.AccountStore,
+	potRepo repository.PotStore,
+	transactionRepo repository.TransactionStore,
+	accountID int,
+	balance float64,
+	pot *models.Pot,
+	amount float64,
+	relatedTransactionID *int,
+) (*models.Transaction, error) {
+	if err := accountRepo.UpdateBalance(ctx, accountID, sumAmounts(balance, -amount)); err != nil {
+		return nil, err
+	}
+	if err := potRepo.UpdateBalance(ctx, pot.ID, sumAmounts(pot.Balance, amount)); err != nil {
+		return nil, err
+	}
+	return transactionRepo.Insert(ctx, &models.Transaction{
+		FromAccountID:        &accountID,
+		Amount:               amount,
+		Type:                 models.TransactionTypePot,
+		Description:          "Moved to " + pot.Name,
+		RelatedTransactionID: relatedTransactionID,
+		PotID:                &pot.ID,
+	})
+}
+
+// moveFromPot is the reverse of moveToPot
+func moveFromPot(
+	ctx context.Context,
+	accountRepo repository.AccountStore,
+	potRepo repository.PotStore,
+	transactionRepo repository.TransactionStore,
+	accountID int,
+	balance float64,
+	pot *models.Pot,
+	amount float64,
+) (*models.Transaction, error) {
+	if err := potRepo.UpdateBalance(ctx, pot.ID, sumAmounts(pot.Balance, -amount)); err != nil {
+		return nil, err
+	}
+	if err := accountRepo.UpdateBalance(ctx, accountID, sumAmounts(balance, amount)); err != nil {
+		return nil, err
+	}
+	return transactionRepo.Insert(ctx, &models.Transaction{
+		ToAccountID: &accountID,
+		Amount:      amount,
+		Type:        models.TransactionTypePot,
+		Description: "Moved from " + pot.Name,
+		PotID:       &pot.ID,
+	})
+}
+
+func potName(value string) (string, error) {
+	name := utils.SanitizeString(value)
+	if err := utils.ValidateRequired(name, "name"); err != nil {
+		return "", err
+	}
+	if len(name) > 50 {
+		return "", &utils.ValidationError{Field: "name", Message: "name must be at most 50 characters"}
+	}
+	return name, nil
+}
+
+// potTargetDate parses an optional YYYY-MM-DD target, which can't be in
+// the past. An empty value means no target date.
+func potTargetDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, &utils.ValidationError{Field: "target_date", Message: "target_date must be a date in YYYY-MM-DD format"}
+	}
+	now := time.Now().UTC()
+	if date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		return nil, &utils.ValidationError{Field: "target_date", Message: "target_date cannot be in the past"}
+	}
+	return &date, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestPotService(t *testing.T) (*PotService, *TransactionService, *memory.Store) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	pots := NewPotService(store, store.Accounts(), store.Pots(), store.Transactions())
+	return pots, transactions, store
+}
+
+func createPot(t *testing.T, svc *PotService, accountID int, req *models.CreatePotRequest) *models.PotResponse {
+	t.Helper()
+	pot, err := svc.Create(context.Background(), accountID, req)
+	if err != nil {
+		t.Fatalf("failed to create pot: %v", err)
+	}
+	return pot
+}
+
+func TestPotCreateValidation(t *testing.T) {
+	pots, svc, store := newTestPotService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "pots@example.com", 0)
+	negative := -5.0
+
+	tests := []struct {
+		name string
+		req  models.CreatePotRequest
+	}{
+		{"missing name", models.CreatePotRequest{Name: "  "}},
+		{"negative target", models.CreatePotRequest{Name: "Holiday", TargetAmount: &negative}},
+		{"bad date", models.CreatePotRequest{Name: "Holiday", TargetDate: "next year"}},
+		{"past date", models.CreatePotRequest{Name: "Holiday", TargetDate: "2001-01-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pots.Create(ctx, account.ID, &tt.req)
+			var validationErr *utils.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Errorf("expected validation error, got %v", err)
+			}
+		})
+	}
+
+	createPot(t, pots, account.ID, &models.CreatePotRequest{Name: "Holiday"})
+	_, err := pots.Create(ctx, account.ID, &models.CreatePotRequest{Name: "holiday"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected conflict for a duplicate name, got %v", err)
+	}
+}
+
+func TestPotMovesSplitBalance(t *testing.T) {
+	pots, svc, store := newTestPotService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "saver@example.com", 100)
+	other := createFundedAccount(t, store, svc, "other@example.com", 0)
+	target := 200.0
+	pot := createPot(t, pots, account.ID, &models.CreatePotRequest{Name: "Bike", TargetAmount: &target})
+
+	moved, err := pots.Deposit(ctx, account.ID, pot.ID, &models.PotMoveRequest{Amount: 60})
+	if err != nil {
+		t.Fatalf("deposit to pot failed: %v", err)
+	}
+	if moved.Pot.Balance != 60 || moved.Pot.Progress == nil || *moved.Pot.Progress != 30 {
+		t.Errorf("expected pot balance 60 at 30%%, got %+v", moved.Pot)
+	}
+	if moved.Transaction.Type != models.TransactionTypePot || moved.Transaction.PotID == nil || *moved.Transaction.PotID != pot.ID {
+		t.Errorf("expected a pot transaction for pot %d, got %+v", pot.ID, moved.Transaction)
+	}
+
+	balance, err := svc.GetBalance(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	if balance.Balance != 40 || balance.PotsTotal != 60 || balance.TotalBalance != 100 {
+		t.Errorf("expected 40 + 60 in pots = 100, got %+v", balance)
+	}
+
+	if _, err := pots.Deposit(ctx, account.ID, pot.ID, &models.PotMoveRequest{Amount: 41}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds moving more than the balance, got %v", err)
+	}
+	if _, err := pots.Withdraw(ctx, account.ID, pot.ID, &models.PotMoveRequest{Amount: 61}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds taking more than the pot holds, got %v", err)
+	}
+	if _, err := pots.Withdraw(ctx, other.ID, pot.ID, &models.PotMoveRequest{Amount: 10}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another account's pot to be not found, got %v", err)
+	}
+
+	if _, err := pots.Withdraw(ctx, account.ID, pot.ID, &models.PotMoveRequest{Amount: 10}); err != nil {
+		t.Fatalf("withdraw from pot failed: %v", err)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 50 {
+		t.Errorf("expected balance 50 after taking 10 back, got %.2f", got)
+	}
+
+	// Closing returns what is left to the balance
+	if err := pots.Close(ctx, account.ID, pot.ID); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 100 {
+		t.Errorf("expected balance 100 after closing, got %.2f", got)
+	}
+	list, err := pots.List(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected no open pots, got %d", len(list))
+	}
+}
+
+func TestWithdrawSweepsRoundUp(t *testing.T) {
+	pots, svc, store := newTestPotService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "roundup@example.com", 20)
+	first := createPot(t, pots, account.ID, &models.CreatePotRequest{Name: "Change", RoundUp: true})
+	second := createPot(t, pots, account.ID, &models.CreatePotRequest{Name: "Spare", RoundUp: true})
+
+	response, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 3.40})
+	if err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if response.RoundUp == nil || response.RoundUp.Amount != 0.6 {
+		t.Fatalf("expected a 0.60 round-up, got %+v", response.RoundUp)
+	}
+	if *response.RoundUp.PotID != second.ID || *response.RoundUp.RelatedTransactionID != response.ID {
+		t.Errorf("expected the round-up to go to the newest round-up pot and link to %d, got %+v", response.ID, response.RoundUp)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 16 {
+		t.Errorf("expected balance 16.00, got %.2f", got)
+	}
+
+	// Whole amounts have nothing to round up
+	response, err = svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 5})
+	if err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if response.RoundUp != nil {
+		t.Errorf("expected no round-up on a whole amount, got %+v", response.RoundUp)
+	}
+
+	// The round-up can take the balance to zero but never below it
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 10.50}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 0 {
+		t.Errorf("expected balance 0.00, got %.2f", got)
+	}
+	if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 0.30}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	response, err = svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 0.25})
+	if err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if response.RoundUp != nil {
+		t.Errorf("expected the round-up to be skipped, got %+v", response.RoundUp)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 0.05 {
+		t.Errorf("expected balance 0.05, got %.2f", got)
+	}
+
+	list, err := pots.List(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, pot := range list {
+		if pot.ID == first.ID && (pot.RoundUp || pot.Balance != 0) {
+			t.Errorf("expected the first pot to have handed over round-ups, got %+v", pot)
+		}
+		if pot.ID == second.ID && pot.Balance != 1.1 {
+			t.Errorf("expected 1.10 swept into the round-up pot, got %.2f", pot.Balance)
+		}
+	}
+}
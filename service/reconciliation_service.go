@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/wizzyszn/go_bank/metrics"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// ReconciliationService checks every account's stored balance against the
+// sum of its completed transactions and records the accounts that differ.
+// Accounts that don't reconcile can be suspended until someone looks.
+type ReconciliationService struct {
+	db                 repository.TxRunner
+	accountRepo        repository.AccountStore
+	transactionRepo    repository.TransactionStore
+	reconciliationRepo repository.ReconciliationStore
+	notifier           notifications.Notifier
+	// freeze is whether scheduled runs suspend accounts that don't reconcile
+	freeze bool
+}
+
+func NewReconciliationService(
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	transactionRepo repository.TransactionStore,
+	reconciliationRepo repository.ReconciliationStore,
+	notifier notifications.Notifier,
+	freeze bool,
+) *ReconciliationService {
+	return &ReconciliationService{
+		db:                 database,
+		accountRepo:        accountRepo,
+		transactionRepo:    transactionRepo,
+		reconciliationRepo: reconciliationRepo,
+		notifier:           notifier,
+		freeze:             freeze,
+	}
+}
+
+// RunScheduled reconciles once per UTC day; later calls the same day do
+// nothing and return nil
+func (s *ReconciliationService) RunScheduled(ctx context.Context, now time.Time) (*models.ReconciliationResponse, error) {
+	latest, err := s.reconciliationRepo.GetLatestRun(ctx, models.ReconciliationScheduled)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, wrapInternal("failed to get latest reconciliation", err)
+	}
+	if latest != nil && !latest.StartedAt.UTC().Before(startOfDay(now)) {
+		return nil, nil
+	}
+	return s.Run(ctx, models.ReconciliationScheduled, s.freeze)
+}
+
+// RunManual reconciles now. req.Freeze overrides the configured choice.
+func (s *ReconciliationService) RunManual(ctx context.Context, req *models.RunReconciliationRequest) (*models.ReconciliationResponse, error) {
+	freeze := s.freeze
+	if req.Freeze != nil {
+		freeze = *req.Freeze
+	}
+	return s.Run(ctx, models.ReconciliationManual, freeze)
+}
+
+// Run checks every account and records the run with its discrepancies.
+// With freeze set, active accounts that still don't reconcile once locked
+// are suspended and their holder told.
+func (s *ReconciliationService) Run(ctx context.Context, trigger string, freeze bool) (*models.ReconciliationResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Run")
+	defer span.End()
+	span.SetAttribute("reconciliation.trigger", trigger)
+
+	run := &models.ReconciliationRun{Trigger: trigger, StartedAt: time.Now()}
+	checked, discrepancies, err := s.reconciliationRepo.CheckBalances(ctx)
+	if err != nil {
+		return nil, wrapInternal("failed to check balances", err)
+	}
+	run.AccountsChecked = checked
+	run.Discrepancies = len(discrepancies)
+
+	if freeze {
+		for _, d := range discrepancies {
+			frozen, err := s.freezeAccount(ctx, d.AccountID)
+			if err != nil {
+				// The discrepancy is still recorded below
+				log.Printf("failed to freeze account %d: %v", d.AccountID, err)
+				continue
+			}
+			d.Frozen = frozen
+			if frozen {
+				run.AccountsFrozen++
+			}
+		}
+	}
+	run.FinishedAt = time.Now()
+
+	created, err := s.reconciliationRepo.CreateRun(ctx, run, discrepancies)
+	if err != nil {
+		return nil, wrapInternal("failed to record reconciliation", err)
+	}
+	span.SetAttribute("reconciliation.discrepancies", created.Discrepancies)
+
+	for _, d := range discrepancies {
+		if d.Frozen {
+			sendNotification(ctx, s.notifier, d.AccountID, notifications.EventAccountStatusChanged, map[string]any{
+				"status": models.AccountStatusSuspended,
+			})
+		}
+	}
+	return &models.ReconciliationResponse{ReconciliationRun: created, Details: discrepancies}, nil
+}
+
+// freezeAccount suspends an active account if, with it locked, its balance
+// still differs from its ledger
+func (s *ReconciliationService) freezeAccount(ctx context.Context, accountID int) (bool, error) {
+	frozen := false
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		balance, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		account, err := s.accountRepo.GetByID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Status != models.AccountStatusActice {
+			return nil
+		}
+		ledger, err := s.transactionRepo.GetTotalBalance(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if sumAmounts(balance, -ledger) == 0 {
+			return nil
+		}
+		frozen = true
+		return s.accountRepo.SetStatus(ctx, accountID, models.AccountStatusSuspended)
+	})
+	return frozen, err
+}
+
+func (s *ReconciliationService) Get(ctx context.Context, id int) (*models.ReconciliationResponse, error) {
+	run, err := s.reconciliationRepo.GetRun(ctx, id)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "reconciliation_not_found", "reconciliation run not found")
+	}
+	discrepancies, err := s.reconciliationRepo.ListDiscrepancies(ctx, id)
+	if err != nil {
+		return nil, wrapInternal("failed to list discrepancies", err)
+	}
+	return &models.ReconciliationResponse{ReconciliationRun: run, Details: discrepancies}, nil
+}
+
+// List returns the most recent runs, newest first
+func (s *ReconciliationService) List(ctx context.Context, limit int) ([]*models.ReconciliationRun, error) {
+	if limit < 1 || limit > 100 {
+		return nil, &utils.ValidationError{Field: "limit", Message: "limit must be between 1 and 100"}
+	}
+	runs, err := s.reconciliationRepo.ListRuns(ctx, limit)
+	if err != nil {
+		return nil, wrapInternal("failed to list reconciliations", err)
+	}
+	return runs, nil
+}
+
+// Metrics describes the latest run, scheduled or manual. There are none
+// before the first run.
+func (s *ReconciliationService) Metrics(ctx context.Context) ([]metrics.Metric, error) {
+	run, err := s.reconciliationRepo.GetLatestRun(ctx, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		return []metrics.Metric{}, nil
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to get latest reconciliation", err)
+	}
+	discrepancies, err := s.reconciliationRepo.ListDiscrepancies(ctx, run.ID)
+	if err != nil {
+		return nil, wrapInternal("failed to list discrepancies", err)
+	}
+	amounts := make([]float64, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		amounts = append(amounts, math.Abs(d.Difference))
+	}
+
+	return []metrics.Metric{
+		{
+			Name:  "gobank_reconciliation_last_run_timestamp_seconds",
+			Help:  "When the latest balance reconciliation finished.",
+			Type:  metrics.Gauge,
+			Value: float64(run.FinishedAt.Unix()),
+		},
+		{
+			Name:  "gobank_reconciliation_accounts_checked",
+			Help:  "Accounts checked by the latest balance reconciliation.",
+			Type:  metrics.Gauge,
+			Value: float64(run.AccountsChecked),
+		},
+		{
+			Name:  "gobank_reconciliation_discrepancies",
+			Help:  "Accounts whose balance didn't match their transactions in the latest reconciliation.",
+			Type:  metrics.Gauge,
+			Value: float64(run.Discrepancies),
+		},
+		{
+			Name:  "gobank_reconciliation_discrepancy_amount",
+			Help:  "Total absolute difference between stored and ledger balances in the latest reconciliation.",
+			Type:  metrics.Gauge,
+			Value: sumAmounts(amounts...),
+		},
+		{
+			Name:  "gobank_reconciliation_accounts_frozen",
+			Help:  "Accounts suspended by the latest balance reconciliation.",
+			Type:  metrics.Gauge,
+			Value: float64(run.AccountsFrozen),
+		},
+	}, nil
+}
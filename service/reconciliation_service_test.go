@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/metrics"
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+)
+
+func newTestReconciliationService(t *testing.T, freeze bool) (*ReconciliationService, *TransactionService, *memory.Store, *notifications.MemoryNotifier) {
+	t.Helper()
+	svc, store := newTestTransactionService(t)
+	notifier := &notifications.MemoryNotifier{}
+	return NewReconciliationService(store, store.Accounts(), store.Transactions(), store.Reconciliations(), notifier, freeze), svc, store, notifier
+}
+
+func TestReconcileBalances(t *testing.T) {
+	tests := []struct {
+		name       string
+		freeze     bool
+		wantStatus string
+	}{
+		{name: "records discrepancy", freeze: false, wantStatus: models.AccountStatusActice},
+		{name: "freezes account", freeze: true, wantStatus: models.AccountStatusSuspended},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recon, svc, store, notifier := newTestReconciliationService(t, false)
+			ctx := context.Background()
+			clean := createFundedAccount(t, store, svc, "clean@example.com", 100)
+			broken := createFundedAccount(t, store, svc, "broken@example.com", 100)
+			if _, err := svc.Transfer(ctx, clean.ID, &models.TransferRequest{ToAccountID: broken.ID, Amount: 10.1}); err != nil {
+				t.Fatalf("transfer failed: %v", err)
+			}
+			// A balance changed without a transaction behind it
+			if err := store.Accounts().UpdateBalance(ctx, broken.ID, 125.5); err != nil {
+				t.Fatalf("failed to tamper with balance: %v", err)
+			}
+
+			result, err := recon.RunManual(ctx, &models.RunReconciliationRequest{Freeze: &tt.freeze})
+			if err != nil {
+				t.Fatalf("reconciliation failed: %v", err)
+			}
+			if result.Trigger != models.ReconciliationManual || result.AccountsChecked != 2 || result.Discrepancies != 1 {
+				t.Fatalf("unexpected run: %+v", result.ReconciliationRun)
+			}
+			if len(result.Details) != 1 {
+				t.Fatalf("expected 1 discrepancy, got %d", len(result.Details))
+			}
+			d := result.Details[0]
+			if d.AccountID != broken.ID || d.StoredBalance != 125.5 || d.LedgerBalance != 110.1 || d.Difference != 15.4 {
+				t.Errorf("unexpected discrepancy: %+v", d)
+			}
+			if d.TransactionCount != 2 || d.LastTransactionAt == nil || d.Frozen != tt.freeze {
+				t.Errorf("unexpected discrepancy details: %+v", d)
+			}
+
+			account, err := store.Accounts().GetByID(ctx, broken.ID)
+			if err != nil {
+				t.Fatalf("failed to get account: %v", err)
+			}
+			if account.Status != tt.wantStatus {
+				t.Errorf("expected status %q, got %q", tt.wantStatus, account.Status)
+			}
+			wantEvents := 0
+			if tt.freeze {
+				wantEvents = 1
+			}
+			if got := countEvents(notifier, broken.ID, notifications.EventAccountStatusChanged); got != wantEvents {
+				t.Errorf("expected %d status events, got %d", wantEvents, got)
+			}
+
+			stored, err := recon.Get(ctx, result.ID)
+			if err != nil {
+				t.Fatalf("failed to get run: %v", err)
+			}
+			if len(stored.Details) != 1 || stored.Details[0].AccountNumber != broken.AccountNumber || stored.AccountsFrozen != result.AccountsFrozen {
+				t.Errorf("stored run doesn't match: %+v", stored)
+			}
+		})
+	}
+}
+
+func TestReconcileCleanLedger(t *testing.T) {
+	recon, svc, store, _ := newTestReconciliationService(t, true)
+	ctx := context.Background()
+	from := createFundedAccount(t, store, svc, "from@example.com", 0.3)
+	to := createFundedAccount(t, store, svc, "to@example.com", 0)
+	for range 3 {
+		if _, err := svc.Transfer(ctx, from.ID, &models.TransferRequest{ToAccountID: to.ID, Amount: 0.1}); err != nil {
+			t.Fatalf("transfer failed: %v", err)
+		}
+	}
+
+	result, err := recon.RunManual(ctx, &models.RunReconciliationRequest{})
+	if err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+	if result.AccountsChecked != 2 || result.Discrepancies != 0 || result.AccountsFrozen != 0 {
+		t.Errorf("expected a clean run, got %+v", result.ReconciliationRun)
+	}
+}
+
+func TestReconcileScheduledOncePerDay(t *testing.T) {
+	recon, svc, store, _ := newTestReconciliationService(t, true)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "holder@example.com", 50)
+	if err := store.Accounts().UpdateBalance(ctx, account.ID, 40); err != nil {
+		t.Fatalf("failed to tamper with balance: %v", err)
+	}
+	now := time.Now()
+
+	first, err := recon.RunScheduled(ctx, now)
+	if err != nil {
+		t.Fatalf("scheduled run failed: %v", err)
+	}
+	if first == nil || first.Trigger != models.ReconciliationScheduled || first.AccountsFrozen != 1 {
+		t.Fatalf("expected a scheduled run that froze the account, got %+v", first)
+	}
+	again, err := recon.RunScheduled(ctx, now)
+	if err != nil {
+		t.Fatalf("scheduled run failed: %v", err)
+	}
+	if again != nil {
+		t.Errorf("expected no second run the same day, got run %d", again.ID)
+	}
+	// The account is already suspended, so the next day only records it
+	next, err := recon.RunScheduled(ctx, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("scheduled run failed: %v", err)
+	}
+	if next == nil || next.Discrepancies != 1 || next.AccountsFrozen != 0 {
+		t.Errorf("expected the next day's run to record without freezing, got %+v", next)
+	}
+
+	runs, err := recon.List(ctx, 10)
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != next.ID {
+		t.Errorf("expected 2 runs, newest first, got %d", len(runs))
+	}
+}
+
+func TestReconciliationMetrics(t *testing.T) {
+	recon, svc, store, _ := newTestReconciliationService(t, false)
+	ctx := context.Background()
+
+	samples, err := recon.Metrics(ctx)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	if len(samples) != 0 {
+		t.Errorf("expected no metrics before the first run, got %d", len(samples))
+	}
+
+	first := createFundedAccount(t, store, svc, "first@example.com", 20)
+	second := createFundedAccount(t, store, svc, "second@example.com", 20)
+	if err := store.Accounts().UpdateBalance(ctx, first.ID, 25); err != nil {
+		t.Fatalf("failed to tamper with balance: %v", err)
+	}
+	if err := store.Accounts().UpdateBalance(ctx, second.ID, 17.5); err != nil {
+		t.Fatalf("failed to tamper with balance: %v", err)
+	}
+	if _, err := recon.RunManual(ctx, &models.RunReconciliationRequest{}); err != nil {
+		t.Fatalf("reconciliation failed: %v", err)
+	}
+
+	samples, err = recon.Metrics(ctx)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	var b strings.Builder
+	if err := metrics.Write(&b, samples); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	for _, want := range []string{
+		"gobank_reconciliation_accounts_checked 2\n",
+		"gobank_reconciliation_discrepancies 2\n",
+		"gobank_reconciliation_discrepancy_amount 7.5\n",
+		"gobank_reconciliation_accounts_frozen 0\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+}
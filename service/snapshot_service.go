@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+// maxBackfillDays bounds the days one account backfill rebuilds
+const maxBackfillDays = 366
+
+// SnapshotService records each account's end-of-day balance so a past
+// balance is the nearest snapshot plus the transactions since, instead of
+// a replay of the whole history
+type SnapshotService struct {
+	accountRepo     repository.AccountStore
+	transactionRepo repository.TransactionStore
+	snapshotRepo    repository.SnapshotStore
+}
+
+func NewSnapshotService(
+	accountRepo repository.AccountStore,
+	transactionRepo repository.TransactionStore,
+	snapshotRepo repository.SnapshotStore,
+) *SnapshotService {
+	return &SnapshotService{
+		accountRepo:     accountRepo,
+		transactionRepo: transactionRepo,
+		snapshotRepo:    snapshotRepo,
+	}
+}
+
+// RunDaily snapshots every open account for each day up to yesterday that
+// it has no snapshot for yet, so it catches up after downtime. It returns
+// how many snapshots were written.
+func (s *SnapshotService) RunDaily(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.RunDaily")
+	defer span.End()
+
+	through := startOfDay(now).AddDate(0, 0, -1)
+	ids, err := s.accountRepo.ListOpenIDs(ctx)
+	if err != nil {
+		return 0, wrapInternal("failed to list accounts", err)
+	}
+
+	total := 0
+	var errs []error
+	for _, id := range ids {
+		account, err := s.accountRepo.GetByID(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", id, err))
+			continue
+		}
+		start := startOfDay(account.CreatedAt)
+		last, err := s.snapshotRepo.LastDate(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", id, err))
+			continue
+		}
+		if last != nil {
+			start = startOfDay(*last).AddDate(0, 0, 1)
+		}
+		count, err := s.build(ctx, id, start, through)
+		total += count
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", id, err))
+		}
+	}
+
+	span.SetAttribute("snapshot.count", total)
+	if len(errs) > 0 {
+		return total, wrapInternal("balance snapshots failed", errors.Join(errs...))
+	}
+	return total, nil
+}
+
+// Backfill rebuilds the account's snapshots for the days from from to to,
+// inclusive, replacing any already there. Days before the account was
+// opened are skipped.
+func (s *SnapshotService) Backfill(ctx context.Context, accountID int, from, to time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.Backfill")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	from, to = startOfDay(from), startOfDay(to)
+	if err := validateBackfillRange(from, to, time.Now()); err != nil {
+		return 0, err
+	}
+	if to.Sub(from) >= maxBackfillDays*24*time.Hour {
+		return 0, &utils.ValidationError{Field: "from", Message: fmt.Sprintf("a backfill covers at most %d days", maxBackfillDays)}
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return 0, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if opened := startOfDay(account.CreatedAt); from.Before(opened) {
+		from = opened
+	}
+	count, err := s.build(ctx, accountID, from, to)
+	if err != nil {
+		return count, wrapInternal("failed to backfill balance snapshots", err)
+	}
+	return count, nil
+}
+
+// BackfillAll rebuilds snapshots for every open account, for the command
+// line backfill; unlike Backfill the range is not bounded
+func (s *SnapshotService) BackfillAll(ctx context.Context, from, to time.Time) (int, error) {
+	from, to = startOfDay(from), startOfDay(to)
+	if err := validateBackfillRange(from, to, time.Now()); err != nil {
+		return 0, err
+	}
+	ids, err := s.accountRepo.ListOpenIDs(ctx)
+	if err != nil {
+		return 0, wrapInternal("failed to list accounts", err)
+	}
+
+	total := 0
+	for _, id := range ids {
+		account, err := s.accountRepo.GetByID(ctx, id)
+		if err != nil {
+			return total, wrapInternal("failed to get account", err)
+		}
+		start := from
+		if opened := startOfDay(account.CreatedAt); start.Before(opened) {
+			start = opened
+		}
+		count, err := s.build(ctx, id, start, to)
+		total += count
+		if err != nil {
+			return total, wrapInternal(fmt.Sprintf("failed to backfill account %d", id), err)
+		}
+	}
+	return total, nil
+}
+
+// validateBackfillRange only allows days that have ended
+func validateBackfillRange(from, to, now time.Time) error {
+	if from.After(to) {
+		return &utils.ValidationError{Field: "from", Message: "from must not be after to"}
+	}
+	if !to.Before(startOfDay(now)) {
+		return &utils.ValidationError{Field: "to", Message: "to must be before today"}
+	}
+	return nil
+}
+
+// build saves the account's end-of-day balance for each day in
+// [from, through], starting from the ledger balance at the start of from
+func (s *SnapshotService) build(ctx context.Context, accountID int, from, through time.Time) (int, error) {
+	if from.After(through) {
+		return 0, nil
+	}
+	balance, err := s.transactionRepo.GetBalanceAt(ctx, accountID, from)
+	if err != nil {
+		return 0, err
+	}
+
+	// Collected first: the transactions are still being read while the
+	// days are worked out
+	snapshots := make([]*models.BalanceSnapshot, 0)
+	day := from
+	closeDay := func() {
+		snapshots = append(snapshots, &models.BalanceSnapshot{AccountID: accountID, SnapshotDate: day, Balance: balance})
+		day = day.AddDate(0, 0, 1)
+	}
+	err = s.transactionRepo.GetByDateRange(ctx, accountID, from, through.AddDate(0, 0, 1), func(t *models.Transaction) error {
+		if t.Status != models.TransactionStatusCompleted {
+			return nil
+		}
+		for !t.CreatedAt.Before(day.AddDate(0, 0, 1)) {
+			closeDay()
+		}
+		balance = sumAmounts(balance, ledgerDelta(t, accountID))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for !day.After(through) {
+		closeDay()
+	}
+
+	for i, snapshot := range snapshots {
+		if err := s.snapshotRepo.Save(ctx, snapshot); err != nil {
+			return i, err
+		}
+	}
+	return len(snapshots), nil
+}
+
+// BalanceAsOf returns the account's balance at asOf: the last snapshot
+// taken by then plus the completed transactions after it. Without a
+// snapshot the whole history is summed.
+func (s *SnapshotService) BalanceAsOf(ctx context.Context, accountID int, asOf time.Time) (*models.HistoricalBalanceResponse, error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.BalanceAsOf")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if account.Status != models.AccountStatusActice {
+		return nil, accountInactive(account.Status)
+	}
+	if asOf.After(time.Now()) {
+		return nil, &utils.ValidationError{Field: "as_of", Message: "as_of must not be in the future"}
+	}
+
+	response := &models.HistoricalBalanceResponse{Currency: account.Currency, AsOf: asOf}
+	// A day's snapshot holds its balance from midnight after it
+	snapshot, err := s.snapshotRepo.GetLatest(ctx, accountID, startOfDay(asOf.Add(-24*time.Hour)))
+	if errors.Is(err, repository.ErrNotFound) {
+		balance, err := s.transactionRepo.GetBalanceAt(ctx, accountID, asOf)
+		if err != nil {
+			return nil, wrapInternal("failed to get balance", err)
+		}
+		response.Balance = sumAmounts(balance)
+		return response, nil
+	}
+	if err != nil {
+		return nil, wrapInternal("failed to get balance snapshot", err)
+	}
+
+	balance := snapshot.Balance
+	err = s.transactionRepo.GetByDateRange(ctx, accountID, snapshot.SnapshotDate.AddDate(0, 0, 1), asOf, func(t *models.Transaction) error {
+		if t.Status == models.TransactionStatusCompleted {
+			balance = sumAmounts(balance, ledgerDelta(t, accountID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wrapInternal("failed to get balance", err)
+	}
+	response.Balance = balance
+	response.SnapshotDate = &snapshot.SnapshotDate
+	span.SetAttribute("snapshot.date", snapshot.SnapshotDate.Format(time.DateOnly))
+	return response, nil
+}
+
+// ledgerDelta is what the transaction added to the account's balance
+func ledgerDelta(t *models.Transaction, accountID int) float64 {
+	delta := 0.0
+	if t.ToAccountID != nil && *t.ToAccountID == accountID {
+		delta += t.Amount
+	}
+	if t.FromAccountID != nil && *t.FromAccountID == accountID {
+		delta -= t.Amount
+	}
+	return delta
+}
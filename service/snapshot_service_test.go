@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestSnapshotService(t *testing.T) (*SnapshotService, *TransactionService, *memory.Store) {
+	t.Helper()
+	transactions, store := newTestTransactionService(t)
+	return NewSnapshotService(store.Accounts(), store.Transactions(), store.Snapshots()), transactions, store
+}
+
+func TestSnapshotDaily(t *testing.T) {
+	ctx := context.Background()
+	snapshots, transactions, store := newTestSnapshotService(t)
+	from := createFundedAccount(t, store, transactions, "from@example.com", 100)
+	to := createFundedAccount(t, store, transactions, "to@example.com", 0)
+	if _, err := transactions.Transfer(ctx, from.ID, &models.TransferRequest{ToAccountID: to.ID, Amount: 30.3}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	today := startOfDay(time.Now())
+
+	// Nothing has ended yet on the day the accounts were opened
+	if count, err := snapshots.RunDaily(ctx, time.Now()); err != nil || count != 0 {
+		t.Fatalf("expected no snapshots today, got %d (%v)", count, err)
+	}
+
+	count, err := snapshots.RunDaily(ctx, today.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("RunDaily failed: %v", err)
+	}
+	if count != 6 {
+		t.Fatalf("expected 3 days for each of 2 accounts, got %d", count)
+	}
+	if count, err := snapshots.RunDaily(ctx, today.AddDate(0, 0, 3)); err != nil || count != 0 {
+		t.Errorf("expected a second run to write nothing, got %d (%v)", count, err)
+	}
+
+	tests := []struct {
+		accountID int
+		want      float64
+	}{
+		{from.ID, 69.7},
+		{to.ID, 30.3},
+	}
+	for _, tt := range tests {
+		last, err := store.Snapshots().LastDate(ctx, tt.accountID)
+		if err != nil || last == nil || !last.Equal(today.AddDate(0, 0, 2)) {
+			t.Errorf("account %d: expected last snapshot on %s, got %v (%v)", tt.accountID, today.AddDate(0, 0, 2), last, err)
+		}
+		snapshot, err := store.Snapshots().GetLatest(ctx, tt.accountID, today)
+		if err != nil {
+			t.Fatalf("account %d: failed to get snapshot: %v", tt.accountID, err)
+		}
+		if !snapshot.SnapshotDate.Equal(today) || snapshot.Balance != tt.want {
+			t.Errorf("account %d: expected %.2f on %s, got %+v", tt.accountID, tt.want, today, snapshot)
+		}
+	}
+}
+
+func TestBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	snapshots, transactions, store := newTestSnapshotService(t)
+	account := createFundedAccount(t, store, transactions, "holder@example.com", 100)
+	beforeDeposit := time.Now()
+	if _, err := transactions.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 25.55}); err != nil {
+		t.Fatalf("deposit failed: %v", err)
+	}
+	today := startOfDay(time.Now())
+
+	got, err := snapshots.BalanceAsOf(ctx, account.ID, time.Now())
+	if err != nil {
+		t.Fatalf("BalanceAsOf failed: %v", err)
+	}
+	if got.Balance != 125.55 || got.SnapshotDate != nil {
+		t.Errorf("expected 125.55 from the ledger alone, got %+v", got)
+	}
+
+	// A snapshot two days back is used and only later transactions added,
+	// so a balance that differs from the ledger shows through
+	earlier := today.AddDate(0, 0, -2)
+	if err := store.Snapshots().Save(ctx, &models.BalanceSnapshot{AccountID: account.ID, SnapshotDate: earlier, Balance: 40}); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	tests := []struct {
+		name string
+		asOf time.Time
+		want float64
+	}{
+		{"now", time.Now(), 165.55},
+		{"before the deposit", beforeDeposit, 140},
+		{"end of the snapshot day", earlier.AddDate(0, 0, 1), 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snapshots.BalanceAsOf(ctx, account.ID, tt.asOf)
+			if err != nil {
+				t.Fatalf("BalanceAsOf failed: %v", err)
+			}
+			if got.Balance != tt.want || got.SnapshotDate == nil || !got.SnapshotDate.Equal(earlier) {
+				t.Errorf("expected %.2f from the %s snapshot, got %+v", tt.want, earlier, got)
+			}
+		})
+	}
+
+	got, err = snapshots.BalanceAsOf(ctx, account.ID, earlier)
+	if err != nil {
+		t.Fatalf("BalanceAsOf failed: %v", err)
+	}
+	if got.Balance != 0 || got.SnapshotDate != nil {
+		t.Errorf("expected no balance before the snapshot, got %+v", got)
+	}
+
+	if _, err := snapshots.BalanceAsOf(ctx, account.ID, time.Now().Add(time.Hour)); !errors.As(err, new(*utils.ValidationError)) {
+		t.Errorf("expected a validation error for a future as_of, got %v", err)
+	}
+}
+
+func TestBackfillSnapshotsValidation(t *testing.T) {
+	ctx := context.Background()
+	snapshots, transactions, store := newTestSnapshotService(t)
+	account := createFundedAccount(t, store, transactions, "holder@example.com", 10)
+	today := startOfDay(time.Now())
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{"from after to", today.AddDate(0, 0, -1), today.AddDate(0, 0, -2), true},
+		{"today has not ended", today.AddDate(0, 0, -1), today, true},
+		{"too many days", today.AddDate(0, 0, -maxBackfillDays-1), today.AddDate(0, 0, -1), true},
+		{"before the account was opened", today.AddDate(0, 0, -10), today.AddDate(0, 0, -1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := snapshots.Backfill(ctx, account.ID, tt.from, tt.to)
+			if tt.wantErr {
+				if !errors.As(err, new(*utils.ValidationError)) {
+					t.Errorf("expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil || count != 0 {
+				t.Errorf("expected no snapshots before the account existed, got %d (%v)", count, err)
+			}
+		})
+	}
+}
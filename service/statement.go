@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/statement"
+	"github.com/wizzyszn/go_bank/tracing"
+)
+
+// ExportStatement streams the account's booked transactions in [from, to)
+// to enc, with opening and closing balances for the period. Validation
+// happens before enc.Begin, so a returned error means nothing was written
+// unless it came from the stream itself.
+func (s *TransactionService) ExportStatement(ctx context.Context, accountID int, from, to time.Time, enc statement.Encoder) error {
+	ctx, span := tracing.Start(ctx, "TransactionService.ExportStatement")
+	defer span.End()
+
+	now := time.Now()
+	if to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return Validation("invalid_date_range", "from must be before to")
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return notFoundOrInternal(err, "account_not_found", "account not found")
+	}
+	if account.Status != models.AccountStatusActice {
+		return accountInactive(account.Status)
+	}
+
+	opening, err := s.transactionRepo.GetBalanceAt(ctx, accountID, from)
+	if err != nil {
+		return wrapInternal("failed to export statement", err)
+	}
+	closing, err := s.transactionRepo.GetBalanceAt(ctx, accountID, to)
+	if err != nil {
+		return wrapInternal("failed to export statement", err)
+	}
+
+	err = enc.Begin(statement.Statement{
+		AccountNumber:  account.AccountNumber,
+		Currency:       account.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+		GeneratedAt:    now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Statements usually repeat the same few counterparties
+	numbers := make(map[int]string)
+	counterpartyNumber := func(id *int) (string, error) {
+		if id == nil {
+			return "", nil
+		}
+		if number, ok := numbers[*id]; ok {
+			return number, nil
+		}
+		counterparty, err := s.accountRepo.GetByID(ctx, *id)
+		if err != nil {
+			return "", err
+		}
+		numbers[*id] = counterparty.AccountNumber
+		return counterparty.AccountNumber, nil
+	}
+
+	balance := opening
+	err = s.transactionRepo.GetByDateRange(ctx, accountID, from, to, func(t *models.Transaction) error {
+		// Only booked money movements belong on a statement
+		if t.Status != models.TransactionStatusCompleted {
+			return nil
+		}
+
+		entry := statement.Entry{
+			ID:          t.ID,
+			BookedAt:    t.CreatedAt,
+			Type:        t.Type,
+			Amount:      t.Amount,
+			Description: t.Description,
+		}
+		counterparty := t.FromAccountID
+		if t.FromAccountID != nil && *t.FromAccountID == accountID {
+			entry.Amount = -t.Amount
+			counterparty = t.ToAccountID
+		}
+		number, err := counterpartyNumber(counterparty)
+		if err != nil {
+			return err
+		}
+		entry.CounterpartyAccountNumber = number
+		balance = sumAmounts(balance, entry.Amount)
+		entry.Balance = balance
+
+		return enc.Encode(entry)
+	})
+	if err != nil {
+		return wrapInternal("failed to export statement", err)
+	}
+
+	return enc.End()
+}
@@ -2,199 +2,454 @@ package service
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 
-	"github.com/wizzyszn/go_bank/db"
 	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
 	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
 	"github.com/wizzyszn/go_bank/utils"
 )
 
 type TransactionService struct {
-	db              *db.DB
-	accountRepo     *repository.AccountRepository
-	transactionRepo *repository.TransactionRepositoty
+	db              repository.TxRunner
+	accountRepo     repository.AccountStore
+	transactionRepo repository.TransactionStore
+	payeeRepo       repository.PayeeStore
+	feeRepo         repository.FeeStore
+	potRepo         repository.PotStore
+	alertRepo       repository.AlertStore
+	kycRepo         repository.KYCStore
+	annotationRepo  repository.AnnotationStore
+	iban            utils.IBANFormat
+	notifier        notifications.Notifier
 }
 
 func NewTransactionService(
-	database *db.DB,
-	accountRepo *repository.AccountRepository,
-	transactionRepo *repository.TransactionRepositoty,
+	database repository.TxRunner,
+	accountRepo repository.AccountStore,
+	transactionRepo repository.TransactionStore,
+	payeeRepo repository.PayeeStore,
+	feeRepo repository.FeeStore,
+	potRepo repository.PotStore,
+	alertRepo repository.AlertStore,
+	kycRepo repository.KYCStore,
+	annotationRepo repository.AnnotationStore,
+	iban utils.IBANFormat,
+	notifier notifications.Notifier,
 ) *TransactionService {
 	return &TransactionService{
 		db:              database,
 		accountRepo:     accountRepo,
 		transactionRepo: transactionRepo,
+		payeeRepo:       payeeRepo,
+		feeRepo:         feeRepo,
+		potRepo:         potRepo,
+		alertRepo:       alertRepo,
+		kycRepo:         kycRepo,
+		annotationRepo:  annotationRepo,
+		iban:            iban,
+		notifier:        notifier,
 	}
 }
 
-func (s *TransactionService) Deposit(accountID int, req *models.DepositRequest) (*models.TransactionResponse, error) {
+func (s *TransactionService) Deposit(ctx context.Context, accountID int, req *models.DepositRequest) (*models.TransactionResponse, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.Deposit")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
 
 	if err := utils.ValidateAmount(req.Amount); err != nil {
 		return nil, err
 	}
 
-	account, err := s.accountRepo.GetByID(accountID)
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("account not found: %w", err)
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
 	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("account is %s", account.Status)
+		return nil, accountInactive(account.Status)
 	}
 
 	var transaction *models.Transaction
+	var newBalance float64
 
-	err = s.db.WithTransaction(context.Background(), func(tx *sql.Tx) error {
-		currentBalance, err := s.accountRepo.GetBalanceForUpdate(tx, accountID)
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		currentBalance, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID)
 		if err != nil {
 			return err
 		}
 
-		newBalance := currentBalance + req.Amount
+		newBalance = currentBalance + req.Amount
 
-		if err := s.accountRepo.UpdateBalance(tx, accountID, newBalance); err != nil {
+		if err := s.accountRepo.UpdateBalance(ctx, accountID, newBalance); err != nil {
 			return err
 		}
 
-		transaction, err = s.transactionRepo.Create(tx, nil, &accountID, req.Amount, models.TransactionTypeDeposit, req.Description)
+		transaction, err = s.transactionRepo.Create(ctx, nil, &accountID, req.Amount, models.TransactionTypeDeposit, req.Description)
 
 		return err
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("deposit failed: %w", err)
+		return nil, wrapInternal("deposit failed", err)
 	}
-	return transaction.ToResponse(), nil
+
+	afterCommit(ctx, func(ctx context.Context) {
+		sendNotification(ctx, s.notifier, accountID, notifications.EventDeposit, map[string]any{
+			"transaction_id": transaction.ID,
+			"amount":         transaction.Amount,
+			"balance":        newBalance,
+		})
+		evaluateAlerts(ctx, s.db, s.accountRepo, s.alertRepo, s.notifier, accountID, newBalance, 0, transaction.ID)
+	})
+	response := transaction.ToResponse()
+	setAccountNumbers(map[int]string{accountID: account.AccountNumber}, response)
+	return response, nil
 }
 
-func (s *TransactionService) WithDraw(accountID int, req *models.WitdrawRequest) (*models.TransactionResponse, error) {
+func (s *TransactionService) WithDraw(ctx context.Context, accountID int, req *models.WitdrawRequest) (*models.TransactionResponse, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.WithDraw")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
 	if err := utils.ValidateAmount(req.Amount); err != nil {
 		return nil, err
 	}
-	account, err := s.accountRepo.GetByID(accountID)
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("account not found")
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
 	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("account is %s", account.Status)
+		return nil, accountInactive(account.Status)
 	}
 
-	var transaction *models.Transaction
+	fee, _, err := feeFor(ctx, s.feeRepo, account.Product, models.TransactionTypeWithdraw, req.Amount)
+	if err != nil {
+		return nil, wrapInternal("withdrawal failed", err)
+	}
+	total := sumAmounts(req.Amount, fee)
+
+	var transaction, feeTransaction, roundUpTransaction *models.Transaction
+	var balance float64
 
-	err = s.db.WithTransaction(context.Background(), func(tx *sql.Tx) error {
-		currentBalance, err := s.accountRepo.GetBalanceForUpdate(tx, accountID)
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		currentBalance, overdraftLimit, err := s.accountRepo.GetFundsForUpdate(ctx, accountID)
 		if err != nil {
 			return err
 		}
-		if currentBalance < req.Amount {
-			return fmt.Errorf("insufficient funds: have %.2f, need %.2f", currentBalance, req.Amount)
+		if available := models.AvailableBalance(currentBalance, overdraftLimit); available < total {
+			return InsufficientFunds(available, total)
+		}
+		if err := checkKYCLimits(ctx, s.kycRepo, s.transactionRepo, accountID, req.Amount); err != nil {
+			return err
+		}
+		newBalace := sumAmounts(currentBalance, -total)
+
+		err = s.accountRepo.UpdateBalance(ctx, accountID, newBalace)
+		if err != nil {
+			return err
 		}
-		newBalace := currentBalance - req.Amount
 
-		err = s.accountRepo.UpdateBalance(tx, accountID, newBalace)
+		transaction, err = s.transactionRepo.Create(ctx, &accountID, nil, req.Amount, models.TransactionTypeWithdraw, req.Description)
 		if err != nil {
 			return err
 		}
 
-		transaction, err = s.transactionRepo.Create(tx, &accountID, nil, req.Amount, models.TransactionTypeWithdraw, req.Description)
+		feeTransaction, err = s.chargeFee(ctx, accountID, fee, "Withdrawal fee", transaction)
+		if err != nil {
+			return err
+		}
 
-		return err
+		roundUpTransaction, err = s.sweepRoundUp(ctx, accountID, newBalace, transaction)
+		if err != nil {
+			return err
+		}
+		balance = newBalace
+		if roundUpTransaction != nil {
+			balance = sumAmounts(balance, -roundUpTransaction.Amount)
+		}
+		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("withdrawal failed: %w", err)
+		return nil, wrapInternal("withdrawal failed", err)
 	}
 
-	return transaction.ToResponse(), nil
+	afterCommit(ctx, func(ctx context.Context) {
+		sendNotification(ctx, s.notifier, accountID, notifications.EventWithdrawal, map[string]any{
+			"transaction_id": transaction.ID,
+			"amount":         transaction.Amount,
+			"fee":            fee,
+			"balance":        balance,
+		})
+		evaluateAlerts(ctx, s.db, s.accountRepo, s.alertRepo, s.notifier, accountID, balance, transaction.Amount, transaction.ID)
+	})
+
+	response := withFee(transaction, feeTransaction)
+	if roundUpTransaction != nil {
+		response.RoundUp = roundUpTransaction.ToResponse()
+	}
+	setAccountNumbers(map[int]string{accountID: account.AccountNumber}, response)
+	return response, nil
 }
 
-func (s *TransactionService) Transfer(fromAccountID int, req *models.TransferRequest) (*models.TransactionResponse, error) {
+func (s *TransactionService) Transfer(ctx context.Context, fromAccountID int, req *models.TransferRequest) (*models.TransactionResponse, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.Transfer")
+	defer span.End()
+	span.SetAttribute("account.id", fromAccountID)
+
 	if err := utils.ValidateAmount(req.Amount); err != nil {
 		return nil, err
 	}
 
-	if err := utils.ValidateAccountID(req.ToAccountID); err != nil {
+	toAccountID, err := s.resolveRecipient(ctx, fromAccountID, req)
+	if err != nil {
 		return nil, err
 	}
+	span.SetAttribute("transfer.to_account_id", toAccountID)
 
-	if fromAccountID == req.ToAccountID {
-		return nil, fmt.Errorf("cannot transfer to your own account")
+	if fromAccountID == toAccountID {
+		return nil, Validation("same_account", "cannot transfer to your own account")
 	}
 
-	fromAccount, err := s.accountRepo.GetByID(fromAccountID)
+	fromAccount, err := s.accountRepo.GetByID(ctx, fromAccountID)
 	if err != nil {
-		return nil, fmt.Errorf("sender account not found")
+		return nil, notFoundOrInternal(err, "account_not_found", "sender account not found")
 	}
 	if fromAccount.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("sender account is %s", fromAccount.Status)
+		return nil, accountInactive(fromAccount.Status)
 	}
 
-	toAccount, err := s.accountRepo.GetByID(req.ToAccountID)
+	toAccount, err := s.accountRepo.GetByID(ctx, toAccountID)
 	if err != nil {
-		return nil, fmt.Errorf("recipient account not found")
+		return nil, notFoundOrInternal(err, "recipient_not_found", "recipient account not found")
 	}
 
 	if toAccount.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("sender account is %s", fromAccount.Status)
+		return nil, Forbidden("recipient_inactive", fmt.Sprintf("recipient account is %s", toAccount.Status))
 	}
 
-	var transaction *models.Transaction
+	lastPaid, err := s.transactionRepo.LastTransferAt(ctx, fromAccountID, toAccountID)
+	if err != nil {
+		return nil, wrapInternal("transfer failed", err)
+	}
+	firstTimePayee := lastPaid == nil
+	span.SetAttribute("transfer.first_time_payee", firstTimePayee)
+
+	fee, _, err := feeFor(ctx, s.feeRepo, fromAccount.Product, models.TransactionTypeTransfer, req.Amount)
+	if err != nil {
+		return nil, wrapInternal("transfer failed", err)
+	}
+	total := sumAmounts(req.Amount, fee)
 
-	err = s.db.WithTransaction(context.Background(), func(tx *sql.Tx) error {
-		firstID, secondID := fromAccountID, req.ToAccountID
+	var transaction, feeTransaction *models.Transaction
+	var senderBalanceAfter, receiverBalanceAfter float64
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		firstID, secondID := fromAccountID, toAccountID
 
 		if firstID > secondID {
 			firstID, secondID = secondID, firstID
 		}
-		firstBalance, err := s.accountRepo.GetBalanceForUpdate(tx, firstID)
+		firstBalance, firstLimit, err := s.accountRepo.GetFundsForUpdate(ctx, firstID)
 		if err != nil {
 			return err
 		}
-		secondBalance, err := s.accountRepo.GetBalanceForUpdate(tx, secondID)
+		secondBalance, secondLimit, err := s.accountRepo.GetFundsForUpdate(ctx, secondID)
 		if err != nil {
 			return err
 		}
 
-		senderBalance := firstBalance
+		senderBalance, senderLimit := firstBalance, firstLimit
 		receiverBalance := secondBalance
-		if fromAccountID > req.ToAccountID {
-			senderBalance, receiverBalance = secondBalance, firstBalance
+		if fromAccountID > toAccountID {
+			senderBalance, senderLimit, receiverBalance = secondBalance, secondLimit, firstBalance
+		}
+		if available := models.AvailableBalance(senderBalance, senderLimit); available < total {
+			return InsufficientFunds(available, total)
 		}
-		if senderBalance < req.Amount {
-			return fmt.Errorf("insufficient funds: have %.2f, need %.2f", senderBalance, req.Amount)
+		if err := checkKYCLimits(ctx, s.kycRepo, s.transactionRepo, fromAccountID, req.Amount); err != nil {
+			return err
 		}
-		if err := s.accountRepo.UpdateBalance(tx, fromAccountID, senderBalance-req.Amount); err != nil {
+		senderBalanceAfter = sumAmounts(senderBalance, -total)
+		if err := s.accountRepo.UpdateBalance(ctx, fromAccountID, senderBalanceAfter); err != nil {
 			return err
 		}
-		if err := s.accountRepo.UpdateBalance(tx, req.ToAccountID, receiverBalance+req.Amount); err != nil {
+		receiverBalanceAfter = receiverBalance + req.Amount
+		if err := s.accountRepo.UpdateBalance(ctx, toAccountID, receiverBalanceAfter); err != nil {
 			return err
 		}
-		toAccountID := req.ToAccountID
 		transaction, err = s.transactionRepo.Create(
-			tx,
+			ctx,
 			&fromAccountID,
 			&toAccountID,
 			req.Amount,
 			models.TransactionTypeTransfer,
 			req.Description,
 		)
-		return err
+		if err != nil {
+			return err
+		}
+		feeTransaction, err = s.chargeFee(ctx, fromAccountID, fee, "Transfer fee", transaction)
+		if err != nil {
+			return err
+		}
+		return s.payeeRepo.MarkPaid(ctx, fromAccountID, toAccountID, transaction.CreatedAt)
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("transfer failed: %w", err)
+		return nil, wrapInternal("transfer failed", err)
+	}
+
+	afterCommit(ctx, func(ctx context.Context) {
+		sendNotification(ctx, s.notifier, fromAccountID, notifications.EventTransferSent, map[string]any{
+			"transaction_id": transaction.ID,
+			"amount":         transaction.Amount,
+			"fee":            fee,
+			"to":             utils.MaskName(toAccount.FirstName, toAccount.LastName),
+			"balance":        senderBalanceAfter,
+		})
+		sendNotification(ctx, s.notifier, toAccountID, notifications.EventTransferReceived, map[string]any{
+			"transaction_id": transaction.ID,
+			"amount":         transaction.Amount,
+			"from":           utils.MaskName(fromAccount.FirstName, fromAccount.LastName),
+		})
+		evaluateAlerts(ctx, s.db, s.accountRepo, s.alertRepo, s.notifier, fromAccountID, senderBalanceAfter, transaction.Amount, transaction.ID)
+		evaluateAlerts(ctx, s.db, s.accountRepo, s.alertRepo, s.notifier, toAccountID, receiverBalanceAfter, 0, transaction.ID)
+	})
+
+	response := withFee(transaction, feeTransaction)
+	response.FirstTimePayee = firstTimePayee
+	setAccountNumbers(map[int]string{fromAccountID: fromAccount.AccountNumber, toAccountID: toAccount.AccountNumber}, response)
+	return response, nil
+}
+
+// chargeFee records the fee on transaction as a separate fee transaction
+// linked to it. The caller has already taken the fee from the balance, in
+// the same database transaction. It does nothing when fee is zero.
+func (s *TransactionService) chargeFee(ctx context.Context, accountID int, fee float64, description string, transaction *models.Transaction) (*models.Transaction, error) {
+	if fee <= 0 {
+		return nil, nil
+	}
+	return s.transactionRepo.Insert(ctx, &models.Transaction{
+		FromAccountID:        &accountID,
+		Amount:               fee,
+		Type:                 models.TransactionTypeFee,
+		Description:          description,
+		RelatedTransactionID: &transaction.ID,
+	})
+}
+
+// sweepRoundUp moves the spare change from rounding a withdrawal up to the
+// next whole unit into the account's round-up pot. It is skipped when there
+// is no round-up pot or when balance, already locked and net of the
+// withdrawal, can't cover it without going overdrawn.
+func (s *TransactionService) sweepRoundUp(ctx context.Context, accountID int, balance float64, transaction *models.Transaction) (*models.Transaction, error) {
+	roundUp := sumAmounts(math.Ceil(transaction.Amount), -transaction.Amount)
+	if roundUp <= 0 || balance < roundUp {
+		return nil, nil
+	}
+
+	pot, err := s.potRepo.GetRoundUp(ctx, accountID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return moveToPot(ctx, s.accountRepo, s.potRepo, s.transactionRepo, accountID, balance, pot, roundUp, &transaction.ID)
+}
+
+func withFee(transaction, feeTransaction *models.Transaction) *models.TransactionResponse {
+	response := transaction.ToResponse()
+	if feeTransaction != nil {
+		response.Fee = feeTransaction.ToResponse()
+	}
+	return response
+}
+
+// findByAccountNumber looks an account up by its number or IBAN, reporting
+// a malformed number against field
+func findByAccountNumber(ctx context.Context, accountRepo repository.AccountStore, iban utils.IBANFormat, number, field string) (*models.Account, error) {
+	accountNumber, err := utils.ParseAccountNumber(number, iban)
+	if err != nil {
+		var validationErr *utils.ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.Field = field
+		}
+		return nil, err
+	}
+	account, err := accountRepo.GetByAccountNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "recipient_not_found", "recipient account not found")
+	}
+	return account, nil
+}
+
+// resolveRecipient turns whichever of to_account_number, payee_id or
+// to_email the request uses into an account ID. Callers inside the service
+// may give ToAccountID instead.
+func (s *TransactionService) resolveRecipient(ctx context.Context, fromAccountID int, req *models.TransferRequest) (int, error) {
+	given := 0
+	for _, set := range []bool{req.ToAccountNumber != "", req.ToAccountID != 0, req.PayeeID != 0, req.ToEmail != ""} {
+		if set {
+			given++
+		}
+	}
+	if given == 0 {
+		return 0, &utils.ValidationError{Field: "to_account_number", Message: "one of to_account_number, payee_id or to_email is required"}
+	}
+	if given > 1 {
+		return 0, Validation("ambiguous_recipient", "give only one of to_account_number, payee_id or to_email")
 	}
 
-	return transaction.ToResponse(), nil
+	switch {
+	case req.ToAccountNumber != "":
+		recipient, err := findByAccountNumber(ctx, s.accountRepo, s.iban, req.ToAccountNumber, "to_account_number")
+		if err != nil {
+			return 0, err
+		}
+		return recipient.ID, nil
+
+	case req.PayeeID != 0:
+		payee, err := s.payeeRepo.GetByID(ctx, req.PayeeID)
+		if err != nil {
+			return 0, notFoundOrInternal(err, "payee_not_found", "payee not found")
+		}
+		if payee.AccountID != fromAccountID {
+			return 0, NotFound("payee_not_found", "payee not found")
+		}
+		return payee.PayeeAccountID, nil
+
+	case req.ToEmail != "":
+		if err := utils.ValidateEmail(req.ToEmail); err != nil {
+			return 0, err
+		}
+		recipient, err := s.accountRepo.GeyByEmail(ctx, strings.ToLower(strings.TrimSpace(req.ToEmail)))
+		if err != nil {
+			return 0, notFoundOrInternal(err, "recipient_not_found", "recipient account not found")
+		}
+		return recipient.ID, nil
+
+	default:
+		if err := utils.ValidateAccountID(req.ToAccountID); err != nil {
+			return 0, err
+		}
+		return req.ToAccountID, nil
+	}
 }
 
-func (s *TransactionService) GetTransaction(accountID, transactionID int) (*models.TransactionResponse, error) {
+func (s *TransactionService) GetTransaction(ctx context.Context, accountID, transactionID int) (*models.TransactionResponse, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.GetTransaction")
+	defer span.End()
 
-	transaction, err := s.transactionRepo.GetByID(transactionID)
+	transaction, err := s.transactionRepo.GetByID(ctx, transactionID)
 
 	if err != nil {
-		return nil, fmt.Errorf("transaction not found")
+		return nil, notFoundOrInternal(err, "transaction_not_found", "transaction not found")
 	}
 	isParticipant := false
 	if transaction.FromAccountID != nil && *transaction.FromAccountID == accountID {
@@ -206,69 +461,199 @@ func (s *TransactionService) GetTransaction(accountID, transactionID int) (*mode
 	}
 
 	if !isParticipant {
-		return nil, fmt.Errorf("transaction not found")
+		return nil, NotFound("transaction_not_found", "transaction not found")
 	}
 
-	return transaction.ToResponse(), nil
+	response := transaction.ToResponse()
+	if err := accountNumbers(ctx, s.accountRepo, response); err != nil {
+		return nil, err
+	}
+	if err := s.annotate(ctx, accountID, []*models.TransactionResponse{response}); err != nil {
+		return nil, err
+	}
+	attachments, err := s.annotationRepo.ListAttachments(ctx, accountID, []int{transactionID})
+	if err != nil {
+		return nil, wrapInternal("failed to get transaction", err)
+	}
+	if len(attachments) > 0 {
+		response.Attachments = attachments
+	}
+	return response, nil
 
 }
 
-func (s *TransactionService) GetTransactions(accountID, page, limit int) (*models.PaginatedResponse, error) {
-	if page < 1 {
-		page = 1
-	}
+// ListTransactions returns one page of an account's history. Pass the
+// NextCursor of the previous page to continue; an empty cursor starts over.
+func (s *TransactionService) ListTransactions(ctx context.Context, accountID int, filter models.TransactionFilter, cursor string, limit int) (*models.TransactionPage, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.ListTransactions")
+	defer span.End()
 
-	if limit < 1 && limit > 100 {
-		limit = 20
+	if err := utils.ValidatePagination(1, limit); err != nil {
+		return nil, err
 	}
-
-	if err := utils.ValidatePagination(page, limit); err != nil {
+	if err := utils.ValidateTransactionFilter(filter); err != nil {
 		return nil, err
 	}
 
-	account, err := s.accountRepo.GetByID(accountID)
+	var after *models.TransactionCursor
+	if cursor != "" {
+		decoded, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("account not found: %w", err)
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
 
 	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("this account is %s", account.Status)
+		return nil, accountInactive(account.Status)
 	}
 
-	transactions, totalCount, err := s.transactionRepo.GetByAccountID(accountID, page, limit)
+	if filter.CounterpartyAccountNumber != "" {
+		counterparty, err := findByAccountNumber(ctx, s.accountRepo, s.iban, filter.CounterpartyAccountNumber, "counterparty")
+		if errors.Is(err, ErrNotFound) {
+			// No account means no transactions with it
+			return &models.TransactionPage{Data: []*models.TransactionResponse{}, Limit: limit}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		filter.CounterpartyID = &counterparty.ID
+	}
 
+	// One extra row tells us whether another page exists
+	transactions, err := s.transactionRepo.ListByAccount(ctx, accountID, filter, after, limit+1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transactions: %w", err)
+		return nil, wrapInternal("failed to get transactions", err)
 	}
-	responses := make([]*models.TransactionResponse, len(transactions))
 
+	hasMore := len(transactions) > limit
+	if hasMore {
+		transactions = transactions[:limit]
+	}
+
+	responses := make([]*models.TransactionResponse, len(transactions))
 	for i, transaction := range transactions {
 		responses[i] = transaction.ToResponse()
 	}
+	if err := accountNumbers(ctx, s.accountRepo, responses...); err != nil {
+		return nil, err
+	}
+	if err := s.annotate(ctx, accountID, responses); err != nil {
+		return nil, err
+	}
 
-	totalPages := totalCount / limit
-	if totalCount%limit != 0 {
-		totalPages++
+	page := &models.TransactionPage{
+		Data:    responses,
+		Limit:   limit,
+		HasMore: hasMore,
 	}
-	return &models.PaginatedResponse{
-		Data:       responses,
-		Page:       page,
-		Limit:      limit,
-		TotalCount: totalCount,
-		TotalPages: totalPages,
-	}, nil
+	if hasMore {
+		last := transactions[len(transactions)-1]
+		page.NextCursor = encodeCursor(models.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	return page, nil
 }
 
-func (s *TransactionService) GetBalance(accountID int) (*models.BalanceResponse, error) {
-	account, err := s.accountRepo.GetByID(accountID)
+// annotate adds the account's own notes and tags to the responses
+func (s *TransactionService) annotate(ctx context.Context, accountID int, responses []*models.TransactionResponse) error {
+	if len(responses) == 0 {
+		return nil
+	}
+	ids := make([]int, len(responses))
+	for i, response := range responses {
+		ids[i] = response.ID
+	}
+	annotations, err := s.annotationRepo.ListByTransactions(ctx, accountID, ids)
 	if err != nil {
-		return nil, fmt.Errorf("account not found")
+		return wrapInternal("failed to get transaction annotations", err)
+	}
+	byTransaction := make(map[int]*models.TransactionAnnotation, len(annotations))
+	for _, annotation := range annotations {
+		byTransaction[annotation.TransactionID] = annotation
+	}
+	for _, response := range responses {
+		response.Annotate(byTransaction[response.ID])
+	}
+	return nil
+}
+
+// accountNumbers looks up the account numbers of the parties to the
+// responses and sets them
+func accountNumbers(ctx context.Context, accountRepo repository.AccountStore, responses ...*models.TransactionResponse) error {
+	seen := make(map[int]bool)
+	ids := make([]int, 0)
+	var collect func(response *models.TransactionResponse)
+	collect = func(response *models.TransactionResponse) {
+		if response == nil {
+			return
+		}
+		for _, id := range []*int{response.FromAccountID, response.ToAccountID} {
+			if id != nil && !seen[*id] {
+				seen[*id] = true
+				ids = append(ids, *id)
+			}
+		}
+		collect(response.Fee)
+		collect(response.RoundUp)
+	}
+	for _, response := range responses {
+		collect(response)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	numbers, err := accountRepo.GetAccountNumbers(ctx, ids)
+	if err != nil {
+		return wrapInternal("failed to get account numbers", err)
+	}
+	setAccountNumbers(numbers, responses...)
+	return nil
+}
+
+// setAccountNumbers sets the account numbers of the parties to the
+// responses, and to their fee and round-up transactions, from numbers
+func setAccountNumbers(numbers map[int]string, responses ...*models.TransactionResponse) {
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+		if response.FromAccountID != nil {
+			response.FromAccountNumber = numbers[*response.FromAccountID]
+		}
+		if response.ToAccountID != nil {
+			response.ToAccountNumber = numbers[*response.ToAccountID]
+		}
+		setAccountNumbers(numbers, response.Fee, response.RoundUp)
+	}
+}
+
+func (s *TransactionService) GetBalance(ctx context.Context, accountID int) (*models.BalanceResponse, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.GetBalance")
+	defer span.End()
+
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "account_not_found", "account not found")
 	}
 	if account.Status != models.AccountStatusActice {
-		return nil, fmt.Errorf("this account is: %s", account.Status)
+		return nil, accountInactive(account.Status)
+	}
+	potsTotal, err := s.potRepo.TotalByAccount(ctx, accountID)
+	if err != nil {
+		return nil, wrapInternal("failed to get balance", err)
 	}
 	return &models.BalanceResponse{
-		Currency: account.Currency,
-		Balance:  account.Balance,
+		Currency:         account.Currency,
+		Balance:          account.Balance,
+		AvailableBalance: account.AvailableBalance(),
+		OverdraftLimit:   account.OverdraftLimit,
+		PotsTotal:        potsTotal,
+		TotalBalance:     sumAmounts(account.Balance, potsTotal),
 	}, nil
 }
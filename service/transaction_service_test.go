@@ -0,0 +1,413 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/statement"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+func newTestTransactionService(t *testing.T) (*TransactionService, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	return NewTransactionService(store, store.Accounts(), store.Transactions(), store.Payees(), store.Fees(), store.Pots(), store.Alerts(), store.KYC(), store.Annotations(), utils.IBANFormat{}, &notifications.MemoryNotifier{}), store
+}
+
+func createFundedAccount(t *testing.T, store *memory.Store, svc *TransactionService, email string, balance float64) *models.Account {
+	t.Helper()
+	ctx := context.Background()
+
+	account, err := store.Accounts().Create(ctx, email, "hash", "Test", "User")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	if balance > 0 {
+		if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: balance, Description: "seed"}); err != nil {
+			t.Fatalf("failed to fund account: %v", err)
+		}
+	}
+	return account
+}
+
+func balanceOf(t *testing.T, svc *TransactionService, accountID int) float64 {
+	t.Helper()
+	balance, err := svc.GetBalance(context.Background(), accountID)
+	if err != nil {
+		t.Fatalf("failed to get balance: %v", err)
+	}
+	return balance.Balance
+}
+
+func TestDepositAndWithdraw(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "a@example.com", 100)
+
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 40}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 60 {
+		t.Errorf("expected balance 60, got %.2f", got)
+	}
+
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 61}); err == nil {
+		t.Error("expected insufficient funds error")
+	}
+	if got := balanceOf(t, svc, account.ID); got != 60 {
+		t.Errorf("failed withdrawal should not change balance, got %.2f", got)
+	}
+
+	if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: -5}); err == nil {
+		t.Error("expected validation error for negative deposit")
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	sender := createFundedAccount(t, store, svc, "sender@example.com", 100)
+	receiver := createFundedAccount(t, store, svc, "receiver@example.com", 0)
+
+	tx, err := svc.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: receiver.ID, Amount: 30})
+	if err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+	if tx.Type != models.TransactionTypeTransfer || tx.Amount != 30 {
+		t.Errorf("unexpected transaction: %+v", tx)
+	}
+	if tx.FromAccountNumber != sender.AccountNumber || tx.ToAccountNumber != receiver.AccountNumber {
+		t.Errorf("expected parties %s -> %s, got %s -> %s", sender.AccountNumber, receiver.AccountNumber, tx.FromAccountNumber, tx.ToAccountNumber)
+	}
+	stored, err := svc.GetTransaction(ctx, receiver.ID, tx.ID)
+	if err != nil {
+		t.Fatalf("failed to get transaction: %v", err)
+	}
+	if stored.FromAccountNumber != sender.AccountNumber || stored.ToAccountNumber != receiver.AccountNumber {
+		t.Errorf("expected stored parties %s -> %s, got %s -> %s", sender.AccountNumber, receiver.AccountNumber, stored.FromAccountNumber, stored.ToAccountNumber)
+	}
+	if got := balanceOf(t, svc, sender.ID); got != 70 {
+		t.Errorf("expected sender balance 70, got %.2f", got)
+	}
+	if got := balanceOf(t, svc, receiver.ID); got != 30 {
+		t.Errorf("expected receiver balance 30, got %.2f", got)
+	}
+
+	if _, err := svc.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: sender.ID, Amount: 1}); err == nil {
+		t.Error("expected error transferring to own account")
+	}
+	if _, err := svc.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: 999, Amount: 1}); err == nil {
+		t.Error("expected error for unknown recipient")
+	}
+	if _, err := svc.Transfer(ctx, sender.ID, &models.TransferRequest{ToAccountID: receiver.ID, Amount: 500}); err == nil {
+		t.Error("expected insufficient funds error")
+	}
+}
+
+func TestGetTransactionHidesOtherAccounts(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	owner := createFundedAccount(t, store, svc, "owner@example.com", 50)
+	stranger := createFundedAccount(t, store, svc, "stranger@example.com", 0)
+
+	page, err := svc.ListTransactions(ctx, owner.ID, models.TransactionFilter{}, "", 10)
+	if err != nil {
+		t.Fatalf("failed to list transactions: %v", err)
+	}
+	if len(page.Data) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(page.Data))
+	}
+	deposit := page.Data[0]
+
+	if _, err := svc.GetTransaction(ctx, owner.ID, deposit.ID); err != nil {
+		t.Errorf("owner should see their transaction: %v", err)
+	}
+	if _, err := svc.GetTransaction(ctx, stranger.ID, deposit.ID); err == nil {
+		t.Error("stranger should not see someone else's transaction")
+	}
+}
+
+// Opposing transfers lock the same two rows in different orders; they must
+// neither deadlock nor lose money
+func TestConcurrentTransfersConserveMoney(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	a := createFundedAccount(t, store, svc, "a@example.com", 1000)
+	b := createFundedAccount(t, store, svc, "b@example.com", 1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			svc.Transfer(ctx, a.ID, &models.TransferRequest{ToAccountID: b.ID, Amount: 7})
+		}()
+		go func() {
+			defer wg.Done()
+			svc.Transfer(ctx, b.ID, &models.TransferRequest{ToAccountID: a.ID, Amount: 3})
+		}()
+	}
+	wg.Wait()
+
+	balanceA := balanceOf(t, svc, a.ID)
+	balanceB := balanceOf(t, svc, b.ID)
+	if balanceA+balanceB != 2000 {
+		t.Errorf("money was created or lost: %.2f + %.2f != 2000", balanceA, balanceB)
+	}
+	if balanceA != 1000-50*7+50*3 {
+		t.Errorf("expected balance A %.2f, got %.2f", float64(1000-50*7+50*3), balanceA)
+	}
+}
+
+// Concurrent withdrawals must not overdraw: the balance check and the update
+// happen under the same row lock
+func TestConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "race@example.com", 100)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 20, Description: fmt.Sprint(i)})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 5 {
+		t.Errorf("expected exactly 5 successful withdrawals, got %d", succeeded)
+	}
+	if got := balanceOf(t, svc, account.ID); got != 0 {
+		t.Errorf("expected balance 0, got %.2f", got)
+	}
+}
+
+func TestFailedTransactionRollsBack(t *testing.T) {
+	store := memory.NewStore()
+	ctx := context.Background()
+	account, _ := store.Accounts().Create(ctx, "rb@example.com", "hash", "Roll", "Back")
+
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := store.Accounts().UpdateBalance(ctx, account.ID, 500); err != nil {
+			return err
+		}
+		if _, err := store.Transactions().Create(ctx, nil, &account.ID, 500, models.TransactionTypeDeposit, ""); err != nil {
+			return err
+		}
+		return fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error from transaction")
+	}
+
+	reloaded, _ := store.Accounts().GetByID(ctx, account.ID)
+	if reloaded.Balance != 0 {
+		t.Errorf("balance should be rolled back, got %.2f", reloaded.Balance)
+	}
+	if transactions, _ := store.Transactions().ListByAccount(ctx, account.ID, models.TransactionFilter{}, nil, 10); len(transactions) != 0 {
+		t.Errorf("transaction should be rolled back, found %d", len(transactions))
+	}
+}
+
+func TestListTransactionsPagesWithCursor(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "pager@example.com", 0)
+
+	for i := 1; i <= 7; i++ {
+		if _, err := svc.Deposit(ctx, account.ID, &models.DepositRequest{Amount: float64(i)}); err != nil {
+			t.Fatalf("deposit failed: %v", err)
+		}
+	}
+
+	for _, ascending := range []bool{false, true} {
+		var seen []float64
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > 4 {
+				t.Fatal("pagination did not terminate")
+			}
+			page, err := svc.ListTransactions(ctx, account.ID, models.TransactionFilter{Ascending: ascending}, cursor, 3)
+			if err != nil {
+				t.Fatalf("failed to list transactions: %v", err)
+			}
+			for _, tx := range page.Data {
+				seen = append(seen, tx.Amount)
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = page.NextCursor
+		}
+
+		if len(seen) != 7 {
+			t.Fatalf("ascending=%v: expected 7 transactions, got %v", ascending, seen)
+		}
+		for i, amount := range seen {
+			want := float64(7 - i)
+			if ascending {
+				want = float64(i + 1)
+			}
+			if amount != want {
+				t.Fatalf("ascending=%v: unexpected order %v", ascending, seen)
+			}
+		}
+	}
+}
+
+func TestListTransactionsFilters(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 100)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 100)
+	carol := createFundedAccount(t, store, svc, "carol@example.com", 100)
+
+	mustTransfer := func(from, to *models.Account, amount float64, description string) {
+		t.Helper()
+		if _, err := svc.Transfer(ctx, from.ID, &models.TransferRequest{ToAccountID: to.ID, Amount: amount, Description: description}); err != nil {
+			t.Fatalf("transfer failed: %v", err)
+		}
+	}
+	mustTransfer(alice, bob, 10, "Rent for March")
+	mustTransfer(bob, alice, 20, "Dinner")
+	mustTransfer(alice, carol, 30, "rent share")
+	if _, err := svc.WithDraw(ctx, alice.ID, &models.WitdrawRequest{Amount: 5, Description: "ATM"}); err != nil {
+		t.Fatalf("withdraw failed: %v", err)
+	}
+
+	minAmount, maxAmount := 15.0, 30.0
+	tests := []struct {
+		name   string
+		filter models.TransactionFilter
+		want   []float64
+	}{
+		{"no filter", models.TransactionFilter{}, []float64{5, 30, 20, 10, 100}},
+		{"incoming", models.TransactionFilter{Direction: models.TransactionDirectionIncoming}, []float64{20, 100}},
+		{"outgoing transfers", models.TransactionFilter{Direction: models.TransactionDirectionOutgoing, Type: models.TransactionTypeTransfer}, []float64{30, 10}},
+		{"amount range", models.TransactionFilter{MinAmount: &minAmount, MaxAmount: &maxAmount}, []float64{30, 20}},
+		{"counterparty", models.TransactionFilter{CounterpartyAccountNumber: bob.AccountNumber}, []float64{20, 10}},
+		{"unknown counterparty", models.TransactionFilter{CounterpartyAccountNumber: "0000000000"}, []float64{}},
+		{"search is case-insensitive", models.TransactionFilter{Search: "RENT"}, []float64{30, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := svc.ListTransactions(ctx, alice.ID, tt.filter, "", 50)
+			if err != nil {
+				t.Fatalf("failed to list transactions: %v", err)
+			}
+			got := make([]float64, len(page.Data))
+			for i, tx := range page.Data {
+				got[i] = tx.Amount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var validationErr *utils.ValidationError
+	if _, err := svc.ListTransactions(ctx, alice.ID, models.TransactionFilter{Direction: "sideways"}, "", 10); !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error for unknown direction, got %v", err)
+	}
+	if _, err := svc.ListTransactions(ctx, alice.ID, models.TransactionFilter{CounterpartyAccountNumber: "1234567890"}, "", 10); !errors.As(err, &validationErr) || validationErr.Field != "counterparty" {
+		t.Errorf("expected counterparty validation error for bad check digit, got %v", err)
+	}
+	if _, err := svc.ListTransactions(ctx, alice.ID, models.TransactionFilter{}, "not-a-cursor", 10); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected validation error for bad cursor, got %v", err)
+	}
+}
+
+func TestExportStatementBalancesAddUp(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	ctx := context.Background()
+	from := time.Now().Add(-time.Hour)
+
+	alice := createFundedAccount(t, store, svc, "alice@example.com", 100)
+	bob := createFundedAccount(t, store, svc, "bob@example.com", 0)
+	if _, err := svc.Transfer(ctx, alice.ID, &models.TransferRequest{ToAccountID: bob.ID, Amount: 40}); err != nil {
+		t.Fatalf("transfer failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc, _ := statement.NewEncoder(statement.FormatCSV, &buf)
+	if err := svc.ExportStatement(ctx, alice.ID, from, time.Now().Add(time.Hour), enc); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %v", records)
+	}
+	if records[1][4] != "100.00" || records[2][4] != "-40.00" || records[2][5] != "60.00" {
+		t.Errorf("unexpected rows: %v", records[1:])
+	}
+
+	if err := svc.ExportStatement(ctx, alice.ID, time.Now(), from, enc); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected validation error for inverted range, got %v", err)
+	}
+}
+
+func TestWithdrawIntoOverdraft(t *testing.T) {
+	svc, store := newTestTransactionService(t)
+	auth := NewAuthService(store, store.Accounts(), store.Sessions(), time.Hour, utils.IBANFormat{}, &notifications.MemoryNotifier{}, newTestVerificationService(store))
+	ctx := context.Background()
+	account := createFundedAccount(t, store, svc, "overdraft@example.com", 50)
+
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 60}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds without an overdraft, got %v", err)
+	}
+
+	if _, err := auth.SetOverdraftLimit(ctx, account.ID, 100); err != nil {
+		t.Fatalf("SetOverdraftLimit: %v", err)
+	}
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 120}); err != nil {
+		t.Fatalf("withdraw into overdraft failed: %v", err)
+	}
+
+	balance, err := svc.GetBalance(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance.Balance != -70 || balance.AvailableBalance != 30 {
+		t.Errorf("balance = %.2f available %.2f, want -70.00 and 30.00", balance.Balance, balance.AvailableBalance)
+	}
+
+	if _, err := svc.WithDraw(ctx, account.ID, &models.WitdrawRequest{Amount: 40}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds beyond the limit, got %v", err)
+	}
+	if _, err := auth.SetOverdraftLimit(ctx, account.ID, 50); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected conflict lowering the limit below usage, got %v", err)
+	}
+
+	response, err := auth.GetAccount(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if response.OverdraftLimit != 100 || response.OverdraftUsed != 70 || response.AvailableBalance != 30 {
+		t.Errorf("account response = %+v, want limit 100, used 70, available 30", response)
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository"
+	"github.com/wizzyszn/go_bank/tracing"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const (
+	// verificationPurpose is signed into every verification token
+	verificationPurpose = "email-verification"
+	verificationTTL     = 24 * time.Hour
+	// A new verification email can be asked for once a minute, and at most
+	// maxVerificationEmails a day including the one sent on registration
+	verificationResendInterval = time.Minute
+	maxVerificationEmails      = 5
+)
+
+// VerificationService confirms new accounts' email addresses. Each email
+// carries a token signed with the session secret; only its hash is stored,
+// and it activates the account once.
+type VerificationService struct {
+	db               repository.TxRunner
+	accountRepo      repository.AccountStore
+	verificationRepo repository.VerificationStore
+	mailer           notifications.Mailer
+	secret           string
+	// verifyURL is the page the emailed link opens; the token is added as
+	// its token query parameter
+	verifyURL string
+}
+
+func NewVerificationService(database repository.TxRunner, accountRepo repository.AccountStore, verificationRepo repository.VerificationStore, mailer notifications.Mailer, secret, verifyURL string) *VerificationService {
+	return &VerificationService{
+		db:               database,
+		accountRepo:      accountRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+		secret:           secret,
+		verifyURL:        verifyURL,
+	}
+}
+
+// Verify activates the account the token was issued to
+func (s *VerificationService) Verify(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "VerificationService.Verify")
+	defer span.End()
+
+	if err := utils.ValidateRequired(token, "token"); err != nil {
+		return err
+	}
+	// A token we never signed is turned away without a database lookup
+	if !utils.VerifyTokenSignature(s.secret, verificationPurpose, token) {
+		return Validation("invalid_token", "verification link is invalid")
+	}
+
+	now := time.Now()
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		verification, err := s.verificationRepo.GetByTokenHash(ctx, utils.HashToken(token))
+		if errors.Is(err, repository.ErrNotFound) {
+			return Validation("invalid_token", "verification link is invalid")
+		}
+		if err != nil {
+			return err
+		}
+		span.SetAttribute("account.id", verification.AccountID)
+
+		if !verification.ExpiresAt.After(now) {
+			return Validation("token_expired", "verification link has expired; ask for a new one")
+		}
+		used, err := s.verificationRepo.MarkUsed(ctx, verification.ID, now)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return Conflict("token_used", "verification link has already been used")
+		}
+
+		if _, err := s.accountRepo.GetBalanceForUpdate(ctx, verification.AccountID); err != nil {
+			return notFoundOrInternal(err, "account_not_found", "account not found")
+		}
+		account, err := s.accountRepo.GetByID(ctx, verification.AccountID)
+		if err != nil {
+			return err
+		}
+		if account.Status != models.AccountStatusPendingVerification {
+			return Conflict("already_verified", "email address is already verified")
+		}
+		return s.accountRepo.SetStatus(ctx, account.ID, models.AccountStatusActice)
+	})
+	if err != nil {
+		return wrapInternal("failed to verify email", err)
+	}
+	return nil
+}
+
+// Resend emails a new verification link to an account still pending
+// verification. Links sent earlier keep working until they expire.
+func (s *VerificationService) Resend(ctx context.Context, accountID int) error {
+	ctx, span := tracing.Start(ctx, "VerificationService.Resend")
+	defer span.End()
+	span.SetAttribute("account.id", accountID)
+
+	now := time.Now()
+	err := s.db.WithTransaction(ctx, func(ctx context.Context) error {
+		// The row lock stops concurrent resends both passing the limits
+		if _, err := s.accountRepo.GetBalanceForUpdate(ctx, accountID); err != nil {
+			return notFoundOrInternal(err, "account_not_found", "account not found")
+		}
+		account, err := s.accountRepo.GetByID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Status != models.AccountStatusPendingVerification {
+			return Conflict("already_verified", "email address is already verified")
+		}
+
+		recent, err := s.verificationRepo.CountSince(ctx, accountID, now.Add(-verificationResendInterval))
+		if err != nil {
+			return err
+		}
+		if recent > 0 {
+			return TooManyRequests("verification_throttled", "wait a minute before asking for another verification email")
+		}
+		today, err := s.verificationRepo.CountSince(ctx, accountID, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if today >= maxVerificationEmails {
+			return TooManyRequests("verification_limit", "too many verification emails today; try again tomorrow")
+		}
+
+		token, expiresAt, err := s.issue(ctx, accountID, now)
+		if err != nil {
+			return err
+		}
+		// Sending inside the transaction means a failed send does not use
+		// up the account's allowance
+		return s.send(ctx, account, token, expiresAt)
+	})
+	if err != nil {
+		return wrapInternal("failed to resend verification email", err)
+	}
+	return nil
+}
+
+// issue signs a new token for the account and stores its hash
+func (s *VerificationService) issue(ctx context.Context, accountID int, now time.Time) (string, time.Time, error) {
+	token, err := utils.SignToken(s.secret, verificationPurpose)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := now.Add(verificationTTL)
+	_, err = s.verificationRepo.Create(ctx, &models.EmailVerification{
+		AccountID: accountID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func (s *VerificationService) send(ctx context.Context, account *models.Account, token string, expiresAt time.Time) error {
+	link := s.verifyURL + "?" + url.Values{"token": {token}}.Encode()
+	return s.mailer.SendEmail(ctx, account.Email, notifications.TemplateData{
+		Name: account.FirstName,
+		Type: notifications.EmailVerifyAddress,
+		Data: map[string]any{
+			"link":       link,
+			"expires_at": expiresAt.UTC().Format("2 Jan 2006 15:04 MST"),
+		},
+	})
+}
+
+// start puts a newly created account into pending verification and issues
+// its first token. It runs inside the registration transaction; the email
+// is sent by sendFirst once that commits.
+func (s *VerificationService) start(ctx context.Context, accountID int) (string, time.Time, error) {
+	if err := s.accountRepo.SetStatus(ctx, accountID, models.AccountStatusPendingVerification); err != nil {
+		return "", time.Time{}, err
+	}
+	return s.issue(ctx, accountID, time.Now())
+}
+
+// sendFirst emails the token issued on registration. A failure is logged
+// rather than failing the registration; the holder can ask for a resend.
+func (s *VerificationService) sendFirst(ctx context.Context, account *models.Account, token string, expiresAt time.Time) {
+	if err := s.send(ctx, account, token, expiresAt); err != nil {
+		log.Printf("failed to send verification email to account %d: %v", account.ID, err)
+	}
+}
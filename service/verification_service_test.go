@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+	"github.com/wizzyszn/go_bank/notifications"
+	"github.com/wizzyszn/go_bank/repository/memory"
+	"github.com/wizzyszn/go_bank/utils"
+)
+
+const testVerifyURL = "https://bank.example/verify-email"
+
+func newTestVerificationService(store *memory.Store) *VerificationService {
+	return NewVerificationService(store, store.Accounts(), store.Verifications(), &notifications.MemoryMailer{}, "test-secret", testVerifyURL)
+}
+
+// verificationToken returns the token from the latest verification email
+// sent to email
+func verificationToken(t *testing.T, svc *VerificationService, email string) string {
+	t.Helper()
+	emails := svc.mailer.(*notifications.MemoryMailer).Emails()
+	for i := len(emails) - 1; i >= 0; i-- {
+		if emails[i].To != email || emails[i].Data.Type != notifications.EmailVerifyAddress {
+			continue
+		}
+		link, err := url.Parse(emails[i].Data.Data["link"].(string))
+		if err != nil {
+			t.Fatalf("failed to parse verification link: %v", err)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("no verification email sent to %s", email)
+	return ""
+}
+
+func errorCode(err error) string {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return ""
+}
+
+func TestEmailVerification(t *testing.T) {
+	auth, store := newTestAuthService(t)
+	svc := auth.verification
+	transactions := NewTransactionService(store, store.Accounts(), store.Transactions(), store.Payees(), store.Fees(), store.Pots(), store.Alerts(), store.KYC(), store.Annotations(), utils.IBANFormat{}, &notifications.MemoryNotifier{})
+	ctx := context.Background()
+
+	account, err := auth.Register(ctx, &models.CreateAccountRequest{
+		Email: "new@example.com", FirstName: "Jane", LastName: "Doe", Password: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	// An unverified account can sign in but not move money
+	if _, err := auth.Login(ctx, models.LoginAccountRequest{Email: "new@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("expected an unverified account to sign in, got %v", err)
+	}
+	_, err = transactions.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 10})
+	if !errors.Is(err, ErrForbidden) || errorCode(err) != "email_unverified" {
+		t.Errorf("expected an unverified deposit to be refused, got %v", err)
+	}
+	if _, err := auth.SetStatus(ctx, account.ID, models.AccountStatusActice); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected an admin not to activate an unverified account, got %v", err)
+	}
+
+	token := verificationToken(t, svc, "new@example.com")
+	otherSecret, err := utils.SignToken("other-secret", verificationPurpose)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	otherPurpose, err := utils.SignToken("test-secret", "password-reset")
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"tampered", token + "x"},
+		{"other secret", otherSecret},
+		{"other purpose", otherPurpose},
+		// Correctly signed but never issued
+		{"unknown", func() string { token, _ := utils.SignToken("test-secret", verificationPurpose); return token }()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.Verify(ctx, tt.token); errorCode(err) != "invalid_token" {
+				t.Errorf("expected an invalid token, got %v", err)
+			}
+		})
+	}
+
+	if err := svc.Verify(ctx, token); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	verified, err := store.Accounts().GetByID(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if verified.Status != models.AccountStatusActice {
+		t.Errorf("expected a verified account to be active, got %s", verified.Status)
+	}
+	if _, err := transactions.Deposit(ctx, account.ID, &models.DepositRequest{Amount: 10}); err != nil {
+		t.Errorf("expected a verified account to deposit, got %v", err)
+	}
+
+	if err := svc.Verify(ctx, token); !errors.Is(err, ErrConflict) || errorCode(err) != "token_used" {
+		t.Errorf("expected a token to verify once, got %v", err)
+	}
+}
+
+func TestExpiredVerificationToken(t *testing.T) {
+	auth, store := newTestAuthService(t)
+	svc := auth.verification
+	ctx := context.Background()
+	account, err := store.Accounts().Create(ctx, "late@example.com", "hash", "Jane", "Doe")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	token, _, err := svc.issue(ctx, account.ID, time.Now().Add(-verificationTTL))
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	if err := svc.Verify(ctx, token); !errors.Is(err, ErrValidation) || errorCode(err) != "token_expired" {
+		t.Errorf("expected an expired token to be refused, got %v", err)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	auth, _ := newTestAuthService(t)
+	svc := auth.verification
+	mailer := svc.mailer.(*notifications.MemoryMailer)
+	ctx := context.Background()
+
+	account, err := auth.Register(ctx, &models.CreateAccountRequest{
+		Email: "resend@example.com", FirstName: "Jane", LastName: "Doe", Password: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if len(mailer.Emails()) != 1 {
+		t.Fatalf("expected a verification email on registration, got %d", len(mailer.Emails()))
+	}
+	email := mailer.Emails()[0]
+	if email.Data.Name != "Jane" || email.Data.Data["expires_at"] == "" {
+		t.Errorf("expected the email to greet the holder and say when it expires, got %+v", email.Data)
+	}
+
+	// The registration email was sent under a minute ago
+	if err := svc.Resend(ctx, account.ID); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("expected an immediate resend to be throttled, got %v", err)
+	}
+	if len(mailer.Emails()) != 1 {
+		t.Errorf("expected a throttled resend to send nothing, got %d emails", len(mailer.Emails()))
+	}
+
+	if err := svc.Verify(ctx, verificationToken(t, svc, "resend@example.com")); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if err := svc.Resend(ctx, account.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a verified account not to get another email, got %v", err)
+	}
+	if err := svc.Resend(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown account to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// camt053Encoder writes an ISO 20022 BankToCustomerStatement (camt.053.001.02)
+type camt053Encoder struct {
+	enc *xml.Encoder
+	s   Statement
+}
+
+func newCAMT053Encoder(w io.Writer) *camt053Encoder {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &camt053Encoder{enc: enc}
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtDateTime struct {
+	DateTime string `xml:"DtTm"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtEntry struct {
+	XMLName        xml.Name     `xml:"Ntry"`
+	Reference      string       `xml:"NtryRef"`
+	Amount         camtAmount   `xml:"Amt"`
+	Indicator      string       `xml:"CdtDbtInd"`
+	Status         string       `xml:"Sts"`
+	BookingDate    camtDateTime `xml:"BookgDt"`
+	ValueDate      camtDateTime `xml:"ValDt"`
+	ServicerRef    string       `xml:"AcctSvcrRef"`
+	BankTxCode     string       `xml:"BkTxCd>Prtry>Cd"`
+	AdditionalInfo string       `xml:"AddtlNtryInf,omitempty"`
+}
+
+func (e *camt053Encoder) Begin(s Statement) error {
+	e.s = s
+	statementID := fmt.Sprintf("STMT-%s-%s-%s", s.AccountNumber, s.From.UTC().Format("20060102"), s.To.UTC().Format("20060102"))
+
+	document := start("Document")
+	document.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace}}
+
+	err := encodeTokens(e.enc,
+		xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)},
+		document,
+		start("BkToCstmrStmt"),
+		start("GrpHdr"),
+	)
+	if err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "MsgId", statementID, "CreDtTm", camtTime(s.GeneratedAt)); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("GrpHdr"), start("Stmt")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "Id", statementID, "CreDtTm", camtTime(s.GeneratedAt)); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, start("FrToDt")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "FrDtTm", camtTime(s.From), "ToDtTm", camtTime(s.To)); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("FrToDt"), start("Acct"), start("Id"), start("Othr")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "Id", s.AccountNumber); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("Othr"), end("Id")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "Ccy", s.Currency); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("Acct")); err != nil {
+		return err
+	}
+
+	// Opening balance is as of the start of the period, closing as of its end
+	if err := e.enc.EncodeElement(e.balance("OPBD", s.OpeningBalance, s.From), start("Bal")); err != nil {
+		return err
+	}
+	return e.enc.EncodeElement(e.balance("CLBD", s.ClosingBalance, s.To.Add(-time.Nanosecond)), start("Bal"))
+}
+
+func (e *camt053Encoder) Encode(entry Entry) error {
+	booked := camtDateTime{DateTime: camtTime(entry.BookedAt)}
+	return e.enc.Encode(camtEntry{
+		Reference:      strconv.Itoa(entry.ID),
+		Amount:         camtAmount{Currency: e.s.Currency, Value: formatAmount(abs(entry.Amount))},
+		Indicator:      creditDebit(entry.Amount),
+		Status:         "BOOK",
+		BookingDate:    booked,
+		ValueDate:      booked,
+		ServicerRef:    strconv.Itoa(entry.ID),
+		BankTxCode:     strings.ToUpper(entry.Type),
+		AdditionalInfo: truncate(entry.Description, 500),
+	})
+}
+
+func (e *camt053Encoder) End() error {
+	if err := encodeTokens(e.enc, end("Stmt"), end("BkToCstmrStmt"), end("Document")); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+func (e *camt053Encoder) balance(code string, amount float64, at time.Time) camtBalance {
+	return camtBalance{
+		Code:      code,
+		Amount:    camtAmount{Currency: e.s.Currency, Value: formatAmount(abs(amount))},
+		Indicator: creditDebit(amount),
+		Date:      at.UTC().Format(time.DateOnly),
+	}
+}
+
+// creditDebit is the ISO 20022 CdtDbtInd for a signed amount. Zero counts as
+// a credit, which is how a zero balance is conventionally reported.
+func creditDebit(amount float64) string {
+	if amount < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,56 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type csvEncoder struct {
+	w        *csv.Writer
+	currency string
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Begin(s Statement) error {
+	e.currency = s.Currency
+	return e.w.Write([]string{"id", "date", "type", "direction", "amount", "balance", "currency", "counterparty_account_number", "description"})
+}
+
+func (e *csvEncoder) Encode(entry Entry) error {
+	direction := "credit"
+	if entry.Amount < 0 {
+		direction = "debit"
+	}
+
+	return e.w.Write([]string{
+		strconv.Itoa(entry.ID),
+		entry.BookedAt.UTC().Format(time.RFC3339),
+		entry.Type,
+		direction,
+		formatAmount(entry.Amount),
+		formatAmount(entry.Balance),
+		e.currency,
+		entry.CounterpartyAccountNumber,
+		escapeFormula(entry.Description),
+	})
+}
+
+func (e *csvEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula stops spreadsheet apps from evaluating user-supplied text
+// as a formula when the file is opened
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
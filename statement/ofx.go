@@ -0,0 +1,150 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/wizzyszn/go_bank/models"
+)
+
+// ofxBankID identifies this bank in BANKACCTFROM
+const ofxBankID = "GOBANK"
+
+// ofxEncoder writes an OFX 2.2 bank statement response
+type ofxEncoder struct {
+	enc *xml.Encoder
+	s   Statement
+}
+
+func newOFXEncoder(w io.Writer) *ofxEncoder {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &ofxEncoder{enc: enc}
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	XMLName xml.Name `xml:"STMTTRN"`
+	TrnType string   `xml:"TRNTYPE"`
+	Posted  string   `xml:"DTPOSTED"`
+	Amount  string   `xml:"TRNAMT"`
+	FITID   string   `xml:"FITID"`
+	Name    string   `xml:"NAME,omitempty"`
+	Memo    string   `xml:"MEMO,omitempty"`
+}
+
+func (e *ofxEncoder) Begin(s Statement) error {
+	e.s = s
+
+	tokens := []xml.Token{
+		xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8" standalone="no"`)},
+		xml.ProcInst{Target: "OFX", Inst: []byte(`OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"`)},
+		start("OFX"),
+		start("SIGNONMSGSRSV1"),
+		start("SONRS"),
+	}
+	if err := encodeTokens(e.enc, tokens...); err != nil {
+		return err
+	}
+	if err := e.enc.EncodeElement(ofxStatus{Code: 0, Severity: "INFO"}, start("STATUS")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "DTSERVER", ofxTime(s.GeneratedAt), "LANGUAGE", "ENG"); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("SONRS"), end("SIGNONMSGSRSV1"), start("BANKMSGSRSV1"), start("STMTTRNRS")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "TRNUID", "0"); err != nil {
+		return err
+	}
+	if err := e.enc.EncodeElement(ofxStatus{Code: 0, Severity: "INFO"}, start("STATUS")); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, start("STMTRS")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "CURDEF", s.Currency); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, start("BANKACCTFROM")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "BANKID", ofxBankID, "ACCTID", s.AccountNumber, "ACCTTYPE", "CHECKING"); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("BANKACCTFROM"), start("BANKTRANLIST")); err != nil {
+		return err
+	}
+	return encodeLeaves(e.enc, "DTSTART", ofxTime(s.From), "DTEND", ofxTime(s.To))
+}
+
+func (e *ofxEncoder) Encode(entry Entry) error {
+	return e.enc.Encode(ofxTransaction{
+		TrnType: ofxTransactionType(entry),
+		Posted:  ofxTime(entry.BookedAt),
+		Amount:  formatAmount(entry.Amount),
+		FITID:   strconv.Itoa(entry.ID),
+		Name:    truncate(counterpartyName(entry), 32),
+		Memo:    truncate(entry.Description, 255),
+	})
+}
+
+func (e *ofxEncoder) End() error {
+	if err := encodeTokens(e.enc, end("BANKTRANLIST"), start("LEDGERBAL")); err != nil {
+		return err
+	}
+	if err := encodeLeaves(e.enc, "BALAMT", formatAmount(e.s.ClosingBalance), "DTASOF", ofxTime(e.s.To)); err != nil {
+		return err
+	}
+	if err := encodeTokens(e.enc, end("LEDGERBAL"), end("STMTRS"), end("STMTTRNRS"), end("BANKMSGSRSV1"), end("OFX")); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+func ofxTransactionType(entry Entry) string {
+	switch entry.Type {
+	case models.TransactionTypeDeposit:
+		return "DEP"
+	case models.TransactionTypeWithdraw:
+		return "CASH"
+	case models.TransactionTypeTransfer:
+		return "XFER"
+	case models.TransactionTypeFee:
+		return "FEE"
+	}
+	if entry.Amount < 0 {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+// ofxTime formats t as YYYYMMDDHHMMSS.XXX[gmt offset:tz name]
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:UTC]"
+}
+
+func counterpartyName(entry Entry) string {
+	if entry.CounterpartyAccountNumber == "" {
+		return ""
+	}
+	if entry.Amount < 0 {
+		return "To account " + entry.CounterpartyAccountNumber
+	}
+	return "From account " + entry.CounterpartyAccountNumber
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
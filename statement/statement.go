@@ -0,0 +1,107 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatOFX     Format = "ofx"
+	FormatCAMT053 Format = "camt053"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatOFX, FormatCAMT053:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: use csv, ofx or camt053", s)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatOFX:
+		return "application/x-ofx"
+	default:
+		return "application/xml"
+	}
+}
+
+func (f Format) Extension() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatOFX:
+		return "ofx"
+	default:
+		return "xml"
+	}
+}
+
+// Statement describes the account and period being exported
+type Statement struct {
+	AccountNumber string
+	Currency      string
+	// From is inclusive, To is exclusive
+	From           time.Time
+	To             time.Time
+	OpeningBalance float64
+	ClosingBalance float64
+	GeneratedAt    time.Time
+}
+
+// Entry is one booked transaction, seen from the exported account
+type Entry struct {
+	// ID is the transaction ID, stable across exports
+	ID       int
+	BookedAt time.Time
+	Type     string
+	// Amount is signed: positive credits the account, negative debits it
+	Amount float64
+	// Balance is the running balance after this entry
+	Balance float64
+	// CounterpartyAccountNumber is empty for deposits, withdrawals and the like
+	CounterpartyAccountNumber string
+	Description               string
+}
+
+// Encoder writes a statement incrementally: Begin once, Encode per entry in
+// booking order, then End.
+type Encoder interface {
+	Begin(s Statement) error
+	Encode(e Entry) error
+	End() error
+}
+
+func NewEncoder(format Format, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w), nil
+	case FormatOFX:
+		return newOFXEncoder(w), nil
+	case FormatCAMT053:
+		return newCAMT053Encoder(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func abs(amount float64) float64 {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
@@ -0,0 +1,124 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleStatement() (Statement, []Entry) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := Statement{
+		AccountNumber:  "0000000042",
+		Currency:       "USD",
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: 100,
+		ClosingBalance: 75.5,
+		GeneratedAt:    from.AddDate(0, 1, 1),
+	}
+	entries := []Entry{
+		{ID: 10, BookedAt: from.Add(time.Hour), Type: "deposit", Amount: 25.5, Balance: 125.5, Description: "Salary"},
+		{ID: 11, BookedAt: from.Add(2 * time.Hour), Type: "transfer", Amount: -50, Balance: 75.5, CounterpartyAccountNumber: "0000000007", Description: "=HYPERLINK(\"x\") & <rent>"},
+	}
+	return s, entries
+}
+
+func encodeAll(t *testing.T, format Format) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewEncoder(format, &buf)
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	s, entries := sampleStatement()
+	if err := enc.Begin(s); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	if err := enc.End(); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(encodeAll(t, FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header + 2 rows, got %d", len(records))
+	}
+
+	debit := records[2]
+	if debit[0] != "11" || debit[3] != "debit" || debit[4] != "-50.00" || debit[5] != "75.50" || debit[7] != "0000000007" {
+		t.Errorf("unexpected debit row: %v", debit)
+	}
+	if !strings.HasPrefix(debit[8], "'=") {
+		t.Errorf("formula in description should be escaped, got %q", debit[8])
+	}
+}
+
+func TestXMLFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   []string
+	}{
+		{FormatOFX, []string{
+			`<?OFX OFXHEADER="200" VERSION="220"`,
+			"<TRNTYPE>XFER</TRNTYPE>",
+			"<TRNAMT>-50.00</TRNAMT>",
+			"<FITID>11</FITID>",
+			"<BALAMT>75.50</BALAMT>",
+		}},
+		{FormatCAMT053, []string{
+			`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">`,
+			"<Cd>OPBD</Cd>",
+			`<Amt Ccy="USD">100.00</Amt>`,
+			"<NtryRef>11</NtryRef>",
+			`<Amt Ccy="USD">50.00</Amt>`,
+			"<CdtDbtInd>DBIT</CdtDbtInd>",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			out := encodeAll(t, tt.format)
+
+			dec := xml.NewDecoder(strings.NewReader(out))
+			for {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("output is not well-formed XML: %v\n%s", err, out)
+				}
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, valid := range []string{"csv", "ofx", "camt053"} {
+		if _, err := ParseFormat(valid); err != nil {
+			t.Errorf("ParseFormat(%q): %v", valid, err)
+		}
+	}
+	if _, err := ParseFormat("qif"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
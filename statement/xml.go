@@ -0,0 +1,30 @@
+package statement
+
+import "encoding/xml"
+
+func start(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+func end(name string) xml.EndElement {
+	return xml.EndElement{Name: xml.Name{Local: name}}
+}
+
+func encodeTokens(enc *xml.Encoder, tokens ...xml.Token) error {
+	for _, token := range tokens {
+		if err := enc.EncodeToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeLeaves writes simple <NAME>value</NAME> elements from name/value pairs
+func encodeLeaves(enc *xml.Encoder, pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if err := enc.EncodeElement(pairs[i+1], start(pairs[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
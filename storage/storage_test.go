@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStorage(dir)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "kyc/1/abc", strings.NewReader("passport scan")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "kyc", "1", "abc"))
+	if err != nil {
+		t.Fatalf("expected the file on disk: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file to be private, got %v", info.Mode().Perm())
+	}
+
+	f, err := s.Open(ctx, "kyc/1/abc")
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "passport scan" {
+		t.Errorf("expected the stored contents back, got %q", data)
+	}
+
+	if err := s.Delete(ctx, "kyc/1/abc"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := s.Open(ctx, "kyc/1/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a deleted file to be not found, got %v", err)
+	}
+	if err := s.Delete(ctx, "kyc/1/abc"); err != nil {
+		t.Errorf("expected deleting a missing file to succeed, got %v", err)
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	ctx := context.Background()
+
+	tests := []string{"", "/etc/passwd", "../outside", "kyc/../../outside", "kyc//1", "kyc/./1", `kyc\1`}
+	for _, key := range tests {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("x")); err == nil {
+				t.Errorf("expected key %q to be refused", key)
+			}
+			if _, err := s.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("expected key %q to be refused on open, got %v", key, err)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestLocalStorageLeavesNothingOnFailedPut(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStorage(dir)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "kyc/1/abc", failingReader{}); err == nil {
+		t.Fatal("expected a failed read to fail the put")
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "kyc", "1"))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files left behind, got %v", entries)
+	}
+}
@@ -0,0 +1,289 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a whole request across services (16 bytes, W3C trace context)
+type TraceID [16]byte
+
+// SpanID identifies a single operation inside a trace (8 bytes)
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header: version-traceid-parentid-flags
+func ParseTraceparent(header string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent: expected 4 fields, got %d", len(parts))
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if len(version) != 2 || version == "ff" {
+		return sc, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	// Version 00 has exactly four fields, later versions may append more
+	if version == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent: version 00 must have 4 fields")
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, fmt.Errorf("invalid traceparent field lengths")
+	}
+	if strings.ToLower(traceID) != traceID || strings.ToLower(spanID) != spanID {
+		return sc, fmt.Errorf("invalid traceparent: ids must be lowercase hex")
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace id: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid parent id: %w", err)
+	}
+
+	var flagByte [1]byte
+	if _, err := hex.Decode(flagByte[:], []byte(flags)); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace flags: %w", err)
+	}
+	sc.Sampled = flagByte[0]&0x01 == 0x01
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: all-zero ids")
+	}
+	return sc, nil
+}
+
+// Span records the timing and attributes of a single operation.
+// A nil *Span is valid and does nothing, so callers never need to check.
+type Span struct {
+	mu         sync.Mutex
+	tracer     *Tracer
+	name       string
+	sc         SpanContext
+	parentID   SpanID
+	start      time.Time
+	end        time.Time
+	attributes map[string]any
+	err        error
+	ended      bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed. Only the first error is kept.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// End finishes the span and hands it to the exporter. Calling End twice is a no-op.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := s.snapshot()
+	s.mu.Unlock()
+
+	s.tracer.export(data)
+}
+
+func (s *Span) snapshot() SpanData {
+	attrs := make(map[string]any, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	data := SpanData{
+		Name:       s.name,
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		StartTime:  s.start,
+		EndTime:    s.end,
+		Duration:   s.end.Sub(s.start),
+		Attributes: attrs,
+		Status:     StatusOK,
+	}
+	if s.parentID.IsValid() {
+		data.ParentSpanID = s.parentID.String()
+	}
+	if s.err != nil {
+		data.Status = StatusError
+		data.Error = s.err.Error()
+	}
+	return data
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the active span, or nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan makes span the active span for ctx
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithRemoteSpanContext stores a span context received from another
+// service (e.g. a traceparent header) so the next span becomes its child
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Tracer creates spans and forwards finished ones to an exporter
+type Tracer struct {
+	exporter Exporter
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start begins a span as a child of the span in ctx (or of a remote parent).
+// Without a parent a new trace is started.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		start:      time.Now(),
+		attributes: make(map[string]any),
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.sc.TraceID = parent.sc.TraceID
+		span.sc.Sampled = parent.sc.Sampled
+		span.parentID = parent.sc.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok && remote.IsValid() {
+		span.sc.TraceID = remote.TraceID
+		span.sc.Sampled = remote.Sampled
+		span.parentID = remote.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+		span.sc.Sampled = true
+	}
+	span.sc.SpanID = newSpanID()
+
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t == nil || t.exporter == nil {
+		return
+	}
+	// Spans are best-effort; a broken exporter must never fail a request
+	_ = t.exporter.ExportSpan(data)
+}
+
+// Shutdown flushes and closes the exporter
+func (t *Tracer) Shutdown() error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown()
+}
+
+var (
+	globalMu     sync.RWMutex
+	globalTracer *Tracer
+)
+
+// SetTracer installs the tracer used by Start. Passing nil disables tracing.
+func SetTracer(t *Tracer) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalTracer = t
+}
+
+func GetTracer() *Tracer {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalTracer
+}
+
+// Start begins a span using the global tracer. When tracing is disabled it
+// returns ctx unchanged and a nil span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return GetTracer().Start(ctx, name)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
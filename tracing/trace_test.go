@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header    string
+		shouldErr bool
+		sampled   bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, true}, // Future version
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, false}, // Extra field on v00
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},       // Forbidden version
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", true, false},       // Zero trace id
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true, false},       // Zero span id
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true, false},       // Uppercase
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", true, false},        // Short trace id
+		{"garbage", true, false},
+		{"", true, false},
+	}
+
+	for _, tt := range tests {
+		sc, err := ParseTraceparent(tt.header)
+		if tt.shouldErr && err == nil {
+			t.Errorf("traceparent %q should fail to parse", tt.header)
+			continue
+		}
+		if !tt.shouldErr && err != nil {
+			t.Errorf("traceparent %q should parse: %v", tt.header, err)
+			continue
+		}
+		if !tt.shouldErr && sc.Sampled != tt.sampled {
+			t.Errorf("traceparent %q: expected sampled=%v", tt.header, tt.sampled)
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if sc.Traceparent() != header {
+		t.Errorf("expected %s, got %s", header, sc.Traceparent())
+	}
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buf))
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+
+	ctx, parent := tracer.Start(ctx, "parent")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("db.statement", "SELECT 1")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+	parent.End() // second End must not export again
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 exported spans, got %d", len(lines))
+	}
+
+	var childData, parentData SpanData
+	if err := json.Unmarshal([]byte(lines[0]), &childData); err != nil {
+		t.Fatalf("failed to decode child span: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &parentData); err != nil {
+		t.Fatalf("failed to decode parent span: %v", err)
+	}
+
+	if parentData.TraceID != remote.TraceID.String() {
+		t.Errorf("parent should join the remote trace, got %s", parentData.TraceID)
+	}
+	if parentData.ParentSpanID != remote.SpanID.String() {
+		t.Errorf("parent should point at the remote span, got %s", parentData.ParentSpanID)
+	}
+	if childData.TraceID != parentData.TraceID {
+		t.Error("child should share the parent's trace id")
+	}
+	if childData.ParentSpanID != parentData.SpanID {
+		t.Error("child should point at the parent span")
+	}
+	if childData.Status != StatusError || childData.Error != "boom" {
+		t.Errorf("expected child to be marked as failed, got %s %q", childData.Status, childData.Error)
+	}
+	if childData.Attributes["db.statement"] != "SELECT 1" {
+		t.Errorf("expected db.statement attribute, got %v", childData.Attributes)
+	}
+}
+
+func TestDisabledTracer(t *testing.T) {
+	SetTracer(nil)
+
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil {
+		t.Error("expected nil span when tracing is disabled")
+	}
+
+	// Nil spans must be safe to use
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+
+	if SpanFromContext(ctx) != nil {
+		t.Error("expected no span in context")
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AccountNumberLength is the length of a generated account number: nine
+// random digits followed by a Luhn check digit
+const AccountNumberLength = 10
+
+// GenerateAccountNumber returns a random account number with a Luhn check
+// digit. The first digit is never zero so numbers keep their length when
+// handled as integers by spreadsheets and the like.
+func GenerateAccountNumber() (string, error) {
+	// 100000000..999999999
+	n, err := rand.Int(rand.Reader, big.NewInt(900_000_000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate account number: %w", err)
+	}
+	payload := fmt.Sprintf("%09d", n.Int64()+100_000_000)
+	return payload + string(luhnCheckDigit(payload)), nil
+}
+
+// NormalizeAccountNumber strips the spaces and hyphens people type or paste
+// into account numbers and IBANs
+func NormalizeAccountNumber(number string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(number)))
+}
+
+// ParseAccountNumber accepts an account number or an IBAN of this bank
+// wrapping one and returns the bare account number
+func ParseAccountNumber(number string, iban IBANFormat) (string, error) {
+	number = NormalizeAccountNumber(number)
+	if err := ValidateAccountNumber(number, iban); err != nil {
+		return "", err
+	}
+	return number[len(number)-AccountNumberLength:], nil
+}
+
+// IBANFormat describes how account numbers are presented as IBANs. The
+// zero value disables IBANs.
+type IBANFormat struct {
+	CountryCode string
+	BankCode    string
+}
+
+// Enabled reports whether IBANs should be shown
+func (f IBANFormat) Enabled() bool {
+	return f.CountryCode != "" && f.BankCode != ""
+}
+
+// Format builds the IBAN for an account number, or "" when disabled
+func (f IBANFormat) Format(accountNumber string) string {
+	if !f.Enabled() || accountNumber == "" {
+		return ""
+	}
+	country := strings.ToUpper(f.CountryCode)
+	bban := strings.ToUpper(f.BankCode) + accountNumber
+	check := 98 - ibanMod97(bban+country+"00")
+	return fmt.Sprintf("%s%02d%s", country, check, bban)
+}
+
+// Matches reports whether a normalized IBAN has this format's country and
+// bank codes in front of an account number
+func (f IBANFormat) Matches(iban string) bool {
+	if !f.Enabled() {
+		return false
+	}
+	prefix := strings.ToUpper(f.CountryCode)
+	bank := strings.ToUpper(f.BankCode)
+	return len(iban) == len(prefix)+2+len(bank)+AccountNumberLength &&
+		strings.HasPrefix(iban, prefix) &&
+		iban[len(prefix)+2:len(prefix)+2+len(bank)] == bank
+}
+
+// luhnCheckDigit returns the digit that makes payload+digit pass the Luhn check
+func luhnCheckDigit(payload string) byte {
+	sum := 0
+	double := true
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := int(payload[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func validLuhn(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return luhnCheckDigit(number[:len(number)-1]) == number[len(number)-1]
+}
+
+// validIBAN checks an IBAN's shape and its ISO 7064 mod-97 check digits
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	for i, r := range iban {
+		isLetter := r >= 'A' && r <= 'Z'
+		isDigit := r >= '0' && r <= '9'
+		if (i < 2 && !isLetter) || (i >= 2 && i < 4 && !isDigit) || (!isLetter && !isDigit) {
+			return false
+		}
+	}
+	return ibanMod97(iban[4:]+iban[:4]) == 1
+}
+
+// ibanMod97 computes s mod 97 with letters expanded to 10..35, one digit
+// at a time so any IBAN length fits in an int
+func ibanMod97(s string) int {
+	rem := 0
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			v := int(r-'A') + 10
+			rem = (rem*100 + v) % 97
+		} else {
+			rem = (rem*10 + int(r-'0')) % 97
+		}
+	}
+	return rem
+}
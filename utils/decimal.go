@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// ParseDecimal reads a decimal string such as "2.5" or "-10.25" exactly
+func ParseDecimal(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	return r, nil
+}
+
+// DecimalFromAmount converts a money amount held as a float64 to its exact
+// value in cents, as stored in DECIMAL(15, 2) columns
+func DecimalFromAmount(amount float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', 2, 64))
+	return r
+}
+
+// FormatDecimal rounds r half away from zero to scale decimal places
+func FormatDecimal(r *big.Rat, scale int) string {
+	return r.FloatString(scale)
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "strings"
+
+// MaskName shows only the first letter of each part of a name, e.g.
+// "Jane Doe" becomes "J*** D***", so a payer can recognise a recipient
+// without the full name being disclosed
+func MaskName(firstName, lastName string) string {
+	parts := strings.Fields(firstName + " " + lastName)
+	for i, part := range parts {
+		runes := []rune(part)
+		parts[i] = string(runes[0]) + "***"
+	}
+	return strings.Join(parts, " ")
+}
+
+// NormalizeName lowercases a name and collapses its whitespace for comparison
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
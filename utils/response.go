@@ -32,14 +32,76 @@ func WriteCreated(w http.ResponseWriter, data any) error {
 	return WriteJSON(w, http.StatusCreated, response)
 }
 
+// WriteAccepted reports work that was queued and will finish later
+func WriteAccepted(w http.ResponseWriter, data any) error {
+	response := models.ApiResponse{
+		Success: true,
+		Data:    data,
+		Message: "Request accepted for processing",
+	}
+
+	return WriteJSON(w, http.StatusAccepted, response)
+}
+
 func WriteError(w http.ResponseWriter, status int, message string) error {
+	return WriteErrorCode(w, status, DefaultErrorCode(status), message)
+}
+
+// WriteErrorCode writes an error with a machine-readable code clients can switch on
+func WriteErrorCode(w http.ResponseWriter, status int, code, message string) error {
 	response := models.ApiResponse{
 		Error:   message,
+		Code:    code,
 		Success: false,
 	}
 	return WriteJSON(w, status, response)
 }
 
+// WriteProblem writes an RFC 7807 problem document
+func WriteProblem(w http.ResponseWriter, problem models.ProblemDetails) error {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// DefaultErrorCode derives an error code from an HTTP status for responses
+// that have no more specific code
+func DefaultErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable_entity"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "service_unavailable"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "internal_error"
+		}
+		return "error"
+	}
+}
+
 func WriteBadRequest(w http.ResponseWriter, message string) error {
 
 	return WriteError(w, http.StatusBadRequest, message)
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// SignToken returns a random token signed with secret for purpose, in the
+// form nonce.signature. The purpose stops a token made for one use from
+// being accepted for another.
+func SignToken(secret, purpose string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token %w", err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	return nonce + "." + tokenSignature(secret, purpose, nonce), nil
+}
+
+// VerifyTokenSignature reports whether token was made by SignToken with the
+// same secret and purpose
+func VerifyTokenSignature(secret, purpose, token string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(tokenSignature(secret, purpose, nonce)))
+}
+
+// HashToken returns the hex SHA-256 of a token, so tokens can be looked up
+// without being stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenSignature(secret, purpose, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -8,8 +8,6 @@ import (
 	"testing"
 
 	"github.com/wizzyszn/go_bank/models"
-
-	
 )
 
 // Test Password Utilities
@@ -278,3 +276,69 @@ func TestSanitizeString(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateAccountNumber(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		number, err := GenerateAccountNumber()
+		if err != nil {
+			t.Fatalf("GenerateAccountNumber() error = %v", err)
+		}
+		if len(number) != AccountNumberLength || number[0] == '0' {
+			t.Fatalf("GenerateAccountNumber() = %q, want %d digits without a leading zero", number, AccountNumberLength)
+		}
+		if err := ValidateAccountNumber(number, IBANFormat{}); err != nil {
+			t.Fatalf("generated number %q fails validation: %v", number, err)
+		}
+	}
+}
+
+func TestParseAccountNumber(t *testing.T) {
+	format := IBANFormat{CountryCode: "GB", BankCode: "GOBK"}
+	iban := format.Format("7992739875")
+
+	tests := []struct {
+		input   string
+		format  IBANFormat
+		want    string
+		wantErr bool
+	}{
+		{"7992739875", format, "7992739875", false},
+		{" 7992-7398 75 ", format, "7992739875", false},
+		{"7992739875", IBANFormat{}, "7992739875", false},
+		{"7992739871", format, "", true},
+		{"79927398", format, "", true},
+		{iban, format, "7992739875", false},
+		{iban, IBANFormat{}, "", true},               // IBANs disabled
+		{"GB82WEST12345698765432", format, "", true}, // valid IBAN, but no valid account number inside
+		{"GB00GOBK7992739875", format, "", true},
+		{IBANFormat{CountryCode: "GB", BankCode: "OTHR"}.Format("7992739875"), format, "", true}, // another bank
+		{IBANFormat{CountryCode: "DE", BankCode: "GOBK"}.Format("7992739875"), format, "", true}, // another country
+		{IBANFormat{CountryCode: "GB", BankCode: "GOBANK"}.Format("7992739875"), format, "", true},
+		{"", format, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAccountNumber(tt.input, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAccountNumber(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAccountNumber(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIBANFormat(t *testing.T) {
+	if got := (IBANFormat{}).Format("7992739875"); got != "" {
+		t.Errorf("disabled format = %q, want empty", got)
+	}
+	// Known-good check digits for this BBAN
+	if !validIBAN("GB82WEST12345698765432") {
+		t.Error("GB82WEST12345698765432 should be a valid IBAN")
+	}
+	iban := IBANFormat{CountryCode: "gb", BankCode: "gobk"}.Format("7992739875")
+	if len(iban) != 18 || iban[:2] != "GB" || !validIBAN(iban) {
+		t.Errorf("Format() = %q, want a valid GB IBAN", iban)
+	}
+}
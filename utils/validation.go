@@ -2,9 +2,13 @@ package utils
 
 import (
 	"fmt"
+	"math/big"
 	"net/mail"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/wizzyszn/go_bank/models"
 )
 
 // ValidationError represents a validation error
@@ -89,6 +93,107 @@ func isValidMoneyFormat(amount float64) bool {
 	return cents == float64(int(cents))
 }
 
+// ValidateOverdraftLimit checks an approved overdraft; 0 means none
+func ValidateOverdraftLimit(limit float64) error {
+	if limit < 0 {
+		return &ValidationError{Field: "overdraft_limit", Message: "overdraft_limit cannot be negative"}
+	}
+	if limit > 1000000 {
+		return &ValidationError{Field: "overdraft_limit", Message: "overdraft_limit exceeds maximum allowed"}
+	}
+	if !isValidMoneyFormat(limit) {
+		return &ValidationError{Field: "overdraft_limit", Message: "overdraft_limit can have at most 2 decimal places"}
+	}
+	return nil
+}
+
+// ValidateTargetAmount checks a pot's savings target
+func ValidateTargetAmount(amount float64) error {
+	if amount <= 0 {
+		return &ValidationError{Field: "target_amount", Message: "target_amount must be greater than 0"}
+	}
+	if amount > 1000000000 {
+		return &ValidationError{Field: "target_amount", Message: "target_amount exceeds maximum allowed"}
+	}
+	if !isValidMoneyFormat(amount) {
+		return &ValidationError{Field: "target_amount", Message: "target_amount can have at most 2 decimal places"}
+	}
+	return nil
+}
+
+// ValidateMonthlyLimit checks a budget's monthly spending limit
+func ValidateMonthlyLimit(limit float64) error {
+	if limit <= 0 {
+		return &ValidationError{Field: "monthly_limit", Message: "monthly_limit must be greater than 0"}
+	}
+	if limit > 1000000000 {
+		return &ValidationError{Field: "monthly_limit", Message: "monthly_limit exceeds maximum allowed"}
+	}
+	if !isValidMoneyFormat(limit) {
+		return &ValidationError{Field: "monthly_limit", Message: "monthly_limit can have at most 2 decimal places"}
+	}
+	return nil
+}
+
+// ValidateCategory checks that category is one of models.Categories
+func ValidateCategory(category string) error {
+	if !slices.Contains(models.Categories, category) {
+		return &ValidationError{Field: "category", Message: "unknown category"}
+	}
+	return nil
+}
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// NormalizeTag trims a tag and lowercases it, so "Travel" and "travel" are
+// the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ValidateTag checks a normalized tag: 1-32 lowercase letters, digits,
+// hyphens and underscores, starting with a letter or digit
+func ValidateTag(tag, fieldName string) error {
+	if !tagPattern.MatchString(tag) {
+		return &ValidationError{Field: fieldName, Message: fmt.Sprintf("%s must be 1-32 letters, digits, hyphens or underscores", fieldName)}
+	}
+	return nil
+}
+
+// ValidatePhone checks a phone number in E.164 form, e.g. +447700900123
+func ValidatePhone(phone string) error {
+	if !phonePattern.MatchString(phone) {
+		return &ValidationError{Field: "phone", Message: "phone must be in international format, e.g. +447700900123"}
+	}
+	return nil
+}
+
+var phonePattern = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// ValidateCountryCode checks an ISO 3166 alpha-2 country code, e.g. GB
+func ValidateCountryCode(code, fieldName string) error {
+	if !countryCodePattern.MatchString(code) {
+		return &ValidationError{Field: fieldName, Message: fmt.Sprintf("%s must be a two-letter country code, e.g. GB", fieldName)}
+	}
+	return nil
+}
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ValidateAlertThreshold checks the amount an alert rule fires at
+func ValidateAlertThreshold(threshold float64) error {
+	if threshold <= 0 {
+		return &ValidationError{Field: "threshold", Message: "threshold must be greater than 0"}
+	}
+	if threshold > 1000000000 {
+		return &ValidationError{Field: "threshold", Message: "threshold exceeds maximum allowed"}
+	}
+	if !isValidMoneyFormat(threshold) {
+		return &ValidationError{Field: "threshold", Message: "threshold can have at most 2 decimal places"}
+	}
+	return nil
+}
+
 // ValidateAccountID checks if an account ID is valid
 func ValidateAccountID(id int) error {
 	if id <= 0 {
@@ -97,6 +202,145 @@ func ValidateAccountID(id int) error {
 	return nil
 }
 
+// ValidateAccountNumber checks a normalized account number's Luhn check
+// digit, or an IBAN's mod-97 check digits and the account number inside it.
+// Only IBANs in this bank's format are accepted, so none are when iban is
+// disabled.
+func ValidateAccountNumber(number string, iban IBANFormat) error {
+	if number == "" {
+		return &ValidationError{Field: "account_number", Message: "account number is required"}
+	}
+	if len(number) == AccountNumberLength {
+		if !validLuhn(number) {
+			return &ValidationError{Field: "account_number", Message: "invalid account number"}
+		}
+		return nil
+	}
+	if !iban.Enabled() {
+		return &ValidationError{Field: "account_number", Message: "invalid account number"}
+	}
+	if !validIBAN(number) || !validLuhn(number[len(number)-AccountNumberLength:]) {
+		return &ValidationError{Field: "account_number", Message: "invalid account number or IBAN"}
+	}
+	if !iban.Matches(number) {
+		return &ValidationError{Field: "account_number", Message: "IBAN is not for an account at this bank"}
+	}
+	return nil
+}
+
+// ValidateInterestRate checks an annual percentage rate given as a decimal
+// string: between 0 and 100 with at most six decimal places
+func ValidateInterestRate(rate string) error {
+	if !interestRatePattern.MatchString(rate) {
+		return &ValidationError{Field: "annual_rate", Message: "annual_rate must be a decimal with at most 6 decimal places"}
+	}
+	r, err := ParseDecimal(rate)
+	if err != nil || r.Cmp(big.NewRat(100, 1)) > 0 {
+		return &ValidationError{Field: "annual_rate", Message: "annual_rate must be between 0 and 100"}
+	}
+	return nil
+}
+
+var interestRatePattern = regexp.MustCompile(`^\d{1,3}(\.\d{1,6})?$`)
+
+// ValidateFeeRule checks a fee schedule entry. Money values are decimal
+// strings with at most 2 places and percentages follow ValidateInterestRate.
+// Tiers must rise strictly and the last one must be open-ended, so every
+// amount falls in exactly one tier.
+func ValidateFeeRule(req *models.CreateFeeRuleRequest) error {
+	switch req.TransactionType {
+	case models.TransactionTypeWithdraw, models.TransactionTypeTransfer, models.FeeTypeMaintenance:
+	default:
+		return &ValidationError{Field: "transaction_type", Message: "transaction_type must be one of withdraw, transfer, maintenance"}
+	}
+
+	switch req.Kind {
+	case models.FeeKindFlat:
+		if err := validateFeeAmount(req.FlatAmount, "flat_amount", true); err != nil {
+			return err
+		}
+	case models.FeeKindPercentage:
+		if err := validateFeePercentage(req.Percentage, "percentage", true); err != nil {
+			return err
+		}
+	case models.FeeKindTiered:
+		if len(req.Tiers) == 0 {
+			return &ValidationError{Field: "tiers", Message: "tiers are required for a tiered fee"}
+		}
+		var previous *big.Rat
+		for i, tier := range req.Tiers {
+			field := fmt.Sprintf("tiers[%d]", i)
+			if err := validateFeeAmount(tier.FlatAmount, field+".flat_amount", false); err != nil {
+				return err
+			}
+			if err := validateFeePercentage(tier.Percentage, field+".percentage", false); err != nil {
+				return err
+			}
+			last := i == len(req.Tiers)-1
+			if tier.UpTo == nil {
+				if !last {
+					return &ValidationError{Field: field + ".up_to", Message: "only the last tier can be open-ended"}
+				}
+				continue
+			}
+			if last {
+				return &ValidationError{Field: field + ".up_to", Message: "the last tier must be open-ended"}
+			}
+			if err := validateFeeAmount(*tier.UpTo, field+".up_to", true); err != nil {
+				return err
+			}
+			upTo, _ := ParseDecimal(*tier.UpTo)
+			if previous != nil && upTo.Cmp(previous) <= 0 {
+				return &ValidationError{Field: field + ".up_to", Message: "tiers must be in increasing order of up_to"}
+			}
+			previous = upTo
+		}
+	default:
+		return &ValidationError{Field: "kind", Message: "kind must be one of flat, percentage, tiered"}
+	}
+
+	if req.MinFee != nil {
+		if err := validateFeeAmount(*req.MinFee, "min_fee", true); err != nil {
+			return err
+		}
+	}
+	if req.MaxFee != nil {
+		if err := validateFeeAmount(*req.MaxFee, "max_fee", true); err != nil {
+			return err
+		}
+	}
+	if req.MinFee != nil && req.MaxFee != nil {
+		minFee, _ := ParseDecimal(*req.MinFee)
+		maxFee, _ := ParseDecimal(*req.MaxFee)
+		if minFee.Cmp(maxFee) > 0 {
+			return &ValidationError{Field: "min_fee", Message: "min_fee cannot exceed max_fee"}
+		}
+	}
+	return nil
+}
+
+var feeAmountPattern = regexp.MustCompile(`^\d{1,13}(\.\d{1,2})?$`)
+
+func validateFeeAmount(value, field string, required bool) error {
+	if value == "" && !required {
+		return nil
+	}
+	if !feeAmountPattern.MatchString(value) {
+		return &ValidationError{Field: field, Message: field + " must be a non-negative amount with at most 2 decimal places"}
+	}
+	return nil
+}
+
+func validateFeePercentage(value, field string, required bool) error {
+	if value == "" && !required {
+		return nil
+	}
+	if err := ValidateInterestRate(value); err != nil {
+		return &ValidationError{Field: field, Message: field + " must be between 0 and 100 with at most 6 decimal places"}
+	}
+	return nil
+}
+
 // ValidatePositiveInt checks if an integer is positive
 func ValidatePositiveInt(value int, fieldName string) error {
 	if value <= 0 {
@@ -122,6 +366,47 @@ func ValidatePagination(page, limit int) error {
 	return nil
 }
 
+// ValidateTransactionFilter checks the filters on a transaction history query
+func ValidateTransactionFilter(filter models.TransactionFilter) error {
+	switch filter.Type {
+	case "", models.TransactionTypeDeposit, models.TransactionTypeWithdraw, models.TransactionTypeTransfer, models.TransactionTypeInterest, models.TransactionTypeFee, models.TransactionTypePot:
+	default:
+		return &ValidationError{Field: "type", Message: "type must be one of deposit, withdraw, transfer, interest, fee, pot"}
+	}
+
+	switch filter.Status {
+	case "", models.TransactionStatusPending, models.TransactionStatusCompleted, models.TransactionStatusFailed:
+	default:
+		return &ValidationError{Field: "status", Message: "status must be one of pending, completed, failed"}
+	}
+
+	switch filter.Direction {
+	case "", models.TransactionDirectionIncoming, models.TransactionDirectionOutgoing:
+	default:
+		return &ValidationError{Field: "direction", Message: "direction must be incoming or outgoing"}
+	}
+
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return &ValidationError{Field: "min_amount", Message: "min_amount cannot exceed max_amount"}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return &ValidationError{Field: "from", Message: "from must be before to"}
+	}
+
+	if len(filter.Search) > 100 {
+		return &ValidationError{Field: "q", Message: "search text cannot exceed 100 characters"}
+	}
+
+	if filter.Tag != "" {
+		if err := ValidateTag(filter.Tag, "tag"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ValidateRequired checks if a string field is not empty
 func ValidateRequired(value, fieldName string) error {
 	if strings.TrimSpace(value) == "" {